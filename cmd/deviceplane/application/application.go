@@ -0,0 +1,35 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+func applicationDeployAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	latest, err := config.APIClient.GetLatestRelease(ctx, *config.Flags.Project, *applicationArg)
+	if err != nil {
+		return err
+	}
+
+	service, rawConfig, err := setServiceImage(latest.RawConfig, *deployServiceFlag, *imageArg)
+	if err != nil {
+		return err
+	}
+
+	release, err := config.APIClient.CreateRelease(ctx, *config.Flags.Project, *applicationArg, rawConfig)
+	if err != nil {
+		return err
+	}
+
+	if *applicationOutputFlag == cliutils.FormatTable {
+		fmt.Printf("Release %d created, deploying %s to service %s\n", release.Number, *imageArg, service)
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(release, *applicationOutputFlag)
+}
@@ -0,0 +1,43 @@
+package application
+
+import (
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	applicationArg *string = &[]string{""}[0]
+	imageArg       *string = &[]string{""}[0]
+
+	deployServiceFlag *string = &[]string{""}[0]
+
+	applicationOutputFlag *string = &[]string{""}[0]
+
+	config *global.Config
+)
+
+func Initialize(c *global.Config) {
+	config = c
+
+	applicationCmd := c.App.Command("application", "Manage applications.")
+	cliutils.RequireProject(config, applicationCmd)
+
+	applicationDeployCmd := applicationCmd.Command("deploy", `Release a new image for a service, keeping the rest of the latest release. e.g. "deploy my-app nginx:1.19"`)
+	addApplicationArg(applicationDeployCmd)
+	applicationDeployCmd.Arg("image", "Image reference to deploy.").Required().StringVar(imageArg)
+	applicationDeployCmd.Flag("service", "Service to update. Required if the application has more than one service.").Short('s').StringVar(deployServiceFlag)
+	cliutils.AddFormatFlag(applicationOutputFlag, applicationDeployCmd,
+		cliutils.FormatTable,
+		cliutils.FormatYAML,
+		cliutils.FormatJSON,
+	)
+	applicationDeployCmd.Action(applicationDeployAction)
+}
+
+func addApplicationArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
+	arg := cmd.Arg("application", "Application name.").Required()
+	arg.StringVar(applicationArg)
+	arg.PreAction(cliutils.RequireValidNames(config, "application", applicationArg))
+	return arg
+}
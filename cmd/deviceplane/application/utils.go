@@ -0,0 +1,71 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// setServiceImage replaces the image of service in rawConfig, returning the
+// name of the service that was changed and the updated config. If service
+// is empty the config must have exactly one service. Everything else in the config is
+// kept as written, apart from comments.
+func setServiceImage(rawConfig, service, image string) (string, string, error) {
+	if image == "" || strings.ContainsAny(image, " \t\n") {
+		return "", "", fmt.Errorf("invalid image reference (%s)", image)
+	}
+
+	var services yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(rawConfig), &services); err != nil {
+		return "", "", errors.Wrap(err, "parse latest release")
+	}
+
+	if service == "" {
+		switch len(services) {
+		case 0:
+			return "", "", errors.New("latest release has no services")
+		case 1:
+			service = fmt.Sprint(services[0].Key)
+		default:
+			names := make([]string, len(services))
+			for i, s := range services {
+				names[i] = fmt.Sprint(s.Key)
+			}
+			sort.Strings(names)
+			return "", "", fmt.Errorf("application has multiple services (%s), choose one with --service", strings.Join(names, ", "))
+		}
+	}
+
+	for i, s := range services {
+		if fmt.Sprint(s.Key) != service {
+			continue
+		}
+
+		spec, ok := s.Value.(yaml.MapSlice)
+		if !ok {
+			return "", "", fmt.Errorf("service %s in latest release is not a map", service)
+		}
+		services[i].Value = setKey(spec, "image", image)
+
+		updated, err := yaml.Marshal(services)
+		if err != nil {
+			return "", "", err
+		}
+		return service, string(updated), nil
+	}
+
+	return "", "", fmt.Errorf("service %s not found in latest release", service)
+}
+
+func setKey(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
@@ -0,0 +1,29 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetServiceImage(t *testing.T) {
+	single := "web:\n  image: nginx:1.18\n  ports:\n  - 80:80\n"
+
+	service, updated, err := setServiceImage(single, "", "nginx:1.19")
+	require.NoError(t, err)
+	require.Equal(t, "web", service)
+	require.Equal(t, "web:\n  image: nginx:1.19\n  ports:\n  - 80:80\n", updated)
+
+	multiple := "web:\n  image: nginx:1.18\nworker:\n  image: worker:1\n"
+
+	_, _, err = setServiceImage(multiple, "", "nginx:1.19")
+	require.EqualError(t, err, "application has multiple services (web, worker), choose one with --service")
+
+	service, updated, err = setServiceImage(multiple, "worker", "worker:2")
+	require.NoError(t, err)
+	require.Equal(t, "worker", service)
+	require.Equal(t, "web:\n  image: nginx:1.18\nworker:\n  image: worker:2\n", updated)
+
+	_, _, err = setServiceImage(multiple, "db", "postgres:12")
+	require.EqualError(t, err, "service db not found in latest release")
+}
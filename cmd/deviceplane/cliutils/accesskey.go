@@ -0,0 +1,94 @@
+package cliutils
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	"github.com/pkg/errors"
+)
+
+// StdinAccessKey is passed as --access-key to read the key from stdin
+const StdinAccessKey = "-"
+
+// ResolveAccessKey replaces the access key with the contents of
+// --access-key-file, or with stdin if --access-key is "-", so that the key
+// doesn't have to appear in shell history or process listings. It returns
+// whether the key was read from either source.
+func ResolveAccessKey(config *global.Config) (bool, error) {
+	accessKeyFile := *config.Flags.AccessKeyFile
+	accessKey := *config.Flags.AccessKey
+
+	switch {
+	case accessKeyFile != "" && accessKey != "":
+		return false, errors.New("only one of --access-key and --access-key-file can be used")
+	case accessKeyFile != "":
+		key, err := readAccessKeyFile(config, accessKeyFile)
+		if err != nil {
+			return false, err
+		}
+		*config.Flags.AccessKey = key
+		return true, nil
+	case accessKey == StdinAccessKey:
+		key, err := readAccessKey(os.Stdin)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to read access key from stdin")
+		}
+		*config.Flags.AccessKey = key
+		return true, nil
+	}
+	return false, nil
+}
+
+func readAccessKeyFile(config *global.Config, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open access key file")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to stat access key file")
+	}
+	if info.Mode().Perm()&0004 != 0 {
+		if err := config.Logger.Warnf("access key file %s is readable by all users, consider running chmod 600 on it", path); err != nil {
+			return "", err
+		}
+	}
+
+	key, err := readAccessKey(f)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read access key file")
+	}
+	return key, nil
+}
+
+func readAccessKey(r io.Reader) (string, error) {
+	keyBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(keyBytes))
+	if key == "" {
+		return "", errors.New("access key is empty")
+	}
+	return key, nil
+}
+
+// JoinStdinAccessKey rewrites "--access-key -" as "--access-key=-", since
+// kingpin would otherwise parse the lone "-" as a flag
+func JoinStdinAccessKey(args []string) []string {
+	joined := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--access-key" && i+1 < len(args) && args[i+1] == StdinAccessKey {
+			joined = append(joined, "--access-key="+StdinAccessKey)
+			i++
+			continue
+		}
+		joined = append(joined, args[i])
+	}
+	return joined
+}
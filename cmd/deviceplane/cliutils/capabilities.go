@@ -0,0 +1,136 @@
+package cliutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	"github.com/deviceplane/cli/pkg/file"
+	"github.com/deviceplane/cli/pkg/models"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	capabilitiesCacheFilename = "capabilities-cache"
+	capabilitiesCacheTTL      = 24 * time.Hour
+	// capabilitiesUnreachableTTL is how long an API that couldn't be
+	// reached isn't asked again, so that commands run while it's down don't
+	// each wait for capabilitiesTimeout
+	capabilitiesUnreachableTTL = time.Minute
+	capabilitiesTimeout        = 3 * time.Second
+)
+
+type capabilitiesCache struct {
+	APIEndpoint  string                 `json:"apiEndpoint"`
+	FetchedAt    time.Time              `json:"fetchedAt"`
+	Unreachable  bool                   `json:"unreachable,omitempty"`
+	Capabilities models.APICapabilities `json:"capabilities"`
+}
+
+func (c capabilitiesCache) fresh(apiEndpoint string) bool {
+	ttl := capabilitiesCacheTTL
+	if c.Unreachable {
+		ttl = capabilitiesUnreachableTTL
+	}
+	return c.APIEndpoint == apiEndpoint && time.Since(c.FetchedAt) < ttl
+}
+
+// loadAPICapabilities returns the capabilities of the configured API,
+// preferring a recent cached copy stored next to the config file. They're
+// nil if the API couldn't be reached. An error is only returned if a warning
+// is promoted by --strict.
+func loadAPICapabilities(config *global.Config) (*models.APICapabilities, error) {
+	apiEndpoint := (*config.Flags.APIEndpoint).String()
+
+	if cacheBytes, err := ioutil.ReadFile(capabilitiesCacheFile(config)); err == nil {
+		var cache capabilitiesCache
+		if err := json.Unmarshal(cacheBytes, &cache); err == nil && cache.fresh(apiEndpoint) {
+			if cache.Unreachable {
+				return nil, nil
+			}
+			return &cache.Capabilities, checkAPIVersion(config, apiEndpoint, cache.Capabilities)
+		}
+	}
+
+	capabilities := fetchAPICapabilities(config)
+	if capabilities == nil {
+		return nil, nil
+	}
+	return capabilities, checkAPIVersion(config, apiEndpoint, *capabilities)
+}
+
+func capabilitiesCacheFile(config *global.Config) string {
+	return filepath.Join(filepath.Dir(*config.Flags.ConfigFile), capabilitiesCacheFilename)
+}
+
+// fetchAPICapabilities asks the API for its capabilities and caches the
+// answer. They're nil if the API couldn't be reached.
+func fetchAPICapabilities(config *global.Config) *models.APICapabilities {
+	apiEndpoint := (*config.Flags.APIEndpoint).String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), capabilitiesTimeout)
+	defer cancel()
+
+	cache := capabilitiesCache{
+		APIEndpoint: apiEndpoint,
+		FetchedAt:   time.Now(),
+	}
+	capabilities, err := config.APIClient.GetCapabilities(ctx)
+	if err != nil {
+		cache.Unreachable = true
+	} else {
+		cache.Capabilities = *capabilities
+	}
+
+	cacheBytes, err := json.Marshal(cache)
+	if err == nil {
+		file.WriteFileAtomic(capabilitiesCacheFile(config), cacheBytes, 0600)
+	}
+
+	if cache.Unreachable {
+		return nil
+	}
+	return capabilities
+}
+
+// checkAPIVersion warns if the API isn't the version this CLI expects
+func checkAPIVersion(config *global.Config, apiEndpoint string, capabilities models.APICapabilities) error {
+	if capabilities.APIVersion == "" || capabilities.APIVersion == models.APIVersion {
+		return nil
+	}
+	return config.Logger.Warnf("the API at %s is version %s, but this CLI expects version %s. Some commands may not work as expected.",
+		apiEndpoint, capabilities.APIVersion, models.APIVersion)
+}
+
+// RequireCapability fails a command early if the API is known not to
+// support it. If the API's capabilities are unknown, the command is allowed
+// to run.
+func RequireCapability(config *global.Config, capability models.Capability, cmd *kingpin.CmdClause) *kingpin.CmdClause {
+	return cmd.PreAction(func(c *kingpin.ParseContext) error {
+		if c.Error() || !*config.ParsedCorrectly {
+			return nil
+		}
+		return CheckCapability(config, capability)
+	})
+}
+
+// CheckCapability is RequireCapability for commands whose capability
+// depends on how they're invoked. Capabilities may be cached from before the
+// server was upgraded, so they're fetched again before the command is
+// refused.
+func CheckCapability(config *global.Config, capability models.Capability) error {
+	if config.APICapabilities == nil || config.APICapabilities.Has(capability) {
+		return nil
+	}
+
+	config.APICapabilities = fetchAPICapabilities(config)
+	if config.APICapabilities == nil || config.APICapabilities.Has(capability) {
+		return nil
+	}
+	return fmt.Errorf("the API at %s does not support this command (missing capability %q), please upgrade the server",
+		(*config.Flags.APIEndpoint).String(), capability)
+}
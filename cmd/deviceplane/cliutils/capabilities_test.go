@@ -0,0 +1,78 @@
+package cliutils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	"github.com/deviceplane/cli/pkg/client"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilitiesCacheFresh(t *testing.T) {
+	const apiEndpoint = "https://api.example.com/api"
+
+	cache := capabilitiesCache{
+		APIEndpoint: apiEndpoint,
+		FetchedAt:   time.Now().Add(-time.Hour),
+	}
+	require.True(t, cache.fresh(apiEndpoint))
+	require.False(t, cache.fresh("https://other.example.com/api"))
+
+	// An unreachable API is asked again much sooner than capabilities expire
+	cache.Unreachable = true
+	require.False(t, cache.fresh(apiEndpoint))
+	cache.FetchedAt = time.Now()
+	require.True(t, cache.fresh(apiEndpoint))
+
+	cache.Unreachable = false
+	cache.FetchedAt = time.Now().Add(-capabilitiesCacheTTL)
+	require.False(t, cache.fresh(apiEndpoint))
+}
+
+func TestCheckCapabilityRefetchesStaleCapabilities(t *testing.T) {
+	supported := []models.Capability{models.CapabilityServiceLogs}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.APICapabilities{
+			Capabilities: supported,
+		})
+	}))
+	defer server.Close()
+
+	apiEndpoint, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	configFile := filepath.Join(t.TempDir(), "config")
+	config := &global.Config{
+		Flags: global.ConfigFlags{
+			APIEndpoint: &apiEndpoint,
+			ConfigFile:  &configFile,
+		},
+		APIClient: client.NewClient(apiEndpoint, "", nil),
+	}
+
+	capabilities, err := loadAPICapabilities(config)
+	require.NoError(t, err)
+	config.APICapabilities = capabilities
+	require.NoError(t, CheckCapability(config, models.CapabilityServiceLogs))
+	require.Error(t, CheckCapability(config, models.CapabilityServiceExec))
+
+	// The server is upgraded while the old capabilities are still cached
+	supported = append(supported, models.CapabilityServiceExec)
+	capabilities, err = loadAPICapabilities(config)
+	require.NoError(t, err)
+	config.APICapabilities = capabilities
+	require.False(t, config.APICapabilities.Has(models.CapabilityServiceExec))
+	require.NoError(t, CheckCapability(config, models.CapabilityServiceExec))
+	require.True(t, config.APICapabilities.Has(models.CapabilityServiceExec))
+
+	// The cache was updated too
+	capabilities, err = loadAPICapabilities(config)
+	require.NoError(t, err)
+	require.True(t, capabilities.Has(models.CapabilityServiceExec))
+}
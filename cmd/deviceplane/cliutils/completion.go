@@ -0,0 +1,74 @@
+package cliutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	ShellBash = "bash"
+	ShellZsh  = "zsh"
+	ShellFish = "fish"
+)
+
+// All scripts defer to kingpin's hidden --completion-bash flag, which prints
+// the options for the words typed so far, including dynamic hints
+var completionScripts = map[string]string{
+	ShellBash: `_{{.Name}}_bash_autocomplete() {
+    local cur opts
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    opts=$( ${COMP_WORDS[0]} --completion-bash ${COMP_WORDS[@]:1:$COMP_CWORD} 2>/dev/null )
+    COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+    return 0
+}
+complete -F _{{.Name}}_bash_autocomplete {{.Name}}
+`,
+	ShellZsh: `#compdef {{.Name}}
+autoload -U compinit && compinit
+autoload -U bashcompinit && bashcompinit
+
+_{{.Name}}_bash_autocomplete() {
+    local cur opts
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    opts=$( ${COMP_WORDS[0]} --completion-bash ${COMP_WORDS[@]:1:$COMP_CWORD} 2>/dev/null )
+    COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+    return 0
+}
+complete -F _{{.Name}}_bash_autocomplete {{.Name}}
+`,
+	ShellFish: `function __{{.Name}}_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    {{.Name}} --completion-bash $args 2>/dev/null
+end
+complete -c {{.Name}} -f -a '(__{{.Name}}_complete)'
+`,
+}
+
+func AddCompletionCmd(config *global.Config) {
+	app := config.App
+	shell := &[]string{""}[0]
+
+	cmd := WithoutAPIClient(config, app.Command("completion", "Generate a shell completion script.").Hidden())
+	cmd.Arg("shell", "Shell to generate the script for. (bash, zsh, fish)").Required().EnumVar(shell, ShellBash, ShellZsh, ShellFish)
+	cmd.Action(func(c *kingpin.ParseContext) error {
+		return WriteCompletionScript(os.Stdout, app.Name, *shell)
+	})
+}
+
+func WriteCompletionScript(w io.Writer, appName, shell string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("shell (%s) not supported", shell)
+	}
+	_, err := io.WriteString(w, strings.Replace(script, "{{.Name}}", appName, -1))
+	return err
+}
@@ -1,10 +1,18 @@
 package cliutils
 
 import (
+	"context"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
 
 	"github.com/deviceplane/cli/cmd/deviceplane/global"
 	"github.com/deviceplane/cli/pkg/client"
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/deviceplane/cli/pkg/proxy"
+	"github.com/deviceplane/cli/pkg/tlsconfig"
 
 	"github.com/olekukonko/tablewriter"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
@@ -12,11 +20,162 @@ import (
 
 func InitializeAPIClient(config *global.Config) func(c *kingpin.ParseContext) error {
 	return func(c *kingpin.ParseContext) error {
-		config.APIClient = client.NewClient(*config.Flags.APIEndpoint, *config.Flags.AccessKey, nil)
+		if !requiresAPIClient(config, c) {
+			return nil
+		}
+
+		if c.Error() || !*config.ParsedCorrectly {
+			config.APIClient = client.NewClient(*config.Flags.APIEndpoint, *config.Flags.AccessKey, nil)
+			return nil
+		}
+
+		if project := *config.Flags.Project; project != "" {
+			if err := ValidateName("project", project); err != nil {
+				return err
+			}
+		}
+
+		apiClient, err := NewAPIClient(config)
+		if err != nil {
+			return err
+		}
+		config.APIClient = apiClient
+
+		capabilities, err := loadAPICapabilities(config)
+		if err != nil {
+			return err
+		}
+		config.APICapabilities = capabilities
+		return nil
+	}
+}
+
+// NewAPIClient returns a client for the configured API endpoint and access
+// key. Commands declared WithoutAPIClient can use it to reach the API
+// without failing when it's unavailable.
+func NewAPIClient(config *global.Config) (*client.Client, error) {
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewClient(*config.Flags.APIEndpoint, *config.Flags.AccessKey, httpClient), nil
+}
+
+// WithoutAPIClient declares that cmd, and any of its subcommands, can run
+// without an initialized API client. Initialization is skipped for it so
+// that missing or broken credentials don't get in the way.
+func WithoutAPIClient(config *global.Config, cmd *kingpin.CmdClause) *kingpin.CmdClause {
+	if config.CommandsWithoutAPIClient == nil {
+		config.CommandsWithoutAPIClient = make(map[*kingpin.CmdClause]bool)
+	}
+	config.CommandsWithoutAPIClient[cmd] = true
+	return cmd
+}
+
+func requiresAPIClient(config *global.Config, c *kingpin.ParseContext) bool {
+	// No command means --help or --version
+	if c.SelectedCommand == nil || c.SelectedCommand == config.App.HelpCommand {
+		return false
+	}
+	for _, element := range c.Elements {
+		if cmd, ok := element.Clause.(*kingpin.CmdClause); ok && config.CommandsWithoutAPIClient[cmd] {
+			return false
+		}
+	}
+	return true
+}
+
+// newHTTPClient returns nil, meaning the default client, unless the TLS
+// settings were changed with --ca-cert, --client-cert and --client-key, or
+// --insecure-skip-tls-verify, or a proxy was set with --proxy
+func newHTTPClient(config *global.Config) (*http.Client, error) {
+	caCert := *config.Flags.CACert
+	clientCert := *config.Flags.ClientCert
+	clientKey := *config.Flags.ClientKey
+	insecure := *config.Flags.InsecureSkipTLSVerify
+	proxyFlag := ""
+	if config.Flags.Proxy != nil {
+		proxyFlag = *config.Flags.Proxy
+	}
+	if caCert == "" && clientCert == "" && clientKey == "" && !insecure && proxyFlag == "" {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyFlag != "" {
+		proxyURL, err := proxy.ParseURL(proxyFlag)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxy.Func(proxyURL)
+	}
+
+	if caCert != "" || clientCert != "" || clientKey != "" || insecure {
+		tlsConfig, err := tlsconfig.New(caCert, clientCert, clientKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if insecure {
+			if err := config.Logger.Warnf("TLS certificate verification is disabled, connections to %s are not secure", (*config.Flags.APIEndpoint).String()); err != nil {
+				return nil, err
+			}
+			tlsConfig.InsecureSkipVerify = true
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
+
+// InitializeTimeout makes the global --timeout flag the default timeout of
+// dpcontext.NewDefault, for the packages shared with the agent. A disabled
+// timeout leaves the default unchanged.
+func InitializeTimeout(config *global.Config) func(c *kingpin.ParseContext) error {
+	return func(c *kingpin.ParseContext) error {
+		if config.Flags.Timeout != nil {
+			dpcontext.SetDefaultTimeout(*config.Flags.Timeout)
+		}
 		return nil
 	}
 }
 
+// NewContext returns a context bounded by the global --timeout flag, for use
+// with a single API request
+func NewContext(config *global.Config) (context.Context, context.CancelFunc) {
+	if config.Flags.Timeout == nil || *config.Flags.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), *config.Flags.Timeout)
+}
+
+// NewStreamContext returns a context for a streaming API request, which is
+// cancelled by an interrupt. The global --timeout flag only bounds connecting,
+// so connected must be called with the request's error once it returns.
+func NewStreamContext(config *global.Config) (ctx context.Context, connected func(error) error, cancel context.CancelFunc) {
+	ctx, cancel = signal.NotifyContext(context.Background(), os.Interrupt)
+	if config.Flags.Timeout == nil || *config.Flags.Timeout <= 0 {
+		return ctx, func(err error) error { return err }, cancel
+	}
+
+	var timedOut int32
+	timer := time.AfterFunc(*config.Flags.Timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		cancel()
+	})
+	connected = func(err error) error {
+		timer.Stop()
+		if err != nil && atomic.LoadInt32(&timedOut) == 1 {
+			return client.ErrRequestTimedOut
+		}
+		return err
+	}
+	return ctx, connected, cancel
+}
+
 func DefaultTable() *tablewriter.Table {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAutoWrapText(false)
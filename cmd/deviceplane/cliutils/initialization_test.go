@@ -0,0 +1,46 @@
+package cliutils
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	apiURL, err := url.Parse("https://api.example.com/api")
+	require.NoError(t, err)
+	config := &global.Config{
+		Flags: global.ConfigFlags{
+			APIEndpoint:           &apiURL,
+			CACert:                &[]string{""}[0],
+			ClientCert:            &[]string{""}[0],
+			ClientKey:             &[]string{""}[0],
+			InsecureSkipTLSVerify: &[]bool{false}[0],
+			Proxy:                 &[]string{""}[0],
+		},
+	}
+
+	httpClient, err := newHTTPClient(config)
+	require.NoError(t, err)
+	require.Nil(t, httpClient)
+
+	*config.Flags.Proxy = "socks5://proxy.example.com:1080"
+	httpClient, err = newHTTPClient(config)
+	require.NoError(t, err)
+	require.NotNil(t, httpClient)
+
+	transport, ok := httpClient.Transport.(*http.Transport)
+	require.True(t, ok)
+	req, err := http.NewRequest("GET", apiURL.String(), nil)
+	require.NoError(t, err)
+	proxyURL, err := transport.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "socks5://proxy.example.com:1080", proxyURL.String())
+
+	*config.Flags.Proxy = "ftp://proxy.example.com"
+	_, err = newHTTPClient(config)
+	require.Error(t, err)
+}
@@ -0,0 +1,31 @@
+package cliutils
+
+import (
+	"errors"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// InitializeLogging sets the log level from the global --quiet and --verbose
+// flags. It must run before any other pre-action so that their logs are
+// filtered too.
+func InitializeLogging(config *global.Config) func(c *kingpin.ParseContext) error {
+	return func(c *kingpin.ParseContext) error {
+		quiet := config.Flags.Quiet != nil && *config.Flags.Quiet
+		verbose := config.Flags.Verbose != nil && *config.Flags.Verbose
+
+		switch {
+		case quiet && verbose:
+			return errors.New("--quiet and --verbose can't be used together")
+		case quiet:
+			log.SetLevel(log.ErrorLevel)
+		case verbose:
+			log.SetLevel(log.DebugLevel)
+		default:
+			log.SetLevel(log.InfoLevel)
+		}
+		return nil
+	}
+}
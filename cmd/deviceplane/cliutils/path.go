@@ -0,0 +1,95 @@
+package cliutils
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// ExpandPath expands a leading "~" or "~user" to a home directory, then any
+// $VAR or ${VAR} to the value of the environment variable, like a shell does.
+// Flag values from config files, environment variables and defaults aren't
+// expanded by the shell, so without this a literal "~" directory is used.
+func ExpandPath(path string) (string, error) {
+	if strings.HasPrefix(path, "~") {
+		name, rest := path[1:], ""
+		if i := strings.IndexRune(name, filepath.Separator); i >= 0 {
+			name, rest = name[:i], name[i:]
+		}
+
+		var home string
+		if name == "" {
+			var err error
+			if home, err = homeDir(); err != nil {
+				return "", errors.Wrapf(err, "expand %s", path)
+			}
+		} else {
+			usr, err := user.Lookup(name)
+			if err != nil {
+				return "", errors.Wrapf(err, "expand %s", path)
+			}
+			home = usr.HomeDir
+		}
+		path = home + rest
+	}
+
+	return os.ExpandEnv(path), nil
+}
+
+// homeDir returns $HOME, or the current user's home directory if it isn't
+// set. Users in containers and CI often have neither.
+func homeDir() (string, error) {
+	if home, err := os.UserHomeDir(); err == nil {
+		return home, nil
+	}
+	if usr, err := user.Current(); err == nil && usr.HomeDir != "" {
+		return usr.HomeDir, nil
+	}
+	return "", errors.New("$HOME isn't set and the current user has no home directory")
+}
+
+// ConfigDirEnvVar is the environment variable that overrides the directory
+// the config file is kept in
+const ConfigDirEnvVar = "DEVICEPLANE_CONFIG_DIR"
+
+// DefaultConfigFile returns the config file to use if --config isn't given.
+// It's in $DEVICEPLANE_CONFIG_DIR if that's set, so the CLI can be used
+// without a home directory.
+func DefaultConfigFile() string {
+	if dir := os.Getenv(ConfigDirEnvVar); dir != "" {
+		return filepath.Join(dir, "config")
+	}
+	return "~/.deviceplane/config"
+}
+
+type pathValue string
+
+func (p *pathValue) Set(value string) error {
+	expanded, err := ExpandPath(value)
+	if err != nil {
+		return err
+	}
+	*p = pathValue(expanded)
+	return nil
+}
+
+func (p *pathValue) String() string {
+	return string(*p)
+}
+
+// Path makes a flag a path, whose value and default are expanded with
+// ExpandPath
+func Path(flag *kingpin.FlagClause) *string {
+	path := new(string)
+	PathVar(flag, path)
+	return path
+}
+
+// PathVar is like Path, but stores the flag's value in target
+func PathVar(flag *kingpin.FlagClause, target *string) {
+	flag.SetValue((*pathValue)(target))
+}
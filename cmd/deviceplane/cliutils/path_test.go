@@ -0,0 +1,54 @@
+package cliutils
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPath(t *testing.T) {
+	usr, err := user.Current()
+	require.NoError(t, err)
+
+	os.Setenv("DEVICEPLANE_TEST_DIR", "/opt/deviceplane")
+	defer os.Unsetenv("DEVICEPLANE_TEST_DIR")
+
+	for path, expected := range map[string]string{
+		"~":                                  usr.HomeDir,
+		"~/.deviceplane/config":              filepath.Join(usr.HomeDir, ".deviceplane/config"),
+		"~" + usr.Username + "/certs/ca.pem": filepath.Join(usr.HomeDir, "certs/ca.pem"),
+		"${HOME}/config":                     os.Getenv("HOME") + "/config",
+		"$DEVICEPLANE_TEST_DIR/state":        "/opt/deviceplane/state",
+		"/etc/deviceplane/config":            "/etc/deviceplane/config",
+		"relative/~/config":                  "relative/~/config",
+	} {
+		expanded, err := ExpandPath(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, expanded, path)
+	}
+
+	_, err = ExpandPath("~no-such-deviceplane-user/config")
+	require.Error(t, err)
+}
+
+func TestExpandPathUsesHome(t *testing.T) {
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", "/home/ci")
+
+	expanded, err := ExpandPath("~/.deviceplane/config")
+	require.NoError(t, err)
+	require.Equal(t, "/home/ci/.deviceplane/config", expanded)
+}
+
+func TestDefaultConfigFile(t *testing.T) {
+	defer os.Unsetenv(ConfigDirEnvVar)
+
+	os.Unsetenv(ConfigDirEnvVar)
+	require.Equal(t, "~/.deviceplane/config", DefaultConfigFile())
+
+	os.Setenv(ConfigDirEnvVar, "/etc/deviceplane")
+	require.Equal(t, "/etc/deviceplane/config", DefaultConfigFile())
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 	"gopkg.in/yaml.v2"
@@ -26,6 +28,7 @@ const (
 	FormatJSON       string = "json"
 	FormatJSONStream string = "json-stream"
 	FormatYAML       string = "yaml"
+	FormatText       string = "text"
 )
 
 func AddFormatFlag(formatVar *string, categoryCmd *kingpin.CmdClause, allowedFormats ...string) {
@@ -35,6 +38,33 @@ func AddFormatFlag(formatVar *string, categoryCmd *kingpin.CmdClause, allowedFor
 	fFlag.EnumVar(formatVar, allowedFormats...)
 }
 
+type timeoutValue time.Duration
+
+// Set accepts a duration such as "90s", or a bare number of seconds for
+// compatibility with the old "device ssh --timeout" flag
+func (t *timeoutValue) Set(value string) error {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		*t = timeoutValue(time.Duration(seconds) * time.Second)
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*t = timeoutValue(d)
+	return nil
+}
+
+func (t *timeoutValue) String() string {
+	return time.Duration(*t).String()
+}
+
+func Timeout(flag *kingpin.FlagClause) *time.Duration {
+	timeout := new(time.Duration)
+	flag.SetValue((*timeoutValue)(timeout))
+	return timeout
+}
+
 func PrintWithFormat(obj interface{}, format string) error {
 	switch format {
 	case FormatJSONStream:
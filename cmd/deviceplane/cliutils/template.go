@@ -68,7 +68,7 @@ func init() {
 {{end}}\
 
 {{define "AutocompleteHelp"}}\
-To add autocompletion, add ` + "`" + `eval "$({{.}} --completion-script-bash)"` + "`" + ` to your bashrc or zshrc file.
+To add autocompletion, add ` + "`" + `eval "$({{.}} completion bash)"` + "`" + ` to your bashrc (or use "completion zsh" or "completion fish").
 {{end}}\
 
 {{if .Context.SelectedCommand}}\
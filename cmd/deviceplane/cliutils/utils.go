@@ -1,9 +1,14 @@
 package cliutils
 
 import (
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
 	"github.com/hako/durafmt"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 func DurafmtSince(d time.Time) *durafmt.Durafmt {
@@ -12,11 +17,18 @@ func DurafmtSince(d time.Time) *durafmt.Durafmt {
 	return duration
 }
 
+// sshFlagsWithValues are the flags of the ssh command that take a separate
+// value, which GetSSHArgs must skip to find the device
+var sshFlagsWithValues = map[string]bool{
+	"--record":  true,
+	"--command": true,
+}
+
 func GetSSHArgs(args []string) (preSSH []string, postSSH []string) {
 	var i int
 	var hasSSH bool
 	for i = 0; i < len(args); i++ {
-		if i > 0 && args[i-1] == "ssh" { // Split like so: deviceplane [...] ssh [device] [post-ssh]
+		if i > 0 && args[i-1] == "ssh" { // Split like so: deviceplane [...] ssh [flags] [device] [post-ssh]
 			hasSSH = true
 			break
 		}
@@ -26,9 +38,61 @@ func GetSSHArgs(args []string) (preSSH []string, postSSH []string) {
 		return args, nil
 	}
 
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		if sshFlagsWithValues[args[i]] {
+			i++
+		}
+		i++
+	}
+	if i >= len(args) {
+		return args, nil
+	}
+
 	preSSH = args[0 : i+1]
 	if len(args) > i+1 {
 		postSSH = args[i+1:]
 	}
 	return
 }
+
+// FlagPassed returns whether the flag called name was given on the command
+// line, rather than taken from its environment variable or default
+func FlagPassed(c *kingpin.ParseContext, name string) bool {
+	for _, element := range c.Elements {
+		if flag, ok := element.Clause.(*kingpin.FlagClause); ok && flag.Model().Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// IsTerminal returns whether f is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// UseEscapeCodes returns whether color and other terminal escape codes may
+// be written to stdout
+func UseEscapeCodes(config *global.Config) bool {
+	if config.Flags.NoColor != nil && *config.Flags.NoColor {
+		return false
+	}
+	return IsTerminal(os.Stdout)
+}
+
+// ClearScreen clears stdout before redrawing a view that refreshes in place.
+// Without escape codes the view is just printed again, after a blank line.
+func ClearScreen(config *global.Config) {
+	if UseEscapeCodes(config) {
+		fmt.Print(clearScreen)
+		return
+	}
+	fmt.Println()
+}
@@ -2,8 +2,10 @@ package cliutils
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 func TestSSHParsing(t *testing.T) {
@@ -90,3 +92,76 @@ func TestSSHParsingWithoutSSH(t *testing.T) {
 	})
 	require.Len(t, postSSH, 0)
 }
+
+func TestSSHParsingWithFlags(t *testing.T) {
+	preSSH, postSSH := GetSSHArgs([]string{
+		"deviceplane",
+		"ssh",
+		"--record",
+		"/var/log/sessions",
+		"--record=/tmp",
+		"--command",
+		"uptime -p",
+		"elegant-lamarr",
+		"-L",
+		"3000:localhost:3000",
+	})
+	require.Equal(t, preSSH, []string{
+		"deviceplane",
+		"ssh",
+		"--record",
+		"/var/log/sessions",
+		"--record=/tmp",
+		"--command",
+		"uptime -p",
+		"elegant-lamarr",
+	})
+	require.Equal(t, postSSH, []string{
+		"-L",
+		"3000:localhost:3000",
+	})
+}
+
+func TestTimeoutValue(t *testing.T) {
+	var timeout timeoutValue
+
+	require.NoError(t, timeout.Set("60"))
+	require.Equal(t, time.Minute, time.Duration(timeout))
+
+	require.NoError(t, timeout.Set("90s"))
+	require.Equal(t, 90*time.Second, time.Duration(timeout))
+
+	require.Error(t, timeout.Set("soon"))
+}
+
+func TestJoinStdinAccessKey(t *testing.T) {
+	require.Equal(t,
+		[]string{"--access-key=-", "device", "list"},
+		JoinStdinAccessKey([]string{"--access-key", "-", "device", "list"}),
+	)
+	require.Equal(t,
+		[]string{"--access-key", "key", "device", "list"},
+		JoinStdinAccessKey([]string{"--access-key", "key", "device", "list"}),
+	)
+}
+
+func TestValidateName(t *testing.T) {
+	for _, valid := range []string{"hq-2", "prj_1Xq8ePZdvNwwKb3TtGsrYEmcdFn"} {
+		require.NoError(t, ValidateName("project", valid))
+	}
+	for _, invalid := range []string{"", "bad_name", "a b", "dev_1Xq8ePZdvNwwKb3TtGsrYEmcdFn"} {
+		require.Error(t, ValidateName("project", invalid))
+	}
+}
+
+func TestFlagPassed(t *testing.T) {
+	app := kingpin.New("test", "")
+	app.Flag("access-key", "").Envar("TEST_FLAG_PASSED_ACCESS_KEY").String()
+	app.Flag("project", "").String()
+
+	t.Setenv("TEST_FLAG_PASSED_ACCESS_KEY", "key")
+	c, err := app.ParseContext([]string{"--project", "p"})
+	require.NoError(t, err)
+	require.True(t, FlagPassed(c, "project"))
+	require.False(t, FlagPassed(c, "access-key"))
+}
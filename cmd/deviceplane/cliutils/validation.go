@@ -3,8 +3,10 @@ package cliutils
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	"github.com/deviceplane/cli/pkg/validator"
 
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
@@ -18,13 +20,19 @@ func RequireAccessKey(config *global.Config, c interface{}) interface{} {
 	)
 }
 
+// RequireProject fails a command before any request is made if no project
+// was resolved, see global.ResolveProject
 func RequireProject(config *global.Config, c interface{}) interface{} {
-	return RequireVariableForPreAction(
-		config,
-		config.Flags.Project,
-		fmt.Errorf("Project not found as a flag, environment variable, or in config (%s)", *config.Flags.ConfigFile),
-		c,
-	)
+	return addPreAction(c, func(c *kingpin.ParseContext) error {
+		if c.Error() || !*config.ParsedCorrectly {
+			return nil // Let kingpin's errors precede
+		}
+		if config.Flags.Project == nil || *config.Flags.Project == "" {
+			return fmt.Errorf("no project set: pass --project, set %s, or set project in the config file (%s)",
+				global.ProjectEnvVar, *config.Flags.ConfigFile)
+		}
+		return nil
+	})
 }
 
 func RequireVariableForPreAction(config *global.Config, variable *string, err error, c interface{}) interface{} {
@@ -38,15 +46,75 @@ func RequireVariableForPreAction(config *global.Config, variable *string, err er
 		return nil
 	}
 
+	return addPreAction(c, requirePreAction)
+}
+
+func addPreAction(c interface{}, action kingpin.Action) interface{} {
 	switch v := c.(type) {
 	case *kingpin.CmdClause:
-		return v.PreAction(requirePreAction)
+		return v.PreAction(action)
 	case *kingpin.ArgClause:
-		return v.PreAction(requirePreAction)
+		return v.PreAction(action)
 	case *kingpin.FlagClause:
-		return v.PreAction(requirePreAction)
+		return v.PreAction(action)
 	default:
-		log.Fatal("Cannot require access key on this type")
+		log.Fatal("Cannot require a variable on this type")
+		return nil
+	}
+}
+
+// nameRequest and idRequest are validated with the rules the API applies
+// to names and IDs, so typos are caught before any request is made
+type nameRequest struct {
+	Name string `validate:"name"`
+}
+
+type idRequest struct {
+	ID string `validate:"id"`
+}
+
+// idPrefixes are the prefixes of the IDs the API assigns to each kind of
+// resource
+var idPrefixes = map[string]string{
+	"project":     "prj_",
+	"device":      "dev_",
+	"application": "app_",
+}
+
+// ValidateName checks that value can refer to an existing resource, by
+// either its name or its ID
+func ValidateName(kind, value string) error {
+	if prefix, ok := idPrefixes[kind]; ok && strings.HasPrefix(value, prefix) &&
+		validator.Validate(idRequest{ID: value}) == nil {
+		return nil
+	}
+	return ValidateNewName(kind, value)
+}
+
+// ValidateNewName checks that value can be used as the name of a new
+// resource
+func ValidateNewName(kind, value string) error {
+	if validator.Validate(nameRequest{Name: value}) != nil {
+		return fmt.Errorf("invalid %s name (%s): names must be 1-100 letters, digits or dashes", kind, value)
+	}
+	return nil
+}
+
+// RequireValidNames fails a command early if any of the names it was given
+// are malformed. Empty values are left to other checks.
+func RequireValidNames(config *global.Config, kind string, values ...*string) func(*kingpin.ParseContext) error {
+	return func(c *kingpin.ParseContext) error {
+		if c.Error() || !*config.ParsedCorrectly {
+			return nil
+		}
+		for _, value := range values {
+			if value == nil || *value == "" {
+				continue
+			}
+			if err := ValidateName(kind, *value); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 }
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
 	"github.com/deviceplane/cli/pkg/interpolation"
 	"github.com/pkg/errors"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
@@ -20,6 +22,15 @@ type ConfigValues struct {
 	Project   *string `yaml:"project,omitempty"`
 }
 
+// accessKeyFromInput is set if the access key was read from
+// --access-key-file or stdin, in which case configure doesn't prompt for it
+var accessKeyFromInput bool
+
+var knownConfigKeys = map[string]bool{
+	"access-key": true,
+	"project":    true,
+}
+
 func populateEmptyValuesFromConfig(c *kingpin.ParseContext) (err error) {
 	defer func() {
 		if err != nil {
@@ -33,19 +44,6 @@ func populateEmptyValuesFromConfig(c *kingpin.ParseContext) (err error) {
 		return nil
 	}
 
-	// This should normally be expanded by the shell,
-	// but this is for the our default flag value,
-	// which starts with "~/" but does not get expanded
-	if strings.HasPrefix(*gConfig.Flags.ConfigFile, "~/") {
-		usr, err := user.Current()
-		if err != nil {
-			return errors.Wrap(err, "failed to get home dir")
-		}
-		dir := usr.HomeDir
-		expandedPath := filepath.Join(dir, (*gConfig.Flags.ConfigFile)[2:])
-		gConfig.Flags.ConfigFile = &expandedPath
-	}
-
 	gcf, err := os.Open(*gConfig.Flags.ConfigFile)
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -53,15 +51,10 @@ func populateEmptyValuesFromConfig(c *kingpin.ParseContext) (err error) {
 		}
 
 		// Create if not exists
-		err := os.MkdirAll(filepath.Dir(*gConfig.Flags.ConfigFile), os.ModeDir)
-		if err != nil && !os.IsExist(err) {
-			return errors.Wrap(err, "could not create config directory")
-		}
-		err = os.Chmod(filepath.Dir(*gConfig.Flags.ConfigFile), 0700)
-		if err != nil {
-			return errors.Wrap(err, "failed to change config file perms")
+		if err := createConfigDir(); err != nil {
+			return err
 		}
-		gcf, err = os.Create(*gConfig.Flags.ConfigFile)
+		gcf, err = os.OpenFile(*gConfig.Flags.ConfigFile, os.O_RDWR|os.O_CREATE, 0600)
 		if err != nil {
 			return errors.Wrap(err, "failed to create config file")
 		}
@@ -84,19 +77,79 @@ func populateEmptyValuesFromConfig(c *kingpin.ParseContext) (err error) {
 		return errors.Wrap(err, "failed to unmarshal config file")
 	}
 
+	var rawValues map[string]interface{}
+	if err := yaml.Unmarshal([]byte(configString), &rawValues); err == nil {
+		var unknownKeys []string
+		for key := range rawValues {
+			if !knownConfigKeys[key] {
+				unknownKeys = append(unknownKeys, key)
+			}
+		}
+		sort.Strings(unknownKeys)
+		for _, key := range unknownKeys {
+			if err := gConfig.Logger.Warnf("unknown key %q in config file %s", key, *gConfig.Flags.ConfigFile); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Fill config in order of FLAG -> ENV -> CONFIG
-	// The first two steps are handled automatically by kingpin
+	// For the access key, the first two steps are handled automatically by kingpin
+	gConfig.AccessKeySource = accessKeySource(c)
+	accessKeyFromInput, err = cliutils.ResolveAccessKey(gConfig)
+	if err != nil {
+		return err
+	}
 	if configValues.AccessKey != nil {
 		if gConfig.Flags.AccessKey == nil || *gConfig.Flags.AccessKey == "" {
 			*gConfig.Flags.AccessKey = *configValues.AccessKey
+			gConfig.AccessKeySource = global.AccessKeySourceConfig
 		}
 	}
-	if configValues.Project != nil {
-		if gConfig.Flags.Project == nil || *gConfig.Flags.Project == "" {
-			*gConfig.Flags.Project = *configValues.Project
+	if err := resolveProject(configValues.Project); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// accessKeySource returns where the access key given before the config file
+// is read comes from
+func accessKeySource(c *kingpin.ParseContext) global.AccessKeySource {
+	switch {
+	case *gConfig.Flags.AccessKeyFile != "":
+		return global.AccessKeySourceFile
+	case *gConfig.Flags.AccessKey == cliutils.StdinAccessKey:
+		return global.AccessKeySourceStdin
+	case cliutils.FlagPassed(c, "access-key"):
+		return global.AccessKeySourceFlag
+	case *gConfig.Flags.AccessKey != "":
+		return global.AccessKeySourceEnv
+	}
+	return global.AccessKeySourceNone
+}
+
+// resolveProject sets the project from the flag, environment or config file,
+// as ordered by global.ResolveProject
+func resolveProject(configProject *string) error {
+	if gConfig.Flags.Project == nil {
+		gConfig.Flags.Project = new(string)
+	}
+	flag := *gConfig.Flags.Project
+
+	var file string
+	if configProject != nil {
+		file = *configProject
+	}
+	env := os.Getenv(global.ProjectEnvVar)
+
+	if flag != "" && env != "" && flag != env {
+		if err := gConfig.Logger.Warnf("--project (%s) overrides %s (%s)", flag, global.ProjectEnvVar, env); err != nil {
+			return err
 		}
 	}
 
+	*gConfig.Flags.Project, gConfig.ProjectSource = global.ResolveProject(flag, env, file)
 	return nil
 }
 
@@ -105,12 +158,15 @@ func configureAction(c *kingpin.ParseContext) error {
 	reader := bufio.NewReader(os.Stdin)
 
 	// Read input
-	var extraAccessKeyMsg string
-	if gConfig.Flags.AccessKey != nil && *gConfig.Flags.AccessKey != "" {
-		extraAccessKeyMsg = fmt.Sprintf(` (or leave empty to use "%s")`, *gConfig.Flags.AccessKey)
+	var rawAccessKey string
+	if !accessKeyFromInput {
+		var extraAccessKeyMsg string
+		if gConfig.Flags.AccessKey != nil && *gConfig.Flags.AccessKey != "" {
+			extraAccessKeyMsg = fmt.Sprintf(` (or leave empty to use "%s")`, *gConfig.Flags.AccessKey)
+		}
+		fmt.Printf("Enter access key%s: \n>", extraAccessKeyMsg)
+		rawAccessKey, _ = reader.ReadString('\n')
 	}
-	fmt.Printf("Enter access key%s: \n>", extraAccessKeyMsg)
-	rawAccessKey, _ := reader.ReadString('\n')
 
 	var extraProjectMsg string
 	if gConfig.Flags.Project != nil && *gConfig.Flags.Project != "" {
@@ -144,9 +200,22 @@ func configureAction(c *kingpin.ParseContext) error {
 		return errors.Wrap(err, "failed to serialize config")
 	}
 
-	err = ioutil.WriteFile(*gConfig.Flags.ConfigFile, configBytes, 0700)
+	if err := createConfigDir(); err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(*gConfig.Flags.ConfigFile, configBytes, 0600)
 	if err != nil {
 		return errors.Wrap(err, "failed to write config to disk")
 	}
 	return nil
 }
+
+// createConfigDir creates the config file's directory if it doesn't exist.
+// The config holds an access key, so only its owner can read it. An existing
+// directory, which may be shared, is left as it is.
+func createConfigDir() error {
+	if err := os.MkdirAll(filepath.Dir(*gConfig.Flags.ConfigFile), 0700); err != nil {
+		return errors.Wrap(err, "could not create config directory")
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package configure
 
 import (
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
 	"github.com/deviceplane/cli/cmd/deviceplane/global"
 )
 
@@ -15,6 +16,6 @@ func Initialize(c *global.Config) {
 	c.App.PreAction(populateEmptyValuesFromConfig)
 
 	// Commands
-	configureCmd := c.App.Command("configure", "Configure this CLI utility.")
+	configureCmd := cliutils.WithoutAPIClient(c, c.App.Command("configure", "Configure this CLI utility."))
 	configureCmd.Action(configureAction)
 }
@@ -0,0 +1,142 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+func deviceBundleGetAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	bundleBytes, err := config.APIClient.GetDeviceBundle(ctx, *config.Flags.Project, *deviceArg)
+	if err != nil {
+		return err
+	}
+
+	if *bundleOutputFlag == cliutils.FormatYAML {
+		canonical, err := canonicalBundle(bundleBytes)
+		if err != nil {
+			return err
+		}
+		fmt.Print(canonical)
+		return nil
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, bundleBytes, "", strings.Repeat(" ", 4)); err != nil {
+		return errors.Wrap(err, "invalid bundle")
+	}
+	fmt.Println(indented.String())
+	return nil
+}
+
+func deviceBundleDiffAction(c *kingpin.ParseContext) error {
+	localBytes, err := ioutil.ReadFile(*bundleFileArg)
+	if err != nil {
+		return err
+	}
+	local, err := canonicalBundle(localBytes)
+	if err != nil {
+		return errors.Wrapf(err, "parse %s", *bundleFileArg)
+	}
+
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	bundleBytes, err := config.APIClient.GetDeviceBundle(ctx, *config.Flags.Project, *deviceArg)
+	if err != nil {
+		return err
+	}
+	current, err := canonicalBundle(bundleBytes)
+	if err != nil {
+		return errors.Wrap(err, "invalid bundle")
+	}
+
+	diff := diffLines(splitLines(current), splitLines(local))
+	if diff == "" {
+		fmt.Println("No differences")
+		return nil
+	}
+
+	fmt.Printf("--- %s (device)\n+++ %s\n", *deviceArg, *bundleFileArg)
+	fmt.Print(diff)
+	os.Exit(1)
+	return nil
+}
+
+// canonicalBundle renders a JSON or YAML bundle as YAML with sorted keys,
+// so bundles from different sources can be compared line by line
+func canonicalBundle(b []byte) (string, error) {
+	var bundle interface{}
+	if err := yaml.Unmarshal(b, &bundle); err != nil {
+		return "", err
+	}
+	canonical, err := yaml.Marshal(bundle)
+	if err != nil {
+		return "", err
+	}
+	return string(canonical), nil
+}
+
+func splitLines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the lines removed from a ("-") and added in b ("+"),
+// with unchanged lines prefixed by a space, or an empty string if a and b
+// are equal
+func diffLines(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&out, " %s\n", a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			fmt.Fprintf(&out, "+%s\n", b[j])
+			changed = true
+			j++
+		default:
+			fmt.Fprintf(&out, "-%s\n", a[i])
+			changed = true
+			i++
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+	return out.String()
+}
@@ -0,0 +1,147 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
+	"gopkg.in/yaml.v2"
+)
+
+const labelColumnPrefix = "labels."
+
+// deviceColumn is a column of device list's table
+type deviceColumn struct {
+	name   string
+	header string
+	value  func(d models.Device) string
+}
+
+var deviceColumns = map[string]deviceColumn{
+	"id": {header: "ID", value: func(d models.Device) string {
+		return d.ID
+	}},
+	"name": {header: "Name", value: func(d models.Device) string {
+		return d.Name
+	}},
+	"status": {header: "Status", value: func(d models.Device) string {
+		return string(d.Status)
+	}},
+	"ip": {header: "IP", value: func(d models.Device) string {
+		return d.Info.IPAddress
+	}},
+	"os": {header: "OS", value: func(d models.Device) string {
+		return d.Info.OSRelease.Name
+	}},
+	"agent-version": {header: "Agent Version", value: func(d models.Device) string {
+		return d.Info.AgentVersion
+	}},
+	"labels": {header: "Labels", value: func(d models.Device) string {
+		labels := make([]string, 0, len(d.Labels))
+		for k, v := range d.Labels {
+			labels = append(labels, fmt.Sprintf("%s:%s", k, v))
+		}
+		sort.Strings(labels)
+		return strings.Join(labels, "\n")
+	}},
+	"last-seen": {header: "Last Seen", value: func(d models.Device) string {
+		return cliutils.DurafmtSince(d.LastSeenAt).String() + " ago"
+	}},
+	"created": {header: "Created", value: func(d models.Device) string {
+		return cliutils.DurafmtSince(d.CreatedAt).String() + " ago"
+	}},
+}
+
+var defaultDeviceColumns = []string{"name", "status", "ip", "os", "labels", "last-seen", "created"}
+
+// parseDeviceColumns returns the columns named in a comma separated list, in
+// order. labels.<key> is a column with the value of one label.
+func parseDeviceColumns(text string) ([]deviceColumn, error) {
+	names := defaultDeviceColumns
+	if text != "" {
+		names = strings.Split(text, ",")
+	}
+
+	columns := make([]deviceColumn, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		if strings.HasPrefix(name, labelColumnPrefix) && len(name) > len(labelColumnPrefix) {
+			key := strings.TrimPrefix(name, labelColumnPrefix)
+			columns = append(columns, deviceColumn{
+				name:   name,
+				header: key,
+				value: func(d models.Device) string {
+					return d.Labels[key]
+				},
+			})
+			continue
+		}
+
+		column, ok := deviceColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, valid columns are %s", name, strings.Join(validDeviceColumns(), ", "))
+		}
+		column.name = name
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func validDeviceColumns() []string {
+	valid := make([]string, 0, len(deviceColumns)+1)
+	for name := range deviceColumns {
+		valid = append(valid, name)
+	}
+	sort.Strings(valid)
+	return append(valid, labelColumnPrefix+"<key>")
+}
+
+// deviceRow is the values of a device's columns, which are marshaled as an
+// object with its fields in column order
+type deviceRow yaml.MapSlice
+
+func (r deviceRow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, item := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (r deviceRow) MarshalYAML() (interface{}, error) {
+	return yaml.MapSlice(r), nil
+}
+
+// deviceRows returns the values of columns for each device, keyed by column
+// name, for the structured output formats
+func deviceRows(devices []models.Device, columns []deviceColumn) []deviceRow {
+	rows := make([]deviceRow, 0, len(devices))
+	for _, d := range devices {
+		row := make(deviceRow, 0, len(columns))
+		for _, column := range columns {
+			row = append(row, yaml.MapItem{Key: column.name, Value: column.value(d)})
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
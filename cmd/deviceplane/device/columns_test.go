@@ -0,0 +1,50 @@
+package device
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseDeviceColumns(t *testing.T) {
+	d := models.Device{
+		Name:   "pi-1",
+		Status: models.DeviceStatusOnline,
+		Info:   models.DeviceInfo{IPAddress: "10.0.0.2"},
+		Labels: map[string]string{"env": "prod", "site": "hq"},
+	}
+
+	columns, err := parseDeviceColumns("name, status,labels.env,ip,labels.missing")
+	require.NoError(t, err)
+
+	var headers, values []string
+	for _, column := range columns {
+		headers = append(headers, column.header)
+		values = append(values, column.value(d))
+	}
+	require.Equal(t, []string{"Name", "Status", "env", "IP", "missing"}, headers)
+	require.Equal(t, []string{"pi-1", "online", "prod", "10.0.0.2", ""}, values)
+
+	// Structured output keeps the columns in the order asked for
+	rows := deviceRows([]models.Device{d}, columns)
+	jsonBytes, err := json.Marshal(rows)
+	require.NoError(t, err)
+	require.Equal(t, `[{"name":"pi-1","status":"online","labels.env":"prod","ip":"10.0.0.2","labels.missing":""}]`, string(jsonBytes))
+	yamlBytes, err := yaml.Marshal(rows)
+	require.NoError(t, err)
+	require.Equal(t, "- name: pi-1\n  status: online\n  labels.env: prod\n  ip: 10.0.0.2\n  labels.missing: \"\"\n", string(yamlBytes))
+
+	columns, err = parseDeviceColumns("")
+	require.NoError(t, err)
+	require.Len(t, columns, len(defaultDeviceColumns))
+	require.Equal(t, "env:prod\nsite:hq", columns[4].value(d))
+
+	_, err = parseDeviceColumns("name,uptime")
+	require.EqualError(t, err, `unknown column "uptime", valid columns are agent-version, created, id, ip, labels, last-seen, name, os, status, labels.<key>`)
+
+	_, err = parseDeviceColumns("labels.")
+	require.Error(t, err)
+}
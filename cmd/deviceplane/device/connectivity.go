@@ -0,0 +1,54 @@
+package device
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+func deviceConnectivityAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	connectivity, err := config.APIClient.GetDeviceConnectivity(ctx, *config.Flags.Project, *deviceArg)
+	if err != nil {
+		return err
+	}
+
+	if *deviceOutputFlag == cliutils.FormatText {
+		printConnectivity(connectivity)
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(connectivity, *deviceOutputFlag)
+}
+
+func printConnectivity(connectivity *models.DeviceConnectivity) {
+	lastSeen := "never"
+	if !connectivity.LastSeenAt.IsZero() {
+		lastSeen = fmt.Sprintf("%s (%s ago)",
+			connectivity.LastSeenAt.Local().Format(time.RFC3339),
+			cliutils.DurafmtSince(connectivity.LastSeenAt).String(),
+		)
+	}
+	fmt.Printf("Status:     %s\n", connectivity.Status)
+	fmt.Printf("Last seen:  %s\n", lastSeen)
+
+	if connectivity.Connected {
+		fmt.Printf("Connection: open\n")
+	} else {
+		fmt.Printf("Connection: none (%s)\n", connectivity.ConnectionError)
+	}
+
+	switch {
+	case connectivity.RoundTripMillis != nil:
+		fmt.Printf("Ping:       %.1fms\n", *connectivity.RoundTripMillis)
+	case connectivity.PingError != "":
+		fmt.Printf("Ping:       failed (%s)\n", connectivity.PingError)
+	default:
+		fmt.Printf("Ping:       not attempted\n")
+	}
+}
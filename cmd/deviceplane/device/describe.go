@@ -0,0 +1,215 @@
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// deviceDescription is everything describe shows about a device
+type deviceDescription struct {
+	models.Device `yaml:",inline"`
+	Applications  []applicationDescription `json:"applications" yaml:"applications"`
+}
+
+type applicationDescription struct {
+	ID   string `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
+	// DesiredReleaseID is the release in the device's bundle. It's empty if
+	// the application isn't scheduled to the device, or the bundle couldn't
+	// be fetched.
+	DesiredReleaseID string               `json:"desiredReleaseId,omitempty" yaml:"desiredReleaseId,omitempty"`
+	CurrentReleaseID string               `json:"currentReleaseId,omitempty" yaml:"currentReleaseId,omitempty"`
+	Services         []serviceDescription `json:"services" yaml:"services"`
+}
+
+type serviceDescription struct {
+	Name             string              `json:"name" yaml:"name"`
+	CurrentReleaseID string              `json:"currentReleaseId,omitempty" yaml:"currentReleaseId,omitempty"`
+	State            models.ServiceState `json:"state,omitempty" yaml:"state,omitempty"`
+	ErrorMessage     string              `json:"errorMessage,omitempty" yaml:"errorMessage,omitempty"`
+	RestartCount     int                 `json:"restartCount,omitempty" yaml:"restartCount,omitempty"`
+	LastRestartAt    *time.Time          `json:"lastRestartAt,omitempty" yaml:"lastRestartAt,omitempty"`
+}
+
+func deviceDescribeAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	device, err := config.APIClient.GetDeviceFull(ctx, *config.Flags.Project, *deviceArg)
+	if err != nil {
+		return err
+	}
+
+	// The bundle is only needed for the desired releases, so describe
+	// still works against servers that can't inspect bundles
+	var bundle *models.Bundle
+	if cliutils.CheckCapability(config, models.CapabilityDeviceBundle) == nil {
+		bundleBytes, err := config.APIClient.GetDeviceBundle(ctx, *config.Flags.Project, *deviceArg)
+		if err == nil {
+			err = json.Unmarshal(bundleBytes, &bundle)
+		}
+		if err != nil {
+			if err := config.Logger.Warnf("couldn't get the device's bundle, desired releases aren't shown: %v", err); err != nil {
+				return err
+			}
+			bundle = nil
+		}
+	}
+
+	description := describeDevice(*device, bundle)
+
+	if *deviceOutputFlag == cliutils.FormatText {
+		printDeviceDescription(description)
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(description, *deviceOutputFlag)
+}
+
+// describeDevice combines a device's statuses with its bundle. Applications
+// that aren't in the bundle and have never run on the device are left out.
+func describeDevice(device models.DeviceFull, bundle *models.Bundle) deviceDescription {
+	desiredReleaseIDs := make(map[string]string)
+	if bundle != nil {
+		for _, application := range bundle.Applications {
+			desiredReleaseIDs[application.Application.ID] = application.LatestRelease.ID
+		}
+	}
+
+	description := deviceDescription{
+		Device:       device.Device,
+		Applications: []applicationDescription{},
+	}
+
+	for _, info := range device.ApplicationStatusInfo {
+		application := applicationDescription{
+			ID:               info.Application.ID,
+			Name:             info.Application.Name,
+			DesiredReleaseID: desiredReleaseIDs[info.Application.ID],
+			Services:         []serviceDescription{},
+		}
+		if info.ApplicationStatus != nil {
+			application.CurrentReleaseID = info.ApplicationStatus.CurrentReleaseID
+		}
+
+		services := make(map[string]*serviceDescription)
+		service := func(name string) *serviceDescription {
+			if _, ok := services[name]; !ok {
+				services[name] = &serviceDescription{Name: name}
+			}
+			return services[name]
+		}
+		for _, status := range info.ServiceStatuses {
+			service(status.Service).CurrentReleaseID = status.CurrentReleaseID
+		}
+		for _, state := range info.ServiceStates {
+			s := service(state.Service)
+			s.State = state.State
+			s.ErrorMessage = state.ErrorMessage
+			s.RestartCount = state.RestartCount
+			s.LastRestartAt = state.LastRestartAt
+		}
+		for _, s := range services {
+			application.Services = append(application.Services, *s)
+		}
+		sort.Slice(application.Services, func(i, j int) bool {
+			return application.Services[i].Name < application.Services[j].Name
+		})
+
+		if application.DesiredReleaseID == "" && application.CurrentReleaseID == "" && len(application.Services) == 0 {
+			continue
+		}
+		description.Applications = append(description.Applications, application)
+	}
+
+	sort.Slice(description.Applications, func(i, j int) bool {
+		return description.Applications[i].Name < description.Applications[j].Name
+	})
+
+	return description
+}
+
+func printDeviceDescription(d deviceDescription) {
+	lastSeen := "never"
+	if !d.LastSeenAt.IsZero() {
+		lastSeen = cliutils.DurafmtSince(d.LastSeenAt).String() + " ago"
+	}
+
+	fmt.Printf("Name:           %s\n", d.Name)
+	fmt.Printf("ID:             %s\n", d.ID)
+	fmt.Printf("Status:         %s (last seen %s)\n", d.Status, lastSeen)
+	fmt.Printf("Created:        %s ago\n", cliutils.DurafmtSince(d.CreatedAt).String())
+	if d.Info.State != "" {
+		fmt.Printf("Agent state:    %s: %s\n", d.Info.State, d.Info.StateMessage)
+	}
+
+	fmt.Println()
+	fmt.Println("Last info report:")
+	agentVersion := d.Info.AgentVersion
+	if d.DesiredAgentVersion != "" && d.DesiredAgentVersion != agentVersion {
+		agentVersion += fmt.Sprintf(" (updating to %s)", d.DesiredAgentVersion)
+	}
+	fmt.Printf("  Agent version: %s\n", agentVersion)
+	fmt.Printf("  IP address:    %s\n", d.Info.IPAddress)
+	fmt.Printf("  OS:            %s\n", d.Info.OSRelease.PrettyName)
+	fmt.Printf("  Kernel:        %s (%s)\n", d.Info.Kernel.Release, d.Info.Kernel.Architecture)
+
+	fmt.Println()
+	fmt.Println("Labels:")
+	if len(d.Labels) == 0 {
+		fmt.Println("  (none)")
+	}
+	keys := make([]string, 0, len(d.Labels))
+	for key := range d.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %s=%s\n", key, d.Labels[key])
+	}
+
+	fmt.Println()
+	fmt.Println("Applications:")
+	if len(d.Applications) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, application := range d.Applications {
+		fmt.Printf("  %s: desired release %s, current release %s\n", application.Name,
+			orNone(application.DesiredReleaseID), orNone(application.CurrentReleaseID))
+		if len(application.Services) == 0 {
+			continue
+		}
+
+		table := cliutils.DefaultTable()
+		table.SetHeader([]string{"Service", "Release", "State", "Restarts", "Error"})
+		for _, service := range application.Services {
+			restarts := strconv.Itoa(service.RestartCount)
+			if service.LastRestartAt != nil {
+				restarts += fmt.Sprintf(" (%s ago)", cliutils.DurafmtSince(*service.LastRestartAt).String())
+			}
+			table.Append([]string{
+				service.Name,
+				orNone(service.CurrentReleaseID),
+				string(service.State),
+				restarts,
+				strings.TrimSpace(service.ErrorMessage),
+			})
+		}
+		table.Render()
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
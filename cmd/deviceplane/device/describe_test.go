@@ -0,0 +1,60 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeDevice(t *testing.T) {
+	device := models.DeviceFull{
+		Device: models.Device{ID: "dev_1", Name: "bench"},
+		ApplicationStatusInfo: []models.DeviceApplicationStatusInfo{
+			{
+				Application: models.Application{ID: "app_web", Name: "web"},
+				ApplicationStatus: &models.DeviceApplicationStatusFull{
+					DeviceApplicationStatus: models.DeviceApplicationStatus{CurrentReleaseID: "rel_1"},
+				},
+				ServiceStatuses: []models.DeviceServiceStatusFull{
+					{DeviceServiceStatus: models.DeviceServiceStatus{Service: "nginx", CurrentReleaseID: "rel_1"}},
+				},
+				ServiceStates: []models.DeviceServiceState{
+					{Service: "nginx", State: models.ServiceState("running")},
+					{Service: "cache", State: models.ServiceState("pulling image")},
+				},
+			},
+			{
+				Application: models.Application{ID: "app_unused", Name: "unused"},
+			},
+			{
+				Application: models.Application{ID: "app_new", Name: "new"},
+			},
+		},
+	}
+	bundle := models.Bundle{
+		Applications: []models.FullBundledApplication{
+			{Application: models.BundledApplication{ID: "app_web"}, LatestRelease: models.Release{ID: "rel_2"}},
+			{Application: models.BundledApplication{ID: "app_new"}, LatestRelease: models.Release{ID: "rel_3"}},
+		},
+	}
+
+	description := describeDevice(device, &bundle)
+	require.Equal(t, "bench", description.Name)
+	require.Equal(t, []applicationDescription{
+		{ID: "app_new", Name: "new", DesiredReleaseID: "rel_3", Services: []serviceDescription{}},
+		{
+			ID: "app_web", Name: "web", DesiredReleaseID: "rel_2", CurrentReleaseID: "rel_1",
+			Services: []serviceDescription{
+				{Name: "cache", State: models.ServiceState("pulling image")},
+				{Name: "nginx", CurrentReleaseID: "rel_1", State: models.ServiceState("running")},
+			},
+		},
+	}, description.Applications)
+
+	// Without a bundle, only applications that have run on the device show
+	description = describeDevice(device, nil)
+	require.Len(t, description.Applications, 1)
+	require.Equal(t, "web", description.Applications[0].Name)
+	require.Empty(t, description.Applications[0].DesiredReleaseID)
+}
@@ -2,15 +2,19 @@ package device
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/client"
 	"github.com/deviceplane/cli/pkg/models"
 	"golang.org/x/sync/errgroup"
 
@@ -27,46 +31,122 @@ func deviceListAction(c *kingpin.ParseContext) error {
 
 		filters = append(filters, filter)
 	}
+	for _, selector := range *deviceSelectorListFlag {
+		selectorFilters, err := parseSelector(selector)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, selectorFilters...)
+	}
+
+	if *deviceLimitListFlag < 0 || *deviceLimitListFlag > client.MaxPageSize {
+		return fmt.Errorf("--limit must be between 1 and %d", client.MaxPageSize)
+	}
+	if *devicePageListFlag < 1 {
+		return errors.New("--page must be at least 1")
+	}
+	if *devicePageListFlag > 1 && *deviceLimitListFlag == 0 {
+		return errors.New("--page requires --limit")
+	}
 
-	devices, err := config.APIClient.ListDevices(context.TODO(), filters, *config.Flags.Project)
+	columns, err := parseDeviceColumns(*deviceColumnsListFlag)
 	if err != nil {
 		return err
 	}
 
+	if !*deviceWatchListFlag {
+		return listDevices(filters, columns)
+	}
+	if *deviceIntervalListFlag <= 0 {
+		return errors.New("--interval must be positive")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	ticker := time.NewTicker(*deviceIntervalListFlag)
+	defer ticker.Stop()
+
+	for {
+		cliutils.ClearScreen(config)
+		if err := listDevices(filters, columns); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func listDevices(filters []models.Filter, columns []deviceColumn) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	devices, err := fetchDevices(ctx, filters)
+	if err != nil {
+		return err
+	}
+	devices = filterDevicesByStatus(devices, *deviceStatusListFlag, *deviceOfflineAfterListFlag, time.Now())
+
 	if *deviceOutputFlag == cliutils.FormatTable {
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = column.header
+		}
+
 		table := cliutils.DefaultTable()
-		table.SetHeader([]string{"Name", "Status", "IP", "OS", "Labels", "Last Seen", "Created"})
+		table.SetHeader(headers)
 		for _, d := range devices {
-			createdStr := cliutils.DurafmtSince(d.CreatedAt).String() + " ago"
-			lastSeenStr := cliutils.DurafmtSince(d.LastSeenAt).String() + " ago"
-
-			labelsArr := make([]string, len(d.Labels))
-			i := 0
-			for k, v := range d.Labels {
-				labelsArr[i] = fmt.Sprintf("%s:%s", k, v)
-				i += 1
+			row := make([]string, len(columns))
+			for i, column := range columns {
+				row[i] = column.value(d)
 			}
-			labelsStr := strings.Join(labelsArr, "\n")
-
-			table.Append([]string{
-				d.Name,
-				string(d.Status),
-				d.Info.IPAddress,
-				d.Info.OSRelease.Name,
-				labelsStr,
-				lastSeenStr,
-				createdStr,
-			})
+			table.Append(row)
 		}
 		table.Render()
 		return nil
 	}
 
+	// Structured output only narrows down to columns that were asked for
+	if *deviceColumnsListFlag != "" {
+		return cliutils.PrintWithFormat(deviceRows(devices, columns), *deviceOutputFlag)
+	}
 	return cliutils.PrintWithFormat(devices, *deviceOutputFlag)
 }
 
+// fetchDevices returns every device, or only the --page of --limit devices
+// if a limit is set
+func fetchDevices(ctx context.Context, filters []models.Filter) ([]models.Device, error) {
+	if *deviceLimitListFlag == 0 {
+		return config.APIClient.ListDevices(ctx, filters, *config.Flags.Project)
+	}
+
+	// Pages are fetched by cursor, so earlier pages are walked through to
+	// find the requested one
+	var devices []models.Device
+	var after string
+	for page := 1; page <= *devicePageListFlag; page++ {
+		var err error
+		devices, err = config.APIClient.ListDevicesPage(ctx, filters, *config.Flags.Project, *deviceLimitListFlag, after)
+		if err != nil {
+			return nil, err
+		}
+		if len(devices) == 0 {
+			break
+		}
+		after = devices[len(devices)-1].ID
+	}
+	return devices, nil
+}
+
 func deviceRebootAction(c *kingpin.ParseContext) error {
-	err := config.APIClient.Reboot(context.TODO(), *config.Flags.Project, *deviceArg)
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	err := config.APIClient.Reboot(ctx, *config.Flags.Project, *deviceArg)
 	if err != nil {
 		return err
 	}
@@ -75,8 +155,23 @@ func deviceRebootAction(c *kingpin.ParseContext) error {
 	return nil
 }
 
+func deviceSyncAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	if err := config.APIClient.SyncDevice(ctx, *config.Flags.Project, *deviceArg); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is downloading its bundle\n", *deviceArg)
+	return nil
+}
+
 func deviceInspectAction(c *kingpin.ParseContext) error {
-	device, err := config.APIClient.GetDevice(context.TODO(), *config.Flags.Project, *deviceArg)
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	device, err := config.APIClient.GetDevice(ctx, *config.Flags.Project, *deviceArg)
 	if err != nil {
 		return err
 	}
@@ -84,6 +179,160 @@ func deviceInspectAction(c *kingpin.ParseContext) error {
 	return cliutils.PrintWithFormat(device, *deviceOutputFlag)
 }
 
+func deviceRenameAction(c *kingpin.ParseContext) error {
+	if err := cliutils.ValidateNewName("device", *newNameArg); err != nil {
+		return err
+	}
+
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	device, err := config.APIClient.RenameDevice(ctx, *config.Flags.Project, *deviceArg, *newNameArg)
+	if err == client.ErrDeviceNameAlreadyInUse {
+		return fmt.Errorf("a device named %s already exists", *newNameArg)
+	} else if err != nil {
+		return err
+	}
+
+	return cliutils.PrintWithFormat(device, *deviceOutputFlag)
+}
+
+func deviceLogsAction(c *kingpin.ParseContext) error {
+	ctx, connected, cancel := cliutils.NewStreamContext(config)
+	defer cancel()
+
+	logs, err := config.APIClient.GetServiceLogs(
+		ctx, *config.Flags.Project, *deviceArg, *applicationArg, *serviceArg,
+		*logsFollowFlag, *logsTailFlag, *logsSinceFlag,
+	)
+	if err = connected(err); err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	if _, err := io.Copy(os.Stdout, logs); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func deviceExecAction(c *kingpin.ParseContext) error {
+	if (*execApplicationFlag == "") != (*execServiceFlag == "") {
+		return errors.New("--application and --service must be given together")
+	}
+	if *execHostFlag == (*execServiceFlag != "") {
+		return errors.New("either --host or --application and --service must be given")
+	}
+
+	capability := models.CapabilityServiceExec
+	if *execHostFlag {
+		capability = models.CapabilityHostExec
+	}
+	if err := cliutils.CheckCapability(config, capability); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var exitCode int
+	var err error
+	if *execHostFlag {
+		exitCode, err = config.APIClient.ExecHost(
+			ctx, *config.Flags.Project, *deviceArg, *execCommandArg, os.Stdout, os.Stderr,
+		)
+	} else {
+		exitCode, err = config.APIClient.ExecService(
+			ctx, *config.Flags.Project, *deviceArg, *execApplicationFlag, *execServiceFlag,
+			*execCommandArg, os.Stdout, os.Stderr,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+	return nil
+}
+
+func deviceEventsAction(c *kingpin.ParseContext) error {
+	ctx, connected, cancel := cliutils.NewStreamContext(config)
+	defer cancel()
+
+	events, err := config.APIClient.GetDeviceEvents(
+		ctx, *config.Flags.Project, *deviceArg, *eventsFollowFlag, *eventsTypeFlag,
+	)
+	if err = connected(err); err != nil {
+		return err
+	}
+	defer events.Close()
+
+	// Events are streamed as JSON lines, which is also the JSON output
+	if *deviceOutputFlag == cliutils.FormatJSON {
+		if _, err := io.Copy(os.Stdout, events); err != nil && ctx.Err() == nil {
+			return err
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(events)
+	for {
+		var event models.AgentEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		source := event.ApplicationID
+		if event.Service != "" {
+			source += "/" + event.Service
+		}
+		if source == "" {
+			source = "-"
+		}
+
+		fmt.Printf("%s  %-22s  %s  %s\n",
+			event.Timestamp.Local().Format("2006-01-02 15:04:05"), event.Type, source, event.Message)
+	}
+}
+
+// sshConnectTimeout returns timeout in whole seconds for ssh's ConnectTimeout,
+// rounded up so that a timeout under a second isn't 0, which ssh takes as
+// no timeout
+func sshConnectTimeout(timeout time.Duration) int {
+	if timeout <= 0 {
+		return 0
+	}
+	return int((timeout + time.Second - 1) / time.Second)
+}
+
+// sshCommandArgs returns the arguments of the local ssh client connecting
+// through port. A remote command runs without a pseudo-terminal, so that its
+// output is passed through unchanged, like "ssh host command".
+func sshCommandArgs(port string, connectTimeout int, postSSH []string, command string) []string {
+	args := []string{
+		"-p", port,
+		"-o",
+		"NoHostAuthenticationForLocalhost yes",
+	}
+	if command != "" {
+		args = append(args, "-T")
+	}
+	args = append(args,
+		"127.0.0.1",
+		"-o",
+		fmt.Sprintf("ConnectTimeout=%d", connectTimeout),
+	)
+	args = append(args, postSSH...)
+	if command != "" {
+		args = append(args, "--", command)
+	}
+	return args
+}
+
 func deviceSSHAction(c *kingpin.ParseContext) error {
 	conn, err := config.APIClient.SSH(context.TODO(), *config.Flags.Project, *deviceArg)
 	if err != nil {
@@ -116,25 +365,23 @@ func deviceSSHAction(c *kingpin.ParseContext) error {
 		port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
 
 		_, postSSH := cliutils.GetSSHArgs(os.Args[1:])
-		sshArguments := append([]string{
-			"-p", port,
-			"-o",
-			"NoHostAuthenticationForLocalhost yes",
-			"127.0.0.1",
-			"-o",
-			fmt.Sprintf("ConnectTimeout=%d", *sshTimeoutFlag),
-		}, postSSH...)
+		sshArguments := sshCommandArgs(port, sshConnectTimeout(*config.Flags.Timeout), postSSH, *sshCommandFlag)
 
 		cmd := exec.CommandContext(
 			ctx,
 			"ssh",
 			sshArguments...,
 		)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		if err := cmd.Run(); err != nil {
+		var err error
+		if *sshRecordFlag != "" {
+			err = runRecorded(cmd, *sshRecordFlag, *deviceArg)
+		} else {
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			err = cmd.Run()
+		}
+		if err != nil {
 			if exitError, ok := err.(*exec.ExitError); ok {
 				os.Exit(exitError.ExitCode())
 				return nil
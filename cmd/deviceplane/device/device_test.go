@@ -0,0 +1,35 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHCommandArgs(t *testing.T) {
+	require.Equal(t, []string{
+		"-p", "2222",
+		"-o", "NoHostAuthenticationForLocalhost yes",
+		"127.0.0.1",
+		"-o", "ConnectTimeout=60",
+		"-L", "3000:localhost:3000",
+	}, sshCommandArgs("2222", 60, []string{"-L", "3000:localhost:3000"}, ""))
+
+	require.Equal(t, []string{
+		"-p", "2222",
+		"-o", "NoHostAuthenticationForLocalhost yes",
+		"-T",
+		"127.0.0.1",
+		"-o", "ConnectTimeout=60",
+		"--", "uptime -p",
+	}, sshCommandArgs("2222", 60, nil, "uptime -p"))
+}
+
+func TestSSHConnectTimeout(t *testing.T) {
+	require.Equal(t, 0, sshConnectTimeout(0))
+	require.Equal(t, 1, sshConnectTimeout(500*time.Millisecond))
+	require.Equal(t, 1, sshConnectTimeout(time.Second))
+	require.Equal(t, 2, sshConnectTimeout(1500*time.Millisecond))
+	require.Equal(t, 60, sshConnectTimeout(time.Minute))
+}
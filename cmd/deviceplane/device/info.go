@@ -0,0 +1,79 @@
+package device
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+func deviceInfoAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	device, err := config.APIClient.GetDevice(ctx, *config.Flags.Project, *deviceArg)
+	if err != nil {
+		return err
+	}
+
+	if *deviceOutputFlag == cliutils.FormatText {
+		printDeviceInfo(os.Stdout, device.Info, device.LastSeenAt)
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(device.Info, *deviceOutputFlag)
+}
+
+func printDeviceInfo(w io.Writer, info models.DeviceInfo, lastSeenAt time.Time) {
+	reported := "unknown"
+	if info.ReportedAt != nil {
+		reported = cliutils.DurafmtSince(*info.ReportedAt).String() + " ago"
+	}
+	lastSeen := "never"
+	if !lastSeenAt.IsZero() {
+		lastSeen = cliutils.DurafmtSince(lastSeenAt).String() + " ago"
+	}
+
+	ipAddresses := info.IPAddresses
+	if len(ipAddresses) == 0 && info.IPAddress != "" {
+		ipAddresses = []string{info.IPAddress}
+	}
+	memory := "unknown"
+	if info.MemoryTotalBytes != 0 {
+		memory = formatBytes(info.MemoryTotalBytes)
+	}
+
+	fmt.Fprintf(w, "Agent version: %s\n", orUnknown(info.AgentVersion))
+	fmt.Fprintf(w, "OS:            %s\n", orUnknown(info.OSRelease.PrettyName))
+	fmt.Fprintf(w, "Kernel:        %s\n", orUnknown(info.Kernel.Release))
+	fmt.Fprintf(w, "Architecture:  %s\n", orUnknown(info.Kernel.Architecture))
+	fmt.Fprintf(w, "Memory:        %s\n", memory)
+	fmt.Fprintf(w, "IP addresses:  %s\n", orUnknown(strings.Join(ipAddresses, ", ")))
+	fmt.Fprintf(w, "Reported:      %s (last seen %s)\n", reported, lastSeen)
+
+	if len(info.NetworkInterfaces) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Network interfaces:")
+	for _, networkInterface := range info.NetworkInterfaces {
+		state := "down"
+		if networkInterface.Up {
+			state = "up"
+		}
+		addresses := append(append([]string{}, networkInterface.IPv4Addresses...), networkInterface.IPv6Addresses...)
+		fmt.Fprintf(w, "  %s (%s, %s): %s\n", networkInterface.Name, state,
+			orUnknown(networkInterface.MACAddress), orNone(strings.Join(addresses, ", ")))
+	}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
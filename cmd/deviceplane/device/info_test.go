@@ -0,0 +1,51 @@
+package device
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintDeviceInfo(t *testing.T) {
+	var buf bytes.Buffer
+	printDeviceInfo(&buf, models.DeviceInfo{
+		AgentVersion:     "1.16.0",
+		IPAddress:        "10.0.0.5",
+		OSRelease:        models.OSRelease{PrettyName: "Ubuntu 20.04.1 LTS"},
+		Kernel:           models.KernelInfo{Release: "5.4.0-42-generic", Architecture: "aarch64"},
+		MemoryTotalBytes: 4 << 30,
+	}, time.Time{})
+
+	require.Equal(t, `Agent version: 1.16.0
+OS:            Ubuntu 20.04.1 LTS
+Kernel:        5.4.0-42-generic
+Architecture:  aarch64
+Memory:        4.0 GiB
+IP addresses:  10.0.0.5
+Reported:      unknown (last seen never)
+`, buf.String())
+}
+
+func TestPrintDeviceInfoNetworkInterfaces(t *testing.T) {
+	var buf bytes.Buffer
+	printDeviceInfo(&buf, models.DeviceInfo{
+		NetworkInterfaces: []models.NetworkInterface{
+			{
+				Name:          "eth0",
+				MACAddress:    "02:42:ac:11:00:02",
+				IPv4Addresses: []string{"172.17.0.2/16"},
+				IPv6Addresses: []string{"fe80::42:acff:fe11:2/64"},
+				Up:            true,
+			},
+			{Name: "wlan0"},
+		},
+	}, time.Time{})
+
+	require.Contains(t, buf.String(), `Network interfaces:
+  eth0 (up, 02:42:ac:11:00:02): 172.17.0.2/16, fe80::42:acff:fe11:2/64
+  wlan0 (down, unknown): none
+`)
+}
@@ -2,21 +2,69 @@ package device
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
 	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	"github.com/deviceplane/cli/pkg/client"
+	"github.com/deviceplane/cli/pkg/models"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	sshTimeoutFlag *int = &[]int{0}[0]
+	deviceArg      *string = &[]string{""}[0]
+	connectionArg  *string = &[]string{""}[0]
+	portArg                = &[]uint{0}[0]
+	applicationArg *string = &[]string{""}[0]
+	serviceArg     *string = &[]string{""}[0]
 
-	deviceArg     *string = &[]string{""}[0]
-	connectionArg *string = &[]string{""}[0]
-	portArg               = &[]uint{0}[0]
+	logsFollowFlag *bool          = &[]bool{false}[0]
+	logsTailFlag   *int           = &[]int{0}[0]
+	logsSinceFlag  *time.Duration = &[]time.Duration{0}[0]
 
-	deviceFilterListFlag *[]string = &[][]string{[]string{}}[0]
+	sshRecordFlag  *string = &[]string{""}[0]
+	sshCommandFlag *string = &[]string{""}[0]
+
+	execApplicationFlag *string   = &[]string{""}[0]
+	execServiceFlag     *string   = &[]string{""}[0]
+	execHostFlag        *bool     = &[]bool{false}[0]
+	execCommandArg      *[]string = &[][]string{[]string{}}[0]
+
+	eventsFollowFlag *bool     = &[]bool{false}[0]
+	eventsTypeFlag   *[]string = &[][]string{[]string{}}[0]
+
+	topOnceFlag     *bool          = &[]bool{false}[0]
+	topIntervalFlag *time.Duration = &[]time.Duration{0}[0]
+
+	labelArg          *string   = &[]string{""}[0]
+	labelDevicesArg   *[]string = &[][]string{[]string{}}[0]
+	labelAllFlag      *bool     = &[]bool{false}[0]
+	labelSelectorFlag *[]string = &[][]string{[]string{}}[0]
+	labelOutputFlag   *string   = &[]string{""}[0]
+
+	deviceFilterListFlag   *[]string = &[][]string{[]string{}}[0]
+	deviceSelectorListFlag *[]string = &[][]string{[]string{}}[0]
+
+	deviceStatusListFlag       *string        = &[]string{""}[0]
+	deviceOfflineAfterListFlag *time.Duration = &[]time.Duration{0}[0]
+
+	deviceWatchListFlag    *bool          = &[]bool{false}[0]
+	deviceIntervalListFlag *time.Duration = &[]time.Duration{0}[0]
+
+	deviceLimitListFlag *int = &[]int{0}[0]
+	devicePageListFlag  *int = &[]int{0}[0]
+
+	deviceColumnsListFlag *string = &[]string{""}[0]
+
+	newNameArg *string = &[]string{""}[0]
+
+	registrationTokenFlag *string = &[]string{""}[0]
+	registerStateDirFlag  *string = &[]string{""}[0]
+	registerOutputFlag    *string = &[]string{""}[0]
+
+	bundleFileArg    *string = &[]string{""}[0]
+	bundleOutputFlag *string = &[]string{""}[0]
 
 	deviceOutputFlag *string = &[]string{""}[0]
 
@@ -27,9 +75,22 @@ func Initialize(c *global.Config) {
 	config = c
 
 	deviceCmd := c.App.Command("device", "Manage devices.")
+	cliutils.RequireProject(config, deviceCmd)
 
 	deviceListCmd := deviceCmd.Command("list", "List devices.")
 	deviceListCmd.Flag("filter", `Label key/values used to filter devices. e.g. "--filter status=online --filter labels.location=hq2"`).StringsVar(deviceFilterListFlag)
+	deviceListCmd.Flag("selector", `Label selector devices must match. e.g. "--selector location=hq2,tier!=dev,gpu,!legacy"`).Short('l').StringsVar(deviceSelectorListFlag)
+	deviceListCmd.Flag("status", "Only show devices with this status. (online, offline, all)").Default(statusAll).EnumVar(deviceStatusListFlag,
+		string(models.DeviceStatusOnline),
+		string(models.DeviceStatusOffline),
+		statusAll,
+	)
+	deviceListCmd.Flag("offline-after", "How long since a device was last seen before --status considers it offline.").Default("2m").DurationVar(deviceOfflineAfterListFlag)
+	deviceListCmd.Flag("limit", fmt.Sprintf("Only fetch this many devices, at most %d. All devices are fetched by default.", client.MaxPageSize)).IntVar(deviceLimitListFlag)
+	deviceListCmd.Flag("page", "Page of --limit devices to fetch, starting from 1.").Default("1").IntVar(devicePageListFlag)
+	deviceListCmd.Flag("watch", "Keep refreshing the list in place.").Short('w').BoolVar(deviceWatchListFlag)
+	deviceListCmd.Flag("interval", "How often --watch refreshes the list.").Default("5s").DurationVar(deviceIntervalListFlag)
+	deviceListCmd.Flag("columns", `Comma separated columns to show, in order. labels.<key> shows one label. e.g. "--columns name,status,labels.env,ip"`).StringVar(deviceColumnsListFlag)
 	cliutils.AddFormatFlag(deviceOutputFlag, deviceListCmd,
 		cliutils.FormatTable,
 		cliutils.FormatYAML,
@@ -40,8 +101,9 @@ func Initialize(c *global.Config) {
 
 	cliutils.GlobalAndCategorizedCmd(config.App, deviceCmd, func(attachmentPoint cliutils.HasCommand) {
 		deviceSSHCmd := attachmentPoint.Command("ssh", "SSH into a device.")
+		cliutils.PathVar(deviceSSHCmd.Flag("record", "Directory to save a recording of the session in, as an asciicast file. Must come before the device."), sshRecordFlag)
+		deviceSSHCmd.Flag("command", `Command to run instead of an interactive shell, exiting with its exit code. e.g. "ssh --command uptime my-device". Must come before the device.`).StringVar(sshCommandFlag)
 		addDeviceArg(deviceSSHCmd)
-		deviceSSHCmd.Flag("timeout", "Maximum length to attempt establishing a connection.").Default("60").IntVar(sshTimeoutFlag)
 		deviceSSHCmd.Action(deviceSSHAction)
 	})
 
@@ -53,6 +115,132 @@ func Initialize(c *global.Config) {
 	)
 	deviceInspectCmd.Action(deviceInspectAction)
 
+	deviceDescribeCmd := deviceCmd.Command("describe", "Show a device's properties, labels, and the releases and states of its applications and services.")
+	addDeviceArg(deviceDescribeCmd)
+	cliutils.AddFormatFlag(deviceOutputFlag, deviceDescribeCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+	deviceDescribeCmd.Action(deviceDescribeAction)
+
+	deviceInfoCmd := deviceCmd.Command("info", "Show the hardware, OS, and agent version a device last reported.")
+	addDeviceArg(deviceInfoCmd)
+	cliutils.AddFormatFlag(deviceOutputFlag, deviceInfoCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+	deviceInfoCmd.Action(deviceInfoAction)
+
+	deviceRegisterCmd := deviceCmd.Command("register", "Register a new device with a registration token, and print its ID and access key.")
+	deviceRegisterCmd.Flag("registration-token", "Device registration token ID.").Required().StringVar(registrationTokenFlag)
+	cliutils.PathVar(deviceRegisterCmd.Flag("state-dir", "Agent state directory to save the registration in, so an agent using it starts already registered."), registerStateDirFlag)
+	cliutils.AddFormatFlag(registerOutputFlag, deviceRegisterCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+	deviceRegisterCmd.Action(deviceRegisterAction)
+
+	deviceRenameCmd := deviceCmd.Command("rename", "Rename a device.")
+	addDeviceArg(deviceRenameCmd)
+	deviceRenameCmd.Arg("new-name", "New device name.").Required().StringVar(newNameArg)
+	cliutils.AddFormatFlag(deviceOutputFlag, deviceRenameCmd,
+		cliutils.FormatYAML,
+		cliutils.FormatJSON,
+	)
+	deviceRenameCmd.Action(deviceRenameAction)
+
+	deviceLogsCmd := deviceCmd.Command("logs", "Show the logs of a service running on a device.")
+	addDeviceArg(deviceLogsCmd)
+	addApplicationArg(deviceLogsCmd)
+	addServiceArg(deviceLogsCmd)
+	deviceLogsCmd.Flag("follow", "Follow log output.").Short('f').BoolVar(logsFollowFlag)
+	deviceLogsCmd.Flag("tail", "Number of lines to show from the end of the logs. (-1 for all)").Default("-1").IntVar(logsTailFlag)
+	deviceLogsCmd.Flag("since", `Show logs newer than a relative duration. e.g. "--since 10m"`).DurationVar(logsSinceFlag)
+	cliutils.RequireCapability(config, models.CapabilityServiceLogs, deviceLogsCmd)
+	deviceLogsCmd.Action(deviceLogsAction)
+
+	deviceExecCmd := deviceCmd.Command("exec", `Run a command inside one of a device's services, or on its host with --host. e.g. "exec my-device --application my-app --service my-service -- cat /etc/hostname"`)
+	addDeviceArg(deviceExecCmd)
+	deviceExecCmd.Flag("application", "Application of the service to run the command in.").Short('a').StringVar(execApplicationFlag)
+	deviceExecCmd.Flag("service", "Service to run the command in.").Short('s').StringVar(execServiceFlag)
+	deviceExecCmd.Flag("host", "Run the command on the device's host, as root, instead of in a service.").BoolVar(execHostFlag)
+	deviceExecCmd.Arg("command", "Command to run, after --.").Required().StringsVar(execCommandArg)
+	deviceExecCmd.Action(deviceExecAction)
+
+	deviceEventsCmd := deviceCmd.Command("events", "Show the events recorded by a device's agent.")
+	addDeviceArg(deviceEventsCmd)
+	deviceEventsCmd.Flag("follow", "Follow new events.").Short('f').BoolVar(eventsFollowFlag)
+	deviceEventsCmd.Flag("type", "Only show events of this type. Can be repeated.").HintOptions(
+		string(models.AgentEventBundleApplied),
+		string(models.AgentEventRollback),
+		string(models.AgentEventHookFailed),
+		string(models.AgentEventServiceStateChanged),
+		string(models.AgentEventServiceFailed),
+		string(models.AgentEventServiceCreated),
+		string(models.AgentEventServiceRemoved),
+		string(models.AgentEventServiceRestarted),
+		string(models.AgentEventValidationFailed),
+	).StringsVar(eventsTypeFlag)
+	cliutils.AddFormatFlag(deviceOutputFlag, deviceEventsCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+	)
+	cliutils.RequireCapability(config, models.CapabilityDeviceEvents, deviceEventsCmd)
+	deviceEventsCmd.Action(deviceEventsAction)
+
+	deviceTopCmd := deviceCmd.Command("top", "Show the live CPU and memory usage of a device's services.")
+	addDeviceArg(deviceTopCmd)
+	deviceTopCmd.Flag("once", "Print a single snapshot instead of refreshing.").BoolVar(topOnceFlag)
+	deviceTopCmd.Flag("interval", "How often to refresh. CPU usage is averaged over this interval.").Default("2s").DurationVar(topIntervalFlag)
+	cliutils.AddFormatFlag(deviceOutputFlag, deviceTopCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+	cliutils.RequireCapability(config, models.CapabilityServiceStats, deviceTopCmd)
+	deviceTopCmd.Action(deviceTopAction)
+
+	deviceConnectivityCmd := deviceCmd.Command("connectivity", "Check whether a device can be reached through the control plane, and how quickly.")
+	addDeviceArg(deviceConnectivityCmd)
+	cliutils.AddFormatFlag(deviceOutputFlag, deviceConnectivityCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+	)
+	cliutils.RequireCapability(config, models.CapabilityDeviceConnectivity, deviceConnectivityCmd)
+	deviceConnectivityCmd.Action(deviceConnectivityAction)
+
+	deviceBundleCmd := deviceCmd.Command("bundle", "Inspect the bundle a device is sent.")
+
+	deviceBundleGetCmd := deviceBundleCmd.Command("get", "Show the bundle a device is sent.")
+	addDeviceArg(deviceBundleGetCmd)
+	cliutils.AddFormatFlag(bundleOutputFlag, deviceBundleGetCmd,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+	cliutils.RequireCapability(config, models.CapabilityDeviceBundle, deviceBundleGetCmd)
+	deviceBundleGetCmd.Action(deviceBundleGetAction)
+
+	deviceBundleDiffCmd := deviceBundleCmd.Command("diff", "Compare the bundle a device is sent with a local JSON or YAML bundle file.")
+	addDeviceArg(deviceBundleDiffCmd)
+	deviceBundleDiffCmd.Arg("file", "Bundle file.").Required().ExistingFileVar(bundleFileArg)
+	cliutils.RequireCapability(config, models.CapabilityDeviceBundle, deviceBundleDiffCmd)
+	deviceBundleDiffCmd.Action(deviceBundleDiffAction)
+
+	deviceLabelCmd := deviceCmd.Command("label", "Manage device labels.")
+	addLabelSetCmd(deviceLabelCmd.Command("set", "Set a label on devices."))
+	addLabelRemoveCmd(deviceLabelCmd.Command("remove", "Remove a label from devices."))
+
+	addLabelSetCmd(deviceCmd.Command("set-label", "Set a label on devices."))
+	addLabelRemoveCmd(deviceCmd.Command("remove-label", "Remove a label from devices."))
+
+	deviceSyncCmd := deviceCmd.Command("sync", "Make a device download its bundle now, instead of at its next poll.")
+	addDeviceArg(deviceSyncCmd)
+	cliutils.RequireCapability(config, models.CapabilityDeviceSync, deviceSyncCmd)
+	deviceSyncCmd.Action(deviceSyncAction)
+
 	cliutils.GlobalAndCategorizedCmd(config.App, deviceCmd, func(attachmentPoint cliutils.HasCommand) {
 		deviceRebootCmd := attachmentPoint.Command("reboot", "Reboot a device.")
 		addDeviceArg(deviceRebootCmd)
@@ -63,7 +251,12 @@ func Initialize(c *global.Config) {
 func addDeviceArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
 	arg := cmd.Arg("device", "Device name.").Required()
 	arg.StringVar(deviceArg)
+	arg.PreAction(cliutils.RequireValidNames(config, "device", deviceArg))
 	arg.HintAction(func() []string {
+		if config.APIClient == nil {
+			return []string{}
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 		defer cancel()
 
@@ -72,7 +265,7 @@ func addDeviceArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
 			return []string{}
 		}
 
-		names := make([]string, len(devices))
+		names := make([]string, 0, len(devices))
 		for _, d := range devices {
 			names = append(names, d.Name)
 		}
@@ -81,12 +274,66 @@ func addDeviceArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
 	return arg
 }
 
+func addLabelSetCmd(cmd *kingpin.CmdClause) {
+	cmd.Arg("label", `Label to set. e.g. "location=hq2"`).Required().StringVar(labelArg)
+	addLabelTargetArgs(cmd)
+	cmd.Action(deviceLabelSetAction)
+}
+
+func addLabelRemoveCmd(cmd *kingpin.CmdClause) {
+	cmd.Arg("key", "Label key to remove.").Required().StringVar(labelArg)
+	addLabelTargetArgs(cmd)
+	cmd.Action(deviceLabelRemoveAction)
+}
+
+func addLabelTargetArgs(cmd *kingpin.CmdClause) {
+	cmd.Arg("device", "Device names or IDs.").StringsVar(labelDevicesArg)
+	cmd.Flag("all", "Apply to all devices matching --selector, or every device if none is given.").BoolVar(labelAllFlag)
+	cmd.Flag("selector", `Label selector choosing devices for --all, like that of "device list". e.g. "--selector location=hq2,!legacy"`).StringsVar(labelSelectorFlag)
+	cliutils.AddFormatFlag(labelOutputFlag, cmd,
+		cliutils.FormatTable,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+}
+
 func addConnectionArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
 	arg := cmd.Arg("connection", "Connection name.").Required()
 	arg.StringVar(connectionArg)
 	return arg
 }
 
+func addApplicationArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
+	arg := cmd.Arg("application", "Application name.").Required()
+	arg.StringVar(applicationArg)
+	arg.HintAction(func() []string {
+		if config.APIClient == nil {
+			return []string{}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		applications, err := config.APIClient.ListApplications(ctx, *config.Flags.Project)
+		if err != nil {
+			return []string{}
+		}
+
+		names := make([]string, 0, len(applications))
+		for _, a := range applications {
+			names = append(names, a.Name)
+		}
+		return names
+	})
+	return arg
+}
+
+func addServiceArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
+	arg := cmd.Arg("service", "Service name.").Required()
+	arg.StringVar(serviceArg)
+	return arg
+}
+
 func addPortArg(cmd *kingpin.CmdClause) *kingpin.ArgClause {
 	arg := cmd.Arg("port", "Local port.").Required()
 	arg.UintVar(portArg)
@@ -0,0 +1,166 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/validator"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// labelRequest is validated with the same rules the API applies to label
+// writes, so mistakes are reported before any request is made
+type labelRequest struct {
+	Key   string `validate:"labelkey"`
+	Value string `validate:"labelvalue"`
+}
+
+type labelKeyRequest struct {
+	Key string `validate:"labelkey"`
+}
+
+const labelRules = "keys must be 1-100 letters, digits or dashes, and values 1-100 characters"
+
+type labelResult struct {
+	Device string `json:"device" yaml:"device"`
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value,omitempty" yaml:"value,omitempty"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func deviceLabelSetAction(c *kingpin.ParseContext) error {
+	i := strings.Index(*labelArg, "=")
+	if i == -1 {
+		return fmt.Errorf("label (%s) must be in the form key=value", *labelArg)
+	}
+	key, value := (*labelArg)[:i], (*labelArg)[i+1:]
+
+	if err := validator.Validate(labelRequest{Key: key, Value: value}); err != nil {
+		return fmt.Errorf("invalid label (%s): %s", *labelArg, labelRules)
+	}
+
+	return forEachLabelTarget(key, value, func(device string) error {
+		ctx, cancel := cliutils.NewContext(config)
+		defer cancel()
+
+		return config.APIClient.SetDeviceLabel(ctx, *config.Flags.Project, device, key, value)
+	})
+}
+
+func deviceLabelRemoveAction(c *kingpin.ParseContext) error {
+	key := *labelArg
+
+	if err := validator.Validate(labelKeyRequest{Key: key}); err != nil {
+		return fmt.Errorf("invalid label key (%s): %s", key, labelRules)
+	}
+
+	return forEachLabelTarget(key, "", func(device string) error {
+		ctx, cancel := cliutils.NewContext(config)
+		defer cancel()
+
+		return config.APIClient.DeleteDeviceLabel(ctx, *config.Flags.Project, device, key)
+	})
+}
+
+// forEachLabelTarget calls f for each device named on the command line, or
+// for every device matching --selector when --all is set, and prints the
+// outcome for each. Every device is attempted even if some fail.
+func forEachLabelTarget(key, value string, f func(device string) error) error {
+	devices, err := labelTargets()
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	results := make([]labelResult, 0, len(devices))
+	for _, device := range devices {
+		result := labelResult{
+			Device: device,
+			Key:    key,
+			Value:  value,
+		}
+		if err := f(device); err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if err := printLabelResults(results); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed on %d of %d devices", failed, len(devices))
+	}
+	return nil
+}
+
+func printLabelResults(results []labelResult) error {
+	if *labelOutputFlag != cliutils.FormatTable {
+		return cliutils.PrintWithFormat(results, *labelOutputFlag)
+	}
+
+	table := cliutils.DefaultTable()
+	table.SetHeader([]string{"Device", "Label", "Result"})
+	for _, r := range results {
+		label := r.Key
+		if r.Value != "" {
+			label = r.Key + "=" + r.Value
+		}
+		outcome := "ok"
+		if r.Error != "" {
+			outcome = r.Error
+		}
+		table.Append([]string{r.Device, label, outcome})
+	}
+	table.Render()
+	return nil
+}
+
+func labelTargets() ([]string, error) {
+	if !*labelAllFlag {
+		if len(*labelDevicesArg) == 0 {
+			return nil, errors.New("specify one or more devices, or --all")
+		}
+		if len(*labelSelectorFlag) > 0 {
+			return nil, errors.New("--selector can only be used with --all")
+		}
+		for _, device := range *labelDevicesArg {
+			if err := cliutils.ValidateName("device", device); err != nil {
+				return nil, err
+			}
+		}
+		return *labelDevicesArg, nil
+	}
+
+	if len(*labelDevicesArg) > 0 {
+		return nil, errors.New("devices cannot be named when using --all")
+	}
+
+	var filters []models.Filter
+	for _, selector := range *labelSelectorFlag {
+		selectorFilters, err := parseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, selectorFilters...)
+	}
+
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	devices, err := config.APIClient.ListDevices(ctx, filters, *config.Flags.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(devices))
+	for _, d := range devices {
+		names = append(names, d.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,134 @@
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recorder writes a terminal session in the asciicast v2 format. Writes
+// never fail, so that a broken recording doesn't end the session; the first
+// error is kept in err instead.
+type recorder struct {
+	lock  sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+	// partial holds the start of a character split between writes
+	partial []byte
+}
+
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+func newRecorder(w io.Writer, width, height int, start time.Time) (*recorder, error) {
+	header, err := json.Marshal(asciicastHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Env: map[string]string{
+			"TERM": os.Getenv("TERM"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return nil, err
+	}
+
+	return &recorder{
+		w:     w,
+		start: start,
+	}, nil
+}
+
+// createRecordingFile creates a new file for a session with device in dir
+func createRecordingFile(dir, device string, start time.Time) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s.cast", device, start.UTC().Format("20060102T150405Z"))
+	return os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+}
+
+// Write records output. A multi-byte character split between writes is
+// recorded once it's complete, since the recording is of strings.
+func (r *recorder) Write(p []byte) (int, error) {
+	t := time.Now()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	data := append(r.partial, p...)
+	n := completeRunesLength(data)
+	r.partial = append([]byte(nil), data[n:]...)
+	if n > 0 {
+		r.writeEvent(t, "o", string(data[:n]))
+	}
+	return len(p), nil
+}
+
+// completeRunesLength returns the length of p without a character cut off at
+// its end
+func completeRunesLength(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i > len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return len(p)
+			}
+			return i
+		}
+	}
+	return len(p)
+}
+
+// Resize records a change in the terminal's size
+func (r *recorder) Resize(width, height int) {
+	r.event(time.Now(), "r", fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *recorder) event(t time.Time, eventType, data string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.writeEvent(t, eventType, data)
+}
+
+// writeEvent must be called with the lock held
+func (r *recorder) writeEvent(t time.Time, eventType, data string) {
+	line, err := json.Marshal([]interface{}{t.Sub(r.start).Seconds(), eventType, data})
+	if r.err != nil {
+		return
+	}
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	r.err = err
+}
+
+func (r *recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// recordingResult returns the error of the recorded command, after warning
+// about any error writing the recording
+func recordingResult(err error, rec *recorder, filename string) error {
+	if recErr := rec.Err(); recErr != nil {
+		if warnErr := config.Logger.Warnf("the session recording in %s is incomplete: %v", filename, recErr); warnErr != nil && err == nil {
+			return warnErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.Unix(1600000000, 0)
+	rec, err := newRecorder(&buf, 80, 24, start)
+	require.NoError(t, err)
+
+	rec.event(start.Add(1500*time.Millisecond), "o", "uptime\r\n")
+	rec.event(start.Add(2*time.Second), "r", "120x40")
+	require.NoError(t, rec.Err())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+
+	var header asciicastHeader
+	require.NoError(t, json.Unmarshal(lines[0], &header))
+	require.Equal(t, 2, header.Version)
+	require.Equal(t, 80, header.Width)
+	require.Equal(t, 24, header.Height)
+	require.Equal(t, int64(1600000000), header.Timestamp)
+
+	require.JSONEq(t, `[1.5, "o", "uptime\r\n"]`, string(lines[1]))
+	require.JSONEq(t, `[2, "r", "120x40"]`, string(lines[2]))
+}
+
+func TestRecorderSplitCharacters(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := newRecorder(&buf, 80, 24, time.Now())
+	require.NoError(t, err)
+
+	output := []byte("héllo ✓\n")
+	for _, chunk := range [][]byte{output[:2], output[2:8], output[8:]} {
+		n, err := rec.Write(chunk)
+		require.NoError(t, err)
+		require.Equal(t, len(chunk), n)
+	}
+	require.NoError(t, rec.Err())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var recorded string
+	for _, line := range lines[1:] {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal(line, &event))
+		recorded += event[2].(string)
+	}
+	require.Equal(t, string(output), recorded)
+}
@@ -0,0 +1,89 @@
+//go:build !windows
+// +build !windows
+
+package device
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kr/pty"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// runRecorded runs cmd attached to the terminal, recording what it prints to
+// a new file in dir. When stdin is a terminal, cmd is given its own pty that
+// follows the terminal's size.
+func runRecorded(cmd *exec.Cmd, dir, device string) error {
+	start := time.Now()
+	f, err := createRecordingFile(dir, device, start)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stdinFd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(stdinFd) {
+		rec, err := newRecorder(f, 80, 24, start)
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = io.MultiWriter(os.Stdout, rec)
+		cmd.Stderr = io.MultiWriter(os.Stderr, rec)
+		err = cmd.Run()
+		return recordingResult(err, rec, f.Name())
+	}
+
+	width, height, err := terminal.GetSize(stdinFd)
+	if err != nil {
+		return err
+	}
+	rec, err := newRecorder(f, width, height, start)
+	if err != nil {
+		return err
+	}
+
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(height), Cols: uint16(width)})
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	resizes := make(chan os.Signal, 1)
+	resizesDone := make(chan struct{})
+	signal.Notify(resizes, syscall.SIGWINCH)
+	defer func() {
+		signal.Stop(resizes)
+		close(resizes)
+		<-resizesDone
+	}()
+	go func() {
+		defer close(resizesDone)
+		for range resizes {
+			if err := pty.InheritSize(os.Stdin, ptmx); err != nil {
+				continue
+			}
+			if width, height, err := terminal.GetSize(stdinFd); err == nil {
+				rec.Resize(width, height)
+			}
+		}
+	}()
+
+	oldState, err := terminal.MakeRaw(stdinFd)
+	if err != nil {
+		return err
+	}
+	defer terminal.Restore(stdinFd, oldState)
+
+	go io.Copy(ptmx, os.Stdin)
+	// Reading the pty fails once cmd exits and closes it
+	io.Copy(io.MultiWriter(os.Stdout, rec), ptmx)
+
+	err = cmd.Wait()
+	return recordingResult(err, rec, f.Name())
+}
@@ -0,0 +1,10 @@
+package device
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func runRecorded(cmd *exec.Cmd, dir, device string) error {
+	return errors.New("recording SSH sessions isn't supported on Windows")
+}
@@ -0,0 +1,73 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/file"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/pkg/errors"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// The files a registered agent keeps its credentials in, under
+// <state dir>/<project ID>. These must match the agent's.
+const (
+	agentAccessKeyFilename = "access-key"
+	agentDeviceIDFilename  = "device-id"
+)
+
+func deviceRegisterAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	// Registration is keyed by project ID, and only resolving a name needs
+	// an access key
+	projectID := *config.Flags.Project
+	if !strings.HasPrefix(projectID, "prj_") {
+		project, err := config.APIClient.GetProject(ctx, projectID)
+		if err != nil {
+			return errors.Wrap(err, "look up project")
+		}
+		projectID = project.ID
+	}
+
+	registration, err := config.APIClient.RegisterDevice(ctx, projectID, *registrationTokenFlag)
+	if err != nil {
+		return err
+	}
+
+	if *registerStateDirFlag != "" {
+		if err := saveAgentRegistration(*registerStateDirFlag, projectID, registration); err != nil {
+			return err
+		}
+	}
+
+	if *registerOutputFlag == cliutils.FormatText {
+		fmt.Printf("Device ID: %s\n", registration.DeviceID)
+		fmt.Printf("Access key: %s\n", registration.DeviceAccessKeyValue)
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(registration, *registerOutputFlag)
+}
+
+// saveAgentRegistration writes a registration where an agent started with
+// stateDir will find it, so it starts already registered
+func saveAgentRegistration(stateDir, projectID string, registration *models.RegisterDeviceResponse) error {
+	dir := path.Join(stateDir, projectID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "create state dir")
+	}
+	if err := file.WriteFileAtomic(path.Join(dir, agentAccessKeyFilename), []byte(registration.DeviceAccessKeyValue), 0600); err != nil {
+		return errors.Wrap(err, "save access key")
+	}
+	if err := file.WriteFileAtomic(path.Join(dir, agentDeviceIDFilename), []byte(registration.DeviceID), 0644); err != nil {
+		return errors.Wrap(err, "save device ID")
+	}
+	return nil
+}
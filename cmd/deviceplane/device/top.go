@@ -0,0 +1,169 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+type serviceUsage struct {
+	models.ServiceStats `yaml:",inline"`
+	CPUPercent          float64 `json:"cpuPercent" yaml:"cpuPercent"`
+}
+
+func deviceTopAction(c *kingpin.ParseContext) error {
+	if *topIntervalFlag <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	if *deviceOutputFlag != cliutils.FormatText && !*topOnceFlag {
+		return errors.New("--output other than text requires --once")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	applicationNames := getApplicationNames()
+
+	// CPU usage is the CPU time used between two samples, so even a single
+	// snapshot needs two
+	previous, previousAt, err := getServiceStats()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*topIntervalFlag)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, currentAt, err := getServiceStats()
+		if err != nil {
+			return err
+		}
+		usage := computeServiceUsage(previous, current, currentAt.Sub(previousAt))
+
+		if !*topOnceFlag {
+			cliutils.ClearScreen(config)
+		}
+		if *deviceOutputFlag != cliutils.FormatText {
+			return cliutils.PrintWithFormat(usage, *deviceOutputFlag)
+		}
+		renderServiceUsage(usage, applicationNames)
+
+		if *topOnceFlag {
+			return nil
+		}
+		previous, previousAt = current, currentAt
+	}
+}
+
+func getServiceStats() ([]models.ServiceStats, time.Time, error) {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	stats, err := config.APIClient.GetDeviceServiceStats(ctx, *config.Flags.Project, *deviceArg)
+	return stats, time.Now(), err
+}
+
+// getApplicationNames maps application IDs to names. Services of
+// applications that can't be listed are shown by application ID instead.
+func getApplicationNames() map[string]string {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	names := make(map[string]string)
+	applications, err := config.APIClient.ListApplications(ctx, *config.Flags.Project)
+	if err != nil {
+		return names
+	}
+	for _, application := range applications {
+		names[application.ID] = application.Name
+	}
+	return names
+}
+
+// computeServiceUsage pairs each service in current with its previous stats
+// to compute its CPU usage over elapsed, and sorts the services by CPU usage.
+// Services that weren't running in both samples, or whose container was
+// replaced in between, show no CPU usage.
+func computeServiceUsage(previous, current []models.ServiceStats, elapsed time.Duration) []serviceUsage {
+	type serviceKey struct {
+		applicationID, service string
+	}
+	previousCPU := make(map[serviceKey]float64)
+	for _, stats := range previous {
+		previousCPU[serviceKey{stats.ApplicationID, stats.Service}] = stats.CPUSeconds
+	}
+
+	usage := make([]serviceUsage, 0, len(current))
+	for _, stats := range current {
+		u := serviceUsage{
+			ServiceStats: stats,
+		}
+		cpuSeconds, ok := previousCPU[serviceKey{stats.ApplicationID, stats.Service}]
+		if ok && elapsed > 0 && stats.CPUSeconds >= cpuSeconds {
+			u.CPUPercent = (stats.CPUSeconds - cpuSeconds) / elapsed.Seconds() * 100
+		}
+		usage = append(usage, u)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].CPUPercent != usage[j].CPUPercent {
+			return usage[i].CPUPercent > usage[j].CPUPercent
+		}
+		if usage[i].ApplicationID != usage[j].ApplicationID {
+			return usage[i].ApplicationID < usage[j].ApplicationID
+		}
+		return usage[i].Service < usage[j].Service
+	})
+	return usage
+}
+
+func renderServiceUsage(usage []serviceUsage, applicationNames map[string]string) {
+	table := cliutils.DefaultTable()
+	table.SetHeader([]string{"Application", "Service", "CPU", "Memory", "Memory Limit"})
+	for _, u := range usage {
+		application, ok := applicationNames[u.ApplicationID]
+		if !ok {
+			application = u.ApplicationID
+		}
+		limit := "-"
+		if u.MemoryLimitBytes > 0 {
+			limit = formatBytes(u.MemoryLimitBytes)
+		}
+		table.Append([]string{
+			application,
+			u.Service,
+			fmt.Sprintf("%.1f%%", u.CPUPercent),
+			formatBytes(u.MemoryUsageBytes),
+			limit,
+		})
+	}
+	table.Render()
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
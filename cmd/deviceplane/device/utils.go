@@ -1,11 +1,14 @@
 package device
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/deviceplane/cli/pkg/models"
 	"github.com/deviceplane/cli/pkg/utils"
+	"github.com/deviceplane/cli/pkg/validator"
 )
 
 func parseTextFilter(text string) (models.Filter, error) {
@@ -88,3 +91,92 @@ func parseTextFilter(text string) (models.Filter, error) {
 
 	return nil, fmt.Errorf(`invalid or missing operator in filter "%s"`, text)
 }
+
+// parseSelector parses a label selector such as "location=hq2,tier!=dev,gpu,!legacy"
+// into filters that devices must all match. A bare key requires the label to
+// exist, and a key prefixed with "!" requires it not to.
+func parseSelector(text string) ([]models.Filter, error) {
+	var filters []models.Filter
+	for _, term := range strings.Split(text, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf(`empty term in selector "%s"`, text)
+		}
+
+		condition, err := parseSelectorTerm(term)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid term "%s" in selector "%s": %v`, term, text, err)
+		}
+		filters = append(filters, models.Filter{condition})
+	}
+	return filters, nil
+}
+
+func parseSelectorTerm(term string) (models.Condition, error) {
+	var condition models.Condition
+	var params interface{}
+
+	if i := strings.Index(term, "="); i != -1 {
+		key, value := term[:i], term[i+len("="):]
+		operator := models.OperatorIs
+		if strings.HasSuffix(key, "!") {
+			key = key[:len(key)-len("!")]
+			operator = models.OperatorIsNot
+		}
+		if err := validator.Validate(labelRequest{Key: key, Value: value}); err != nil {
+			return condition, errors.New(labelRules)
+		}
+
+		condition.Type = models.LabelValueCondition
+		params = models.LabelValueConditionParams{
+			Key:      key,
+			Operator: operator,
+			Value:    value,
+		}
+	} else {
+		key := term
+		operator := models.OperatorExists
+		if strings.HasPrefix(key, "!") {
+			key = key[len("!"):]
+			operator = models.OperatorNotExists
+		}
+		if err := validator.Validate(labelKeyRequest{Key: key}); err != nil {
+			return condition, errors.New(labelRules)
+		}
+
+		condition.Type = models.LabelExistenceCondition
+		params = models.LabelExistenceConditionParams{
+			Key:      key,
+			Operator: operator,
+		}
+	}
+
+	if err := utils.JSONConvert(params, &condition.Params); err != nil {
+		return condition, err
+	}
+	return condition, nil
+}
+
+// statusAll is the --status value that disables filtering by status
+const statusAll = "all"
+
+// filterDevicesByStatus keeps the devices with the given status, judging a
+// device online if it was seen within offlineAfter of now. The status of
+// each kept device is updated to match.
+func filterDevicesByStatus(devices []models.Device, status string, offlineAfter time.Duration, now time.Time) []models.Device {
+	if status == statusAll {
+		return devices
+	}
+
+	var filtered []models.Device
+	for _, d := range devices {
+		d.Status = models.DeviceStatusOffline
+		if now.Sub(d.LastSeenAt) <= offlineAfter {
+			d.Status = models.DeviceStatusOnline
+		}
+		if string(d.Status) == status {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,106 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelector(t *testing.T) {
+	filters, err := parseSelector("location=hq2, tier!=dev,gpu,!legacy")
+	require.NoError(t, err)
+	require.Len(t, filters, 4)
+
+	expected := []struct {
+		conditionType models.ConditionType
+		key           string
+		operator      models.Operator
+	}{
+		{models.LabelValueCondition, "location", models.OperatorIs},
+		{models.LabelValueCondition, "tier", models.OperatorIsNot},
+		{models.LabelExistenceCondition, "gpu", models.OperatorExists},
+		{models.LabelExistenceCondition, "legacy", models.OperatorNotExists},
+	}
+	for i, e := range expected {
+		require.Len(t, filters[i], 1)
+		require.Equal(t, e.conditionType, filters[i][0].Type)
+		require.Equal(t, e.key, filters[i][0].Params["key"])
+		require.Equal(t, string(e.operator), filters[i][0].Params["operator"])
+	}
+
+	for _, invalid := range []string{"", "a,,b", "a b=c", "=x", "a=", "!"} {
+		_, err := parseSelector(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestFilterDevicesByStatus(t *testing.T) {
+	now := time.Now()
+	devices := []models.Device{
+		{Name: "fresh", LastSeenAt: now.Add(-30 * time.Second), Status: models.DeviceStatusOffline},
+		{Name: "stale", LastSeenAt: now.Add(-10 * time.Minute), Status: models.DeviceStatusOnline},
+	}
+
+	online := filterDevicesByStatus(devices, "online", 2*time.Minute, now)
+	require.Len(t, online, 1)
+	require.Equal(t, "fresh", online[0].Name)
+	require.Equal(t, models.DeviceStatusOnline, online[0].Status)
+
+	offline := filterDevicesByStatus(devices, "offline", 2*time.Minute, now)
+	require.Len(t, offline, 1)
+	require.Equal(t, "stale", offline[0].Name)
+
+	require.Len(t, filterDevicesByStatus(devices, "offline", time.Hour, now), 0)
+	require.Equal(t, devices, filterDevicesByStatus(devices, statusAll, time.Minute, now))
+}
+
+func TestBundleDiff(t *testing.T) {
+	current, err := canonicalBundle([]byte(`{"deviceName":"pi","desiredAgentVersion":"1.2","environmentVariables":{"A":"1"}}`))
+	require.NoError(t, err)
+	local, err := canonicalBundle([]byte("deviceName: pi\nenvironmentVariables:\n  A: \"2\"\ndesiredAgentVersion: \"1.2\"\n"))
+	require.NoError(t, err)
+
+	require.Equal(t, ` desiredAgentVersion: "1.2"
+ deviceName: pi
+ environmentVariables:
+-  A: "1"
++  A: "2"
+`, diffLines(splitLines(current), splitLines(local)))
+
+	require.Equal(t, "", diffLines(splitLines(current), splitLines(current)))
+}
+
+func TestComputeServiceUsage(t *testing.T) {
+	previous := []models.ServiceStats{
+		{ApplicationID: "app_1", Service: "web", CPUSeconds: 10},
+		{ApplicationID: "app_1", Service: "worker", CPUSeconds: 5},
+		{ApplicationID: "app_2", Service: "db", CPUSeconds: 100},
+	}
+	current := []models.ServiceStats{
+		{ApplicationID: "app_1", Service: "web", CPUSeconds: 11},
+		{ApplicationID: "app_1", Service: "worker", CPUSeconds: 6.5},
+		// Restarted, so its CPU time went backwards
+		{ApplicationID: "app_2", Service: "db", CPUSeconds: 1},
+		// New since the previous sample
+		{ApplicationID: "app_2", Service: "cache", CPUSeconds: 3},
+	}
+
+	usage := computeServiceUsage(previous, current, 2*time.Second)
+	require.Len(t, usage, 4)
+	require.Equal(t, "worker", usage[0].Service)
+	require.InDelta(t, 75, usage[0].CPUPercent, 0.001)
+	require.Equal(t, "web", usage[1].Service)
+	require.InDelta(t, 50, usage[1].CPUPercent, 0.001)
+	require.Equal(t, "cache", usage[2].Service)
+	require.Zero(t, usage[2].CPUPercent)
+	require.Equal(t, "db", usage[3].Service)
+	require.Zero(t, usage[3].CPUPercent)
+}
+
+func TestFormatBytes(t *testing.T) {
+	require.Equal(t, "512 B", formatBytes(512))
+	require.Equal(t, "1.5 KiB", formatBytes(1536))
+	require.Equal(t, "256.0 MiB", formatBytes(256*1024*1024))
+}
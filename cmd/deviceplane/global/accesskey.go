@@ -0,0 +1,18 @@
+package global
+
+// AccessKeyEnvVar is the environment variable the access key is read from
+// when neither --access-key nor --access-key-file is passed
+const AccessKeyEnvVar = "DEVICEPLANE_ACCESS_KEY"
+
+// AccessKeySource is where the access key a command authenticates with was
+// found
+type AccessKeySource string
+
+const (
+	AccessKeySourceNone   = AccessKeySource("")
+	AccessKeySourceFlag   = AccessKeySource("--access-key")
+	AccessKeySourceStdin  = AccessKeySource("stdin")
+	AccessKeySourceFile   = AccessKeySource("--access-key-file")
+	AccessKeySourceEnv    = AccessKeySource(AccessKeyEnvVar)
+	AccessKeySourceConfig = AccessKeySource("config file")
+)
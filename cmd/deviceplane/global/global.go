@@ -2,10 +2,12 @@ package global
 
 import (
 	"net/url"
+	"time"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/deviceplane/cli/pkg/client"
+	"github.com/deviceplane/cli/pkg/models"
 )
 
 type Config struct {
@@ -13,6 +15,21 @@ type Config struct {
 	ParsedCorrectly *bool
 	Flags           ConfigFlags
 	APIClient       *client.Client
+	Logger          *Logger
+
+	// ProjectSource is where Flags.Project was resolved from, see
+	// ResolveProject
+	ProjectSource ProjectSource
+
+	// AccessKeySource is where Flags.AccessKey was resolved from
+	AccessKeySource AccessKeySource
+
+	// APICapabilities is nil if the API could not be reached
+	APICapabilities *models.APICapabilities
+
+	// CommandsWithoutAPIClient holds the commands that run without an
+	// initialized API client, see cliutils.WithoutAPIClient
+	CommandsWithoutAPIClient map[*kingpin.CmdClause]bool
 }
 
 type ConfigFlags struct {
@@ -20,4 +37,20 @@ type ConfigFlags struct {
 	AccessKey   *string
 	Project     *string
 	ConfigFile  *string
+	Timeout     *time.Duration
+	Strict      *bool
+	Quiet       *bool
+	Verbose     *bool
+	NoColor     *bool
+
+	// AccessKeyFile is read into AccessKey when set
+	AccessKeyFile *string
+
+	CACert                *string
+	ClientCert            *string
+	ClientKey             *string
+	InsecureSkipTLSVerify *bool
+
+	// Proxy is empty to use HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	Proxy *string
 }
@@ -0,0 +1,46 @@
+package global
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Logger reports non-fatal problems to the user. The CLI currently warns
+// about:
+//   - unknown keys in the config file
+//   - an API version that differs from the one this CLI expects
+//   - TLS verification being disabled with --insecure-skip-tls-verify
+//   - an --access-key-file that is readable by all users
+//   - --project overriding a different DEVICEPLANE_PROJECT
+//
+// With --strict set, every warning is returned as an error instead so the
+// command exits non-zero. With --quiet set, warnings aren't printed.
+type Logger struct {
+	out    io.Writer
+	strict *bool
+	quiet  *bool
+}
+
+func NewLogger(out io.Writer, strict, quiet *bool) *Logger {
+	return &Logger{
+		out:    out,
+		strict: strict,
+		quiet:  quiet,
+	}
+}
+
+// Warnf prints a warning, or returns it as an error in strict mode. Callers
+// should return any error they get back.
+func (l *Logger) Warnf(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if l.strict != nil && *l.strict {
+		return errors.Errorf("%s (warnings are errors with --strict)", msg)
+	}
+	if l.quiet != nil && *l.quiet {
+		return nil
+	}
+	fmt.Fprintf(l.out, "Warning: %s\n", msg)
+	return nil
+}
@@ -0,0 +1,36 @@
+package global
+
+// ProjectEnvVar is the environment variable the project is read from when
+// --project isn't passed
+const ProjectEnvVar = "DEVICEPLANE_PROJECT"
+
+// ProjectSource is where the project a command runs against was found
+type ProjectSource string
+
+const (
+	ProjectSourceNone   = ProjectSource("")
+	ProjectSourceFlag   = ProjectSource("--project")
+	ProjectSourceEnv    = ProjectSource(ProjectEnvVar)
+	ProjectSourceConfig = ProjectSource("config file")
+)
+
+// ResolveProject picks the project from the first source that sets one, in
+// order of precedence:
+//  1. the --project flag
+//  2. the DEVICEPLANE_PROJECT environment variable
+//  3. the project key of the config file
+//
+// The CLI has no profiles, so there is no further default. If no source sets
+// a project, both return values are empty.
+func ResolveProject(flag, env, configFile string) (string, ProjectSource) {
+	switch {
+	case flag != "":
+		return flag, ProjectSourceFlag
+	case env != "":
+		return env, ProjectSourceEnv
+	case configFile != "":
+		return configFile, ProjectSourceConfig
+	default:
+		return "", ProjectSourceNone
+	}
+}
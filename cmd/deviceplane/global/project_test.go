@@ -0,0 +1,31 @@
+package global
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveProject(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		flag, env, file string
+		project         string
+		source          ProjectSource
+	}{
+		{"none", "", "", "", "", ProjectSourceNone},
+		{"flag", "a", "", "", "a", ProjectSourceFlag},
+		{"env", "", "b", "", "b", ProjectSourceEnv},
+		{"config file", "", "", "c", "c", ProjectSourceConfig},
+		{"flag over env", "a", "b", "", "a", ProjectSourceFlag},
+		{"flag over config file", "a", "", "c", "a", ProjectSourceFlag},
+		{"env over config file", "", "b", "c", "b", ProjectSourceEnv},
+		{"flag over all", "a", "b", "c", "a", ProjectSourceFlag},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			project, source := ResolveProject(tc.flag, tc.env, tc.file)
+			require.Equal(t, tc.project, project)
+			require.Equal(t, tc.source, source)
+		})
+	}
+}
@@ -1,35 +1,62 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"time"
 
+	"github.com/deviceplane/cli/cmd/deviceplane/application"
 	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
 	"github.com/deviceplane/cli/cmd/deviceplane/configure"
 	"github.com/deviceplane/cli/cmd/deviceplane/device"
 	"github.com/deviceplane/cli/cmd/deviceplane/global"
 	"github.com/deviceplane/cli/cmd/deviceplane/project"
+	"github.com/deviceplane/cli/pkg/client"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
 	version = "dev"
+
+	versionOutputFlag *string = &[]string{""}[0]
+	whoamiOutputFlag  *string = &[]string{""}[0]
 )
 
 var (
 	app = kingpin.New("deviceplane", "The Deviceplane CLI.").UsageTemplate(cliutils.CustomTemplate).Version(version)
 
+	quietFlag  = app.Flag("quiet", "Only print errors.").Short('q').Bool()
+	strictFlag = app.Flag("strict", "Treat warnings, such as unknown config keys or API version skew, as errors. (env: DEVICEPLANE_STRICT)").Envar("DEVICEPLANE_STRICT").Bool()
+
 	config = global.Config{
 		App:             app,
 		ParsedCorrectly: app.Flag("internal-parsing-validator", "").Hidden().Default("true").Bool(),
 
 		Flags: global.ConfigFlags{
 			APIEndpoint: app.Flag("url", "API Endpoint.").Hidden().Default("https://cloud.deviceplane.com:443/api").URL(),
-			AccessKey:   app.Flag("access-key", "Access key used for authentication. (env: DEVICEPLANE_ACCESS_KEY)").Envar("DEVICEPLANE_ACCESS_KEY").String(),
-			Project:     app.Flag("project", "Project name. (env: DEVICEPLANE_PROJECT)").Envar("DEVICEPLANE_PROJECT").String(),
-			ConfigFile:  app.Flag("config", "Config file to use.").Default("~/.deviceplane/config").String(),
+			AccessKey:   app.Flag("access-key", "Access key used for authentication, or - to read it from stdin. (env: DEVICEPLANE_ACCESS_KEY)").Envar(global.AccessKeyEnvVar).String(),
+			Project:     app.Flag("project", "Project name. (env: DEVICEPLANE_PROJECT)").String(),
+			ConfigFile:  cliutils.Path(app.Flag("config", "Config file to use. (default: config in $"+cliutils.ConfigDirEnvVar+" if it's set)").Default(cliutils.DefaultConfigFile())),
+			Timeout:     cliutils.Timeout(app.Flag("timeout", "Timeout for each API request, and for connecting SSH sessions and log and event streams, 0 to disable. (env: DEVICEPLANE_TIMEOUT)").Envar("DEVICEPLANE_TIMEOUT").Default("1m")),
+			Strict:      strictFlag,
+			Quiet:       quietFlag,
+			Verbose:     app.Flag("verbose", "Print debug logs, including the method, URL, status and duration of API requests.").Short('v').Bool(),
+			NoColor:     app.Flag("no-color", "Don't print color or other terminal escape codes. They're never printed when stdout isn't a terminal.").Bool(),
+
+			AccessKeyFile: cliutils.Path(app.Flag("access-key-file", "File containing the access key used for authentication. (env: DEVICEPLANE_ACCESS_KEY_FILE)").Envar("DEVICEPLANE_ACCESS_KEY_FILE")),
+
+			CACert:                cliutils.Path(app.Flag("ca-cert", "PEM bundle of additional CAs to trust for the API. (env: DEVICEPLANE_CA_CERT)").Envar("DEVICEPLANE_CA_CERT")),
+			ClientCert:            cliutils.Path(app.Flag("client-cert", "PEM client certificate for mutual TLS with the API. (env: DEVICEPLANE_CLIENT_CERT)").Envar("DEVICEPLANE_CLIENT_CERT")),
+			ClientKey:             cliutils.Path(app.Flag("client-key", "PEM private key of --client-cert. (env: DEVICEPLANE_CLIENT_KEY)").Envar("DEVICEPLANE_CLIENT_KEY")),
+			InsecureSkipTLSVerify: app.Flag("insecure-skip-tls-verify", "Skip TLS certificate verification for the API. Not recommended.").Bool(),
+
+			Proxy: app.Flag("proxy", "HTTP or SOCKS5 proxy for the API, e.g. socks5://proxy.example.com:1080. (default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY) (env: DEVICEPLANE_PROXY)").Envar("DEVICEPLANE_PROXY").String(),
 		},
 
 		APIClient: nil,
+		Logger:    global.NewLogger(os.Stderr, strictFlag, quietFlag),
 	}
 )
 
@@ -37,8 +64,54 @@ func main() {
 	configure.Initialize(&config)
 	project.Initialize(&config)
 	device.Initialize(&config)
+	application.Initialize(&config)
+	cliutils.AddCompletionCmd(&config)
 
+	versionCmd := cliutils.WithoutAPIClient(&config, app.Command("version", "Show the CLI version, and the API version of the server."))
+	cliutils.AddFormatFlag(versionOutputFlag, versionCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+	versionCmd.Action(versionAction)
+
+	whoamiCmd := cliutils.WithoutAPIClient(&config, app.Command("whoami", "Show the endpoint, project and access key in effect, where each came from, and who the access key belongs to."))
+	cliutils.AddFormatFlag(whoamiOutputFlag, whoamiCmd,
+		cliutils.FormatText,
+		cliutils.FormatJSON,
+		cliutils.FormatYAML,
+	)
+	whoamiCmd.Action(whoamiAction)
+
+	app.GetFlag("project").HintAction(projectHints)
+
+	app.PreAction(cliutils.InitializeLogging(&config))
+	app.PreAction(cliutils.InitializeTimeout(&config))
 	app.PreAction(cliutils.InitializeAPIClient(&config))
 	preSSH, _ := cliutils.GetSSHArgs(os.Args[1:])
-	kingpin.MustParse(app.Parse(preSSH))
+	command, err := app.Parse(cliutils.JoinStdinAccessKey(preSSH))
+	if errors.Is(err, client.ErrRequestTimedOut) {
+		err = fmt.Errorf("%w, use --timeout to wait longer", err)
+	}
+	kingpin.MustParse(command, err)
+}
+
+func projectHints() []string {
+	if config.APIClient == nil {
+		return []string{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	projects, err := config.APIClient.ListProjects(ctx, *config.Flags.Project)
+	if err != nil {
+		return []string{}
+	}
+
+	names := make([]string, 0, len(projects))
+	for _, p := range projects {
+		names = append(names, p.Name)
+	}
+	return names
 }
@@ -6,7 +6,12 @@ import (
 )
 
 var (
+	projectArg        *string = &[]string{""}[0]
 	projectOutputFlag *string = &[]string{""}[0]
+	projectYesFlag    *bool   = &[]bool{false}[0]
+
+	inviteEmailArg *string = &[]string{""}[0]
+	inviteRoleFlag *string = &[]string{""}[0]
 
 	config *global.Config
 )
@@ -26,5 +31,33 @@ func Initialize(c *global.Config) {
 	projectListCmd.Action(projectListAction)
 
 	projectCreateCmd := projectCmd.Command("create", "Create a new project.")
+	projectCreateCmd.Arg("name", "Project name. Defaults to --project.").StringVar(projectArg)
+	cliutils.AddFormatFlag(projectOutputFlag, projectCreateCmd,
+		cliutils.FormatTable,
+		cliutils.FormatYAML,
+		cliutils.FormatJSON,
+	)
 	projectCreateCmd.Action(projectCreateAction)
+
+	projectDeleteCmd := projectCmd.Command("delete", "Delete a project and everything in it.")
+	projectDeleteCmd.Arg("name", "Project name.").Required().PreAction(cliutils.RequireValidNames(config, "project", projectArg)).StringVar(projectArg)
+	projectDeleteCmd.Flag("yes", "Confirm deletion.").BoolVar(projectYesFlag)
+	projectDeleteCmd.Action(projectDeleteAction)
+
+	projectMembersCmd := projectCmd.Command("members", "Manage the members of --project.")
+	cliutils.RequireProject(config, projectMembersCmd)
+
+	projectMembersListCmd := projectMembersCmd.Command("list", "List project members and their roles.")
+	cliutils.AddFormatFlag(projectOutputFlag, projectMembersListCmd,
+		cliutils.FormatTable,
+		cliutils.FormatYAML,
+		cliutils.FormatJSON,
+	)
+	projectMembersListCmd.Action(projectMembersListAction)
+
+	projectInviteCmd := projectCmd.Command("invite", "Add a user to --project by email, with a role.")
+	cliutils.RequireProject(config, projectInviteCmd)
+	projectInviteCmd.Arg("email", "Email of the user to add. They must already have signed up.").Required().StringVar(inviteEmailArg)
+	projectInviteCmd.Flag("role", "Role to grant, one of the project's roles.").Required().HintOptions(defaultRoles...).StringVar(inviteRoleFlag)
+	projectInviteCmd.Action(projectInviteAction)
 }
@@ -0,0 +1,97 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/client"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// defaultRoles are the roles every project is created with
+var defaultRoles = []string{"admin-all", "write-all", "read-all"}
+
+func projectMembersListAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	memberships, err := config.APIClient.ListMemberships(ctx, *config.Flags.Project)
+	if err != nil {
+		return err
+	}
+
+	if *projectOutputFlag == cliutils.FormatTable {
+		table := cliutils.DefaultTable()
+		table.SetHeader([]string{"Name", "Email", "Roles", "Joined"})
+		for _, m := range memberships {
+			roles := make([]string, 0, len(m.Roles))
+			for _, role := range m.Roles {
+				roles = append(roles, role.Name)
+			}
+			sort.Strings(roles)
+
+			table.Append([]string{
+				m.User.Name,
+				m.User.Email,
+				strings.Join(roles, ", "),
+				cliutils.DurafmtSince(m.CreatedAt).String() + " ago",
+			})
+		}
+		table.Render()
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(memberships, *projectOutputFlag)
+}
+
+func projectInviteAction(c *kingpin.ParseContext) error {
+	if !strings.Contains(*inviteEmailArg, "@") {
+		return fmt.Errorf("%q is not an email address", *inviteEmailArg)
+	}
+
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	// Check the role first, so a mistyped role doesn't leave behind a member
+	// without it
+	if err := validateRole(ctx, *inviteRoleFlag); err != nil {
+		return err
+	}
+
+	membership, err := config.APIClient.CreateMembership(ctx, *config.Flags.Project, *inviteEmailArg)
+	if err == client.ErrUserNotFound {
+		return fmt.Errorf("no user has the email %s, they need to sign up before they can be invited", *inviteEmailArg)
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := config.APIClient.CreateMembershipRoleBinding(ctx, *config.Flags.Project, membership.UserID, *inviteRoleFlag); err != nil {
+		return fmt.Errorf("%s was added to %s, but granting the %s role failed: %v", *inviteEmailArg, *config.Flags.Project, *inviteRoleFlag, err)
+	}
+
+	fmt.Printf("Added %s to %s with the %s role\n", *inviteEmailArg, *config.Flags.Project, *inviteRoleFlag)
+	return nil
+}
+
+// validateRole checks that role is one of the project's roles. Projects can
+// have custom roles as well as the default ones.
+func validateRole(ctx context.Context, role string) error {
+	roles, err := config.APIClient.ListRoles(ctx, *config.Flags.Project)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		if r.Name == role {
+			return nil
+		}
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf("unknown role %q, must be one of: %s", role, strings.Join(names, ", "))
+}
@@ -1,15 +1,19 @@
 package project
 
 import (
-	"context"
+	"errors"
 	"fmt"
 
 	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 func projectListAction(c *kingpin.ParseContext) error {
-	projects, err := config.APIClient.ListProjects(context.TODO(), *config.Flags.Project)
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	projects, err := config.APIClient.ListProjects(ctx, *config.Flags.Project)
 	if err != nil {
 		return err
 	}
@@ -33,12 +37,64 @@ func projectListAction(c *kingpin.ParseContext) error {
 }
 
 func projectCreateAction(c *kingpin.ParseContext) error {
-	project, err := config.APIClient.CreateProject(context.TODO(), *config.Flags.Project)
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	name := *projectArg
+	if name == "" {
+		name = *config.Flags.Project
+	}
+	if name == "" {
+		return errors.New("project name is required")
+	}
+	if err := cliutils.ValidateNewName("project", name); err != nil {
+		return err
+	}
+
+	project, err := config.APIClient.CreateProject(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if *projectOutputFlag == cliutils.FormatTable {
+		fmt.Printf("Project %s successfully created at %s!\n", project.Name, project.CreatedAt.Format("Mon Jan _2 15:04:05 2006"))
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(project, *projectOutputFlag)
+}
+
+func projectDeleteAction(c *kingpin.ParseContext) error {
+	ctx, cancel := cliutils.NewContext(config)
+	defer cancel()
+
+	projects, err := config.APIClient.ListProjects(ctx, *projectArg)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Project %s successfully created at %s!\n", project.Name, project.CreatedAt.Format("Mon Jan _2 15:04:05 2006"))
+	var project *models.ProjectFull
+	for i, p := range projects {
+		if p.Name == *projectArg {
+			project = &projects[i]
+			break
+		}
+	}
+	if project == nil {
+		return fmt.Errorf("project (%s) not found", *projectArg)
+	}
+
+	fmt.Printf("Project %s has %d devices and %d applications.\n", project.Name, project.DeviceCounts.AllCount, project.ApplicationCounts.AllCount)
+
+	if !*projectYesFlag {
+		return errors.New("pass --yes to delete this project")
+	}
+
+	if err := config.APIClient.DeleteProject(ctx, project.Name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Project %s deleted.\n", project.Name)
 
 	return nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/pkg/models"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+type versionInfo struct {
+	ClientVersion    string `json:"clientVersion" yaml:"clientVersion"`
+	ClientAPIVersion string `json:"clientApiVersion" yaml:"clientApiVersion"`
+	// ServerAPIVersion is empty if the server couldn't be reached, or
+	// predates reporting its version
+	ServerAPIVersion string `json:"serverApiVersion,omitempty" yaml:"serverApiVersion,omitempty"`
+	ServerError      string `json:"serverError,omitempty" yaml:"serverError,omitempty"`
+}
+
+func versionAction(c *kingpin.ParseContext) error {
+	info := versionInfo{
+		ClientVersion:    version,
+		ClientAPIVersion: models.APIVersion,
+	}
+
+	if serverAPIVersion, err := getServerAPIVersion(); err != nil {
+		info.ServerError = err.Error()
+	} else {
+		info.ServerAPIVersion = serverAPIVersion
+	}
+
+	if *versionOutputFlag == cliutils.FormatText {
+		printVersionInfo(info)
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(info, *versionOutputFlag)
+}
+
+func getServerAPIVersion() (string, error) {
+	apiClient, err := cliutils.NewAPIClient(&config)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := cliutils.NewContext(&config)
+	defer cancel()
+
+	capabilities, err := apiClient.GetCapabilities(ctx)
+	if err != nil {
+		return "", err
+	}
+	return capabilities.APIVersion, nil
+}
+
+func printVersionInfo(info versionInfo) {
+	fmt.Printf("Client: %s (API version %s)\n", info.ClientVersion, info.ClientAPIVersion)
+
+	switch {
+	case info.ServerError != "":
+		fmt.Printf("Server: unknown, couldn't reach the server: %s\n", info.ServerError)
+	case info.ServerAPIVersion == "":
+		fmt.Printf("Server: unknown, the server doesn't report its version\n")
+	case info.ServerAPIVersion != info.ClientAPIVersion:
+		fmt.Printf("Server: API version %s, which doesn't match the client\n", info.ServerAPIVersion)
+	default:
+		fmt.Printf("Server: API version %s\n", info.ServerAPIVersion)
+	}
+}
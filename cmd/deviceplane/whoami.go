@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/deviceplane/cli/cmd/deviceplane/cliutils"
+	"github.com/deviceplane/cli/cmd/deviceplane/global"
+	"github.com/deviceplane/cli/pkg/models"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// whoamiInfo is the effective configuration, and where each value came from
+type whoamiInfo struct {
+	Endpoint        string                 `json:"endpoint" yaml:"endpoint"`
+	EndpointSource  string                 `json:"endpointSource" yaml:"endpointSource"`
+	ConfigFile      string                 `json:"configFile" yaml:"configFile"`
+	Project         string                 `json:"project,omitempty" yaml:"project,omitempty"`
+	ProjectSource   global.ProjectSource   `json:"projectSource,omitempty" yaml:"projectSource,omitempty"`
+	AccessKey       string                 `json:"accessKey,omitempty" yaml:"accessKey,omitempty"`
+	AccessKeySource global.AccessKeySource `json:"accessKeySource,omitempty" yaml:"accessKeySource,omitempty"`
+
+	// At most one of User and ServiceAccount is set. Neither is if the
+	// access key couldn't be checked, and IdentityError says why.
+	User           *models.User           `json:"user,omitempty" yaml:"user,omitempty"`
+	ServiceAccount *models.ServiceAccount `json:"serviceAccount,omitempty" yaml:"serviceAccount,omitempty"`
+	IdentityError  string                 `json:"identityError,omitempty" yaml:"identityError,omitempty"`
+}
+
+func whoamiAction(c *kingpin.ParseContext) error {
+	info := whoamiInfo{
+		Endpoint:        (*config.Flags.APIEndpoint).String(),
+		EndpointSource:  "default",
+		ConfigFile:      *config.Flags.ConfigFile,
+		Project:         *config.Flags.Project,
+		ProjectSource:   config.ProjectSource,
+		AccessKey:       maskAccessKey(*config.Flags.AccessKey),
+		AccessKeySource: config.AccessKeySource,
+	}
+	if cliutils.FlagPassed(c, "url") {
+		info.EndpointSource = "--url"
+	}
+
+	if *config.Flags.AccessKey == "" {
+		info.IdentityError = "no access key"
+	} else if user, serviceAccount, err := getMe(); err != nil {
+		info.IdentityError = err.Error()
+	} else {
+		info.User, info.ServiceAccount = user, serviceAccount
+	}
+
+	if *whoamiOutputFlag == cliutils.FormatText {
+		printWhoamiInfo(info)
+		return nil
+	}
+
+	return cliutils.PrintWithFormat(info, *whoamiOutputFlag)
+}
+
+func getMe() (*models.User, *models.ServiceAccount, error) {
+	apiClient, err := cliutils.NewAPIClient(&config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := cliutils.NewContext(&config)
+	defer cancel()
+
+	return apiClient.GetMe(ctx)
+}
+
+// maskAccessKey keeps enough of an access key to tell keys apart, without
+// printing a usable key
+func maskAccessKey(accessKey string) string {
+	const shown = 6
+	if accessKey == "" {
+		return ""
+	}
+	if len(accessKey) <= 2*shown {
+		return accessKey[:len(accessKey)/3] + "..."
+	}
+	return accessKey[:shown] + "..."
+}
+
+func printWhoamiInfo(info whoamiInfo) {
+	fmt.Printf("Endpoint:    %s (%s)\n", info.Endpoint, info.EndpointSource)
+	fmt.Printf("Config file: %s\n", info.ConfigFile)
+
+	if info.Project == "" {
+		fmt.Printf("Project:     none\n")
+	} else {
+		fmt.Printf("Project:     %s (from %s)\n", info.Project, info.ProjectSource)
+	}
+
+	if info.AccessKeySource == global.AccessKeySourceNone {
+		fmt.Printf("Access key:  none\n")
+	} else {
+		fmt.Printf("Access key:  %s (from %s)\n", info.AccessKey, info.AccessKeySource)
+	}
+
+	switch {
+	case info.User != nil:
+		fmt.Printf("Identity:    user %s (%s)\n", info.User.Name, info.User.ID)
+	case info.ServiceAccount != nil:
+		fmt.Printf("Identity:    service account %s (%s) of project %s\n",
+			info.ServiceAccount.Name, info.ServiceAccount.ID, info.ServiceAccount.ProjectID)
+	default:
+		fmt.Printf("Identity:    unknown, %s\n", info.IdentityError)
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskAccessKey(t *testing.T) {
+	require.Equal(t, "", maskAccessKey(""))
+	require.Equal(t, "u1a2b3...", maskAccessKey("u1a2b3c4d5e6f7g8h9"))
+	require.Equal(t, "ab...", maskAccessKey("abcdefg"))
+}
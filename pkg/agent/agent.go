@@ -5,14 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"os"
 	"path"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/deviceplane/cli/pkg/agent/client"
+	"github.com/deviceplane/cli/pkg/agent/events"
+	"github.com/deviceplane/cli/pkg/agent/hooks"
 	"github.com/deviceplane/cli/pkg/agent/info"
+	"github.com/deviceplane/cli/pkg/agent/logging"
 	"github.com/deviceplane/cli/pkg/agent/metrics"
 	"github.com/deviceplane/cli/pkg/agent/netns"
 	"github.com/deviceplane/cli/pkg/agent/server/local"
@@ -23,9 +30,15 @@ import (
 	"github.com/deviceplane/cli/pkg/agent/updater"
 	"github.com/deviceplane/cli/pkg/agent/validator"
 	"github.com/deviceplane/cli/pkg/agent/validator/customcommands"
+	"github.com/deviceplane/cli/pkg/agent/validator/environment"
 	"github.com/deviceplane/cli/pkg/agent/validator/image"
+	"github.com/deviceplane/cli/pkg/agent/validator/imagedigest"
+	"github.com/deviceplane/cli/pkg/agent/validator/network"
+	"github.com/deviceplane/cli/pkg/agent/validator/registry"
 	"github.com/deviceplane/cli/pkg/agent/variables"
 	"github.com/deviceplane/cli/pkg/agent/variables/fsnotify"
+	"github.com/deviceplane/cli/pkg/agent/variables/httppoll"
+	"github.com/deviceplane/cli/pkg/agent/variables/layered"
 	dpcontext "github.com/deviceplane/cli/pkg/context"
 	"github.com/deviceplane/cli/pkg/engine"
 	"github.com/deviceplane/cli/pkg/file"
@@ -34,49 +47,197 @@ import (
 )
 
 const (
-	accessKeyFilename = "access-key"
-	deviceIDFilename  = "device-id"
-	bundleFilename    = "bundle"
+	accessKeyFilename  = "access-key"
+	deviceIDFilename   = "device-id"
+	bundleFilename     = "bundle"
+	serverPortFilename = "server-port"
+	variablesFilename  = "variables.json"
+
+	listenTimeout = 30 * time.Second
+
+	// Registration is retried with jittered exponential backoff, since on
+	// first boot the network may not be up yet
+	registerTimeout        = 30 * time.Minute
+	registerInitialBackoff = 2 * time.Second
+	registerMaxBackoff     = 2 * time.Minute
+
+	// Number of recent events kept in memory for the events endpoint
+	eventLogSize = 1000
+
+	// Events are also appended to a rotating file in the state dir, so
+	// they outlive agent restarts
+	eventsFilename       = "events.jsonl"
+	eventsFileMaxSize    = 5 * 1024 * 1024
+	eventsFileMaxBackups = 2
+)
+
+// Defaults for how often the agent polls for its bundle, reports device info
+// and pushes metrics
+const (
+	DefaultBundlePollInterval  = 5 * time.Second
+	DefaultInfoReportInterval  = time.Minute
+	DefaultMetricsPushInterval = time.Minute
 )
 
 var (
-	errVersionNotSet = errors.New("version not set")
+	errVersionNotSet    = errors.New("version not set")
+	errNegativeInterval = errors.New("intervals can't be negative")
+	errOfflinePoll      = errors.New("agent is offline and doesn't download bundles")
 )
 
+// Options are the optional settings NewAgent needs at construction. Settings
+// that can be changed afterwards have setters instead. Zero values are the
+// defaults.
+type Options struct {
+	// ServerSocket is a Unix socket the local server listens on instead of
+	// the loopback port
+	ServerSocket string
+
+	// HardwareID is sent when registering, so that the device reclaims its
+	// existing registration if it has one. See the identity package.
+	HardwareID string
+
+	// VariablesURL is an https URL variables are fetched from, under the
+	// files in the conf dir. VariablesToken authenticates the agent to it,
+	// unless the client's certificate is used instead.
+	VariablesURL   string
+	VariablesToken string
+
+	BundlePollInterval  time.Duration
+	InfoReportInterval  time.Duration
+	MetricsPushInterval time.Duration
+}
+
+func (o *Options) setDefaults() error {
+	for _, interval := range []*time.Duration{
+		&o.BundlePollInterval,
+		&o.InfoReportInterval,
+		&o.MetricsPushInterval,
+	} {
+		if *interval < 0 {
+			return errNegativeInterval
+		}
+	}
+	if o.BundlePollInterval == 0 {
+		o.BundlePollInterval = DefaultBundlePollInterval
+	}
+	if o.InfoReportInterval == 0 {
+		o.InfoReportInterval = DefaultInfoReportInterval
+	}
+	if o.MetricsPushInterval == 0 {
+		o.MetricsPushInterval = DefaultMetricsPushInterval
+	}
+	return nil
+}
+
 type Agent struct {
 	client                 *client.Client // TODO: interface
+	engine                 engine.Engine
 	variables              variables.Interface
 	projectID              string
 	registrationToken      string
+	hardwareID             string
 	confDir                string
 	stateDir               string
 	serverPort             int
+	serverSocket           string
+	probeAddress           string
+	bundlePollInterval     time.Duration
+	pollRequests           chan struct{}
+	infoReportInterval     time.Duration
+	binaryPath             string
+	clockSyncTimeout       time.Duration
+	bundleFile             string
+	offline                bool
+	preseededAccessKeyFile string
+	preseededDeviceIDFile  string
+	livenessFile           string
+	livenessInterval       time.Duration
 	supervisor             *supervisor.Supervisor
+	eventLog               *events.Log
 	statusGarbageCollector *status.GarbageCollector
 	metricsPusher          *metrics.MetricsPusher
+	metricsExporter        *metrics.Exporter
 	infoReporter           *info.Reporter
+	hookRunner             *hooks.Runner
+	service                *service.Service
 	localServer            *local.Server
 	remoteServer           *remote.Server
 	updater                *updater.Updater
+
+	// bundleETag identifies the last downloaded bundle to the server, so
+	// that unchanged bundles aren't sent again
+	bundleETag string
+
+	appliedBundle         *models.Bundle
+	appliedBundleLock     sync.RWMutex
+	lastGoodBundle        *models.Bundle
+	rolledBackFingerprint string
+	hookedFingerprint     string
+	// failingSince is when services of the bundle with failingFingerprint
+	// started failing, or zero if they aren't
+	failingSince       time.Time
+	failingFingerprint string
+	// failedApplyFingerprint is the last bundle counted as a failed apply
+	failedApplyFingerprint string
+	// cancelPostApply stops waiting to run the post-apply hooks of a bundle
+	// that has been replaced
+	cancelPostApply context.CancelFunc
+	postApplyLock   sync.Mutex
+
+	healthLock                 sync.Mutex
+	registered                 bool
+	bundleLoaded               bool
+	lastBundleDownload         time.Time
+	bundleDownloadFailingSince time.Time
+	consecutiveWriteFailures   int
+	storageError               string
+	loopProgress               map[string]time.Time
 }
 
 func NewAgent(
 	client *client.Client, engine engine.Engine,
 	projectID, registrationToken, confDir, stateDir, version, binaryPath string, serverPort int,
+	options Options,
 ) (*Agent, error) {
 	if version == "" {
 		return nil, errVersionNotSet
 	}
+	if err := options.setDefaults(); err != nil {
+		return nil, err
+	}
 
 	if err := os.MkdirAll(confDir, 0700); err != nil {
 		return nil, err
 	}
 
-	variables := fsnotify.NewVariables(confDir)
-	if err := variables.Start(); err != nil {
+	fsnotifyVariables := fsnotify.NewVariables(confDir)
+	if err := fsnotifyVariables.Start(); err != nil {
 		return nil, errors.Wrap(err, "start fsnotify variables")
 	}
 
+	// Variables served from a URL are managed centrally. Files in the conf
+	// dir override them, and the last ones fetched are kept in the state dir
+	// for when the URL can't be reached.
+	var variables variables.Interface = fsnotifyVariables
+	if options.VariablesURL != "" {
+		httppollVariables := httppoll.NewVariables(options.VariablesURL, path.Join(stateDir, variablesFilename), httppoll.DefaultPollInterval)
+		httppollVariables.SetToken(options.VariablesToken)
+		httppollVariables.SetHTTPClient(client)
+		if err := httppollVariables.Start(); err != nil {
+			return nil, errors.Wrap(err, "start httppoll variables")
+		}
+		variables = layered.NewVariables(fsnotifyVariables, httppollVariables)
+	}
+
+	eventLog := events.NewLog(eventLogSize)
+	if err := persistEventLog(eventLog, stateDir); err != nil {
+		log.WithError(err).Error("persist event log, events will only be kept in memory")
+	}
+
+	netnsManager := netns.NewManager(engine)
+	netnsManager.Start()
+
 	supervisor := supervisor.NewSupervisor(
 		engine,
 		variables,
@@ -86,43 +247,103 @@ func NewAgent(
 			})
 		},
 		client.SetDeviceServiceStatus,
+		setDeviceServiceStatuses(client),
 		client.SetDeviceServiceState,
+		eventLog.Record,
 		[]validator.Validator{
 			image.NewValidator(variables),
+			imagedigest.NewValidator(variables),
+			registry.NewValidator(variables),
 			customcommands.NewValidator(variables),
+			environment.NewValidator(variables),
+			network.NewValidator(),
 		},
+		netnsManager.ProcessRequest,
 	)
 
-	netnsManager := netns.NewManager(engine)
-	netnsManager.Start()
-
 	serviceMetricsFetcher := metrics.NewServiceMetricsFetcher(
 		supervisor,
 		netnsManager,
+		engine,
 	)
 
-	service := service.NewService(variables, supervisor, engine, confDir, serviceMetricsFetcher)
-
-	return &Agent{
-		client:            client,
-		variables:         variables,
-		projectID:         projectID,
-		registrationToken: registrationToken,
-		confDir:           confDir,
-		stateDir:          stateDir,
-		serverPort:        serverPort,
-		supervisor:        supervisor,
+	var agent *Agent
+	service := service.NewService(variables, supervisor, engine, confDir, serviceMetricsFetcher, eventLog, func() error {
+		return agent.requestPoll()
+	})
+	metricsExporter := metrics.NewExporter(serviceMetricsFetcher)
+
+	agent = &Agent{
+		client:             client,
+		engine:             engine,
+		variables:          variables,
+		projectID:          projectID,
+		registrationToken:  registrationToken,
+		hardwareID:         options.HardwareID,
+		confDir:            confDir,
+		stateDir:           stateDir,
+		serverPort:         serverPort,
+		serverSocket:       options.ServerSocket,
+		bundlePollInterval: options.BundlePollInterval,
+		infoReportInterval: options.InfoReportInterval,
+		binaryPath:         binaryPath,
+		pollRequests:       make(chan struct{}, 1),
+		supervisor:         supervisor,
+		eventLog:           eventLog,
 		statusGarbageCollector: status.NewGarbageCollector(
 			client.DeleteDeviceApplicationStatus,
 			client.DeleteDeviceServiceStatus,
 			client.DeleteDeviceServiceState,
 		),
-		metricsPusher: metrics.NewMetricsPusher(client, serviceMetricsFetcher),
-		infoReporter:  info.NewReporter(client, version),
-		localServer:   local.NewServer(service),
-		remoteServer:  remote.NewServer(client, service),
-		updater:       updater.NewUpdater(projectID, version, binaryPath),
-	}, nil
+		metricsPusher:   metrics.NewMetricsPusher(client, variables, serviceMetricsFetcher, metricsExporter, options.MetricsPushInterval),
+		metricsExporter: metricsExporter,
+		infoReporter:    info.NewReporter(client, version),
+		hookRunner:      hooks.NewRunner(confDir),
+		service:         service,
+		remoteServer:    remote.NewServer(client, service, variables),
+		updater:         updater.NewUpdater(projectID, version, binaryPath, path.Join(stateDir, projectID), metricsExporter.IncUpdateFailures),
+	}
+	agent.localServer = local.NewServer(service, agent.health, agent.currentBundle, agent.requestPoll, agent.metricsExporter.Handler())
+
+	return agent, nil
+}
+
+// setDeviceServiceStatuses reports batched service statuses with the
+// supervisor's sentinel for servers that don't support them
+func setDeviceServiceStatuses(c *client.Client) func(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error {
+	return func(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error {
+		err := c.SetDeviceServiceStatuses(ctx, applicationID, req)
+		if err == client.ErrUnsupported {
+			return supervisor.ErrServiceStatusesUnsupported
+		}
+		return err
+	}
+}
+
+// persistEventLog loads the events saved by previous runs of the agent and
+// saves new events to the same file
+func persistEventLog(eventLog *events.Log, stateDir string) error {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return err
+	}
+	filename := path.Join(stateDir, eventsFilename)
+
+	if f, err := os.Open(filename); err == nil {
+		err = eventLog.Load(f)
+		f.Close()
+		if err != nil {
+			log.WithError(err).Error("load saved events")
+		}
+	} else if !os.IsNotExist(err) {
+		log.WithError(err).Error("open saved events")
+	}
+
+	file, err := logging.NewRotatingFile(filename, eventsFileMaxSize, eventsFileMaxBackups)
+	if err != nil {
+		return err
+	}
+	eventLog.Persist(file)
+	return nil
 }
 
 func (a *Agent) fileLocation(elem ...string) string {
@@ -135,18 +356,30 @@ func (a *Agent) fileLocation(elem ...string) string {
 }
 
 func (a *Agent) writeFile(contents []byte, elem ...string) error {
-	if err := os.MkdirAll(a.fileLocation(), 0700); err != nil {
-		return err
-	}
-	if err := file.WriteFileAtomic(a.fileLocation(elem...), contents, 0644); err != nil {
-		return err
+	err := os.MkdirAll(a.fileLocation(), 0700)
+	if err == nil {
+		err = file.WriteFileAtomic(a.fileLocation(elem...), contents, 0644)
 	}
-	return nil
+	a.recordWriteResult(err)
+	return err
 }
 
 func (a *Agent) Initialize() error {
+	a.waitForClock()
+
+	if a.preseededAccessKeyFile != "" {
+		if err := a.loadPreseededCredentials(); err != nil {
+			return err
+		}
+		a.setRegistered()
+		return a.initializeLocalServer()
+	}
+
 	if _, err := os.Stat(a.fileLocation(accessKeyFilename)); err == nil {
 		log.Info("device already registered")
+	} else if os.IsNotExist(err) && a.offline {
+		log.Info("device not registered, running offline")
+		return a.initializeLocalServer()
 	} else if os.IsNotExist(err) {
 		log.Info("registering device")
 		if err = a.register(); err != nil {
@@ -168,29 +401,133 @@ func (a *Agent) Initialize() error {
 
 	a.client.SetAccessKey(string(accessKeyBytes))
 	a.client.SetDeviceID(string(deviceIDBytes))
+	a.setRegistered()
+
+	return a.initializeLocalServer()
+}
+
+func (a *Agent) initializeLocalServer() error {
+	listener, err := a.listen()
+	if err != nil {
+		return errors.Wrap(err, "failed to start local server")
+	}
+	a.localServer.SetListener(listener)
+
+	return nil
+}
+
+// listen binds the local server, retrying for a bounded amount of time in
+// case a previous agent is still releasing the port. A serverPort of 0 lets
+// the OS pick a free port. Either way, the bound port is written to the
+// state dir so on-device tooling can discover it. If serverSocket is set the
+// server listens on that Unix socket instead.
+func (a *Agent) listen() (net.Listener, error) {
+	if a.serverSocket != "" {
+		return listenUnix(a.serverSocket)
+	}
 
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
+	timeout := time.After(listenTimeout)
+
 	for {
 		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", a.serverPort))
 		if err == nil {
-			a.localServer.SetListener(listener)
-			return nil
+			port := listener.Addr().(*net.TCPAddr).Port
+			if err := a.writeFile([]byte(strconv.Itoa(port)), serverPortFilename); err != nil {
+				log.WithError(err).Error("save server port")
+			}
+			return listener, nil
 		}
 
-		<-ticker.C
+		log.WithError(err).WithField("port", a.serverPort).Error("listen for local server")
+
+		select {
+		case <-ticker.C:
+			continue
+		case <-timeout:
+			return nil, errors.Wrapf(err, "could not listen on port %d after %s", a.serverPort, listenTimeout)
+		}
 	}
 }
 
-func (a *Agent) register() error {
-	ctx, cancel := dpcontext.New(context.Background(), time.Minute)
-	defer cancel()
+// listenUnix binds a Unix socket at path that only the agent's user can
+// connect to. A socket left behind by a previous agent is replaced.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, errors.Wrap(err, "remove stale socket")
+		}
+	}
 
-	registerDeviceResponse, err := a.client.RegisterDevice(ctx, a.registrationToken)
+	listener, err := net.Listen("unix", path)
 	if err != nil {
-		return errors.Wrap(err, "failed to register device")
+		return nil, errors.Wrapf(err, "could not listen on socket %s", path)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, errors.Wrap(err, "set socket permissions")
+	}
+
+	return listener, nil
+}
+
+func (a *Agent) register() error {
+	var registerDeviceResponse *models.RegisterDeviceResponse
+	if err := retryWithBackoff("register device", registerTimeout, func() (bool, error) {
+		var err error
+		registerDeviceResponse, err = a.registerOnce()
+		return true, err
+	}); err != nil {
+		return err
+	}
+	return a.saveRegistration(registerDeviceResponse)
+}
+
+// retryWithBackoff calls f until it succeeds, fails with retry set to false,
+// or timeout has passed, backing off like registration between attempts. A
+// zero timeout retries forever.
+func retryWithBackoff(action string, timeout time.Duration, f func() (retry bool, err error)) error {
+	deadline := time.Now().Add(timeout)
+	backoff := registerInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		retry, err := f()
+		if err == nil || !retry {
+			return err
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if timeout != 0 && time.Now().Add(wait).After(deadline) {
+			return errors.Wrapf(err, "gave up after %d attempts", attempt)
+		}
+
+		log.WithError(err).
+			WithField("attempt", attempt).
+			WithField("retry_in", wait.String()).
+			Error(action)
+
+		time.Sleep(wait)
+
+		backoff *= 2
+		if backoff > registerMaxBackoff {
+			backoff = registerMaxBackoff
+		}
 	}
+}
+
+func (a *Agent) registerOnce() (*models.RegisterDeviceResponse, error) {
+	ctx, cancel := dpcontext.NewDefault(context.Background())
+	defer cancel()
+
+	return a.client.RegisterDevice(ctx, a.registrationToken, a.hardwareID)
+}
+
+func (a *Agent) saveRegistration(registerDeviceResponse *models.RegisterDeviceResponse) error {
 	if err := a.writeFile([]byte(registerDeviceResponse.DeviceAccessKeyValue), accessKeyFilename); err != nil {
 		return errors.Wrap(err, "failed to save access key")
 	}
@@ -200,47 +537,275 @@ func (a *Agent) register() error {
 	return nil
 }
 
+// SetUpdateSoakPeriod sets how long a self-update has to report device info
+// before the agent rolls back to its previous version. Zero disables
+// rollbacks. Defaults to updater.DefaultSoakPeriod. Must be called before Run.
+func (a *Agent) SetUpdateSoakPeriod(soakPeriod time.Duration) {
+	a.updater.SetSoakPeriod(soakPeriod)
+}
+
+// SetRemoteRequestTimeout sets how long a request from the control plane can
+// take before it's cancelled, such as fetching stats or metrics. Streaming
+// requests like SSH, exec and followed logs aren't bounded, they end when the
+// control plane stops answering keepalives. Zero means the dpcontext default
+// timeout. Must be called before Run.
+func (a *Agent) SetRemoteRequestTimeout(timeout time.Duration) {
+	a.service.SetRequestTimeout(timeout)
+}
+
+// SetMaxConcurrentStarts bounds how many service containers the agent starts
+// at once, so that a large bundle doesn't thrash a small device. Zero or less
+// means no limit. Must be called before Run.
+func (a *Agent) SetMaxConcurrentStarts(maxConcurrentStarts int) {
+	a.supervisor.SetMaxConcurrentStarts(maxConcurrentStarts)
+}
+
+// SetReportLoopbackInterfaces sets whether loopback interfaces are included
+// in the network interfaces reported with the device's info. Must be called
+// before Run.
+func (a *Agent) SetReportLoopbackInterfaces(report bool) {
+	a.infoReporter.SetReportLoopbackInterfaces(report)
+}
+
+// SetRestartStablePeriod sets how long a service's container has to stay up
+// after a restart for its restart count to be reset. Zero or less means
+// supervisor.DefaultRestartStablePeriod. Must be called before Run.
+func (a *Agent) SetRestartStablePeriod(period time.Duration) {
+	a.supervisor.SetRestartStablePeriod(period)
+}
+
+// SetProbeAddress serves /v1/healthz and /v1/readyz on address, such as
+// ":8081", to any host, so that orchestrators and probes that can't reach the
+// loopback-only local API can check the agent. Empty disables it. Must be
+// called before Run.
+func (a *Agent) SetProbeAddress(address string) {
+	a.probeAddress = address
+}
+
 func (a *Agent) Run() {
+	logging.CycleLevelOnSignal(syscall.SIGUSR1)
+
+	a.updater.Start()
+	if a.offline {
+		// An offline agent never reports in, so there's nothing to wait for
+		a.updater.Confirm()
+	}
+
 	go a.runBundleApplier()
-	go a.runInfoReporter()
-	go a.runRemoteServer()
+	if !a.offline {
+		go a.runInfoReporter()
+		go a.runRemoteServer()
+	}
 	go a.runLocalServer()
+	if a.probeAddress != "" {
+		go a.runProbeServer()
+	}
+	if a.livenessFile != "" {
+		go a.runLivenessWriter()
+	}
 	select {}
 }
 
 func (a *Agent) runBundleApplier() {
-	bundle := a.loadSavedBundle()
+	a.markProgress(bundleApplierLoop)
+	a.lastGoodBundle = a.loadLastGoodBundle()
+	a.loadRollback()
+
+	bundle := a.seedBundle()
 	if bundle != nil {
-		a.supervisor.Set(*bundle, bundle.Applications)
+		// A saved bundle that was rolled back before the restart stays
+		// rolled back
+		applied := a.bundleToApply(*bundle)
+		a.resumeSupervisor(applied)
+		a.supervisor.Set(applied, applied.Applications)
+		a.setAppliedBundle(&applied)
+		a.setBundleLoaded()
+	}
+
+	// The metrics pusher isn't started offline, there's no control plane
+	// to push to
+	if a.offline {
+		a.runOffline()
+		return
 	}
 
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(a.bundlePollInterval)
 	defer ticker.Stop()
 
 	for {
-		bundle = a.downloadLatestBundle(bundle)
-		if bundle != nil {
-			a.supervisor.Set(*bundle, bundle.Applications)
+		a.markProgress(bundleApplierLoop)
+		// A failed download keeps the previous bundle, so that the next
+		// one is merged with it rather than with nothing
+		downloaded, changed, err := a.downloadLatestBundle(bundle)
+		a.setBundleDownloadResult(err == nil)
+		if err != nil {
+			log.WithError(err).Error("download bundle")
+			a.metricsExporter.IncBundleDownloadFailures()
+		} else {
+			bundle = downloaded
+			a.metricsExporter.BundleDownloaded(time.Now())
+		}
+		if changed {
+			applied := a.bundleToApply(*bundle)
+			a.setSupervisorBundle(applied)
+			a.setBundleLoaded()
 			a.statusGarbageCollector.SetBundle(*bundle)
 			a.updater.SetDesiredVersion(bundle.DesiredAgentVersion)
 			a.metricsPusher.SetBundle(*bundle)
 		}
 
+		a.checkBundleHealth()
+		a.metricsExporter.SetServiceCounts(a.supervisor.ServiceCounts())
+		a.metricsExporter.SetUpdatePending(a.updater.Pending())
+
 		select {
 		case <-ticker.C:
 			continue
+		case <-a.pollRequests:
+			log.Info("polling for bundle on request")
+			continue
+		}
+	}
+}
+
+// requestPoll makes the bundle applier download the bundle now instead of
+// waiting for its next poll. A request made while one is pending is merged
+// into it.
+func (a *Agent) requestPoll() error {
+	if a.offline {
+		return errOfflinePoll
+	}
+	select {
+	case a.pollRequests <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// setAppliedBundle records the bundle handed to the supervisor. It's only
+// called from the bundle applier, which reads appliedBundle without locking.
+func (a *Agent) setAppliedBundle(bundle *models.Bundle) {
+	a.appliedBundleLock.Lock()
+	a.appliedBundle = bundle
+	a.appliedBundleLock.Unlock()
+}
+
+// currentBundle returns the bundle the supervisor is running, or nil if none
+// has been applied yet
+func (a *Agent) currentBundle() *models.Bundle {
+	a.appliedBundleLock.RLock()
+	defer a.appliedBundleLock.RUnlock()
+	return a.appliedBundle
+}
+
+// setSupervisorBundle hands a bundle to the supervisor, running the bundle
+// hooks around it whenever the set of releases changes. Pre-apply hooks run
+// before the bundle is handed over. Post-apply hooks run in the background
+// once the supervisor reports every service running the bundle, and not at
+// all if the bundle is replaced first.
+func (a *Agent) setSupervisorBundle(bundle models.Bundle) {
+	fingerprint := bundleFingerprint(bundle)
+	if fingerprint == a.hookedFingerprint {
+		a.supervisor.Set(bundle, bundle.Applications)
+		a.setAppliedBundle(&bundle)
+		return
+	}
+
+	if a.cancelPostApply != nil {
+		a.cancelPostApply()
+	}
+
+	results := a.hookRunner.Run(context.Background(), hooks.StagePreApply)
+	a.supervisor.Set(bundle, bundle.Applications)
+	a.setAppliedBundle(&bundle)
+
+	a.hookedFingerprint = fingerprint
+
+	a.eventLog.Record(models.AgentEvent{
+		Type:    models.AgentEventBundleApplied,
+		Message: "releases " + fingerprint,
+	})
+	a.recordHookResults(results, results)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancelPostApply = cancel
+	go a.runPostApplyHooks(ctx, results)
+}
+
+// runPostApplyHooks waits for the supervisor to report every service running
+// the bundle just handed to it, then runs the post-apply hooks and reports
+// their results along with those of the pre-apply hooks
+func (a *Agent) runPostApplyHooks(ctx context.Context, preApplyResults []models.BundleHookResult) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		if healthy, _ := a.supervisor.Health(); healthy {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	// The hooks of successive bundles don't overlap, and a bundle replaced
+	// while waiting for them doesn't run its own
+	a.postApplyLock.Lock()
+	defer a.postApplyLock.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+
+	results := a.hookRunner.Run(context.Background(), hooks.StagePostApply)
+	a.recordHookResults(append(append([]models.BundleHookResult{}, preApplyResults...), results...), results)
+}
+
+// recordHookResults reports the results of a bundle's hooks so far, and
+// records the failures among those that just ran
+func (a *Agent) recordHookResults(results, ran []models.BundleHookResult) {
+	a.infoReporter.SetBundleHookResults(results)
+	for _, result := range ran {
+		if !result.Succeeded {
+			a.metricsExporter.IncHookFailures()
+			a.eventLog.Record(models.AgentEvent{
+				Type:    models.AgentEventHookFailed,
+				Message: result.Stage + " hook " + result.Name + " failed",
+			})
 		}
 	}
 }
 
+// resumeSupervisor checks whether the saved bundle was fully applied before
+// the agent restarted, in which case every service already has a container
+// of the bundle's generation and the bundle's hooks aren't run again
+func (a *Agent) resumeSupervisor(bundle models.Bundle) {
+	differing, err := a.supervisor.Resume(context.Background(), bundle)
+	if err != nil {
+		log.WithError(err).Error("compare saved bundle with containers, running its hooks")
+		return
+	}
+
+	if len(differing) == 0 {
+		log.Info("every service has a container of the saved bundle")
+		a.hookedFingerprint = bundleFingerprint(bundle)
+		return
+	}
+	log.WithField("services", differing).Info("services differ from the saved bundle")
+}
+
 func (a *Agent) loadSavedBundle() *models.Bundle {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
 	for {
 		if _, err := os.Stat(a.fileLocation(bundleFilename)); err == nil {
-			savedBundleBytes, err := ioutil.ReadFile(a.fileLocation(bundleFilename))
-			if err != nil {
+			savedBundleBytes, err := a.readFileWithChecksum(bundleFilename)
+			if err == errChecksumMismatch {
+				log.Error("discarding saved bundle that does not match its checksum")
+				return nil
+			} else if err != nil {
 				log.WithError(err).Error("read saved bundle")
 				goto cont
 			}
@@ -267,65 +832,187 @@ func (a *Agent) loadSavedBundle() *models.Bundle {
 	}
 }
 
-func (a *Agent) downloadLatestBundle(oldBundle *models.Bundle) *models.Bundle {
-	ctx, cancel := dpcontext.New(context.Background(), time.Minute)
+// downloadLatestBundle returns the latest bundle, and whether it differs from
+// oldBundle. If the server reports that the bundle hasn't changed since the
+// last download, oldBundle is returned as is and nothing is saved.
+func (a *Agent) downloadLatestBundle(oldBundle *models.Bundle) (*models.Bundle, bool, error) {
+	ctx, cancel := dpcontext.NewDefault(context.Background())
 	defer cancel()
 
-	bundleBytes, err := a.client.GetBundleBytes(ctx)
-	if err != nil {
-		log.WithError(err).Error("get bundle")
-		return nil
+	etag := a.bundleETag
+	if oldBundle == nil {
+		etag = ""
+	}
+
+	bundleBytes, etag, err := a.client.GetBundleBytesIfChanged(ctx, etag)
+	if err == client.ErrNotModified {
+		if logging.Tracing() {
+			log.WithField("etag", etag).Debug("bundle not modified")
+		}
+		return oldBundle, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "get bundle")
 	}
 
-	bundle := mergeBundle(oldBundle, bundleBytes)
+	bundle, kept := parseBundle(oldBundle, bundleBytes)
+	if bundle == nil {
+		a.bundleETag = ""
+		return nil, false, errors.New("bundle can't be applied")
+	}
+	if kept && oldBundle == nil {
+		// There's no previous bundle to keep, and applying an empty one
+		// would remove every service. The agent can still update to a
+		// version that is able to apply the bundle.
+		a.bundleETag = ""
+		a.updater.SetDesiredVersion(bundle.DesiredAgentVersion)
+		return nil, false, errors.New("bundle can't be applied and there's no previous bundle to keep")
+	}
 
 	bundleBytes, err = json.Marshal(bundle)
 	if err != nil {
-		log.WithError(err).Error("marshal bundle")
-		return nil
+		a.bundleETag = ""
+		return nil, false, errors.Wrap(err, "marshal bundle")
 	}
 
-	if err = a.writeFile(bundleBytes, bundleFilename); err != nil {
+	if err = a.writeFileWithChecksum(bundleBytes, bundleFilename); err != nil {
+		// Keep going with the bundle in memory, a persistent failure is
+		// reported as a storage error
 		log.WithError(err).Error("save bundle")
-		return nil
 	}
 
-	return bundle
+	a.bundleETag = etag
+	return bundle, true, nil
 }
 
 func mergeBundle(oldBundle *models.Bundle, bundleBytes []byte) *models.Bundle {
+	bundle, _ := parseBundle(oldBundle, bundleBytes)
+	return bundle
+}
+
+// parseBundle returns the bundle in bundleBytes, merged with oldBundle as
+// mergeBundle does, and whether it couldn't be applied so oldBundle was kept
+func parseBundle(oldBundle *models.Bundle, bundleBytes []byte) (*models.Bundle, bool) {
+	var versionedBundle struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(bundleBytes, &versionedBundle); err == nil && versionedBundle.SchemaVersion > models.BundleSchemaVersion {
+		log.WithField("schemaVersion", versionedBundle.SchemaVersion).
+			WithField("supportedSchemaVersion", models.BundleSchemaVersion).
+			Error("bundle schema is newer than this agent supports, keeping the current bundle")
+		return keepBundle(oldBundle, bundleBytes), true
+	}
+
 	var bundle models.Bundle
 	err := json.Unmarshal(bundleBytes, &bundle)
 	if err != nil {
 		log.WithError(err).Error("unmarshaling full bundle")
 
-		var minimalBundle struct {
-			DesiredAgentVersion string `json:"desiredAgentVersion" yaml:"desiredAgentVersion"`
+		if partialBundle := unmarshalPartialBundle(oldBundle, bundleBytes); partialBundle != nil {
+			return partialBundle, false
 		}
-		err := json.Unmarshal(bundleBytes, &minimalBundle)
-		if err != nil {
-			log.WithError(err).Error("unmarshaling minimal bundle")
-			return nil
+
+		return keepBundle(oldBundle, bundleBytes), true
+	}
+
+	return &bundle, false
+}
+
+// keepBundle returns oldBundle for a bundle that can't be applied, taking
+// only its desired agent version so that the agent can still update to one
+// that is able to apply it
+func keepBundle(oldBundle *models.Bundle, bundleBytes []byte) *models.Bundle {
+	var minimalBundle struct {
+		DesiredAgentVersion string `json:"desiredAgentVersion" yaml:"desiredAgentVersion"`
+	}
+	err := json.Unmarshal(bundleBytes, &minimalBundle)
+	if err != nil {
+		log.WithError(err).Error("unmarshaling minimal bundle")
+		return nil
+	}
+
+	var bundle models.Bundle
+	if oldBundle != nil {
+		bundle = *oldBundle
+	}
+	bundle.DesiredAgentVersion = minimalBundle.DesiredAgentVersion
+	return &bundle
+}
+
+// unmarshalPartialBundle recovers a bundle in which some applications are
+// malformed, so that one bad application doesn't hold back the others. An
+// application that doesn't parse keeps its release from oldBundle, if it had
+// one, rather than being removed. Returns nil if anything other than
+// individual applications is malformed.
+func unmarshalPartialBundle(oldBundle *models.Bundle, bundleBytes []byte) *models.Bundle {
+	var rawBundle map[string]json.RawMessage
+	if err := json.Unmarshal(bundleBytes, &rawBundle); err != nil {
+		return nil
+	}
+
+	var rawApplications []json.RawMessage
+	if err := json.Unmarshal(rawBundle["applications"], &rawApplications); err != nil {
+		return nil
+	}
+	delete(rawBundle, "applications")
+
+	restBytes, err := json.Marshal(rawBundle)
+	if err != nil {
+		return nil
+	}
+	var bundle models.Bundle
+	if err := json.Unmarshal(restBytes, &bundle); err != nil {
+		return nil
+	}
+
+	oldApplications := make(map[string]models.FullBundledApplication)
+	if oldBundle != nil {
+		for _, application := range oldBundle.Applications {
+			oldApplications[application.Application.ID] = application
+		}
+	}
+
+	for i, rawApplication := range rawApplications {
+		var application models.FullBundledApplication
+		err := json.Unmarshal(rawApplication, &application)
+		if err == nil {
+			bundle.Applications = append(bundle.Applications, application)
+			continue
 		}
 
-		if oldBundle != nil {
-			bundle = *oldBundle
+		// Even a malformed application usually has a readable ID
+		var minimalApplication struct {
+			Application struct {
+				ID string `json:"id"`
+			} `json:"application"`
+		}
+		json.Unmarshal(rawApplication, &minimalApplication)
+		applicationID := minimalApplication.Application.ID
+
+		logger := log.WithError(err).WithField("index", i).WithField("application", applicationID)
+		if oldApplication, ok := oldApplications[applicationID]; ok && applicationID != "" {
+			logger.Error("unmarshaling application, keeping its previous release")
+			bundle.Applications = append(bundle.Applications, oldApplication)
+		} else {
+			logger.Error("unmarshaling application, skipping it")
 		}
-		bundle.DesiredAgentVersion = minimalBundle.DesiredAgentVersion
 	}
 
 	return &bundle
 }
 
 func (a *Agent) runInfoReporter() {
-	ticker := time.NewTicker(time.Minute)
+	ticker := time.NewTicker(a.infoReportInterval)
 	defer ticker.Stop()
 
 	for {
+		a.markProgress(infoReporterLoop)
+		reportStart := time.Now()
 		if err := a.infoReporter.Report(); err != nil {
 			log.WithError(err).Error("report device info")
 			goto cont
 		}
+		a.metricsExporter.SetInfoReportDuration(time.Since(reportStart))
+		a.updater.Confirm()
 
 	cont:
 		select {
@@ -342,6 +1029,9 @@ func (a *Agent) runLocalServer() {
 	for {
 		if err := a.localServer.Serve(); err != nil {
 			log.WithError(err).Error("serve local device API")
+			if local.ListenerClosed(err) {
+				a.relistenLocalServer()
+			}
 			goto cont
 		}
 
@@ -353,6 +1043,32 @@ func (a *Agent) runLocalServer() {
 	}
 }
 
+func (a *Agent) runProbeServer() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		listener, err := net.Listen("tcp", a.probeAddress)
+		if err == nil {
+			err = a.localServer.ServeProbes(listener)
+		}
+		log.WithError(err).WithField("address", a.probeAddress).Error("serve health probes")
+
+		<-ticker.C
+	}
+}
+
+// relistenLocalServer replaces a local server listener that was closed out
+// from under it, so the local API recovers instead of failing forever
+func (a *Agent) relistenLocalServer() {
+	listener, err := a.listen()
+	if err != nil {
+		log.WithError(err).Error("relisten for local server")
+		return
+	}
+	a.localServer.SetListener(listener)
+}
+
 func (a *Agent) runRemoteServer() {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
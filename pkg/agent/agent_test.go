@@ -1,9 +1,20 @@
 package agent
 
 import (
+	"bytes"
 	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/deviceplane/cli/pkg/agent/info"
+	"github.com/deviceplane/cli/pkg/agent/server/local"
+	"github.com/deviceplane/cli/pkg/agent/supervisor"
 	"github.com/deviceplane/cli/pkg/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -68,6 +79,38 @@ func TestMergeBundleIncompatible(t *testing.T) {
 	assert.Equal(t, string(oldB), string(mergedB))
 }
 
+func TestMergeBundleNewerSchema(t *testing.T) {
+	old := models.Bundle{
+		EnvironmentVariables: map[string]string{
+			"AAAA": "AAAA",
+		},
+		DesiredAgentVersion: "1",
+	}
+
+	// A well formed bundle is still kept back if its schema is too new
+	new := models.Bundle{
+		SchemaVersion: models.BundleSchemaVersion + 1,
+		EnvironmentVariables: map[string]string{
+			"ASDF": "WASDF",
+		},
+		DesiredAgentVersion: "2",
+	}
+
+	newB, err := json.Marshal(new)
+	assert.NoError(t, err)
+
+	merged := mergeBundle(&old, newB)
+	assert.Equal(t, old.EnvironmentVariables, merged.EnvironmentVariables)
+	assert.Equal(t, new.DesiredAgentVersion, merged.DesiredAgentVersion)
+
+	new.SchemaVersion = models.BundleSchemaVersion
+	newB, err = json.Marshal(new)
+	assert.NoError(t, err)
+
+	merged = mergeBundle(&old, newB)
+	assert.Equal(t, new, *merged)
+}
+
 func TestMergeBundleIncompatibleWithEmptyOld(t *testing.T) {
 	var old *models.Bundle
 	new := map[string]interface{}{
@@ -88,3 +131,404 @@ func TestMergeBundleIncompatibleWithEmptyOld(t *testing.T) {
 	assert.NotEqual(t, new, *merged)
 	assert.Equal(t, new["desiredAgentVersion"], merged.DesiredAgentVersion)
 }
+
+func TestParseBundleKept(t *testing.T) {
+	newB, err := json.Marshal(map[string]interface{}{
+		"schemaVersion":       models.BundleSchemaVersion + 1,
+		"desiredAgentVersion": "2",
+	})
+	assert.NoError(t, err)
+
+	// With nothing to keep, the caller has to know not to apply the empty
+	// bundle that comes back
+	bundle, kept := parseBundle(nil, newB)
+	assert.True(t, kept)
+	assert.Empty(t, bundle.Applications)
+
+	old := models.Bundle{
+		Applications: []models.FullBundledApplication{
+			{Application: models.BundledApplication{ID: "app_a"}},
+		},
+	}
+	bundle, kept = parseBundle(&old, newB)
+	assert.True(t, kept)
+	assert.Equal(t, old.Applications, bundle.Applications)
+	assert.Equal(t, "2", bundle.DesiredAgentVersion)
+
+	newB, err = json.Marshal(models.Bundle{DesiredAgentVersion: "2"})
+	assert.NoError(t, err)
+	_, kept = parseBundle(&old, newB)
+	assert.False(t, kept)
+}
+
+func TestMergeBundleMalformedApplications(t *testing.T) {
+	old := models.Bundle{
+		Applications: []models.FullBundledApplication{
+			{
+				Application:   models.BundledApplication{ID: "app_b"},
+				LatestRelease: models.Release{ID: "rel_1"},
+			},
+		},
+		DesiredAgentVersion: "1",
+	}
+
+	new := map[string]interface{}{
+		"applications": []interface{}{
+			map[string]interface{}{
+				"application":   map[string]string{"id": "app_a"},
+				"latestRelease": map[string]string{"id": "rel_2"},
+			},
+			map[string]interface{}{
+				"application":   map[string]string{"id": "app_b"},
+				"latestRelease": map[string]interface{}{"id": "rel_3", "config": 42},
+			},
+			map[string]interface{}{
+				"application":   map[string]string{"id": "app_c"},
+				"latestRelease": "bad",
+			},
+		},
+		"environmentVariables": map[string]string{
+			"ASDF": "WASDF",
+		},
+		"desiredAgentVersion": "2",
+	}
+
+	newB, err := json.Marshal(new)
+	assert.NoError(t, err)
+
+	merged := mergeBundle(&old, newB)
+	if assert.NotNil(t, merged) && assert.Len(t, merged.Applications, 2) {
+		// Applications that parse are kept, a malformed one keeps its
+		// previous release, and a new malformed one is skipped
+		assert.Equal(t, "app_a", merged.Applications[0].Application.ID)
+		assert.Equal(t, "rel_2", merged.Applications[0].LatestRelease.ID)
+		assert.Equal(t, old.Applications[0], merged.Applications[1])
+	}
+	assert.Equal(t, map[string]string{"ASDF": "WASDF"}, merged.EnvironmentVariables)
+	assert.Equal(t, "2", merged.DesiredAgentVersion)
+}
+
+func TestListenWithOSAssignedPort(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	a := &Agent{
+		projectID:  "prj_test",
+		stateDir:   stateDir,
+		serverPort: 0,
+	}
+
+	listener, err := a.listen()
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	portBytes, err := ioutil.ReadFile(a.fileLocation(serverPortFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(listener.Addr().(*net.TCPAddr).Port), string(portBytes))
+}
+
+func TestListenOnUnixSocket(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	socket := path.Join(stateDir, "agent.sock")
+
+	// A socket left behind by a previous agent is replaced
+	stale, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	a := &Agent{
+		projectID:    "prj_test",
+		stateDir:     stateDir,
+		serverSocket: socket,
+	}
+
+	listener, err := a.listen()
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(socket)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = os.Stat(a.fileLocation(serverPortFilename))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalServerRecoversFromClosedListener(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	a := &Agent{
+		projectID:  "prj_test",
+		stateDir:   stateDir,
+		serverPort: 0,
+		localServer: local.NewServer(http.NotFoundHandler(), func() models.LocalHealth {
+			return models.LocalHealth{}
+		}, func() *models.Bundle {
+			return nil
+		}, func() error {
+			return nil
+		}, http.NotFoundHandler()),
+	}
+
+	listener, err := a.listen()
+	assert.NoError(t, err)
+	a.localServer.SetListener(listener)
+
+	go a.runLocalServer()
+
+	httpClient := &http.Client{Timeout: time.Second}
+	waitForVersion := func(timeout time.Duration) error {
+		deadline := time.Now().Add(timeout)
+		for {
+			portBytes, err := ioutil.ReadFile(a.fileLocation(serverPortFilename))
+			if err == nil {
+				var resp *http.Response
+				resp, err = httpClient.Get("http://127.0.0.1:" + string(portBytes) + "/version")
+				if err == nil {
+					resp.Body.Close()
+					return nil
+				}
+			}
+			if time.Now().After(deadline) {
+				return err
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	assert.NoError(t, waitForVersion(5*time.Second))
+
+	listener.Close()
+
+	assert.NoError(t, waitForVersion(10*time.Second))
+}
+
+func TestPersistentWriteFailuresReportStorageError(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	// A regular file in place of the project directory makes every write fail
+	notADir := path.Join(stateDir, "file")
+	assert.NoError(t, ioutil.WriteFile(notADir, nil, 0644))
+
+	a := &Agent{
+		projectID:    "prj_test",
+		stateDir:     notADir,
+		infoReporter: info.NewReporter(nil, "test"),
+	}
+
+	for i := 0; i < storageFailedWrites-1; i++ {
+		assert.Error(t, a.writeFile([]byte("bundle"), bundleFilename))
+		assert.Empty(t, a.health().StorageError)
+	}
+	assert.Error(t, a.writeFile([]byte("bundle"), bundleFilename))
+	assert.NotEmpty(t, a.health().StorageError)
+
+	a.stateDir = stateDir
+	assert.NoError(t, a.writeFile([]byte("bundle"), bundleFilename))
+	assert.Empty(t, a.health().StorageError)
+}
+
+func TestBundleFingerprintIgnoresApplicationOrder(t *testing.T) {
+	a := models.FullBundledApplication{
+		Application:   models.BundledApplication{ID: "app_a"},
+		LatestRelease: models.Release{ID: "rel_1"},
+	}
+	b := models.FullBundledApplication{
+		Application:   models.BundledApplication{ID: "app_b"},
+		LatestRelease: models.Release{ID: "rel_2"},
+	}
+
+	assert.Equal(t,
+		bundleFingerprint(models.Bundle{Applications: []models.FullBundledApplication{a, b}}),
+		bundleFingerprint(models.Bundle{Applications: []models.FullBundledApplication{b, a}}),
+	)
+
+	b.LatestRelease.ID = "rel_3"
+	assert.NotEqual(t,
+		bundleFingerprint(models.Bundle{Applications: []models.FullBundledApplication{a}}),
+		bundleFingerprint(models.Bundle{Applications: []models.FullBundledApplication{a, b}}),
+	)
+}
+
+func TestLoadSavedBundleVerifiesChecksum(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	a := &Agent{
+		projectID: "prj_test",
+		stateDir:  stateDir,
+	}
+
+	bundleBytes, err := json.Marshal(models.Bundle{DesiredAgentVersion: "1.2"})
+	assert.NoError(t, err)
+	assert.NoError(t, a.writeFileWithChecksum(bundleBytes, bundleFilename))
+
+	bundle := a.loadSavedBundle()
+	if assert.NotNil(t, bundle) {
+		assert.Equal(t, "1.2", bundle.DesiredAgentVersion)
+	}
+
+	// Corruption that still parses is caught by the checksum
+	saved, err := ioutil.ReadFile(a.fileLocation(bundleFilename))
+	assert.NoError(t, err)
+	corrupted := bytes.Replace(saved, []byte("1.2"), []byte("1.3"), 1)
+	assert.NoError(t, ioutil.WriteFile(a.fileLocation(bundleFilename), corrupted, 0644))
+	assert.Nil(t, a.loadSavedBundle())
+
+	// Bundles saved by older agents are checked against their sidecar
+	legacyBytes, err := json.Marshal(models.Bundle{DesiredAgentVersion: "1.3"})
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(a.fileLocation(bundleFilename), legacyBytes, 0644))
+	assert.NoError(t, ioutil.WriteFile(a.fileLocation(bundleFilename+checksumSuffix), []byte("0000\n"), 0644))
+	assert.Nil(t, a.loadSavedBundle())
+
+	// and still load if they were saved before checksums were written
+	assert.NoError(t, os.Remove(a.fileLocation(bundleFilename+checksumSuffix)))
+	bundle = a.loadSavedBundle()
+	if assert.NotNil(t, bundle) {
+		assert.Equal(t, "1.3", bundle.DesiredAgentVersion)
+	}
+
+	// Saving again replaces the sidecar with the checksum line
+	assert.NoError(t, ioutil.WriteFile(a.fileLocation(bundleFilename+checksumSuffix), []byte("0000\n"), 0644))
+	assert.NoError(t, a.writeFileWithChecksum(bundleBytes, bundleFilename))
+	_, err = os.Stat(a.fileLocation(bundleFilename + checksumSuffix))
+	assert.True(t, os.IsNotExist(err))
+	bundle = a.loadSavedBundle()
+	if assert.NotNil(t, bundle) {
+		assert.Equal(t, "1.2", bundle.DesiredAgentVersion)
+	}
+}
+
+func TestSeedBundleFromFile(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	a := &Agent{
+		projectID: "prj_test",
+		stateDir:  stateDir,
+	}
+
+	bundleFile := path.Join(stateDir, "seed.json")
+	a.SetBundleFile(bundleFile, true)
+
+	// A bundle file that can't be applied seeds nothing
+	assert.NoError(t, ioutil.WriteFile(bundleFile, []byte("not json"), 0644))
+	assert.Nil(t, a.seedBundle())
+	assert.NoError(t, ioutil.WriteFile(bundleFile, []byte(`{"schemaVersion":`+strconv.Itoa(models.BundleSchemaVersion+1)+`}`), 0644))
+	assert.Nil(t, a.seedBundle())
+
+	// Without a saved bundle the bundle file is applied and saved
+	assert.NoError(t, ioutil.WriteFile(bundleFile, []byte(`{"deviceName":"bench","desiredAgentVersion":"1.2"}`), 0644))
+	bundle := a.seedBundle()
+	if assert.NotNil(t, bundle) {
+		assert.Equal(t, "bench", bundle.DeviceName)
+		assert.Equal(t, "1.2", bundle.DesiredAgentVersion)
+	}
+	bundle = a.loadSavedBundle()
+	if assert.NotNil(t, bundle) {
+		assert.Equal(t, "1.2", bundle.DesiredAgentVersion)
+	}
+
+	// Once a newer bundle is saved, the stale bundle file doesn't replace it
+	savedBytes, err := json.Marshal(models.Bundle{DesiredAgentVersion: "1.3"})
+	assert.NoError(t, err)
+	assert.NoError(t, a.writeFileWithChecksum(savedBytes, bundleFilename))
+	bundle = a.seedBundle()
+	if assert.NotNil(t, bundle) {
+		assert.Equal(t, "1.3", bundle.DesiredAgentVersion)
+	}
+}
+
+func TestStuckLoops(t *testing.T) {
+	a := &Agent{
+		stateDir: "/var/lib/deviceplane",
+	}
+	a.SetLivenessFile("", 0)
+	assert.Equal(t, "/var/lib/deviceplane/liveness", a.livenessFile)
+	assert.Equal(t, defaultLivenessInterval, a.livenessInterval)
+
+	a.markProgress(bundleApplierLoop)
+	a.markProgress(infoReporterLoop)
+	assert.Empty(t, a.stuckLoops(time.Now()))
+
+	a.loopProgress[infoReporterLoop] = time.Now().Add(-loopStallTimeout - time.Second)
+	assert.Equal(t, []string{infoReporterLoop}, a.stuckLoops(time.Now()))
+
+	a.markProgress(infoReporterLoop)
+	assert.Empty(t, a.stuckLoops(time.Now()))
+
+	// A loop that's configured to run less often gets longer to make progress
+	a.infoReportInterval = time.Hour
+	a.loopProgress[infoReporterLoop] = time.Now().Add(-time.Hour - time.Second)
+	assert.Empty(t, a.stuckLoops(time.Now()))
+	a.loopProgress[infoReporterLoop] = time.Now().Add(-2*time.Hour - time.Second)
+	assert.Equal(t, []string{infoReporterLoop}, a.stuckLoops(time.Now()))
+}
+
+func TestRequestPoll(t *testing.T) {
+	a := &Agent{
+		pollRequests: make(chan struct{}, 1),
+	}
+
+	// Requests made before the bundle applier gets to them are merged
+	assert.NoError(t, a.requestPoll())
+	assert.NoError(t, a.requestPoll())
+	assert.Len(t, a.pollRequests, 1)
+
+	<-a.pollRequests
+	a.offline = true
+	assert.Equal(t, errOfflinePoll, a.requestPoll())
+	assert.Len(t, a.pollRequests, 0)
+}
+
+func TestRollbackSurvivesRestart(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	lastGood := models.Bundle{Applications: []models.FullBundledApplication{
+		{Application: models.BundledApplication{ID: "app_a"}, LatestRelease: models.Release{ID: "rel_1"}},
+	}}
+	rolledBack := models.Bundle{Applications: []models.FullBundledApplication{
+		{Application: models.BundledApplication{ID: "app_a"}, LatestRelease: models.Release{ID: "rel_2"}},
+	}}
+
+	a := &Agent{
+		projectID:  "prj_test",
+		stateDir:   stateDir,
+		supervisor: &supervisor.Supervisor{},
+	}
+	a.saveLastGoodBundle(lastGood)
+	a.setRollback(bundleFingerprint(rolledBack), "rolled back")
+
+	// After a restart the rolled back bundle is still replaced by the last
+	// good one
+	a = &Agent{
+		projectID:  "prj_test",
+		stateDir:   stateDir,
+		supervisor: &supervisor.Supervisor{},
+	}
+	a.lastGoodBundle = a.loadLastGoodBundle()
+	a.loadRollback()
+	assert.Equal(t, bundleFingerprint(lastGood), bundleFingerprint(a.bundleToApply(rolledBack)))
+
+	// Until the control plane hands out other releases
+	fixed := models.Bundle{Applications: []models.FullBundledApplication{
+		{Application: models.BundledApplication{ID: "app_a"}, LatestRelease: models.Release{ID: "rel_3"}},
+	}}
+	assert.Equal(t, bundleFingerprint(fixed), bundleFingerprint(a.bundleToApply(fixed)))
+	_, err = os.Stat(a.fileLocation(rollbackFilename))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// checksumHeader starts the first line of a file written with a
+	// checksum, which is followed by the SHA-256 of the rest of the file
+	checksumHeader = "sha256:"
+	// checksumSuffix is the sidecar file older agents kept checksums in
+	checksumSuffix = ".sha256"
+)
+
+var (
+	errChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// writeFileWithChecksum writes contents preceded by a line holding their
+// SHA-256, so corruption that still parses can be detected on load. They're
+// written together so that a crash can't leave one without the other.
+func (a *Agent) writeFileWithChecksum(contents []byte, elem ...string) error {
+	sum := sha256.Sum256(contents)
+
+	var buf bytes.Buffer
+	buf.WriteString(checksumHeader + hex.EncodeToString(sum[:]) + "\n")
+	buf.Write(contents)
+	if err := a.writeFile(buf.Bytes(), elem...); err != nil {
+		return err
+	}
+
+	// A sidecar left by an older agent no longer applies
+	if err := os.Remove(a.fileLocation(checksumFilename(elem)...)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "remove checksum")
+	}
+	return nil
+}
+
+// readFileWithChecksum reads a file written by writeFileWithChecksum and
+// returns errChecksumMismatch if it doesn't match its checksum. Files from
+// older agents, which kept the checksum in a sidecar file or had none, are
+// checked against their sidecar if there is one.
+func (a *Agent) readFileWithChecksum(elem ...string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(a.fileLocation(elem...))
+	if err != nil {
+		return nil, err
+	}
+
+	var expected string
+	if bytes.HasPrefix(contents, []byte(checksumHeader)) {
+		i := bytes.IndexByte(contents, '\n')
+		if i < 0 {
+			return nil, errChecksumMismatch
+		}
+		expected = string(contents[len(checksumHeader):i])
+		contents = contents[i+1:]
+	} else {
+		sidecar, err := ioutil.ReadFile(a.fileLocation(checksumFilename(elem)...))
+		if os.IsNotExist(err) {
+			return contents, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "read checksum")
+		}
+		expected = strings.TrimSpace(string(sidecar))
+	}
+
+	sum := sha256.Sum256(contents)
+	if hex.EncodeToString(sum[:]) != expected {
+		return nil, errChecksumMismatch
+	}
+
+	return contents, nil
+}
+
+func checksumFilename(elem []string) []string {
+	sidecar := append([]string{}, elem...)
+	sidecar[len(sidecar)-1] += checksumSuffix
+	return sidecar
+}
@@ -2,11 +2,15 @@ package client
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/apex/log"
 	dpcontext "github.com/deviceplane/cli/pkg/context"
@@ -20,15 +24,37 @@ const (
 	bundleURL = "bundle"
 )
 
+// ErrUnsupported is returned for requests the server doesn't support
+var ErrUnsupported = errors.New("not supported by the server")
+
+// ErrNotModified is returned by GetBundleBytesIfChanged if the bundle still
+// has the given ETag
+var ErrNotModified = errors.New("not modified")
+
+// ErrAccessKeyRejected is returned by CheckAuth if the server doesn't accept
+// the access key
+var ErrAccessKeyRejected = errors.New("access key rejected by the server")
+
 type Client struct {
 	url        *url.URL
 	projectID  string
 	httpClient *dphttp.Client
+	wsDialer   *dpwebsocket.Dialer
+
+	// proxyURL and tlsConfig are set with SetProxy and SetTLSConfig
+	proxyURL  *url.URL
+	tlsConfig *tls.Config
 
 	deviceID  string
 	accessKey string
+
+	traceFunc TraceFunc
 }
 
+// TraceFunc is invoked after every HTTP round trip made by the client. The
+// status is 0 if no response was received.
+type TraceFunc func(method, url string, status int, dur time.Duration)
+
 func NewClient(url *url.URL, projectID string, httpClient *dphttp.Client) *Client {
 	if httpClient == nil {
 		httpClient = dphttp.DefaultClient
@@ -37,6 +63,7 @@ func NewClient(url *url.URL, projectID string, httpClient *dphttp.Client) *Clien
 		url:        url,
 		projectID:  projectID,
 		httpClient: httpClient,
+		wsDialer:   dpwebsocket.DefaultDialer,
 	}
 }
 
@@ -48,9 +75,18 @@ func (c *Client) SetAccessKey(accessKey string) {
 	c.accessKey = accessKey
 }
 
-func (c *Client) RegisterDevice(ctx *dpcontext.Context, registrationToken string) (*models.RegisterDeviceResponse, error) {
+// SetTraceFunc sets a function to be called after every HTTP round trip.
+// A nil function disables tracing.
+func (c *Client) SetTraceFunc(traceFunc TraceFunc) {
+	c.traceFunc = traceFunc
+}
+
+// RegisterDevice registers a new device, or reclaims the device previously
+// registered with hardwareID if it isn't empty
+func (c *Client) RegisterDevice(ctx *dpcontext.Context, registrationToken, hardwareID string) (*models.RegisterDeviceResponse, error) {
 	req := models.RegisterDeviceRequest{
 		DeviceRegistrationTokenID: registrationToken,
+		HardwareID:                hardwareID,
 	}
 
 	var registerDeviceResponse models.RegisterDeviceResponse
@@ -62,10 +98,84 @@ func (c *Client) RegisterDevice(ctx *dpcontext.Context, registrationToken string
 	return &registerDeviceResponse, nil
 }
 
+// CheckRegistration checks that registering with registrationToken would
+// succeed, without registering
+func (c *Client) CheckRegistration(ctx *dpcontext.Context, registrationToken string) (*models.CheckDeviceRegistrationResponse, error) {
+	req := models.RegisterDeviceRequest{
+		DeviceRegistrationTokenID: registrationToken,
+	}
+
+	var checkResponse models.CheckDeviceRegistrationResponse
+	err := c.post(ctx, req, &checkResponse, "projects", c.projectID, "devices", "register", "check")
+	if isUnsupported(err) {
+		return nil, ErrUnsupported
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &checkResponse, nil
+}
+
+// CheckAuth returns the device the access key belongs to
+func (c *Client) CheckAuth(ctx *dpcontext.Context) (*models.CheckDeviceAuthResponse, error) {
+	var checkResponse models.CheckDeviceAuthResponse
+	err := c.get(ctx, &checkResponse, "projects", c.projectID, "devices", c.deviceID, "auth")
+	if nonSuccessErr, ok := err.(*dphttp.NonSuccessResponseError); ok &&
+		(nonSuccessErr.StatusCode == http.StatusUnauthorized || nonSuccessErr.StatusCode == http.StatusForbidden) {
+		return nil, ErrAccessKeyRejected
+	} else if isUnsupported(err) {
+		return nil, ErrUnsupported
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &checkResponse, nil
+}
+
 func (c *Client) GetBundleBytes(ctx *dpcontext.Context) ([]byte, error) {
 	return c.getB(ctx, "projects", c.projectID, "devices", c.deviceID, "bundle")
 }
 
+// GetBundleBytesIfChanged fetches the bundle unless its ETag is still etag,
+// in which case ErrNotModified is returned without a body. The bundle's
+// current ETag is returned with it, and is empty if the server doesn't send
+// one. An empty etag always fetches the bundle.
+func (c *Client) GetBundleBytesIfChanged(ctx *dpcontext.Context, etag string) ([]byte, string, error) {
+	req, err := dphttp.NewRequest(ctx, "GET", getURL(c.url, "projects", c.projectID, "devices", c.deviceID, bundleURL), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.SetBasicAuth(c.accessKey, "")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.do(req)
+	if nonSuccessErr, ok := err.(*dphttp.NonSuccessResponseError); ok && nonSuccessErr.StatusCode == http.StatusNotModified {
+		return nil, etag, ErrNotModified
+	} else if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Debug("GET response")
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	log.WithFields(log.Fields{
+		"status": resp.Status,
+		"code":   resp.StatusCode,
+		"etag":   resp.Header.Get("ETag"),
+	}).Debug("GET response")
+
+	return bytes, resp.Header.Get("ETag"), nil
+}
+
 func (c *Client) SetDeviceInfo(ctx *dpcontext.Context, req models.SetDeviceInfoRequest) error {
 	return c.post(ctx, req, nil, "projects", c.projectID, "devices", c.deviceID, "info")
 }
@@ -90,6 +200,23 @@ func (c *Client) SetDeviceServiceStatus(ctx *dpcontext.Context, applicationID, s
 	return c.post(ctx, req, nil, "projects", c.projectID, "devices", c.deviceID, "applications", applicationID, "services", service, "deviceservicestatuses")
 }
 
+// SetDeviceServiceStatuses sets the statuses of several services of an
+// application in one request. ErrUnsupported is returned by servers that
+// predate it.
+func (c *Client) SetDeviceServiceStatuses(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error {
+	err := c.post(ctx, req, nil, "projects", c.projectID, "devices", c.deviceID, "applications", applicationID, "deviceservicestatuses")
+	if isUnsupported(err) {
+		return ErrUnsupported
+	}
+	return err
+}
+
+// isUnsupported returns whether err means the server doesn't have the route
+func isUnsupported(err error) bool {
+	nonSuccessErr, ok := err.(*dphttp.NonSuccessResponseError)
+	return ok && (nonSuccessErr.StatusCode == http.StatusNotFound || nonSuccessErr.StatusCode == http.StatusMethodNotAllowed)
+}
+
 func (c *Client) DeleteDeviceServiceStatus(ctx *dpcontext.Context, applicationID, service string) error {
 	return c.delete(ctx, nil, "projects", c.projectID, "devices", c.deviceID, "applications", applicationID, "services", service, "deviceservicestatuses")
 }
@@ -110,7 +237,7 @@ func (c *Client) InitiateDeviceConnection(ctx *dpcontext.Context) (net.Conn, err
 
 	req.SetBasicAuth(c.accessKey, "")
 
-	wsConn, _, err := dpwebsocket.DefaultDialer.Dial(
+	wsConn, _, err := c.wsDialer.Dial(
 		ctx,
 		getWebsocketURL(c.url, "projects", c.projectID, "devices", c.deviceID, "connection"),
 		req.Header,
@@ -123,7 +250,7 @@ func (c *Client) InitiateDeviceConnection(ctx *dpcontext.Context) (net.Conn, err
 }
 
 func (c *Client) Revdial(ctx *dpcontext.Context, path string) (*dpwebsocket.Conn, *dphttp.Response, error) {
-	return dpwebsocket.DefaultDialer.Dial(
+	return c.wsDialer.Dial(
 		ctx,
 		getWebsocketURL(c.url, strings.TrimPrefix(path, "/")),
 		nil,
@@ -149,7 +276,7 @@ func (c *Client) getB(ctx *dpcontext.Context, s ...string) ([]byte, error) {
 
 	req.SetBasicAuth(c.accessKey, "")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err.Error(),
@@ -197,7 +324,7 @@ func (c *Client) postB(ctx *dpcontext.Context, in interface{}, s ...string) ([]b
 
 	req.SetBasicAuth(c.accessKey, "")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err.Error(),
@@ -239,7 +366,7 @@ func (c *Client) deleteB(ctx *dpcontext.Context, s ...string) ([]byte, error) {
 
 	req.SetBasicAuth(c.accessKey, "")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err.Error(),
@@ -262,6 +389,23 @@ func (c *Client) deleteB(ctx *dpcontext.Context, s ...string) ([]byte, error) {
 	return bytes, nil
 }
 
+func (c *Client) do(req *dphttp.Request) (*dphttp.Response, error) {
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+
+	if c.traceFunc != nil {
+		var status int
+		if resp != nil {
+			status = resp.StatusCode
+		} else if nonSuccessErr, ok := err.(*dphttp.NonSuccessResponseError); ok {
+			status = nonSuccessErr.StatusCode
+		}
+		c.traceFunc(req.Method, req.URL.String(), status, time.Since(start))
+	}
+
+	return resp, err
+}
+
 func getURL(url *url.URL, s ...string) string {
 	return strings.Join(append([]string{url.String()}, s...), "/")
 }
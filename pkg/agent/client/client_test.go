@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBundleBytesIfChanged(t *testing.T) {
+	var ifNoneMatch string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", `"abc"`)
+		if ifNoneMatch == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("bundle"))
+	}))
+	defer server.Close()
+
+	apiURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	client := NewClient(apiURL, "prj_1", nil)
+	client.SetDeviceID("dev_1")
+
+	ctx, cancel := dpcontext.NewDefault(context.Background())
+	defer cancel()
+
+	bundle, etag, err := client.GetBundleBytesIfChanged(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "bundle", string(bundle))
+	require.Equal(t, `"abc"`, etag)
+	require.Empty(t, ifNoneMatch)
+
+	bundle, etag, err = client.GetBundleBytesIfChanged(ctx, etag)
+	require.Equal(t, ErrNotModified, err)
+	require.Nil(t, bundle)
+	require.Equal(t, `"abc"`, etag)
+
+	bundle, etag, err = client.GetBundleBytesIfChanged(ctx, `"old"`)
+	require.NoError(t, err)
+	require.Equal(t, "bundle", string(bundle))
+	require.Equal(t, `"abc"`, etag)
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+
+	dphttp "github.com/deviceplane/cli/pkg/http"
+	"github.com/deviceplane/cli/pkg/proxy"
+	dpwebsocket "github.com/deviceplane/cli/pkg/websocket"
+	"github.com/gorilla/websocket"
+)
+
+// ParseProxyURL parses the value of a --proxy flag. See proxy.ParseURL.
+func ParseProxyURL(s string) (*url.URL, error) {
+	return proxy.ParseURL(s)
+}
+
+// SetProxy sends all API requests and websocket connections through
+// proxyURL. Without it, the proxy is taken from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY. This replaces the HTTP client passed to NewClient.
+func (c *Client) SetProxy(proxyURL *url.URL) {
+	c.proxyURL = proxyURL
+	c.configureTransport()
+}
+
+// SetTLSConfig sets the TLS settings, such as additional CAs or a client
+// certificate for mutual TLS, of all API requests and websocket
+// connections. See tlsconfig.New. This replaces the HTTP client passed to
+// NewClient.
+func (c *Client) SetTLSConfig(tlsConfig *tls.Config) {
+	c.tlsConfig = tlsConfig
+	c.configureTransport()
+}
+
+// Do sends req with the client's proxy and TLS settings, but without the
+// device's credentials, for endpoints other than the control plane
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.httpClient.Client.Do(req)
+}
+
+// configureTransport rebuilds the HTTP client and websocket dialer from the
+// proxy and TLS settings
+func (c *Client) configureTransport() {
+	proxyFunc := proxy.Func(c.proxyURL)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc
+	transport.TLSClientConfig = c.tlsConfig
+	c.httpClient = &dphttp.Client{
+		Client: &http.Client{
+			Transport: transport,
+		},
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = proxyFunc
+	dialer.TLSClientConfig = c.tlsConfig
+	c.wsDialer = &dpwebsocket.Dialer{
+		Dialer: &dialer,
+	}
+}
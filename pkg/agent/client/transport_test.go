@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/deviceplane/cli/pkg/tlsconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProxyURL(t *testing.T) {
+	proxyURL, err := ParseProxyURL("user:pass@proxy.example.com:3128")
+	require.NoError(t, err)
+	require.Equal(t, "http", proxyURL.Scheme)
+	require.Equal(t, "proxy.example.com:3128", proxyURL.Host)
+	require.Equal(t, "user", proxyURL.User.Username())
+
+	_, err = ParseProxyURL("socks5://proxy.example.com:1080")
+	require.NoError(t, err)
+
+	_, err = ParseProxyURL("https://proxy.example.com:3128")
+	require.Error(t, err)
+	_, err = ParseProxyURL("http://")
+	require.Error(t, err)
+}
+
+func TestSetProxy(t *testing.T) {
+	var proxiedURL, proxyAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		proxyAuth = r.Header.Get("Proxy-Authorization")
+		w.Write([]byte("bundle"))
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := ParseProxyURL(proxy.URL)
+	require.NoError(t, err)
+	proxyURL.User = url.UserPassword("user", "pass")
+
+	apiURL, err := url.Parse("http://api.example.com/api")
+	require.NoError(t, err)
+	client := NewClient(apiURL, "prj_1", nil)
+	client.SetDeviceID("dev_1")
+	client.SetProxy(proxyURL)
+
+	ctx, cancel := dpcontext.NewDefault(context.Background())
+	defer cancel()
+
+	bundle, err := client.GetBundleBytes(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "bundle", string(bundle))
+	require.Equal(t, "http://api.example.com/api/projects/prj_1/devices/dev_1/bundle", proxiedURL)
+	require.Equal(t, "Basic dXNlcjpwYXNz", proxyAuth)
+}
+
+func TestSetTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	// Self-signed client certificate, trusted by the server directly
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dev_1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	clientCert, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	apiURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	client := NewClient(apiURL, "prj_1", nil)
+	client.SetDeviceID("dev_1")
+
+	ctx, cancel := dpcontext.NewDefault(context.Background())
+	defer cancel()
+
+	// Without the client certificate the handshake is rejected
+	tlsConfig, err := tlsconfig.New(caFile, "", "")
+	require.NoError(t, err)
+	client.SetTLSConfig(tlsConfig)
+	_, err = client.GetBundleBytes(ctx)
+	require.Error(t, err)
+
+	tlsConfig, err = tlsconfig.New(caFile, certFile, keyFile)
+	require.NoError(t, err)
+	client.SetTLSConfig(tlsConfig)
+	body, err := client.GetBundleBytes(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "dev_1", string(body))
+
+	_, err = tlsconfig.New(caFile, certFile, "")
+	require.Error(t, err)
+}
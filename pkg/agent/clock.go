@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"os"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// minPlausibleTime is earlier than any agent release, so a clock before it
+// hasn't been set, usually because the device has no working RTC and hasn't
+// synced with NTP yet
+var minPlausibleTime = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// SetWaitForClockSync makes Initialize wait up to timeout for the system
+// clock to be set if it's implausibly far in the past, since TLS to the
+// control plane fails until it is. Zero disables waiting, in which case the
+// condition is only logged. Must be called before Initialize.
+func (a *Agent) SetWaitForClockSync(timeout time.Duration) {
+	a.clockSyncTimeout = timeout
+}
+
+// earliestPlausibleTime returns the time the clock must be past to be
+// trusted. The agent binary can't have been written before it was built.
+func earliestPlausibleTime(binaryPath string) time.Time {
+	earliest := minPlausibleTime
+	if info, err := os.Stat(binaryPath); err == nil && info.ModTime().After(earliest) {
+		earliest = info.ModTime()
+	}
+	return earliest
+}
+
+// waitForClock logs if the clock hasn't been set, and waits for it to be set
+// for up to the clock sync timeout
+func (a *Agent) waitForClock() {
+	earliest := earliestPlausibleTime(a.binaryPath)
+	if time.Now().After(earliest) {
+		return
+	}
+
+	logger := log.WithField("time", time.Now()).WithField("earliest_plausible_time", earliest)
+	if a.clockSyncTimeout <= 0 || a.offline {
+		logger.Warn("system clock isn't set, connections to the control plane will fail until it is")
+		return
+	}
+	logger.WithField("timeout", a.clockSyncTimeout).Warn("system clock isn't set, waiting for it to sync")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// The deadline is measured on the monotonic clock, so it isn't affected
+	// by the wall clock jumping when it syncs
+	timeout := time.After(a.clockSyncTimeout)
+
+	for {
+		select {
+		case <-ticker.C:
+			if time.Now().After(earliest) {
+				log.WithField("time", time.Now()).Info("system clock synced")
+				return
+			}
+		case <-timeout:
+			log.WithField("time", time.Now()).Warn("system clock still isn't set, continuing anyway")
+			return
+		}
+	}
+}
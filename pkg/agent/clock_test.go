@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEarliestPlausibleTime(t *testing.T) {
+	assert.Equal(t, minPlausibleTime, earliestPlausibleTime("/nonexistent/deviceplane-agent"))
+
+	f, err := ioutil.TempFile("", "")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// A binary written while the clock was wrong doesn't lower the bound
+	assert.NoError(t, os.Chtimes(f.Name(), time.Unix(0, 0), time.Unix(0, 0)))
+	assert.Equal(t, minPlausibleTime, earliestPlausibleTime(f.Name()))
+
+	built := minPlausibleTime.Add(365 * 24 * time.Hour)
+	assert.NoError(t, os.Chtimes(f.Name(), built, built))
+	assert.True(t, built.Equal(earliestPlausibleTime(f.Name())))
+}
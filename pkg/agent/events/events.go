@@ -0,0 +1,120 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// Number of events buffered for a subscriber before further events are
+// dropped for it
+const subscriberBuffer = 64
+
+// Log keeps the most recent agent events in memory and fans new events out
+// to subscribers
+type Log struct {
+	size int
+
+	lock        sync.Mutex
+	events      []models.AgentEvent
+	subscribers map[chan models.AgentEvent]struct{}
+	out         io.Writer
+}
+
+func NewLog(size int) *Log {
+	return &Log{
+		size:        size,
+		subscribers: make(map[chan models.AgentEvent]struct{}),
+	}
+}
+
+func (l *Log) Record(event models.AgentEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.append(event)
+
+	if l.out != nil {
+		if err := writeEvent(l.out, event); err != nil {
+			log.WithError(err).Error("persist event")
+		}
+	}
+
+	for subscriber := range l.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Persist appends every event recorded from now on to w, one JSON object
+// per line
+func (l *Log) Persist(w io.Writer) {
+	l.lock.Lock()
+	l.out = w
+	l.lock.Unlock()
+}
+
+// Load adds the events persisted in r, e.g. by a previous run of the agent,
+// to the log. Lines that can't be parsed, such as one cut short by a crash,
+// are skipped.
+func (l *Log) Load(r io.Reader) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var event models.AgentEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		l.append(event)
+	}
+	return scanner.Err()
+}
+
+func (l *Log) append(event models.AgentEvent) {
+	l.events = append(l.events, event)
+	if len(l.events) > l.size {
+		l.events = l.events[len(l.events)-l.size:]
+	}
+}
+
+func writeEvent(w io.Writer, event models.AgentEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// Subscribe returns the recorded events along with a channel of the events
+// recorded after them. The returned function must be called to
+// unsubscribe.
+func (l *Log) Subscribe() ([]models.AgentEvent, <-chan models.AgentEvent, func()) {
+	subscriber := make(chan models.AgentEvent, subscriberBuffer)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	recent := make([]models.AgentEvent, len(l.events))
+	copy(recent, l.events)
+	l.subscribers[subscriber] = struct{}{}
+
+	return recent, subscriber, func() {
+		l.lock.Lock()
+		delete(l.subscribers, subscriber)
+		l.lock.Unlock()
+	}
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	log := NewLog(2)
+	log.Record(models.AgentEvent{Message: "a"})
+	log.Record(models.AgentEvent{Message: "b"})
+	log.Record(models.AgentEvent{Message: "c"})
+
+	recent, newEvents, unsubscribe := log.Subscribe()
+	defer unsubscribe()
+
+	require.Len(t, recent, 2)
+	require.Equal(t, "b", recent[0].Message)
+	require.Equal(t, "c", recent[1].Message)
+	require.False(t, recent[0].Timestamp.IsZero())
+
+	log.Record(models.AgentEvent{Message: "d"})
+	require.Equal(t, "d", (<-newEvents).Message)
+}
+
+func TestPersistAndLoad(t *testing.T) {
+	var file bytes.Buffer
+
+	log := NewLog(10)
+	log.Persist(&file)
+	log.Record(models.AgentEvent{Type: models.AgentEventServiceCreated, Service: "web", Message: "a"})
+	log.Record(models.AgentEvent{Type: models.AgentEventServiceRestarted, Service: "web", Message: "b"})
+
+	// A line cut short by a crash is skipped
+	file.WriteString(`{"type":"service-remo`)
+
+	restarted := NewLog(10)
+	require.NoError(t, restarted.Load(&file))
+
+	recent, _, unsubscribe := restarted.Subscribe()
+	defer unsubscribe()
+
+	require.Len(t, recent, 2)
+	require.Equal(t, models.AgentEventServiceCreated, recent[0].Type)
+	require.Equal(t, "web", recent[0].Service)
+	require.Equal(t, "b", recent[1].Message)
+	require.False(t, recent[1].Timestamp.IsZero())
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+const (
+	// Number of poll intervals bundle downloads may fail for before the
+	// agent reports itself as not ready
+	readinessFailedPolls = 12
+)
+
+func (a *Agent) setRegistered() {
+	a.healthLock.Lock()
+	a.registered = true
+	a.healthLock.Unlock()
+}
+
+func (a *Agent) setBundleLoaded() {
+	a.healthLock.Lock()
+	a.bundleLoaded = true
+	a.healthLock.Unlock()
+}
+
+func (a *Agent) setBundleDownloadResult(succeeded bool) {
+	now := time.Now()
+
+	a.healthLock.Lock()
+	defer a.healthLock.Unlock()
+
+	if succeeded {
+		a.lastBundleDownload = now
+		a.bundleDownloadFailingSince = time.Time{}
+	} else if a.bundleDownloadFailingSince.IsZero() {
+		a.bundleDownloadFailingSince = now
+	}
+}
+
+func (a *Agent) health() models.LocalHealth {
+	a.healthLock.Lock()
+	defer a.healthLock.Unlock()
+
+	health := models.LocalHealth{
+		Registered:   a.registered,
+		BundleLoaded: a.bundleLoaded,
+		StorageError: a.storageError,
+	}
+	if !a.lastBundleDownload.IsZero() {
+		lastBundleDownload := a.lastBundleDownload
+		health.LastBundleDownload = &lastBundleDownload
+	}
+
+	downloadsFailing := !a.bundleDownloadFailingSince.IsZero() &&
+		time.Since(a.bundleDownloadFailingSince) > a.bundlePollInterval*readinessFailedPolls
+	// An offline agent doesn't need to be registered to be ready
+	health.Ready = (a.registered || a.offline) && a.bundleLoaded && !downloadsFailing
+
+	return health
+}
@@ -0,0 +1,89 @@
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+type Stage string
+
+const (
+	StagePreApply  = Stage("pre-apply")
+	StagePostApply = Stage("post-apply")
+
+	hookTimeout     = 5 * time.Minute
+	maxOutputLength = 4096
+)
+
+// Runner executes the hooks found in <confDir>/hooks/<stage>.d in lexical
+// order. Only regular, executable files are treated as hooks.
+type Runner struct {
+	dir string
+}
+
+func NewRunner(confDir string) *Runner {
+	return &Runner{
+		dir: path.Join(confDir, "hooks"),
+	}
+}
+
+func (r *Runner) Run(ctx context.Context, stage Stage) []models.BundleHookResult {
+	stageDir := path.Join(r.dir, string(stage)+".d")
+
+	fileInfos, err := ioutil.ReadDir(stageDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).WithField("stage", stage).Error("list hooks")
+		}
+		return nil
+	}
+
+	sort.Slice(fileInfos, func(i, j int) bool {
+		return fileInfos[i].Name() < fileInfos[j].Name()
+	})
+
+	var results []models.BundleHookResult
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.Mode().IsRegular() || fileInfo.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		results = append(results, run(ctx, stage, path.Join(stageDir, fileInfo.Name())))
+	}
+
+	return results
+}
+
+func run(ctx context.Context, stage Stage, hookPath string) models.BundleHookResult {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+
+	result := models.BundleHookResult{
+		Name:  path.Base(hookPath),
+		Stage: string(stage),
+		RanAt: time.Now(),
+	}
+
+	output, err := exec.CommandContext(ctx, hookPath).CombinedOutput()
+	if len(output) > maxOutputLength {
+		output = output[len(output)-maxOutputLength:]
+	}
+	result.Output = string(output)
+	result.Succeeded = err == nil
+
+	if err != nil {
+		log.WithError(err).
+			WithField("hook", result.Name).
+			WithField("stage", stage).
+			Error("bundle hook failed")
+	}
+
+	return result
+}
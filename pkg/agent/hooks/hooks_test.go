@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeHook(t *testing.T, dir, name, script string, mode os.FileMode) {
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), mode))
+}
+
+func TestRun(t *testing.T) {
+	confDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(confDir)
+
+	runner := NewRunner(confDir)
+
+	// No hooks directory means no hooks
+	require.Empty(t, runner.Run(context.Background(), StagePreApply))
+
+	stageDir := path.Join(confDir, "hooks", string(StagePreApply)+".d")
+	require.NoError(t, os.MkdirAll(path.Join(stageDir, "20-dir"), 0755))
+	writeHook(t, stageDir, "30-fail", "echo failing; exit 1", 0755)
+	writeHook(t, stageDir, "10-ok", "echo ok", 0755)
+	writeHook(t, stageDir, "15-not-executable", "echo skipped", 0644)
+
+	results := runner.Run(context.Background(), StagePreApply)
+	require.Len(t, results, 2)
+
+	require.Equal(t, "10-ok", results[0].Name)
+	require.Equal(t, string(StagePreApply), results[0].Stage)
+	require.True(t, results[0].Succeeded)
+	require.Equal(t, "ok\n", results[0].Output)
+	require.False(t, results[0].RanAt.IsZero())
+
+	require.Equal(t, "30-fail", results[1].Name)
+	require.False(t, results[1].Succeeded)
+	require.Equal(t, "failing\n", results[1].Output)
+
+	// Hooks of other stages aren't run
+	require.Empty(t, runner.Run(context.Background(), StagePostApply))
+}
+
+func TestRunTruncatesOutput(t *testing.T) {
+	confDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(confDir)
+
+	stageDir := path.Join(confDir, "hooks", string(StagePostApply)+".d")
+	require.NoError(t, os.MkdirAll(stageDir, 0755))
+	writeHook(t, stageDir, "verbose", "head -c 10000 /dev/zero | tr '\\0' a; echo end", 0755)
+
+	results := NewRunner(confDir).Run(context.Background(), StagePostApply)
+	require.Len(t, results, 1)
+	require.True(t, results[0].Succeeded)
+
+	// The end of the output is kept, since that's where errors usually are
+	require.Len(t, results[0].Output, maxOutputLength)
+	require.True(t, strings.HasSuffix(results[0].Output, "aend\n"))
+}
+
+func TestRunCanceled(t *testing.T) {
+	confDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(confDir)
+
+	stageDir := path.Join(confDir, "hooks", string(StagePreApply)+".d")
+	require.NoError(t, os.MkdirAll(stageDir, 0755))
+	writeHook(t, stageDir, "slow", "sleep 10", 0755)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := NewRunner(confDir).Run(ctx, StagePreApply)
+	require.Len(t, results, 1)
+	require.False(t, results[0].Succeeded)
+}
@@ -0,0 +1,191 @@
+// Package identity computes a hardware ID that stays the same when a
+// device's state dir is wiped or reimaged, so the device can reclaim its
+// existing registration instead of being registered as a new device.
+//
+// The ID is keyed with a secret generated on the device, so that it can't be
+// derived from guessable facts such as a MAC address by anyone who wants to
+// take the device over.
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/deviceplane/cli/pkg/file"
+	"github.com/pkg/errors"
+)
+
+// Source is a piece of hardware information the ID can be derived from
+type Source string
+
+const (
+	// SourceMachineID is the systemd/D-Bus machine ID. It survives a wiped
+	// state dir but not a reinstall of the OS.
+	SourceMachineID = Source("machine-id")
+	// SourceMAC is the MAC address of the first physical network interface
+	SourceMAC = Source("mac")
+	// SourceSerial is the board or system serial number reported by firmware
+	SourceSerial = Source("serial")
+)
+
+// secretSize is how many random bytes a generated secret has
+const secretSize = 32
+
+var (
+	ErrNoSources = errors.New("no hardware ID sources")
+	ErrNoSecret  = errors.New("no hardware ID secret")
+
+	// rootDir is prefixed to every file read, for testing
+	rootDir = "/"
+
+	machineIDFiles = []string{
+		"etc/machine-id",
+		"var/lib/dbus/machine-id",
+	}
+	serialFiles = []string{
+		"sys/class/dmi/id/product_serial",
+		"sys/firmware/devicetree/base/serial-number",
+		"proc/device-tree/serial-number",
+	}
+
+	// interfaces is replaced in tests
+	interfaces = net.Interfaces
+)
+
+// ParseSources parses a comma separated list of sources, e.g.
+// "machine-id,mac"
+func ParseSources(s string) ([]Source, error) {
+	var sources []Source
+	for _, name := range strings.Split(s, ",") {
+		source := Source(strings.TrimSpace(name))
+		switch source {
+		case "":
+			continue
+		case SourceMachineID, SourceMAC, SourceSerial:
+			sources = append(sources, source)
+		default:
+			return nil, errors.Errorf("unknown hardware ID source %q, use %s, %s or %s",
+				source, SourceMachineID, SourceMAC, SourceSerial)
+		}
+	}
+	return sources, nil
+}
+
+// LoadSecret returns the secret in filename, generating it if the file
+// doesn't exist. The file must be kept where it survives the state dir being
+// wiped, and mustn't be baked into an image that's cloned onto several
+// devices, since each one needs its own secret.
+func LoadSecret(filename string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err == nil {
+		secret := []byte(strings.TrimSpace(string(contents)))
+		if len(secret) < secretSize {
+			return nil, errors.Errorf("hardware ID secret %s is too short", filename)
+		}
+		return secret, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	random := make([]byte, secretSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, errors.Wrap(err, "generate hardware ID secret")
+	}
+	secret := []byte(hex.EncodeToString(random))
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return nil, errors.Wrap(err, "create hardware ID secret dir")
+	}
+	if err := file.WriteFileAtomic(filename, secret, 0600); err != nil {
+		return nil, errors.Wrap(err, "save hardware ID secret")
+	}
+	return secret, nil
+}
+
+// HardwareID hashes the values of sources into an ID, keyed with secret.
+// Every source must be available on this device, otherwise an error is
+// returned rather than an ID that could change once the source becomes
+// available.
+func HardwareID(sources []Source, secret []byte) (string, error) {
+	if len(sources) == 0 {
+		return "", ErrNoSources
+	}
+	if len(secret) == 0 {
+		return "", ErrNoSecret
+	}
+
+	hash := hmac.New(sha256.New, secret)
+	for _, source := range sources {
+		value, err := read(source)
+		if err != nil {
+			return "", errors.Wrapf(err, "read %s", source)
+		}
+		fmt.Fprintf(hash, "%s=%s\n", source, value)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func read(source Source) (string, error) {
+	switch source {
+	case SourceMachineID:
+		return readFirst(machineIDFiles)
+	case SourceSerial:
+		return readFirst(serialFiles)
+	case SourceMAC:
+		return physicalMAC()
+	default:
+		return "", errors.Errorf("unknown hardware ID source %q", source)
+	}
+}
+
+// readFirst returns the contents of the first of files that exists and
+// isn't empty
+func readFirst(files []string) (string, error) {
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(filepath.Join(rootDir, file))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		// Device tree strings are NUL terminated
+		value := strings.TrimSpace(strings.TrimRight(string(contents), "\x00"))
+		if value != "" {
+			return value, nil
+		}
+	}
+	return "", errors.New("not available on this device")
+}
+
+// physicalMAC returns the MAC address of the physical interface that sorts
+// first by name. Virtual interfaces, such as bridges and the veths Docker
+// creates, have no device link in sysfs and are skipped.
+func physicalMAC() (string, error) {
+	ifaces, err := interfaces()
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Name < ifaces[j].Name
+	})
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(rootDir, "sys/class/net", iface.Name, "device")); err != nil {
+			continue
+		}
+		return iface.HardwareAddr.String(), nil
+	}
+	return "", errors.New("no physical network interface found")
+}
@@ -0,0 +1,107 @@
+package identity
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+}
+
+func TestParseSources(t *testing.T) {
+	sources, err := ParseSources("machine-id, mac,serial")
+	require.NoError(t, err)
+	require.Equal(t, []Source{SourceMachineID, SourceMAC, SourceSerial}, sources)
+
+	_, err = ParseSources("uuid")
+	require.Error(t, err)
+}
+
+func TestHardwareID(t *testing.T) {
+	rootDir = t.TempDir()
+	defer func() {
+		rootDir = "/"
+		interfaces = net.Interfaces
+	}()
+
+	writeFile(t, filepath.Join(rootDir, "var/lib/dbus/machine-id"), "0123456789abcdef\n")
+	writeFile(t, filepath.Join(rootDir, "proc/device-tree/serial-number"), "10000000abcd\x00")
+	writeFile(t, filepath.Join(rootDir, "sys/class/net/eth0/device/uevent"), "")
+
+	mac, err := net.ParseMAC("b8:27:eb:00:00:01")
+	require.NoError(t, err)
+	interfaces = func() ([]net.Interface, error) {
+		return []net.Interface{
+			{Name: "lo", Flags: net.FlagLoopback},
+			{Name: "docker0", HardwareAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}},
+			{Name: "eth0", HardwareAddr: mac},
+		}, nil
+	}
+
+	value, err := read(SourceMachineID)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789abcdef", value)
+
+	value, err = read(SourceSerial)
+	require.NoError(t, err)
+	require.Equal(t, "10000000abcd", value)
+
+	value, err = read(SourceMAC)
+	require.NoError(t, err)
+	require.Equal(t, "b8:27:eb:00:00:01", value)
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	id, err := HardwareID([]Source{SourceMachineID, SourceMAC}, secret)
+	require.NoError(t, err)
+	require.Len(t, id, 64)
+
+	again, err := HardwareID([]Source{SourceMachineID, SourceMAC}, secret)
+	require.NoError(t, err)
+	require.Equal(t, id, again)
+
+	otherSecret, err := HardwareID([]Source{SourceMachineID, SourceMAC}, []byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	require.NotEqual(t, id, otherSecret)
+
+	serialID, err := HardwareID([]Source{SourceSerial}, secret)
+	require.NoError(t, err)
+	require.NotEqual(t, id, serialID)
+
+	require.NoError(t, os.Remove(filepath.Join(rootDir, "proc/device-tree/serial-number")))
+	_, err = HardwareID([]Source{SourceMachineID, SourceSerial}, secret)
+	require.Error(t, err)
+
+	_, err = HardwareID(nil, secret)
+	require.Equal(t, ErrNoSources, err)
+
+	_, err = HardwareID([]Source{SourceMachineID}, nil)
+	require.Equal(t, ErrNoSecret, err)
+}
+
+func TestLoadSecret(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "identity", "secret")
+
+	secret, err := LoadSecret(filename)
+	require.NoError(t, err)
+	require.Len(t, secret, 2*secretSize)
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	again, err := LoadSecret(filename)
+	require.NoError(t, err)
+	require.Equal(t, secret, again)
+
+	writeFile(t, filename, "short\n")
+	_, err = LoadSecret(filename)
+	require.Error(t, err)
+}
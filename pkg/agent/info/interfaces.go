@@ -0,0 +1,54 @@
+package info
+
+import (
+	"net"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// getNetworkInterfaces returns the device's network interfaces, leaving out
+// loopback interfaces unless includeLoopback is set. An interface whose
+// addresses can't be read, for instance for lack of permission, is still
+// returned, just without addresses.
+func getNetworkInterfaces(includeLoopback bool) ([]models.NetworkInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var networkInterfaces []models.NetworkInterface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && !includeLoopback {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.WithError(err).WithField("interface", iface.Name).Debug("failed to get interface addresses")
+		}
+		networkInterfaces = append(networkInterfaces, toNetworkInterface(iface, addrs))
+	}
+
+	return networkInterfaces, nil
+}
+
+func toNetworkInterface(iface net.Interface, addrs []net.Addr) models.NetworkInterface {
+	networkInterface := models.NetworkInterface{
+		Name:       iface.Name,
+		MACAddress: iface.HardwareAddr.String(),
+		Up:         iface.Flags&net.FlagUp != 0,
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			networkInterface.IPv4Addresses = append(networkInterface.IPv4Addresses, ipnet.String())
+		} else {
+			networkInterface.IPv6Addresses = append(networkInterface.IPv6Addresses, ipnet.String())
+		}
+	}
+	return networkInterface
+}
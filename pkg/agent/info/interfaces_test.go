@@ -0,0 +1,50 @@
+package info
+
+import (
+	"net"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToNetworkInterface(t *testing.T) {
+	mac, err := net.ParseMAC("02:42:ac:11:00:02")
+	require.NoError(t, err)
+	_, ipv4, err := net.ParseCIDR("172.17.0.2/16")
+	require.NoError(t, err)
+	ipv4.IP = net.ParseIP("172.17.0.2")
+	_, ipv6, err := net.ParseCIDR("fe80::42:acff:fe11:2/64")
+	require.NoError(t, err)
+	ipv6.IP = net.ParseIP("fe80::42:acff:fe11:2")
+
+	require.Equal(t, models.NetworkInterface{
+		Name:          "eth0",
+		MACAddress:    "02:42:ac:11:00:02",
+		IPv4Addresses: []string{"172.17.0.2/16"},
+		IPv6Addresses: []string{"fe80::42:acff:fe11:2/64"},
+		Up:            true,
+	}, toNetworkInterface(net.Interface{
+		Name:         "eth0",
+		HardwareAddr: mac,
+		Flags:        net.FlagUp | net.FlagBroadcast,
+	}, []net.Addr{ipv4, ipv6}))
+
+	require.Equal(t, models.NetworkInterface{
+		Name: "wlan0",
+	}, toNetworkInterface(net.Interface{Name: "wlan0"}, nil))
+}
+
+func TestGetNetworkInterfacesExcludesLoopback(t *testing.T) {
+	networkInterfaces, err := getNetworkInterfaces(false)
+	require.NoError(t, err)
+	withLoopback, err := getNetworkInterfaces(true)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(withLoopback), len(networkInterfaces))
+
+	for _, networkInterface := range networkInterfaces {
+		iface, err := net.InterfaceByName(networkInterface.Name)
+		require.NoError(t, err)
+		require.Zero(t, iface.Flags&net.FlagLoopback)
+	}
+}
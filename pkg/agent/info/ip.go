@@ -21,3 +21,20 @@ func getIPAddress() (string, error) {
 
 	return "", nil
 }
+
+// getIPAddresses returns every non-loopback address of the device
+func getIPAddresses() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ipAddresses []string
+	for _, g := range addrs {
+		if ipnet, ok := g.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsLinkLocalUnicast() {
+			ipAddresses = append(ipAddresses, ipnet.IP.String())
+		}
+	}
+
+	return ipAddresses, nil
+}
@@ -0,0 +1,28 @@
+package info
+
+import (
+	"bytes"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"golang.org/x/sys/unix"
+)
+
+func getKernelInfo() (*models.KernelInfo, error) {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return nil, err
+	}
+
+	return &models.KernelInfo{
+		Release:      utsnameString(uname.Release[:]),
+		Version:      utsnameString(uname.Version[:]),
+		Architecture: utsnameString(uname.Machine[:]),
+	}, nil
+}
+
+func utsnameString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
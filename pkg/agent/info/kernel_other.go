@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package info
+
+import (
+	"runtime"
+
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+func getKernelInfo() (*models.KernelInfo, error) {
+	return &models.KernelInfo{
+		Architecture: runtime.GOARCH,
+	}, nil
+}
@@ -0,0 +1,14 @@
+package info
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func getMemoryTotal() (uint64, error) {
+	var sysinfo unix.Sysinfo_t
+	if err := unix.Sysinfo(&sysinfo); err != nil {
+		return 0, err
+	}
+
+	return uint64(sysinfo.Totalram) * uint64(sysinfo.Unit), nil
+}
@@ -1,22 +1,81 @@
 package info
 
 import (
-	//"github.com/cobaugh/osrelease"
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
 	"github.com/deviceplane/cli/pkg/models"
 )
 
+// osReleasePaths are checked in order, as described in os-release(5)
+var osReleasePaths = []string{
+	"/etc/os-release",
+	"/usr/lib/os-release",
+}
+
+// getOSRelease reads the host's os-release file. Hosts without one report
+// an empty release rather than an error.
 func getOSRelease() (*models.OSRelease, error) {
-	//osRelease, err := osrelease.Read()
-	//if err != nil {
-	//	return nil, err
-	//}
-
-	return &models.OSRelease{
-		PrettyName: "", //osRelease["PRETTY_NAME"],
-		Name:       "", //osRelease["NAME"],
-		VersionID:  "", //osRelease["VERSION_ID"],
-		Version:    "", //osRelease["VERSION"],
-		ID:         "", //osRelease["ID"],
-		IDLike:     "", //osRelease["ID_LIKE"],
-	}, nil
+	for _, path := range osReleasePaths {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		values, err := parseOSRelease(f)
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.OSRelease{
+			PrettyName: values["PRETTY_NAME"],
+			Name:       values["NAME"],
+			VersionID:  values["VERSION_ID"],
+			Version:    values["VERSION"],
+			ID:         values["ID"],
+			IDLike:     values["ID_LIKE"],
+		}, nil
+	}
+
+	return &models.OSRelease{}, nil
+}
+
+func parseOSRelease(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], parts[1]
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			} else {
+				value = strings.Trim(value, `"`)
+			}
+		case strings.HasPrefix(value, "'"):
+			value = strings.Trim(value, "'")
+		}
+
+		values[key] = value
+	}
+
+	return values, scanner.Err()
 }
@@ -0,0 +1,29 @@
+package info
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	values, err := parseOSRelease(strings.NewReader(`# comment
+NAME="Ubuntu"
+VERSION="20.04.1 LTS (Focal Fossa)"
+ID=ubuntu
+ID_LIKE=debian
+PRETTY_NAME='Ubuntu 20.04.1 LTS'
+
+VERSION_ID="20.04"
+`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"NAME":        "Ubuntu",
+		"VERSION":     "20.04.1 LTS (Focal Fossa)",
+		"ID":          "ubuntu",
+		"ID_LIKE":     "debian",
+		"PRETTY_NAME": "Ubuntu 20.04.1 LTS",
+		"VERSION_ID":  "20.04",
+	}, values)
+}
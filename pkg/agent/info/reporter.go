@@ -2,6 +2,8 @@ package info
 
 import (
 	"context"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/apex/log"
@@ -10,11 +12,23 @@ import (
 	"github.com/deviceplane/cli/pkg/models"
 )
 
+// heartbeatInterval is how often info is reported even if it hasn't changed,
+// so that the time the controller last received it shows whether the agent
+// is still reporting
+const heartbeatInterval = 10 * time.Minute
+
 type Reporter struct {
 	client       *client.Client // TODO: interface
 	agentVersion string
 
-	info models.DeviceInfo
+	info       models.DeviceInfo
+	reportedAt time.Time
+
+	bundleHookResults []models.BundleHookResult
+	state             models.DeviceState
+	stateMessage      string
+	reportLoopback    bool
+	lock              sync.RWMutex
 }
 
 func NewReporter(client *client.Client, agentVersion string) *Reporter {
@@ -24,11 +38,13 @@ func NewReporter(client *client.Client, agentVersion string) *Reporter {
 	}
 }
 
+// Report sends the device's info if it has changed since it was last sent,
+// or if it hasn't been sent for the heartbeat interval
 func (r *Reporter) Report() error {
 	newInfo := r.readInfo()
 
-	if newInfo != r.info {
-		ctx, cancel := dpcontext.New(context.Background(), time.Minute)
+	if !reflect.DeepEqual(newInfo, r.info) || time.Since(r.reportedAt) >= heartbeatInterval {
+		ctx, cancel := dpcontext.NewDefault(context.Background())
 		defer cancel()
 
 		if err := r.client.SetDeviceInfo(ctx, models.SetDeviceInfoRequest{
@@ -38,15 +54,48 @@ func (r *Reporter) Report() error {
 		}
 
 		r.info = newInfo
+		r.reportedAt = time.Now()
 	}
 
 	return nil
 }
 
+// SetBundleHookResults sets the hook results included in the next report
+func (r *Reporter) SetBundleHookResults(results []models.BundleHookResult) {
+	r.lock.Lock()
+	r.bundleHookResults = results
+	r.lock.Unlock()
+}
+
+// SetState sets the degraded state included in the next report. An empty
+// state clears it.
+func (r *Reporter) SetState(state models.DeviceState, message string) {
+	r.lock.Lock()
+	r.state = state
+	r.stateMessage = message
+	r.lock.Unlock()
+}
+
+// SetReportLoopbackInterfaces sets whether loopback interfaces are included
+// in the reported network interfaces. They're left out by default.
+func (r *Reporter) SetReportLoopbackInterfaces(report bool) {
+	r.lock.Lock()
+	r.reportLoopback = report
+	r.lock.Unlock()
+}
+
 func (r *Reporter) readInfo() models.DeviceInfo {
+	r.lock.RLock()
 	info := models.DeviceInfo{
-		AgentVersion: r.agentVersion,
+		AgentVersion:      r.agentVersion,
+		BundleHookResults: r.bundleHookResults,
+		State:             r.state,
+		StateMessage:      r.stateMessage,
+
+		SupportedBundleSchemaVersion: models.BundleSchemaVersion,
 	}
+	reportLoopback := r.reportLoopback
+	r.lock.RUnlock()
 
 	ipAddress, err := getIPAddress()
 	if err == nil {
@@ -55,6 +104,20 @@ func (r *Reporter) readInfo() models.DeviceInfo {
 		log.WithError(err).Error("failed to get IP address")
 	}
 
+	ipAddresses, err := getIPAddresses()
+	if err == nil {
+		info.IPAddresses = ipAddresses
+	} else {
+		log.WithError(err).Error("failed to get IP addresses")
+	}
+
+	networkInterfaces, err := getNetworkInterfaces(reportLoopback)
+	if err == nil {
+		info.NetworkInterfaces = networkInterfaces
+	} else {
+		log.WithError(err).Error("failed to get network interfaces")
+	}
+
 	osRelease, err := getOSRelease()
 	if err == nil {
 		info.OSRelease = *osRelease
@@ -62,5 +125,19 @@ func (r *Reporter) readInfo() models.DeviceInfo {
 		log.WithError(err).Error("failed to get OS release")
 	}
 
+	kernel, err := getKernelInfo()
+	if err == nil {
+		info.Kernel = *kernel
+	} else {
+		log.WithError(err).Error("failed to get kernel info")
+	}
+
+	memoryTotal, err := getMemoryTotal()
+	if err == nil {
+		info.MemoryTotalBytes = memoryTotal
+	} else {
+		log.WithError(err).Error("failed to get total memory")
+	}
+
 	return info
 }
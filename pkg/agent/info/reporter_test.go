@@ -0,0 +1,40 @@
+package info
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/agent/client"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportHeartbeat(t *testing.T) {
+	var reports int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reports, 1)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	r := NewReporter(client.NewClient(serverURL, "prj_test", nil), "1.0.0")
+
+	require.NoError(t, r.Report())
+	require.NoError(t, r.Report())
+	require.Equal(t, int32(1), atomic.LoadInt32(&reports))
+
+	// Unchanged info is still sent once the heartbeat is due
+	r.reportedAt = time.Now().Add(-heartbeatInterval)
+	require.NoError(t, r.Report())
+	require.Equal(t, int32(2), atomic.LoadInt32(&reports))
+
+	r.SetState(models.DeviceStateStorageError, "disk full")
+	require.NoError(t, r.Report())
+	require.Equal(t, int32(3), atomic.LoadInt32(&reports))
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+const (
+	livenessFilename        = "liveness"
+	defaultLivenessInterval = 30 * time.Second
+
+	// A loop that hasn't started an iteration for this long, or for two of
+	// its intervals if that's longer, is considered stuck
+	loopStallTimeout = 10 * time.Minute
+
+	bundleApplierLoop = "bundle applier"
+	infoReporterLoop  = "info reporter"
+)
+
+// SetLivenessFile makes the agent write the current time to a file every
+// interval, for external supervisors to detect a wedged agent by a stale
+// file. The file is only written while the agent's main loops are making
+// progress. An empty path uses "liveness" in the state dir, and a
+// non-positive interval uses 30 seconds. Must be called before Run.
+func (a *Agent) SetLivenessFile(filename string, interval time.Duration) {
+	if filename == "" {
+		filename = path.Join(a.stateDir, livenessFilename)
+	}
+	if interval <= 0 {
+		interval = defaultLivenessInterval
+	}
+	a.livenessFile = filename
+	a.livenessInterval = interval
+}
+
+// markProgress records that loop has started another iteration
+func (a *Agent) markProgress(loop string) {
+	a.healthLock.Lock()
+	defer a.healthLock.Unlock()
+
+	if a.loopProgress == nil {
+		a.loopProgress = make(map[string]time.Time)
+	}
+	a.loopProgress[loop] = time.Now()
+}
+
+// stuckLoops returns the loops that haven't made progress within their stall
+// timeout before now
+func (a *Agent) stuckLoops(now time.Time) []string {
+	a.healthLock.Lock()
+	defer a.healthLock.Unlock()
+
+	var stuck []string
+	for loop, progress := range a.loopProgress {
+		if now.Sub(progress) > a.loopStallTimeout(loop) {
+			stuck = append(stuck, loop)
+		}
+	}
+	sort.Strings(stuck)
+	return stuck
+}
+
+func (a *Agent) loopStallTimeout(loop string) time.Duration {
+	var interval time.Duration
+	switch loop {
+	case bundleApplierLoop:
+		interval = a.bundlePollInterval
+	case infoReporterLoop:
+		interval = a.infoReportInterval
+	}
+	if 2*interval > loopStallTimeout {
+		return 2 * interval
+	}
+	return loopStallTimeout
+}
+
+func (a *Agent) runLivenessWriter() {
+	ticker := time.NewTicker(a.livenessInterval)
+	defer ticker.Stop()
+
+	wasStuck := false
+
+	for {
+		now := time.Now()
+		if stuck := a.stuckLoops(now); len(stuck) > 0 {
+			if !wasStuck {
+				log.WithField("loops", strings.Join(stuck, ", ")).
+					Error("agent is stuck, no longer updating liveness file")
+			}
+			wasStuck = true
+			goto cont
+		}
+		if wasStuck {
+			log.Info("agent recovered, updating liveness file again")
+			wasStuck = false
+		}
+
+		if err := ioutil.WriteFile(a.livenessFile, []byte(now.UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+			log.WithError(err).Error("write liveness file")
+		}
+
+	cont:
+		select {
+		case <-ticker.C:
+			continue
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+// Syslog priorities, which journald uses for PRIORITY
+var journaldPriorities = map[log.Level]int{
+	log.DebugLevel: 7,
+	log.InfoLevel:  6,
+	log.WarnLevel:  4,
+	log.ErrorLevel: 3,
+	log.FatalLevel: 2,
+}
+
+// JournaldHandler sends entries to journald using its native protocol, so
+// fields are kept as structured journal fields
+type JournaldHandler struct {
+	identifier string
+	conn       *net.UnixConn
+}
+
+func NewJournaldHandler(identifier string) (*JournaldHandler, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{
+		Name: journaldSocket,
+		Net:  "unixgram",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to journald")
+	}
+	return &JournaldHandler{
+		identifier: identifier,
+		conn:       conn,
+	}, nil
+}
+
+func (h *JournaldHandler) HandleLog(e *log.Entry) error {
+	var b bytes.Buffer
+	writeJournaldField(&b, "MESSAGE", e.Message)
+	writeJournaldField(&b, "PRIORITY", fmt.Sprint(journaldPriorities[e.Level]))
+	writeJournaldField(&b, "SYSLOG_IDENTIFIER", h.identifier)
+	for name, value := range e.Fields {
+		writeJournaldField(&b, journaldFieldName(name), fmt.Sprint(value))
+	}
+
+	_, err := h.conn.Write(b.Bytes())
+	return err
+}
+
+// writeJournaldField appends a field in journald's native format. Values
+// containing newlines are length-prefixed.
+func writeJournaldField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journaldFieldName converts a log field name to a valid journal field
+// name, which may only hold uppercase letters, digits and underscores and
+// must not start with an underscore
+func journaldFieldName(name string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return unicode.ToUpper(r)
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	mapped = strings.TrimLeft(mapped, "_")
+	if mapped == "" || (mapped[0] >= '0' && mapped[0] <= '9') {
+		mapped = "FIELD_" + mapped
+	}
+	return mapped
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+
+	"github.com/apex/log"
+)
+
+// Level is how verbose the agent's logs are. apex/log has nothing more
+// verbose than debug, so trace logs at debug and also turns on Tracing, which
+// gates logs that are too frequent even for debug.
+type Level string
+
+const (
+	LevelInfo  = Level("info")
+	LevelDebug = Level("debug")
+	LevelTrace = Level("trace")
+)
+
+// tracing is 1 while the level is trace
+var tracing int32
+
+// Tracing returns whether the level is trace
+func Tracing() bool {
+	return atomic.LoadInt32(&tracing) == 1
+}
+
+// CurrentLevel returns the level logs are filtered at. Levels less verbose
+// than info, such as the one --quiet sets, are reported as info.
+func CurrentLevel() Level {
+	if Tracing() {
+		return LevelTrace
+	}
+	if logger, ok := log.Log.(*log.Logger); ok && logger.Level <= log.DebugLevel {
+		return LevelDebug
+	}
+	return LevelInfo
+}
+
+func SetLevel(level Level) {
+	switch level {
+	case LevelTrace:
+		log.SetLevel(log.DebugLevel)
+		atomic.StoreInt32(&tracing, 1)
+	case LevelDebug:
+		log.SetLevel(log.DebugLevel)
+		atomic.StoreInt32(&tracing, 0)
+	default:
+		log.SetLevel(log.InfoLevel)
+		atomic.StoreInt32(&tracing, 0)
+	}
+}
+
+func nextLevel(level Level) Level {
+	switch level {
+	case LevelInfo:
+		return LevelDebug
+	case LevelDebug:
+		return LevelTrace
+	default:
+		return LevelInfo
+	}
+}
+
+// CycleLevel moves to the next of info, debug and trace, and back to info
+// after trace, and returns the new level
+func CycleLevel() Level {
+	level := nextLevel(CurrentLevel())
+	SetLevel(level)
+	return level
+}
+
+// CycleLevelOnSignal cycles the level whenever the agent receives sig, so
+// that a running agent's logs can be made more verbose without restarting it
+func CycleLevelOnSignal(sig os.Signal) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig)
+
+	go func() {
+		for range signals {
+			level := CycleLevel()
+			// Logged at info so that it's seen whatever the new level is
+			log.WithField("signal", sig.String()).Infof("log level set to %s", level)
+		}
+	}()
+}
@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCycleLevel(t *testing.T) {
+	defer SetLevel(LevelInfo)
+
+	log.SetLevel(log.ErrorLevel)
+	require.Equal(t, LevelInfo, CurrentLevel())
+
+	require.Equal(t, LevelDebug, CycleLevel())
+	require.False(t, Tracing())
+
+	require.Equal(t, LevelTrace, CycleLevel())
+	require.True(t, Tracing())
+	require.Equal(t, log.DebugLevel, log.Log.(*log.Logger).Level)
+
+	require.Equal(t, LevelInfo, CycleLevel())
+	require.False(t, Tracing())
+	require.Equal(t, log.InfoLevel, log.Log.(*log.Logger).Level)
+}
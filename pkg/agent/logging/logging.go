@@ -0,0 +1,91 @@
+// Package logging provides the sinks the agent can send its own logs to
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+type Driver string
+
+const (
+	DriverStdout   = Driver("stdout")
+	DriverFile     = Driver("file")
+	DriverSyslog   = Driver("syslog")
+	DriverJournald = Driver("journald")
+
+	// Identifier is the name the agent logs under in syslog and journald
+	Identifier = "deviceplane-agent"
+
+	logFilename       = "agent.log"
+	logFileMaxSize    = 10 * 1024 * 1024
+	logFileMaxBackups = 3
+)
+
+// Drivers lists the supported drivers, for use in flag help
+var Drivers = []Driver{
+	DriverStdout,
+	DriverFile,
+	DriverSyslog,
+	DriverJournald,
+}
+
+// Configure sends all agent logs to the given driver. The file driver writes
+// to a rotating file in stateDir.
+func Configure(driver Driver, stateDir string) error {
+	handler, err := NewHandler(driver, stateDir)
+	if err != nil {
+		return err
+	}
+	log.SetHandler(handler)
+	return nil
+}
+
+func NewHandler(driver Driver, stateDir string) (log.Handler, error) {
+	switch driver {
+	case DriverStdout, "":
+		return NewTextHandler(os.Stdout), nil
+	case DriverFile:
+		if err := os.MkdirAll(stateDir, 0700); err != nil {
+			return nil, errors.Wrap(err, "create state dir")
+		}
+		file, err := NewRotatingFile(path.Join(stateDir, logFilename), logFileMaxSize, logFileMaxBackups)
+		if err != nil {
+			return nil, errors.Wrap(err, "open log file")
+		}
+		return NewTextHandler(file), nil
+	case DriverSyslog:
+		return NewSyslogHandler(Identifier)
+	case DriverJournald:
+		return NewJournaldHandler(Identifier)
+	default:
+		return nil, fmt.Errorf("unknown log driver %q", driver)
+	}
+}
+
+// formatEntry renders an entry as a single line, with fields sorted by name
+func formatEntry(e *log.Entry, withTimestamp bool) string {
+	var b bytes.Buffer
+	if withTimestamp {
+		fmt.Fprintf(&b, "%s ", e.Timestamp.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "%5s %-25s", e.Level, e.Message)
+
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, " %s=%v", name, e.Fields[name])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := path.Join(dir, "agent.log")
+	file, err := NewRotatingFile(filename, 10, 2)
+	require.NoError(t, err)
+	defer file.Close()
+
+	for _, line := range []string{"aaaaaaa\n", "bbbbbbb\n", "ccccccc\n", "ddddddd\n"} {
+		_, err := file.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	for name, expected := range map[string]string{
+		"agent.log":   "ddddddd\n",
+		"agent.log.1": "ccccccc\n",
+		"agent.log.2": "bbbbbbb\n",
+	} {
+		contents, err := ioutil.ReadFile(path.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(contents), name)
+	}
+
+	_, err = os.Stat(path.Join(dir, "agent.log.3"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestTextHandler(t *testing.T) {
+	var b bytes.Buffer
+	handler := NewTextHandler(&b)
+
+	require.NoError(t, handler.HandleLog(&log.Entry{
+		Level:     log.ErrorLevel,
+		Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message:   "save bundle",
+		Fields: log.Fields{
+			"error":   "disk full",
+			"attempt": 2,
+		},
+	}))
+	require.Equal(t, "2020-01-02T03:04:05Z error save bundle               attempt=2 error=disk full\n", b.String())
+}
+
+func TestJournaldFields(t *testing.T) {
+	require.Equal(t, "APPLICATION_ID", journaldFieldName("application_id"))
+	require.Equal(t, "CONTAINER_ID", journaldFieldName("container-id"))
+	require.Equal(t, "FIELD_1X", journaldFieldName("1x"))
+
+	var b bytes.Buffer
+	writeJournaldField(&b, "MESSAGE", "one line")
+	writeJournaldField(&b, "ERROR", "two\nlines")
+	require.Equal(t, "MESSAGE=one line\nERROR\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n", b.String())
+}
@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"log/syslog"
+
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+// SyslogHandler sends entries to the local syslog daemon
+type SyslogHandler struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogHandler(tag string) (*SyslogHandler, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to syslog")
+	}
+	return &SyslogHandler{
+		writer: writer,
+	}, nil
+}
+
+func (h *SyslogHandler) HandleLog(e *log.Entry) error {
+	line := formatEntry(e, false)
+
+	switch e.Level {
+	case log.DebugLevel:
+		return h.writer.Debug(line)
+	case log.InfoLevel:
+		return h.writer.Info(line)
+	case log.WarnLevel:
+		return h.writer.Warning(line)
+	case log.ErrorLevel:
+		return h.writer.Err(line)
+	default:
+		return h.writer.Crit(line)
+	}
+}
@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/apex/log"
+)
+
+// TextHandler writes one timestamped line per entry
+type TextHandler struct {
+	w    io.Writer
+	lock sync.Mutex
+}
+
+func NewTextHandler(w io.Writer) *TextHandler {
+	return &TextHandler{
+		w: w,
+	}
+}
+
+func (h *TextHandler) HandleLog(e *log.Entry) error {
+	line := formatEntry(e, true)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	_, err := fmt.Fprintln(h.w, line)
+	return err
+}
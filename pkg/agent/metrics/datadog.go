@@ -1,12 +1,14 @@
 package metrics
 
 import (
+	"bytes"
 	"context"
 	"sync"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/deviceplane/cli/pkg/agent/client"
+	"github.com/deviceplane/cli/pkg/agent/variables"
 	dpcontext "github.com/deviceplane/cli/pkg/context"
 	"github.com/deviceplane/cli/pkg/metrics/datadog/processing"
 	"github.com/deviceplane/cli/pkg/metrics/datadog/translation"
@@ -14,23 +16,37 @@ import (
 )
 
 type MetricsPusher struct {
-	client                *client.Client
+	cloudSink             Sink
+	variables             variables.Interface
 	statsCache            *translation.StatsCache
 	serviceMetricsFetcher *ServiceMetricsFetcher
+	exporter              *Exporter
+	interval              time.Duration
 
 	lock sync.Mutex
 	once sync.Once
 
 	bundle models.Bundle
+
+	localSink         Sink
+	localSinkEndpoint string
 }
 
 func NewMetricsPusher(
 	client *client.Client,
+	variables variables.Interface,
 	serviceMetricsFetcher *ServiceMetricsFetcher,
+	exporter *Exporter,
+	interval time.Duration,
 ) *MetricsPusher {
 	return &MetricsPusher{
-		client:                client,
+		cloudSink: &cloudSink{
+			client: client,
+		},
+		variables:             variables,
 		serviceMetricsFetcher: serviceMetricsFetcher,
+		exporter:              exporter,
+		interval:              interval,
 
 		statsCache: translation.NewStatsCache(),
 	}
@@ -46,16 +62,52 @@ func (m *MetricsPusher) SetBundle(bundle models.Bundle) {
 	})
 }
 
+// sinks returns where metrics should currently be pushed. The Deviceplane
+// backend is used unless disabled, and a local collector is added if one
+// is configured.
+func (m *MetricsPusher) sinks() []Sink {
+	var sinks []Sink
+	if !m.variables.GetDisableCloudMetrics() {
+		sinks = append(sinks, m.cloudSink)
+	}
+
+	endpoint := m.variables.GetLocalMetricsEndpoint()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if endpoint != m.localSinkEndpoint {
+		m.localSink = nil
+		m.localSinkEndpoint = endpoint
+		if endpoint != "" {
+			localSink, err := NewLocalSink(endpoint)
+			if err != nil {
+				log.WithError(err).Error("invalid local metrics endpoint")
+			}
+			m.localSink = localSink
+		}
+	}
+
+	if m.localSink != nil {
+		sinks = append(sinks, m.localSink)
+	}
+	return sinks
+}
+
 func (m *MetricsPusher) begin() {
-	ticker := time.NewTicker(time.Minute)
+	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
 
 	for {
 		ctx, cancel := dpcontext.New(context.Background(), 10*time.Second)
 
 		var wg sync.WaitGroup
-		wg.Add(2)
+		wg.Add(3)
 
+		go func() {
+			m.PushAgentMetrics(ctx)
+			wg.Done()
+		}()
 		go func() {
 			m.PushDeviceMetrics(ctx)
 			wg.Done()
@@ -72,6 +124,26 @@ func (m *MetricsPusher) begin() {
 	}
 }
 
+// PushAgentMetrics pushes the agent's metrics about itself along with the
+// device metrics, whether or not the project exposes any device metrics
+func (m *MetricsPusher) PushAgentMetrics(ctx *dpcontext.Context) {
+	agentMetrics, err := m.exporter.agentMetrics(m.statsCache)
+	if err != nil {
+		log.WithError(err).Error("could not get agent metrics")
+		return
+	}
+
+	if len(agentMetrics) == 0 {
+		return
+	}
+
+	for _, sink := range m.sinks() {
+		if err := sink.SendDeviceMetrics(ctx, agentMetrics); err != nil {
+			log.WithError(err).Error("could not send agent metrics")
+		}
+	}
+}
+
 func (m *MetricsPusher) PushDeviceMetrics(ctx *dpcontext.Context) {
 	if m.bundle.DeviceMetricsConfig == nil {
 		return
@@ -102,11 +174,10 @@ func (m *MetricsPusher) PushDeviceMetrics(ctx *dpcontext.Context) {
 		return
 	}
 
-	err = m.client.SendDeviceMetrics(ctx, models.DatadogPostMetricsRequest{
-		Series: processedMetrics,
-	})
-	if err != nil {
-		log.WithError(err).Error("could not POST device metrics")
+	for _, sink := range m.sinks() {
+		if err := sink.SendDeviceMetrics(ctx, processedMetrics); err != nil {
+			log.WithError(err).Error("could not send device metrics")
+		}
 	}
 }
 
@@ -129,6 +200,13 @@ func (m *MetricsPusher) PushServiceMetrics(ctx *dpcontext.Context) {
 		serviceConfigsByID[config.ApplicationID] = &m.bundle.ServiceMetricsConfigs[i]
 	}
 
+	type serviceTarget struct {
+		app           *models.FullBundledApplication
+		serviceConfig *models.ServiceMetricsConfig
+	}
+	var requests []ServiceMetricsRequest
+	var targets []serviceTarget
+
 	for _, service := range m.bundle.ServiceStatuses {
 		app, exists := appsByID[service.ApplicationID]
 		if !exists {
@@ -150,46 +228,59 @@ func (m *MetricsPusher) PushServiceMetrics(ctx *dpcontext.Context) {
 			config.Path = models.DefaultMetricPath
 		}
 
-		metricResponse, err := m.serviceMetricsFetcher.ContainerServiceMetrics(
-			ctx,
-			service.ApplicationID,
-			service.Service,
-			int(config.Port),
-			config.Path,
-		)
-		if err != nil {
-			log.WithField("application_id", service.ApplicationID).
-				WithField("service", service.Service).Error("could not fetch service metrics")
+		requests = append(requests, ServiceMetricsRequest{
+			ApplicationID: service.ApplicationID,
+			Service:       service.Service,
+			Port:          int(config.Port),
+			Path:          config.Path,
+		})
+		targets = append(targets, serviceTarget{
+			app:           app,
+			serviceConfig: serviceConfig,
+		})
+	}
+
+	results := m.serviceMetricsFetcher.ContainerServicesMetrics(ctx, requests)
+	for i, result := range results {
+		if result.Err != nil {
+			log.WithField("application_id", result.ApplicationID).
+				WithField("service", result.Service).WithError(result.Err).Error("could not fetch service metrics")
 			continue
 		}
-		defer metricResponse.Body.Close()
+		app := targets[i].app
 
 		convertedMetrics, err := translation.ConvertOpenMetricsToDataDog(
-			metricResponse.Body,
+			bytes.NewReader(result.Body),
 			m.statsCache,
 			"service-metrics",
 		)
+		if err != nil {
+			log.WithField("application_id", result.ApplicationID).
+				WithField("service", result.Service).WithError(err).Error("could not convert service metrics")
+			continue
+		}
 
-		processedMetrics := processing.ProcessServiceMetrics(app.Application.Name, service.Service)(
+		processedMetrics := processing.ProcessServiceMetrics(app.Application.Name, result.Service)(
 			convertedMetrics,
-			serviceConfig.ExposedMetrics,
+			targets[i].serviceConfig.ExposedMetrics,
 			nil,
 			nil,
 		)
 
-		_, exists = datadogMetrics[app.Application.ID]
+		_, exists := datadogMetrics[app.Application.ID]
 		if !exists {
 			datadogMetrics[app.Application.ID] = make(map[string]models.DatadogSeries)
 		}
-		datadogMetrics[app.Application.ID][service.Service] = processedMetrics
+		datadogMetrics[app.Application.ID][result.Service] = processedMetrics
 	}
 
 	if len(datadogMetrics) == 0 {
 		return
 	}
 
-	err := m.client.SendServiceMetrics(ctx, datadogMetrics)
-	if err != nil {
-		log.WithError(err).Error("could not POST service metrics")
+	for _, sink := range m.sinks() {
+		if err := sink.SendServiceMetrics(ctx, datadogMetrics); err != nil {
+			log.WithError(err).Error("could not send service metrics")
+		}
 	}
 }
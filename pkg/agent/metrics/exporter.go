@@ -0,0 +1,228 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/metrics/datadog/processing"
+	"github.com/deviceplane/cli/pkg/metrics/datadog/translation"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	serviceStatsTimeout = 5 * time.Second
+
+	agentMetricNamePrefix = "deviceplane_agent_"
+)
+
+var (
+	serviceCPUSecondsDesc = prometheus.NewDesc(
+		"deviceplane_service_cpu_seconds_total",
+		"Total CPU time consumed by a service's container.",
+		[]string{"application_id", "service"}, nil,
+	)
+	serviceMemoryUsageDesc = prometheus.NewDesc(
+		"deviceplane_service_memory_usage_bytes",
+		"Memory used by a service's container, excluding page cache.",
+		[]string{"application_id", "service"}, nil,
+	)
+	serviceMemoryLimitDesc = prometheus.NewDesc(
+		"deviceplane_service_memory_limit_bytes",
+		"Memory limit of a service's container.",
+		[]string{"application_id", "service"}, nil,
+	)
+)
+
+// Exporter serves service resource usage and agent counters in the
+// Prometheus text format, for scraping by on-device collectors. The agent's
+// default registry, which holds Go runtime and process metrics, is
+// included as well.
+type Exporter struct {
+	serviceMetricsFetcher *ServiceMetricsFetcher
+	registry              *prometheus.Registry
+
+	// The agent's metrics about itself are kept apart from the service
+	// metrics so they can also be pushed
+	agentRegistry          *prometheus.Registry
+	bundleDownloads        prometheus.Counter
+	bundleDownloadFailures prometheus.Counter
+	lastBundleDownload     atomic.Value
+	bundleApplyFailures    prometheus.Counter
+	hookFailures           prometheus.Counter
+	rollbacks              prometheus.Counter
+	infoReportDuration     prometheus.Gauge
+	runningServices        prometheus.Gauge
+	desiredServices        prometheus.Gauge
+	updatePending          prometheus.Gauge
+	updateFailures         prometheus.Counter
+}
+
+func NewExporter(serviceMetricsFetcher *ServiceMetricsFetcher) *Exporter {
+	e := &Exporter{
+		serviceMetricsFetcher: serviceMetricsFetcher,
+		registry:              prometheus.NewRegistry(),
+		agentRegistry:         prometheus.NewRegistry(),
+
+		bundleDownloads: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deviceplane_agent_bundle_downloads_total",
+			Help: "Number of successful downloads of the device's bundle.",
+		}),
+		bundleDownloadFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deviceplane_agent_bundle_download_failures_total",
+			Help: "Number of failed attempts to download the device's bundle.",
+		}),
+		bundleApplyFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deviceplane_agent_bundle_apply_failures_total",
+			Help: "Number of applied bundles whose services failed.",
+		}),
+		hookFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deviceplane_agent_bundle_hook_failures_total",
+			Help: "Number of bundle hooks that failed.",
+		}),
+		rollbacks: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deviceplane_agent_rollbacks_total",
+			Help: "Number of rollbacks to the last known good bundle.",
+		}),
+		infoReportDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "deviceplane_agent_info_report_duration_seconds",
+			Help: "How long the last report of the device's info took.",
+		}),
+		runningServices: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "deviceplane_agent_running_services",
+			Help: "Number of services of the applied bundle that are running.",
+		}),
+		desiredServices: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "deviceplane_agent_desired_services",
+			Help: "Number of services in the applied bundle.",
+		}),
+		updatePending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "deviceplane_agent_update_pending",
+			Help: "1 if the agent isn't running the version desired for the device, 0 otherwise.",
+		}),
+		updateFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deviceplane_agent_update_failures_total",
+			Help: "Number of failed attempts to update the agent.",
+		}),
+	}
+
+	e.lastBundleDownload.Store(time.Now())
+
+	e.registry.MustRegister(e)
+	e.agentRegistry.MustRegister(
+		e.bundleDownloads,
+		e.bundleDownloadFailures,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "deviceplane_agent_bundle_download_age_seconds",
+			Help: "Time since the device's bundle was last downloaded, or since the agent started if it hasn't been yet.",
+		}, func() float64 {
+			return time.Since(e.lastBundleDownload.Load().(time.Time)).Seconds()
+		}),
+		e.bundleApplyFailures,
+		e.hookFailures,
+		e.rollbacks,
+		e.infoReportDuration,
+		e.runningServices,
+		e.desiredServices,
+		e.updatePending,
+		e.updateFailures,
+	)
+
+	return e
+}
+
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(
+		prometheus.Gatherers{prometheus.DefaultGatherer, e.registry, e.agentRegistry},
+		promhttp.HandlerOpts{},
+	)
+}
+
+func (e *Exporter) IncBundleDownloadFailures() { e.bundleDownloadFailures.Inc() }
+func (e *Exporter) IncBundleApplyFailures()    { e.bundleApplyFailures.Inc() }
+func (e *Exporter) IncHookFailures()           { e.hookFailures.Inc() }
+func (e *Exporter) IncRollbacks()              { e.rollbacks.Inc() }
+func (e *Exporter) IncUpdateFailures()         { e.updateFailures.Inc() }
+
+// BundleDownloaded records a successful download of the device's bundle
+func (e *Exporter) BundleDownloaded(at time.Time) {
+	e.bundleDownloads.Inc()
+	e.lastBundleDownload.Store(at)
+}
+
+func (e *Exporter) SetInfoReportDuration(d time.Duration) {
+	e.infoReportDuration.Set(d.Seconds())
+}
+
+func (e *Exporter) SetServiceCounts(running, desired int) {
+	e.runningServices.Set(float64(running))
+	e.desiredServices.Set(float64(desired))
+}
+
+func (e *Exporter) SetUpdatePending(pending bool) {
+	if pending {
+		e.updatePending.Set(1)
+	} else {
+		e.updatePending.Set(0)
+	}
+}
+
+// agentMetrics returns the agent's metrics about itself, named for pushing.
+// Counters are converted to the change since they were last pushed.
+func (e *Exporter) agentMetrics(statsCache *translation.StatsCache) (models.DatadogSeries, error) {
+	families, err := e.agentRegistry.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			return nil, err
+		}
+	}
+
+	agentMetrics, err := translation.ConvertOpenMetricsToDataDog(&buf, statsCache, "agent-metrics")
+	if err != nil {
+		return nil, err
+	}
+	for i := range agentMetrics {
+		agentMetrics[i].Metric = processing.AgentMetricPrefix + strings.TrimPrefix(agentMetrics[i].Metric, agentMetricNamePrefix)
+	}
+	return agentMetrics, nil
+}
+
+// Describe implements prometheus.Collector for the service metrics
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serviceCPUSecondsDesc
+	ch <- serviceMemoryUsageDesc
+	ch <- serviceMemoryLimitDesc
+}
+
+// Collect implements prometheus.Collector for the service metrics, which
+// are read from the engine on every scrape
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), serviceStatsTimeout)
+	defer cancel()
+
+	stats, err := e.serviceMetricsFetcher.ServiceStats(ctx)
+	if err != nil {
+		log.WithError(err).Error("could not get service stats")
+		return
+	}
+
+	for _, s := range stats {
+		ch <- prometheus.MustNewConstMetric(serviceCPUSecondsDesc, prometheus.CounterValue, s.CPUSeconds, s.ApplicationID, s.Service)
+		ch <- prometheus.MustNewConstMetric(serviceMemoryUsageDesc, prometheus.GaugeValue, float64(s.MemoryUsageBytes), s.ApplicationID, s.Service)
+		if s.MemoryLimitBytes != 0 {
+			ch <- prometheus.MustNewConstMetric(serviceMemoryLimitDesc, prometheus.GaugeValue, float64(s.MemoryLimitBytes), s.ApplicationID, s.Service)
+		}
+	}
+}
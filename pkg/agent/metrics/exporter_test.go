@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/deviceplane/cli/pkg/metrics/datadog/translation"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+type statsEngine struct {
+	engine.Engine
+}
+
+func (statsEngine) ListContainers(context.Context, map[string]struct{}, map[string]string, bool) ([]engine.Instance, error) {
+	return []engine.Instance{{
+		ID: "container",
+		Labels: map[string]string{
+			models.ApplicationLabel: "app_1",
+			models.ServiceLabel:     "web",
+		},
+	}}, nil
+}
+
+func (statsEngine) ContainerStats(context.Context, string) (*engine.ContainerStats, error) {
+	return &engine.ContainerStats{
+		CPUSeconds:       1.5,
+		MemoryUsageBytes: 1024,
+	}, nil
+}
+
+func TestExporter(t *testing.T) {
+	exporter := NewExporter(NewServiceMetricsFetcher(nil, nil, statsEngine{}))
+	exporter.IncRollbacks()
+
+	rec := httptest.NewRecorder()
+	exporter.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	require.Contains(t, body, `deviceplane_service_cpu_seconds_total{application_id="app_1",service="web"} 1.5`)
+	require.Contains(t, body, `deviceplane_service_memory_usage_bytes{application_id="app_1",service="web"} 1024`)
+	require.NotContains(t, body, "deviceplane_service_memory_limit_bytes{")
+	require.Contains(t, body, "deviceplane_agent_rollbacks_total 1")
+	require.Contains(t, body, "deviceplane_agent_bundle_apply_failures_total 0")
+}
+
+func TestExporterAgentMetrics(t *testing.T) {
+	exporter := NewExporter(NewServiceMetricsFetcher(nil, nil, statsEngine{}))
+	exporter.SetServiceCounts(2, 3)
+	statsCache := translation.NewStatsCache()
+
+	values := func() map[string]float64 {
+		agentMetrics, err := exporter.agentMetrics(statsCache)
+		require.NoError(t, err)
+		values := make(map[string]float64)
+		for _, m := range agentMetrics {
+			_, value, ok := pointValue(m.Points[0])
+			require.True(t, ok)
+			values[m.Metric] = value
+		}
+		return values
+	}
+
+	// Counters are only pushed once there's a previous value to compare to
+	first := values()
+	require.Equal(t, float64(2), first["deviceplane.agent.running_services"])
+	require.Equal(t, float64(3), first["deviceplane.agent.desired_services"])
+	require.NotContains(t, first, "deviceplane.agent.bundle_downloads_total")
+
+	exporter.BundleDownloaded(time.Now().Add(-time.Hour))
+	exporter.BundleDownloaded(time.Now().Add(-time.Minute))
+	second := values()
+	require.Equal(t, float64(2), second["deviceplane.agent.bundle_downloads_total"])
+	require.InDelta(t, 60, second["deviceplane.agent.bundle_download_age_seconds"], 1)
+
+	exporter.BundleDownloaded(time.Now())
+	require.Equal(t, float64(1), values()["deviceplane.agent.bundle_downloads_total"])
+}
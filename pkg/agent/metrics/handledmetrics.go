@@ -3,17 +3,24 @@ package metrics
 import (
 	"bytes"
 	"context"
+	"io/ioutil"
 	"net/http"
 	"sync"
 
 	"github.com/apex/log"
 	"github.com/deviceplane/cli/pkg/agent/netns"
 	"github.com/deviceplane/cli/pkg/agent/supervisor"
+	"github.com/deviceplane/cli/pkg/engine"
 	"github.com/deviceplane/cli/pkg/metrics/datadog/processing"
+	"github.com/deviceplane/cli/pkg/models"
 	"github.com/deviceplane/cli/pkg/utils"
 	"github.com/pkg/errors"
 )
 
+// serviceMetricsConcurrency bounds how many services are queried for metrics
+// at once
+const serviceMetricsConcurrency = 4
+
 var once sync.Once
 var hostMetricsHandler http.Handler
 
@@ -71,16 +78,120 @@ func FilteredHostMetricsHandler() http.Handler {
 type ServiceMetricsFetcher struct {
 	supervisorLookup supervisor.Lookup
 	netnsManager     *netns.Manager
+	engine           engine.Engine
 }
 
 func NewServiceMetricsFetcher(
 	supervisorLookup supervisor.Lookup,
 	netnsManager *netns.Manager,
+	engine engine.Engine,
 ) *ServiceMetricsFetcher {
 	return &ServiceMetricsFetcher{
 		supervisorLookup: supervisorLookup,
 		netnsManager:     netnsManager,
+		engine:           engine,
+	}
+}
+
+type ServiceStats struct {
+	ApplicationID string
+	Service       string
+	engine.ContainerStats
+}
+
+// ServiceStats returns the resource usage of every running service
+// container. Containers whose stats can't be read are skipped.
+func (s *ServiceMetricsFetcher) ServiceStats(ctx context.Context) ([]ServiceStats, error) {
+	instances, err := s.engine.ListContainers(ctx, map[string]struct{}{
+		models.ApplicationLabel: struct{}{},
+		models.ServiceLabel:     struct{}{},
+	}, nil, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "list containers")
+	}
+
+	allStats := make([]*ServiceStats, len(instances))
+	runConcurrently(len(instances), serviceMetricsConcurrency, func(i int) {
+		instance := instances[i]
+		containerStats, err := s.engine.ContainerStats(ctx, instance.ID)
+		if err != nil {
+			log.WithField("container_id", instance.ID).WithError(err).Error("could not get container stats")
+			return
+		}
+		allStats[i] = &ServiceStats{
+			ApplicationID:  instance.Labels[models.ApplicationLabel],
+			Service:        instance.Labels[models.ServiceLabel],
+			ContainerStats: *containerStats,
+		}
+	})
+
+	var stats []ServiceStats
+	for _, stat := range allStats {
+		if stat != nil {
+			stats = append(stats, *stat)
+		}
+	}
+	return stats, nil
+}
+
+type ServiceMetricsRequest struct {
+	ApplicationID string
+	Service       string
+	Port          int
+	Path          string
+}
+
+type ServiceMetricsResult struct {
+	ServiceMetricsRequest
+	Body []byte
+	Err  error
+}
+
+// ContainerServicesMetrics scrapes the metrics endpoints of several services
+// concurrently. Results are returned in the order of requests, and a failure
+// to scrape one service is reported in its result alone.
+func (s *ServiceMetricsFetcher) ContainerServicesMetrics(ctx context.Context, requests []ServiceMetricsRequest) []ServiceMetricsResult {
+	results := make([]ServiceMetricsResult, len(requests))
+	runConcurrently(len(requests), serviceMetricsConcurrency, func(i int) {
+		request := requests[i]
+		results[i].ServiceMetricsRequest = request
+
+		resp, err := s.ContainerServiceMetrics(
+			ctx, request.ApplicationID, request.Service, request.Port, request.Path,
+		)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			results[i].Err = errors.Wrap(err, "read service metrics")
+			return
+		}
+		results[i].Body = body
+	})
+	return results
+}
+
+// runConcurrently calls fn for each index below count, running at most
+// limit calls at a time, and returns once all calls have finished
+func runConcurrently(count, limit int, fn func(int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
 	}
+	wg.Wait()
 }
 
 func (s *ServiceMetricsFetcher) ContainerServiceMetrics(ctx context.Context, applicationID, service string, port int, path string) (*http.Response, error) {
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunConcurrently(t *testing.T) {
+	var lock sync.Mutex
+	var running, maxRunning int
+	called := make([]bool, 10)
+
+	runConcurrently(len(called), 3, func(i int) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		called[i] = true
+		lock.Unlock()
+	})
+
+	require.LessOrEqual(t, maxRunning, 3)
+	for i := range called {
+		require.True(t, called[i])
+	}
+}
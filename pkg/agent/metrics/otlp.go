@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	dphttp "github.com/deviceplane/cli/pkg/http"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// The types below are the subset of the OTLP/HTTP JSON encoding needed to
+// export gauges and delta sums
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+const otlpAggregationTemporalityDelta = 1
+
+type otlpSink struct {
+	url        string
+	httpClient *dphttp.Client
+}
+
+func newOTLPSink(url string) *otlpSink {
+	return &otlpSink{
+		url: url,
+		httpClient: &dphttp.Client{
+			Client: http.DefaultClient,
+		},
+	}
+}
+
+func (s *otlpSink) SendDeviceMetrics(ctx *dpcontext.Context, series models.DatadogSeries) error {
+	return s.send(ctx, series)
+}
+
+func (s *otlpSink) SendServiceMetrics(ctx *dpcontext.Context, metrics models.IntermediateServiceMetricsRequest) error {
+	return s.send(ctx, flattenServiceMetrics(metrics))
+}
+
+func (s *otlpSink) send(ctx *dpcontext.Context, series models.DatadogSeries) error {
+	reqBytes, err := json.Marshal(otlpRequestFromSeries(series))
+	if err != nil {
+		return err
+	}
+
+	req, err := dphttp.NewRequest(ctx, "POST", s.url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func otlpRequestFromSeries(series models.DatadogSeries) otlpRequest {
+	metrics := make([]otlpMetric, 0, len(series))
+	for _, metric := range series {
+		var attributes []otlpAttribute
+		for _, tag := range metric.Tags {
+			parts := strings.SplitN(tag, ":", 2)
+			attribute := otlpAttribute{
+				Key: parts[0],
+			}
+			if len(parts) == 2 {
+				attribute.Value.StringValue = parts[1]
+			}
+			attributes = append(attributes, attribute)
+		}
+
+		var dataPoints []otlpDataPoint
+		for _, point := range metric.Points {
+			timestamp, value, ok := pointValue(point)
+			if !ok {
+				continue
+			}
+			dataPoints = append(dataPoints, otlpDataPoint{
+				Attributes:   attributes,
+				TimeUnixNano: strconv.FormatInt(timestamp*int64(time.Second), 10),
+				AsDouble:     value,
+			})
+		}
+		if len(dataPoints) == 0 {
+			continue
+		}
+
+		otlpMetric := otlpMetric{
+			Name: metric.Metric,
+		}
+		if metric.Type == "count" {
+			otlpMetric.Sum = &otlpSum{
+				DataPoints:             dataPoints,
+				AggregationTemporality: otlpAggregationTemporalityDelta,
+				IsMonotonic:            true,
+			}
+		} else {
+			otlpMetric.Gauge = &otlpGauge{
+				DataPoints: dataPoints,
+			}
+		}
+		metrics = append(metrics, otlpMetric)
+	}
+
+	return otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{
+			{
+				ScopeMetrics: []otlpScopeMetrics{
+					{
+						Scope: otlpScope{
+							Name: "deviceplane-agent",
+						},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
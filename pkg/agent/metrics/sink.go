@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/url"
+
+	"github.com/deviceplane/cli/pkg/agent/client"
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/pkg/errors"
+)
+
+// Sink is a destination for processed device and service metrics
+type Sink interface {
+	SendDeviceMetrics(ctx *dpcontext.Context, series models.DatadogSeries) error
+	SendServiceMetrics(ctx *dpcontext.Context, metrics models.IntermediateServiceMetricsRequest) error
+}
+
+type cloudSink struct {
+	client *client.Client
+}
+
+func (s *cloudSink) SendDeviceMetrics(ctx *dpcontext.Context, series models.DatadogSeries) error {
+	return s.client.SendDeviceMetrics(ctx, models.DatadogPostMetricsRequest{
+		Series: series,
+	})
+}
+
+func (s *cloudSink) SendServiceMetrics(ctx *dpcontext.Context, metrics models.IntermediateServiceMetricsRequest) error {
+	return s.client.SendServiceMetrics(ctx, metrics)
+}
+
+// NewLocalSink returns a sink for an on-device collector. The endpoint is
+// either "statsd://host:port" or "otlp://host:port", optionally with a path
+// for OTLP which defaults to /v1/metrics.
+func NewLocalSink(endpoint string) (Sink, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse local metrics endpoint")
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("local metrics endpoint %q has no host", endpoint)
+	}
+
+	switch u.Scheme {
+	case "statsd":
+		return newStatsdSink(u.Host), nil
+	case "otlp":
+		path := u.Path
+		if path == "" || path == "/" {
+			path = "/v1/metrics"
+		}
+		return newOTLPSink("http://" + u.Host + path), nil
+	}
+
+	return nil, errors.Errorf("unsupported local metrics endpoint scheme %q", u.Scheme)
+}
+
+func pointValue(point [2]interface{}) (int64, float64, bool) {
+	timestamp, ok := point[0].(int64)
+	if !ok {
+		return 0, 0, false
+	}
+	switch value := point[1].(type) {
+	case float32:
+		return timestamp, float64(value), true
+	case float64:
+		return timestamp, value, true
+	}
+	return 0, 0, false
+}
+
+func flattenServiceMetrics(metrics models.IntermediateServiceMetricsRequest) models.DatadogSeries {
+	var series models.DatadogSeries
+	for _, services := range metrics {
+		for _, serviceSeries := range services {
+			series = append(series, serviceSeries...)
+		}
+	}
+	return series
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdLines(t *testing.T) {
+	lines := statsdLines(models.DatadogSeries{
+		{
+			Metric: "deviceplane.device.load1",
+			Points: [][2]interface{}{{int64(1), float32(0.5)}},
+			Type:   "gauge",
+		},
+		{
+			Metric: "deviceplane.service.requests",
+			Points: [][2]interface{}{{int64(1), float32(3)}},
+			Type:   "count",
+			Tags:   []string{"deviceplane.application:web", "deviceplane.service:api"},
+		},
+	})
+	require.Equal(t, []string{
+		"deviceplane.device.load1:0.5|g",
+		"deviceplane.service.requests:3|c|#deviceplane.application:web,deviceplane.service:api",
+	}, lines)
+}
+
+func TestNewLocalSink(t *testing.T) {
+	sink, err := NewLocalSink("statsd://127.0.0.1:8125")
+	require.NoError(t, err)
+	require.IsType(t, &statsdSink{}, sink)
+
+	sink, err = NewLocalSink("otlp://127.0.0.1:4318")
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:4318/v1/metrics", sink.(*otlpSink).url)
+
+	_, err = NewLocalSink("carbon://127.0.0.1:2003")
+	require.Error(t, err)
+}
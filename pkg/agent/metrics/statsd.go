@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// Keep each datagram below a typical MTU
+const maxStatsdPacketSize = 1432
+
+type statsdSink struct {
+	addr string
+}
+
+func newStatsdSink(addr string) *statsdSink {
+	return &statsdSink{
+		addr: addr,
+	}
+}
+
+func (s *statsdSink) SendDeviceMetrics(ctx *dpcontext.Context, series models.DatadogSeries) error {
+	return s.send(ctx, series)
+}
+
+func (s *statsdSink) SendServiceMetrics(ctx *dpcontext.Context, metrics models.IntermediateServiceMetricsRequest) error {
+	return s.send(ctx, flattenServiceMetrics(metrics))
+}
+
+func (s *statsdSink) send(ctx *dpcontext.Context, series models.DatadogSeries) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var packet bytes.Buffer
+	for _, line := range statsdLines(series) {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > maxStatsdPacketSize {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+
+	if packet.Len() > 0 {
+		if _, err := conn.Write(packet.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// statsdLines formats metrics in the DogStatsD line format, which carries
+// tags and is understood by most StatsD servers
+func statsdLines(series models.DatadogSeries) []string {
+	var lines []string
+	for _, metric := range series {
+		statsdType := "g"
+		if metric.Type == "count" {
+			statsdType = "c"
+		}
+
+		for _, point := range metric.Points {
+			_, value, ok := pointValue(point)
+			if !ok {
+				continue
+			}
+
+			line := metric.Metric + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + statsdType
+			if len(metric.Tags) > 0 {
+				line += "|#" + strings.Join(metric.Tags, ",")
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
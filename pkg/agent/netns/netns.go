@@ -15,6 +15,9 @@ import (
 
 const (
 	timeout = time.Second
+	// workers is the number of requests that can be processed at once, each
+	// on its own OS thread since network namespaces are per-thread
+	workers = 4
 )
 
 type request struct {
@@ -22,6 +25,7 @@ type request struct {
 	containerID string
 	port        int
 	path        string
+	out         chan response
 }
 
 type response struct {
@@ -32,41 +36,43 @@ type response struct {
 type Manager struct {
 	engine engine.Engine
 	in     chan request
-	out    chan response
 }
 
 func NewManager(engine engine.Engine) *Manager {
 	return &Manager{
 		engine: engine,
 		in:     make(chan request),
-		out:    make(chan response),
 	}
 }
 
 func (m *Manager) Start() {
-	go func() {
-		runtime.LockOSThread()
-		for {
-			select {
-			case request := <-m.in:
-				ctx, cancel := context.WithTimeout(request.ctx, timeout)
-				m.out <- m.processRequest(ctx, request)
-				cancel()
+	for i := 0; i < workers; i++ {
+		go func() {
+			runtime.LockOSThread()
+			for {
+				select {
+				case request := <-m.in:
+					ctx, cancel := context.WithTimeout(request.ctx, timeout)
+					request.out <- m.processRequest(ctx, request)
+					cancel()
+				}
 			}
-		}
-	}()
+		}()
+	}
 }
 
 func (m *Manager) ProcessRequest(
 	ctx context.Context, containerID string, port int, path string,
 ) (*http.Response, error) {
+	out := make(chan response, 1)
 	m.in <- request{
 		ctx:         ctx,
 		containerID: containerID,
 		port:        port,
 		path:        path,
+		out:         out,
 	}
-	resp := <-m.out
+	resp := <-out
 	return resp.response, resp.err
 }
 
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/pkg/errors"
+)
+
+// SetBundleFile makes the agent apply the bundle in filename when it starts
+// without a saved bundle, such as on its first boot, before any bundle is
+// downloaded. Once a bundle has been saved it takes precedence, so that a
+// stale bundle file doesn't replace a newer bundle. The file is in the JSON
+// format the API sends devices, as printed by
+// "deviceplane device bundle get -o json". If offline is set, bundles are
+// never downloaded and the device doesn't need to be registered, so it can
+// be commissioned without connectivity. Must be called before Initialize.
+func (a *Agent) SetBundleFile(filename string, offline bool) {
+	a.bundleFile = filename
+	a.offline = offline
+}
+
+// loadBundleFile reads a bundle file, applying the same checks as a
+// downloaded bundle
+func loadBundleFile(filename string) (*models.Bundle, error) {
+	bundleBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, kept := parseBundle(nil, bundleBytes)
+	if bundle == nil || kept {
+		return nil, errors.New("bundle can't be applied")
+	}
+	return bundle, nil
+}
+
+// seedBundle returns the saved bundle, or if there is none the bundle from
+// the bundle file, which is then saved so it's still applied if the agent
+// restarts without it
+func (a *Agent) seedBundle() *models.Bundle {
+	if bundle := a.loadSavedBundle(); bundle != nil || a.bundleFile == "" {
+		return bundle
+	}
+
+	bundle, err := loadBundleFile(a.bundleFile)
+	if err != nil {
+		log.WithError(err).WithField("file", a.bundleFile).Error("load bundle file")
+		return nil
+	}
+
+	bundleBytes, err := json.Marshal(bundle)
+	if err == nil {
+		err = a.writeFileWithChecksum(bundleBytes, bundleFilename)
+	}
+	if err != nil {
+		log.WithError(err).Error("save bundle from bundle file")
+	}
+	return bundle
+}
+
+// runOffline keeps the bundle applier loop alive without downloading bundles
+func (a *Agent) runOffline() {
+	ticker := time.NewTicker(a.bundlePollInterval)
+	defer ticker.Stop()
+
+	for {
+		a.markProgress(bundleApplierLoop)
+
+		select {
+		case <-ticker.C:
+			continue
+		}
+	}
+}
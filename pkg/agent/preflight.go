@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/agent/client"
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/deviceplane/cli/pkg/file"
+	"github.com/pkg/errors"
+)
+
+const (
+	preflightFilename = ".preflight"
+
+	// maxClockSkew is how far the device's clock can be from the control
+	// plane's. Certificates aren't valid to a device whose clock is too far
+	// off, so registration fails.
+	maxClockSkew = 5 * time.Minute
+)
+
+// ErrPreflightFailed is returned by Agent.Preflight if any check failed
+var ErrPreflightFailed = errors.New("preflight checks failed")
+
+// PreflightResult is the outcome of one preflight check. Err is nil if the
+// check passed.
+type PreflightResult struct {
+	Check string
+	Err   error
+}
+
+// Preflight checks that a device is ready to be enrolled, without
+// registering it: the container engine must be reachable, the state dir
+// writable, and the control plane reachable and accepting registrationToken
+// with a clock close to the device's.
+func Preflight(client *client.Client, engine engine.Engine, stateDir, registrationToken string) []PreflightResult {
+	results := []PreflightResult{
+		{Check: "container engine is reachable", Err: checkEngine(engine)},
+		{Check: "state dir is writable", Err: checkStateDir(stateDir)},
+	}
+
+	serverTime, err := checkRegistration(client, registrationToken)
+	results = append(results, PreflightResult{Check: "control plane accepts registration token", Err: err})
+
+	if err != nil {
+		err = errors.New("couldn't get the control plane's time")
+	} else {
+		err = checkClockSkew(time.Now(), serverTime)
+	}
+	results = append(results, PreflightResult{Check: "clock is in sync with control plane", Err: err})
+
+	return results
+}
+
+// Preflight runs the preflight checks with the agent's engine, state dir and
+// registration token, and prints their report to w. It's run instead of
+// Initialize by the agent's preflight command, which exits non-zero if
+// ErrPreflightFailed is returned.
+func (a *Agent) Preflight(w io.Writer) error {
+	if !PrintPreflight(w, Preflight(a.client, a.engine, a.fileLocation(), a.registrationToken)) {
+		return ErrPreflightFailed
+	}
+	return nil
+}
+
+// PrintPreflight prints a pass/fail report of results, and returns whether
+// every check passed
+func PrintPreflight(w io.Writer, results []PreflightResult) bool {
+	passed := true
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(w, "FAIL  %s: %v\n", result.Check, result.Err)
+			passed = false
+		} else {
+			fmt.Fprintf(w, "PASS  %s\n", result.Check)
+		}
+	}
+	return passed
+}
+
+func checkEngine(engine engine.Engine) error {
+	ctx, cancel := dpcontext.NewDefault(context.Background())
+	defer cancel()
+
+	_, err := engine.ListContainers(ctx, nil, nil, false)
+	return err
+}
+
+func checkStateDir(stateDir string) error {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return err
+	}
+	filename := path.Join(stateDir, preflightFilename)
+	if err := file.WriteFileAtomic(filename, []byte(time.Now().String()), 0644); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
+
+func checkRegistration(c *client.Client, registrationToken string) (time.Time, error) {
+	ctx, cancel := dpcontext.NewDefault(context.Background())
+	defer cancel()
+
+	checkResponse, err := c.CheckRegistration(ctx, registrationToken)
+	if err == client.ErrUnsupported {
+		return time.Time{}, errors.New("the control plane is too old to check registration tokens")
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return checkResponse.ServerTime, nil
+}
+
+func checkClockSkew(now, serverTime time.Time) error {
+	skew := now.Sub(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		return fmt.Errorf("clock is off by %s, more than %s", skew.Round(time.Second), maxClockSkew)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/agent/client"
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+type preflightEngine struct {
+	engine.Engine
+	err error
+}
+
+func (e preflightEngine) ListContainers(context.Context, map[string]struct{}, map[string]string, bool) ([]engine.Instance, error) {
+	return nil, e.err
+}
+
+func TestPreflightChecks(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	assert.NoError(t, checkStateDir(path.Join(stateDir, "state")))
+	_, err = os.Stat(path.Join(stateDir, "state", preflightFilename))
+	assert.True(t, os.IsNotExist(err))
+
+	notADir := path.Join(stateDir, "file")
+	assert.NoError(t, ioutil.WriteFile(notADir, nil, 0644))
+	assert.Error(t, checkStateDir(notADir))
+
+	now := time.Now()
+	assert.NoError(t, checkClockSkew(now, now.Add(-time.Minute)))
+	assert.EqualError(t, checkClockSkew(now, now.Add(time.Hour)), "clock is off by 1h0m0s, more than 5m0s")
+
+	var report bytes.Buffer
+	assert.False(t, PrintPreflight(&report, []PreflightResult{
+		{Check: "state dir is writable"},
+		{Check: "container engine is reachable", Err: errors.New("connection refused")},
+	}))
+	assert.Equal(t, "PASS  state dir is writable\nFAIL  container engine is reachable: connection refused\n", report.String())
+}
+
+func TestAgentPreflight(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(stateDir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/projects/prj_test/devices/register/check", r.URL.Path)
+		fmt.Fprintf(w, `{"serverTime": %q}`, time.Now().Format(time.RFC3339))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	a := &Agent{
+		client:            client.NewClient(serverURL, "prj_test", nil),
+		engine:            preflightEngine{},
+		projectID:         "prj_test",
+		registrationToken: "drt_test",
+		stateDir:          stateDir,
+	}
+
+	var report bytes.Buffer
+	assert.NoError(t, a.Preflight(&report))
+	assert.Equal(t, 4, strings.Count(report.String(), "PASS  "))
+
+	a.engine = preflightEngine{err: errors.New("connection refused")}
+	report.Reset()
+	assert.Equal(t, ErrPreflightFailed, a.Preflight(&report))
+	assert.Contains(t, report.String(), "FAIL  container engine is reachable: connection refused\n")
+}
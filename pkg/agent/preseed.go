@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/agent/client"
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/pkg/errors"
+)
+
+var errPreseededAccessKeyRejected = errors.New("the pre-seeded access key was rejected by the control plane, check that it hasn't been revoked")
+
+// SetPreseededCredentials makes the agent use the access key and device ID in
+// the given files, such as ones baked into an immutable image, instead of
+// registering. They're checked with the control plane before the agent runs,
+// and if they're rejected Initialize fails rather than registering again. If
+// the control plane can't be reached they're checked again in the background,
+// so that the agent can run its saved bundle in the meantime, and the agent
+// exits if they're rejected then. Must be called before Initialize.
+func (a *Agent) SetPreseededCredentials(accessKeyFile, deviceIDFile string) {
+	a.preseededAccessKeyFile = accessKeyFile
+	a.preseededDeviceIDFile = deviceIDFile
+}
+
+func (a *Agent) loadPreseededCredentials() error {
+	accessKey, err := readCredentialFile(a.preseededAccessKeyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read pre-seeded access key")
+	}
+	deviceID, err := readCredentialFile(a.preseededDeviceIDFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read pre-seeded device ID")
+	}
+
+	a.client.SetAccessKey(accessKey)
+	a.client.SetDeviceID(deviceID)
+
+	if a.offline {
+		return nil
+	}
+
+	retry, err := a.checkCredentialsOnce(deviceID)
+	if err == nil || !retry {
+		return err
+	}
+
+	log.WithError(err).Warn("couldn't check pre-seeded credentials, checking again in the background")
+	go a.recheckPreseededCredentials(deviceID)
+	return nil
+}
+
+// recheckPreseededCredentials checks the client's credentials until the
+// control plane can be reached, and exits if they're rejected
+func (a *Agent) recheckPreseededCredentials(deviceID string) {
+	if err := retryWithBackoff("check pre-seeded credentials", 0, func() (bool, error) {
+		return a.checkCredentialsOnce(deviceID)
+	}); err != nil {
+		log.WithError(err).Fatal("pre-seeded credentials")
+	}
+	log.Info("pre-seeded credentials checked")
+}
+
+// checkCredentialsOnce checks the client's credentials belong to deviceID.
+// Failing to reach the control plane is worth retrying, a rejected access key
+// isn't.
+func (a *Agent) checkCredentialsOnce(deviceID string) (bool, error) {
+	ctx, cancel := dpcontext.NewDefault(context.Background())
+	defer cancel()
+
+	checkResponse, err := a.client.CheckAuth(ctx)
+	switch {
+	case err == client.ErrUnsupported:
+		log.Warn("the control plane can't check pre-seeded credentials, assuming they're valid")
+		return false, nil
+	case err == client.ErrAccessKeyRejected:
+		return false, errPreseededAccessKeyRejected
+	case err != nil:
+		return true, err
+	case checkResponse.DeviceID != deviceID:
+		return false, errors.Errorf("the pre-seeded access key belongs to device %s, not the pre-seeded device ID %s",
+			checkResponse.DeviceID, deviceID)
+	}
+	return false, nil
+}
+
+func readCredentialFile(filename string) (string, error) {
+	credentialBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	credential := strings.TrimSpace(string(credentialBytes))
+	if credential == "" {
+		return "", errors.Errorf("%s is empty", filename)
+	}
+	return credential, nil
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/agent/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPreseededCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	accessKeyFile := path.Join(dir, "access-key")
+	deviceIDFile := path.Join(dir, "device-id")
+	assert.NoError(t, ioutil.WriteFile(accessKeyFile, []byte("key_valid\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(deviceIDFile, []byte("dev_1\n"), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/projects/prj_test/devices/dev_1/auth", r.URL.Path)
+		accessKey, _, _ := r.BasicAuth()
+		if accessKey != "key_valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"deviceId": "dev_1"}`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	a := &Agent{
+		client: client.NewClient(serverURL, "prj_test", nil),
+	}
+	a.SetPreseededCredentials(accessKeyFile, deviceIDFile)
+	assert.NoError(t, a.loadPreseededCredentials())
+
+	// A rejected key fails right away instead of being retried
+	assert.NoError(t, ioutil.WriteFile(accessKeyFile, []byte("key_revoked"), 0600))
+	assert.Equal(t, errPreseededAccessKeyRejected, a.loadPreseededCredentials())
+
+	assert.NoError(t, ioutil.WriteFile(accessKeyFile, nil, 0600))
+	assert.Error(t, a.loadPreseededCredentials())
+}
+
+func TestLoadPreseededCredentialsUnreachable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	accessKeyFile := path.Join(dir, "access-key")
+	deviceIDFile := path.Join(dir, "device-id")
+	assert.NoError(t, ioutil.WriteFile(accessKeyFile, []byte("key_valid"), 0600))
+	assert.NoError(t, ioutil.WriteFile(deviceIDFile, []byte("dev_1"), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	// A device that boots without the control plane runs rather than
+	// waiting for its credentials to be checked
+	a := &Agent{
+		client: client.NewClient(serverURL, "prj_test", nil),
+	}
+	a.SetPreseededCredentials(accessKeyFile, deviceIDFile)
+	assert.NoError(t, a.loadPreseededCredentials())
+}
@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+const (
+	lastGoodBundleFilename = "bundle.last-good"
+	rollbackFilename       = "bundle.rollback"
+
+	// How long services of the applied bundle must keep failing before the
+	// agent rolls back to the last known good bundle
+	rollbackAfter = time.Minute
+)
+
+// bundleFingerprint identifies a bundle by the releases it deploys
+func bundleFingerprint(bundle models.Bundle) string {
+	releases := make([]string, 0, len(bundle.Applications))
+	for _, application := range bundle.Applications {
+		releases = append(releases, application.Application.ID+":"+application.LatestRelease.ID)
+	}
+	sort.Strings(releases)
+	return strings.Join(releases, ",")
+}
+
+func (a *Agent) loadLastGoodBundle() *models.Bundle {
+	bundleBytes, err := a.readFileWithChecksum(lastGoodBundleFilename)
+	if err == errChecksumMismatch {
+		log.Error("discarding last good bundle that does not match its checksum")
+		return nil
+	} else if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).Error("read last good bundle")
+		}
+		return nil
+	}
+
+	var bundle models.Bundle
+	if err = json.Unmarshal(bundleBytes, &bundle); err != nil {
+		log.WithError(err).Error("discarding invalid last good bundle")
+		return nil
+	}
+
+	return &bundle
+}
+
+func (a *Agent) saveLastGoodBundle(bundle models.Bundle) {
+	bundleBytes, err := json.Marshal(bundle)
+	if err != nil {
+		log.WithError(err).Error("marshal last good bundle")
+		return
+	}
+
+	if err = a.writeFileWithChecksum(bundleBytes, lastGoodBundleFilename); err != nil {
+		log.WithError(err).Error("save last good bundle")
+	}
+
+	a.lastGoodBundle = &bundle
+}
+
+// rollback is a rollback to the last known good bundle, saved so that the
+// bundle that was rolled back isn't applied again after a restart
+type rollback struct {
+	Fingerprint string `json:"fingerprint"`
+	Reason      string `json:"reason"`
+}
+
+func (a *Agent) loadRollback() {
+	rollbackBytes, err := a.readFileWithChecksum(rollbackFilename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).Error("read rollback")
+		}
+		return
+	}
+
+	var r rollback
+	if err = json.Unmarshal(rollbackBytes, &r); err != nil {
+		log.WithError(err).Error("discarding invalid rollback")
+		return
+	}
+
+	a.rolledBackFingerprint = r.Fingerprint
+	a.supervisor.SetRollbackReason(r.Reason)
+}
+
+// setRollback records that the bundle with fingerprint was rolled back, or
+// with an empty fingerprint that there's no rollback in effect
+func (a *Agent) setRollback(fingerprint, reason string) {
+	a.rolledBackFingerprint = fingerprint
+	a.supervisor.SetRollbackReason(reason)
+
+	if fingerprint == "" {
+		if err := os.Remove(a.fileLocation(rollbackFilename)); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Error("remove rollback")
+		}
+		return
+	}
+
+	rollbackBytes, err := json.Marshal(rollback{
+		Fingerprint: fingerprint,
+		Reason:      reason,
+	})
+	if err != nil {
+		log.WithError(err).Error("marshal rollback")
+		return
+	}
+	if err = a.writeFileWithChecksum(rollbackBytes, rollbackFilename); err != nil {
+		log.WithError(err).Error("save rollback")
+	}
+}
+
+// bundleToApply returns the bundle the supervisor should run. A bundle that
+// was previously rolled back is replaced by the last known good bundle
+// until the control plane hands out different releases.
+func (a *Agent) bundleToApply(bundle models.Bundle) models.Bundle {
+	if a.rolledBackFingerprint != "" {
+		if bundleFingerprint(bundle) == a.rolledBackFingerprint && a.lastGoodBundle != nil {
+			return *a.lastGoodBundle
+		}
+		a.setRollback("", "")
+	}
+	return bundle
+}
+
+func (a *Agent) checkBundleHealth() {
+	if a.appliedBundle == nil {
+		return
+	}
+
+	// Failures only count towards rolling back the bundle they happened
+	// with, not one applied after it
+	fingerprint := bundleFingerprint(*a.appliedBundle)
+	healthy, failing := a.supervisor.Health()
+	if !failing || fingerprint != a.failingFingerprint {
+		a.failingSince = time.Time{}
+		a.failingFingerprint = ""
+	}
+
+	if healthy {
+		if a.lastGoodBundle == nil || bundleFingerprint(*a.lastGoodBundle) != fingerprint {
+			a.saveLastGoodBundle(*a.appliedBundle)
+		}
+		return
+	}
+
+	if !failing {
+		return
+	}
+
+	if a.failingSince.IsZero() {
+		a.failingSince = time.Now()
+		a.failingFingerprint = fingerprint
+	}
+	// An apply fails once, however many checks its services fail
+	if fingerprint != a.failedApplyFingerprint {
+		a.failedApplyFingerprint = fingerprint
+		a.metricsExporter.IncBundleApplyFailures()
+	}
+	failingFor := time.Since(a.failingSince)
+	if failingFor < rollbackAfter ||
+		a.lastGoodBundle == nil ||
+		a.rolledBackFingerprint != "" ||
+		bundleFingerprint(*a.lastGoodBundle) == fingerprint {
+		return
+	}
+
+	reason := fmt.Sprintf(
+		"rolled back to last known good bundle after its services failed for %s",
+		failingFor.Round(time.Second),
+	)
+	log.WithField("releases", fingerprint).Error(reason)
+
+	a.setRollback(fingerprint, reason)
+	a.failingSince = time.Time{}
+	a.failingFingerprint = ""
+	a.metricsExporter.IncRollbacks()
+	a.eventLog.Record(models.AgentEvent{
+		Type:    models.AgentEventRollback,
+		Message: reason,
+	})
+	a.setSupervisorBundle(*a.lastGoodBundle)
+}
@@ -1,26 +1,107 @@
 package local
 
 import (
+	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 
 	"github.com/deviceplane/cli/pkg/agent/server/conncontext"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/utils"
+	"github.com/gorilla/mux"
 )
 
+// APIVersion is the version of the local device API. Routes are served
+// under "/<APIVersion>" and the version is bumped on any breaking change
+// to a route or payload.
+const APIVersion = "v1"
+
 type Server struct {
-	httpServer *http.Server
-	listener   net.Listener
+	httpServer  *http.Server
+	listener    net.Listener
+	probeServer *http.Server
 }
 
-func NewServer(service http.Handler) *Server {
+// NewServer creates the local server. bundle returns the bundle the agent is
+// currently running, or nil before one has been applied. requestPoll makes
+// the agent download its bundle now rather than at its next poll.
+//
+// Every route is served under "/<APIVersion>":
+//
+//	GET  /version  models.LocalAPIVersion
+//	GET  /healthz  models.LocalHealth
+//	GET  /readyz   models.LocalHealth, with 503 Service Unavailable until
+//	               the agent is ready
+//	GET  /bundle   models.Bundle, or 404 Not Found before one is applied
+//	POST /poll     no body, or 409 Conflict if the agent doesn't poll
+//	GET  /metrics  the agent's metrics in the Prometheus text format
+//
+// along with the routes of service. service's routes are also served
+// without the prefix, for on-device tooling that predates versioning.
+//
+// The health routes are also served by ServeProbes, for orchestrators and
+// probes that aren't on the device.
+func NewServer(
+	service http.Handler, health func() models.LocalHealth, bundle func() *models.Bundle,
+	requestPoll func() error, metrics http.Handler,
+) *Server {
+	router := mux.NewRouter()
+	router.Use(loopbackOnly)
+
+	v1 := router.PathPrefix("/" + APIVersion).Subrouter()
+	v1.HandleFunc("/version", version).Methods("GET")
+	handleHealth(v1, health)
+	v1.HandleFunc("/bundle", func(w http.ResponseWriter, r *http.Request) {
+		b := bundle()
+		if b == nil {
+			http.Error(w, "no bundle has been applied", http.StatusNotFound)
+			return
+		}
+		utils.Respond(w, b)
+	}).Methods("GET")
+	v1.HandleFunc("/poll", func(w http.ResponseWriter, r *http.Request) {
+		if err := requestPoll(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}).Methods("POST")
+	v1.Handle("/metrics", metrics).Methods("GET")
+	v1.PathPrefix("/").Handler(http.StripPrefix("/"+APIVersion, service))
+
+	// Unversioned routes are kept for existing on-device tooling
+	router.PathPrefix("/").Handler(service)
+
+	probeRouter := mux.NewRouter()
+	handleHealth(probeRouter.PathPrefix("/"+APIVersion).Subrouter(), health)
+
 	return &Server{
 		httpServer: &http.Server{
-			Handler:     service,
+			Handler:     router,
 			ConnContext: conncontext.SaveConn,
 		},
+		probeServer: &http.Server{
+			Handler: probeRouter,
+		},
 	}
 }
 
+func handleHealth(router *mux.Router, health func() models.LocalHealth) {
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		utils.Respond(w, health())
+	}).Methods("GET")
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		h := health()
+		if !h.Ready {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(h)
+			return
+		}
+		utils.Respond(w, h)
+	}).Methods("GET")
+}
+
 func (s *Server) SetListener(listener net.Listener) {
 	s.listener = listener
 }
@@ -28,3 +109,43 @@ func (s *Server) SetListener(listener net.Listener) {
 func (s *Server) Serve() error {
 	return s.httpServer.Serve(s.listener)
 }
+
+// ServeProbes serves the health routes alone on listener, to any host, since
+// they're meant for probes that can't reach the loopback interface
+func (s *Server) ServeProbes(listener net.Listener) error {
+	return s.probeServer.Serve(listener)
+}
+
+// ListenerClosed reports whether err from Serve means the listener was
+// closed, in which case Serve will keep failing until a new listener is set
+func ListenerClosed(err error) bool {
+	return errors.Is(err, net.ErrClosed)
+}
+
+func version(w http.ResponseWriter, r *http.Request) {
+	utils.Respond(w, models.LocalAPIVersion{
+		APIVersion: APIVersion,
+	})
+}
+
+// loopbackOnly rejects requests from other hosts. Connections over a Unix
+// socket are always local, and are guarded by the socket's permissions.
+func loopbackOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.Network() == "unix" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			http.Error(w, "invalid remote address", http.StatusForbidden)
+			return
+		}
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			http.Error(w, "local API is only available on loopback", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
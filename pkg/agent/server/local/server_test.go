@@ -0,0 +1,217 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func healthy() models.LocalHealth {
+	return models.LocalHealth{
+		Registered:   true,
+		BundleLoaded: true,
+		Ready:        true,
+	}
+}
+
+func noBundle() *models.Bundle {
+	return nil
+}
+
+func noPoll() error {
+	return nil
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	service := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+	server := NewServer(service, healthy, noBundle, noPoll, http.NotFoundHandler())
+
+	req := httptest.NewRequest("GET", "/"+APIVersion+"/version", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var version models.LocalAPIVersion
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&version))
+	require.Equal(t, APIVersion, version.APIVersion)
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/metrics/host", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, "/metrics/host", rec.Body.String())
+
+	// Only the service's routes are served without the prefix
+	req = httptest.NewRequest("GET", "/version", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, "/version", rec.Body.String())
+
+	req = httptest.NewRequest("GET", "/metrics/host", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, "/metrics/host", rec.Body.String())
+}
+
+func TestLoopbackOnly(t *testing.T) {
+	server := NewServer(http.NotFoundHandler(), healthy, noBundle, noPoll, http.NotFoundHandler())
+
+	req := httptest.NewRequest("GET", "/"+APIVersion+"/version", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/version", nil)
+	req.RemoteAddr = "[::1]:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/version", nil)
+	req.RemoteAddr = "@"
+	req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, &net.UnixAddr{
+		Name: "/run/deviceplane/agent.sock",
+		Net:  "unix",
+	}))
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestReadyz(t *testing.T) {
+	health := models.LocalHealth{
+		Registered: true,
+	}
+	server := NewServer(http.NotFoundHandler(), func() models.LocalHealth {
+		return health
+	}, noBundle, noPoll, http.NotFoundHandler())
+
+	req := httptest.NewRequest("GET", "/"+APIVersion+"/readyz", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/healthz", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	health.BundleLoaded = true
+	health.Ready = true
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/readyz", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestBundle(t *testing.T) {
+	var bundle *models.Bundle
+	server := NewServer(http.NotFoundHandler(), healthy, func() *models.Bundle {
+		return bundle
+	}, noPoll, http.NotFoundHandler())
+
+	req := httptest.NewRequest("GET", "/"+APIVersion+"/bundle", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	bundle = &models.Bundle{
+		DesiredAgentVersion: "1.16.0",
+		Applications: []models.FullBundledApplication{
+			{Application: models.BundledApplication{ID: "app_web"}, LatestRelease: models.Release{ID: "rel_1"}},
+		},
+	}
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/bundle", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var served models.Bundle
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&served))
+	require.Equal(t, "1.16.0", served.DesiredAgentVersion)
+	require.Len(t, served.Applications, 1)
+	require.Equal(t, "rel_1", served.Applications[0].LatestRelease.ID)
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/bundle", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestPoll(t *testing.T) {
+	var polls int
+	var pollErr error
+	server := NewServer(http.NotFoundHandler(), healthy, noBundle, func() error {
+		if pollErr != nil {
+			return pollErr
+		}
+		polls++
+		return nil
+	}, http.NotFoundHandler())
+
+	req := httptest.NewRequest("POST", "/"+APIVersion+"/poll", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 1, polls)
+
+	pollErr = errors.New("agent is offline and doesn't download bundles")
+	req = httptest.NewRequest("POST", "/"+APIVersion+"/poll", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	pollErr = nil
+	req = httptest.NewRequest("POST", "/"+APIVersion+"/poll", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	rec = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Equal(t, 1, polls)
+}
+
+func TestProbes(t *testing.T) {
+	server := NewServer(http.NotFoundHandler(), healthy, noBundle, noPoll, http.NotFoundHandler())
+
+	req := httptest.NewRequest("GET", "/"+APIVersion+"/readyz", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	rec := httptest.NewRecorder()
+	server.probeServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/healthz", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	rec = httptest.NewRecorder()
+	server.probeServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Nothing but health is served to other hosts
+	req = httptest.NewRequest("GET", "/"+APIVersion+"/bundle", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	rec = httptest.NewRecorder()
+	server.probeServer.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
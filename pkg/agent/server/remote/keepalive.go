@@ -0,0 +1,58 @@
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// keepaliveInterval is how often each connection from the control plane
+	// is pinged
+	keepaliveInterval = 15 * time.Second
+
+	// keepaliveTimeout is how long a connection can go without a pong
+	// before reads from it fail. Streaming handlers have no deadline, so
+	// this is what ends them once the control plane has gone away.
+	keepaliveTimeout = 3 * keepaliveInterval
+)
+
+// keepalive pings the other end of conn until conn is closed, and sets its
+// read deadline from the last pong. The deadline is reasserted on every ping
+// since the HTTP server clears it before reading each request. Pongs are only
+// handled while conn is being read, so a handler that isn't reading is never
+// cut off.
+func keepalive(conn *websocket.Conn) {
+	interval, timeout := keepaliveInterval, keepaliveTimeout
+
+	var lock sync.Mutex
+	lastPong := time.Now()
+
+	extendDeadline := func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		return conn.SetReadDeadline(lastPong.Add(timeout))
+	}
+
+	conn.SetPongHandler(func(string) error {
+		lock.Lock()
+		lastPong = time.Now()
+		lock.Unlock()
+		return extendDeadline()
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				return
+			}
+			if err := extendDeadline(); err != nil {
+				return
+			}
+		}
+	}()
+}
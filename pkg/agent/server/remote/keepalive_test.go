@@ -0,0 +1,69 @@
+package remote
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepalive(t *testing.T) {
+	defer func(interval, timeout time.Duration) {
+		keepaliveInterval, keepaliveTimeout = interval, timeout
+	}(keepaliveInterval, keepaliveTimeout)
+	keepaliveInterval, keepaliveTimeout = 20*time.Millisecond, 100*time.Millisecond
+
+	// The control plane answers pings only while it's reading
+	answerPings := true
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		if answerPings {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		require.NoError(t, err)
+		keepalive(conn)
+		return conn
+	}
+
+	conn := dial()
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(keepaliveTimeout))
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		readErr <- err
+	}()
+	select {
+	case err := <-readErr:
+		t.Fatalf("read failed while the control plane was answering pings: %v", err)
+	case <-time.After(5 * keepaliveTimeout):
+	}
+	conn.Close()
+
+	answerPings = false
+	conn = dial()
+	defer conn.Close()
+	_, _, err := conn.ReadMessage()
+	netErr, ok := err.(net.Error)
+	require.True(t, ok, "%v", err)
+	require.True(t, netErr.Timeout())
+}
@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/agent/variables"
+)
+
+const (
+	// defaultMaxSessions is how many sessions of each limited endpoint can
+	// be open at once, unless overridden with variables.RemoteMaxSessions,
+	// shared or for the endpoint
+	defaultMaxSessions = 8
+
+	// defaultRateLimit is how many sessions of each limited endpoint can be
+	// started per rateWindow, unless overridden with
+	// variables.RemoteRateLimit, shared or for the endpoint
+	defaultRateLimit = 60
+
+	rateWindow = time.Minute
+)
+
+// limitedEndpoint returns the name of the expensive endpoint that path
+// belongs to, or "" if it isn't limited
+func limitedEndpoint(path string) string {
+	switch {
+	case path == "/ssh":
+		return "ssh"
+	case path == "/connecttcp" || path == "/connecthttp":
+		return "connect"
+	case path == "/exec" || strings.HasSuffix(path, "/exec"):
+		return "exec"
+	case strings.HasSuffix(path, "/logs"):
+		return "logs"
+	}
+	return ""
+}
+
+// limiter bounds how many sessions of each expensive endpoint are open at
+// once, and how often they can be started, so that the control plane can't
+// exhaust the device's resources
+type limiter struct {
+	variables variables.Interface
+	now       func() time.Time
+
+	lock        sync.Mutex
+	open        map[string]int
+	started     map[string]int
+	windowStart map[string]time.Time
+}
+
+func newLimiter(variables variables.Interface) *limiter {
+	return &limiter{
+		variables:   variables,
+		now:         time.Now,
+		open:        make(map[string]int),
+		started:     make(map[string]int),
+		windowStart: make(map[string]time.Time),
+	}
+}
+
+// acquire starts a session of endpoint, and returns why it can't be started
+// if a limit has been reached. Sessions that are started must be released.
+func (l *limiter) acquire(endpoint string) string {
+	maxSessions := l.variables.GetRemoteMaxSessions(endpoint)
+	if maxSessions <= 0 {
+		maxSessions = defaultMaxSessions
+	}
+	rateLimit := l.variables.GetRemoteRateLimit(endpoint)
+	if rateLimit <= 0 {
+		rateLimit = defaultRateLimit
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if now.Sub(l.windowStart[endpoint]) >= rateWindow {
+		l.windowStart[endpoint] = now
+		l.started[endpoint] = 0
+	}
+
+	if l.open[endpoint] >= maxSessions {
+		return fmt.Sprintf("too many %s sessions, at most %d can be open at once", endpoint, maxSessions)
+	}
+	if l.started[endpoint] >= rateLimit {
+		return fmt.Sprintf("too many %s sessions, at most %d can be started per %s", endpoint, rateLimit, rateWindow)
+	}
+
+	l.open[endpoint]++
+	l.started[endpoint]++
+	return ""
+}
+
+func (l *limiter) release(endpoint string) {
+	l.lock.Lock()
+	l.open[endpoint]--
+	l.lock.Unlock()
+}
+
+// handler rejects requests to limited endpoints with 429 Too Many Requests
+// once a limit has been reached
+func (l *limiter) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := limitedEndpoint(r.URL.Path)
+		if endpoint == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if reason := l.acquire(endpoint); reason != "" {
+			log.WithField("endpoint", endpoint).Warn(reason)
+			http.Error(w, reason, http.StatusTooManyRequests)
+			return
+		}
+		defer l.release(endpoint)
+
+		next.ServeHTTP(w, r)
+	})
+}
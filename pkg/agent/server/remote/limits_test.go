@@ -0,0 +1,94 @@
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/stretchr/testify/require"
+)
+
+type limitVariables struct {
+	variables.Interface
+	// limits are keyed by variable name
+	limits map[string]int
+}
+
+func (v *limitVariables) GetRemoteMaxSessions(endpoint string) int {
+	return variables.EndpointLimit(v.limits, variables.RemoteMaxSessions, endpoint)
+}
+
+func (v *limitVariables) GetRemoteRateLimit(endpoint string) int {
+	return variables.EndpointLimit(v.limits, variables.RemoteRateLimit, endpoint)
+}
+
+func TestLimitedEndpoint(t *testing.T) {
+	require.Equal(t, "ssh", limitedEndpoint("/ssh"))
+	require.Equal(t, "connect", limitedEndpoint("/connecthttp"))
+	require.Equal(t, "exec", limitedEndpoint("/exec"))
+	require.Equal(t, "exec", limitedEndpoint("/applications/a/services/s/exec"))
+	require.Equal(t, "logs", limitedEndpoint("/applications/a/services/s/logs"))
+	require.Empty(t, limitedEndpoint("/ping"))
+}
+
+func TestLimiterMaxSessions(t *testing.T) {
+	l := newLimiter(&limitVariables{limits: map[string]int{
+		variables.RemoteMaxSessions:                                2,
+		variables.RemoteLimit(variables.RemoteMaxSessions, "exec"): 1,
+	}})
+
+	require.Empty(t, l.acquire("ssh"))
+	require.Empty(t, l.acquire("ssh"))
+	require.NotEmpty(t, l.acquire("ssh"))
+	require.Empty(t, l.acquire("exec"))
+	require.NotEmpty(t, l.acquire("exec"))
+
+	l.release("ssh")
+	require.Empty(t, l.acquire("ssh"))
+}
+
+func TestLimiterRateLimit(t *testing.T) {
+	now := time.Now()
+	l := newLimiter(&limitVariables{limits: map[string]int{variables.RemoteRateLimit: 2}})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		require.Empty(t, l.acquire("logs"))
+		l.release("logs")
+	}
+	require.NotEmpty(t, l.acquire("logs"))
+
+	now = now.Add(rateWindow)
+	require.Empty(t, l.acquire("logs"))
+}
+
+func TestLimiterHandler(t *testing.T) {
+	opened, release := make(chan struct{}), make(chan struct{})
+	handler := newLimiter(&limitVariables{limits: map[string]int{variables.RemoteMaxSessions: 1}}).handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ssh" {
+			close(opened)
+			<-release
+		}
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ssh", nil))
+		close(done)
+	}()
+
+	<-opened
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ssh", nil))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ping", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	close(release)
+	<-done
+}
@@ -3,10 +3,10 @@ package remote
 import (
 	"context"
 	"net/http"
-	"time"
 
 	"github.com/deviceplane/cli/pkg/agent/client"
 	"github.com/deviceplane/cli/pkg/agent/server/conncontext"
+	"github.com/deviceplane/cli/pkg/agent/variables"
 	dpcontext "github.com/deviceplane/cli/pkg/context"
 	"github.com/deviceplane/cli/pkg/revdial"
 	"github.com/gorilla/websocket"
@@ -18,18 +18,21 @@ type Server struct {
 	httpServer *http.Server
 }
 
-func NewServer(client *client.Client, service http.Handler) *Server {
+// NewServer returns a server for requests from the control plane. The
+// number of concurrent and recent sessions of expensive endpoints is limited
+// according to variables.
+func NewServer(client *client.Client, service http.Handler, variables variables.Interface) *Server {
 	return &Server{
 		client: client,
 		httpServer: &http.Server{
-			Handler:     service,
+			Handler:     newLimiter(variables).handler(service),
 			ConnContext: conncontext.SaveConn,
 		},
 	}
 }
 
 func (s *Server) Serve() error {
-	ctx, cancel := dpcontext.New(context.Background(), time.Minute)
+	ctx, cancel := dpcontext.NewDefault(context.Background())
 	defer cancel()
 
 	conn, err := s.client.InitiateDeviceConnection(ctx)
@@ -44,13 +47,14 @@ func (s *Server) Serve() error {
 }
 
 func (s *Server) revdial(ctx context.Context, path string) (*websocket.Conn, *http.Response, error) {
-	dpctx, cancel := dpcontext.New(ctx, time.Minute)
+	dpctx, cancel := dpcontext.NewDefault(ctx)
 	defer cancel()
 
 	conn, resp, err := s.client.Revdial(dpctx, path)
 	if err != nil {
 		return nil, nil, err
 	}
+	keepalive(conn.Conn)
 
 	return conn.Conn, resp.Response, nil
 }
@@ -2,13 +2,17 @@ package client
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+
+	"github.com/deviceplane/cli/pkg/models"
 )
 
 func GetAgentMetrics(ctx context.Context, deviceConn net.Conn) (*http.Response, error) {
@@ -47,6 +51,42 @@ func GetDeviceMetrics(ctx context.Context, deviceConn net.Conn) (*http.Response,
 	return http.ReadResponse(bufio.NewReader(deviceConn), req)
 }
 
+func GetServiceStats(ctx context.Context, deviceConn net.Conn) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		"/stats",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Write(deviceConn); err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(deviceConn), req)
+}
+
+func Ping(ctx context.Context, deviceConn net.Conn) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		"/ping",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Write(deviceConn); err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(deviceConn), req)
+}
+
 func GetServiceMetrics(ctx context.Context, deviceConn net.Conn, applicationID, service string, metricPath string, metricPort uint) (*http.Response, error) {
 	serviceURL := url.URL{
 		Path: fmt.Sprintf(
@@ -98,6 +138,66 @@ func GetImagePullProgress(ctx context.Context, deviceConn net.Conn, applicationI
 	return http.ReadResponse(bufio.NewReader(deviceConn), req)
 }
 
+func GetServiceLogs(ctx context.Context, deviceConn net.Conn, applicationID, service string, query url.Values) (*http.Response, error) {
+	serviceURL := url.URL{
+		Path: fmt.Sprintf(
+			"/applications/%s/services/%s/logs",
+			applicationID, service,
+		),
+		RawQuery: query.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		serviceURL.RequestURI(),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Write(deviceConn); err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(deviceConn), req)
+}
+
+func ExecService(ctx context.Context, deviceConn net.Conn, applicationID, service string, execRequest models.ExecRequest) (*http.Response, error) {
+	return execCommand(ctx, deviceConn, fmt.Sprintf(
+		"/applications/%s/services/%s/exec",
+		applicationID, service,
+	), execRequest)
+}
+
+func ExecHost(ctx context.Context, deviceConn net.Conn, execRequest models.ExecRequest) (*http.Response, error) {
+	return execCommand(ctx, deviceConn, "/exec", execRequest)
+}
+
+func execCommand(ctx context.Context, deviceConn net.Conn, path string, execRequest models.ExecRequest) (*http.Response, error) {
+	reqBytes, err := json.Marshal(execRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		path,
+		bytes.NewReader(reqBytes),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Write(deviceConn); err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(deviceConn), req)
+}
+
 func SSH(ctx context.Context, deviceConn net.Conn) error {
 	req, err := http.NewRequestWithContext(
 		ctx,
@@ -172,3 +272,44 @@ func Reboot(ctx context.Context, deviceConn net.Conn) (*http.Response, error) {
 
 	return http.ReadResponse(bufio.NewReader(deviceConn), req)
 }
+
+func Poll(ctx context.Context, deviceConn net.Conn) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		"/poll",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Write(deviceConn); err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(deviceConn), req)
+}
+
+func GetDeviceEvents(ctx context.Context, deviceConn net.Conn, query url.Values) (*http.Response, error) {
+	eventsURL := url.URL{
+		Path:     "/events",
+		RawQuery: query.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		eventsURL.RequestURI(),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Write(deviceConn); err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(deviceConn), req)
+}
@@ -41,6 +41,9 @@ func (s *Service) connectHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// The proxied request ends with the connection, rather than
+		// outliving it if the local server hangs
+		req = req.WithContext(r.Context())
 		req.RequestURI = ""
 		req.URL.Scheme = "http"
 		req.URL.Host = fmt.Sprintf("localhost:%d", port)
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// events writes recent agent events as JSON lines, optionally filtered by
+// one or more "type" query parameters. With follow=true the response stays
+// open and new events are streamed as they are recorded.
+func (s *Service) events(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	follow := query.Get("follow") == "true"
+
+	types := make(map[models.AgentEventType]bool)
+	for _, t := range query["type"] {
+		types[models.AgentEventType(t)] = true
+	}
+
+	recent, newEvents, unsubscribe := s.eventLog.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(flushWriter{w})
+	write := func(event models.AgentEvent) error {
+		if len(types) != 0 && !types[event.Type] {
+			return nil
+		}
+		return encoder.Encode(event)
+	}
+
+	for _, event := range recent {
+		if err := write(event); err != nil {
+			return
+		}
+	}
+
+	if !follow {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-newEvents:
+			if err := write(event); err != nil {
+				return
+			}
+		}
+	}
+}
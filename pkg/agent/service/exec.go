@@ -0,0 +1,98 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os/exec"
+
+	"github.com/deviceplane/cli/pkg/agent/validator/customcommands"
+	"github.com/deviceplane/cli/pkg/codes"
+	"github.com/deviceplane/cli/pkg/execstream"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+func (s *Service) exec(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	applicationID := vars["application"]
+	service := vars["service"]
+
+	execRequest, ok := s.readExecRequest(w, r)
+	if !ok {
+		return
+	}
+
+	containerID, ok := s.supervisorLookup.GetContainerID(applicationID, service)
+	if !ok {
+		http.Error(w, "service is not running", codes.StatusExecNotAvailable)
+		return
+	}
+
+	streamExec(w, func(stdout, stderr io.Writer) (int, error) {
+		return s.engine.ContainerExec(r.Context(), containerID, execRequest.Command, stdout, stderr)
+	})
+}
+
+func (s *Service) execHost(w http.ResponseWriter, r *http.Request) {
+	// A command on the host runs as root, so it's no less than SSH access
+	if s.variables.GetDisableSSH() {
+		http.Error(w, "SSH is disabled", http.StatusForbidden)
+		return
+	}
+
+	execRequest, ok := s.readExecRequest(w, r)
+	if !ok {
+		return
+	}
+
+	streamExec(w, func(stdout, stderr io.Writer) (int, error) {
+		cmd := exec.CommandContext(r.Context(), execRequest.Command[0], execRequest.Command[1:]...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+
+		err := cmd.Run()
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return exitError.ExitCode(), nil
+		}
+		return 0, err
+	})
+}
+
+// readExecRequest reads the command to run, responding with an error if it
+// is missing or custom commands are disabled on this device
+func (s *Service) readExecRequest(w http.ResponseWriter, r *http.Request) (*models.ExecRequest, bool) {
+	var execRequest models.ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&execRequest); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if len(execRequest.Command) == 0 {
+		http.Error(w, "command is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if s.variables.GetDisableCustomCommands() {
+		http.Error(w, customcommands.ErrCustomCommandsAreDisabled.Error(), codes.StatusCustomCommandsDisabled)
+		return nil, false
+	}
+
+	return &execRequest, true
+}
+
+// streamExec runs a command, streaming its output and then its exit code
+func streamExec(w http.ResponseWriter, run func(stdout, stderr io.Writer) (int, error)) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	stream := execstream.NewWriter(flushWriter{w})
+
+	exitCode, err := run(stream.Stdout(), stream.Stderr())
+	if err != nil {
+		// Without an exit code frame the caller treats the stream as failed
+		stream.Stderr().Write([]byte(err.Error() + "\n"))
+		return
+	}
+
+	stream.WriteExitCode(exitCode)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/codes"
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/gorilla/mux"
+)
+
+func (s *Service) logs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	applicationID := vars["application"]
+	service := vars["service"]
+
+	options, err := logsOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	containerID, ok := s.supervisorLookup.GetContainerID(applicationID, service)
+	if !ok {
+		http.Error(w, "service is not running", codes.StatusLogsNotAvailable)
+		return
+	}
+
+	logs, err := s.engine.ContainerLogs(r.Context(), containerID, *options)
+	if err != nil {
+		http.Error(w, err.Error(), codes.StatusLogsNotAvailable)
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	io.Copy(flushWriter{w}, logs)
+}
+
+func logsOptions(r *http.Request) (*engine.LogsOptions, error) {
+	query := r.URL.Query()
+
+	options := engine.LogsOptions{
+		Follow: query.Get("follow") == "true",
+		Tail:   -1,
+	}
+
+	if tailRaw := query.Get("tail"); tailRaw != "" {
+		tail, err := strconv.Atoi(tailRaw)
+		if err != nil {
+			return nil, err
+		}
+		options.Tail = tail
+	}
+
+	if sinceRaw := query.Get("since"); sinceRaw != "" {
+		since, err := time.ParseDuration(sinceRaw)
+		if err != nil {
+			return nil, err
+		}
+		options.Since = time.Now().Add(-since)
+	}
+
+	return &options, nil
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/deviceplane/cli/pkg/codes"
+	"github.com/deviceplane/cli/pkg/models"
 	"github.com/deviceplane/cli/pkg/utils"
 	"github.com/gorilla/mux"
 )
@@ -32,3 +33,25 @@ func (s *Service) metrics(w http.ResponseWriter, r *http.Request) {
 		})
 	})
 }
+
+// stats returns the resource usage of every running service container
+func (s *Service) stats(w http.ResponseWriter, r *http.Request) {
+	allStats, err := s.serviceMetricsFetcher.ServiceStats(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	serviceStats := make([]models.ServiceStats, 0, len(allStats))
+	for _, stats := range allStats {
+		serviceStats = append(serviceStats, models.ServiceStats{
+			ApplicationID:    stats.ApplicationID,
+			Service:          stats.Service,
+			CPUSeconds:       stats.CPUSeconds,
+			MemoryUsageBytes: stats.MemoryUsageBytes,
+			MemoryLimitBytes: stats.MemoryLimitBytes,
+		})
+	}
+
+	utils.Respond(w, serviceStats)
+}
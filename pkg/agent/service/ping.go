@@ -0,0 +1,17 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/utils"
+)
+
+// ping answers immediately, so that the control plane can measure the round
+// trip over the device connection
+func (s *Service) ping(w http.ResponseWriter, r *http.Request) {
+	utils.Respond(w, models.DevicePing{
+		Time: time.Now(),
+	})
+}
@@ -0,0 +1,14 @@
+package service
+
+import (
+	"net/http"
+)
+
+// poll makes the agent download its bundle now, rather than at its next
+// poll, so a new release is applied right away
+func (s *Service) poll(w http.ResponseWriter, r *http.Request) {
+	if err := s.requestPoll(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+}
@@ -7,10 +7,13 @@ import (
 	"encoding/pem"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/deviceplane/cli/pkg/agent/events"
 	"github.com/deviceplane/cli/pkg/agent/metrics"
 	"github.com/deviceplane/cli/pkg/agent/supervisor"
 	"github.com/deviceplane/cli/pkg/agent/variables"
+	dpcontext "github.com/deviceplane/cli/pkg/context"
 	"github.com/deviceplane/cli/pkg/engine"
 	"github.com/gliderlabs/ssh"
 	"github.com/gorilla/mux"
@@ -23,37 +26,57 @@ import (
 type Service struct {
 	variables        variables.Interface
 	supervisorLookup supervisor.Lookup
+	engine           engine.Engine
 	confDir          string
 	router           *mux.Router
 
 	serviceMetricsFetcher *metrics.ServiceMetricsFetcher
+	eventLog              *events.Log
+	requestPoll           func() error
 
 	signer     ssh.Signer
 	signerLock sync.Mutex
+
+	// requestTimeout bounds non-streaming requests. Zero means the
+	// dpcontext default timeout.
+	requestTimeout time.Duration
 }
 
 func NewService(
 	variables variables.Interface, supervisorLookup supervisor.Lookup,
 	engine engine.Engine, confDir string, serviceMetricsFetcher *metrics.ServiceMetricsFetcher,
+	eventLog *events.Log, requestPoll func() error,
 ) *Service {
 	s := &Service{
 		variables: variables,
+		engine:    engine,
 		confDir:   confDir,
 		router:    mux.NewRouter(),
 
 		supervisorLookup:      supervisorLookup,
 		serviceMetricsFetcher: serviceMetricsFetcher,
+		eventLog:              eventLog,
+		requestPoll:           requestPoll,
 	}
 	go s.getSigner()
 
+	// Streaming routes run for as long as their connection is alive, the
+	// rest are bounded by the request timeout
 	s.router.HandleFunc("/ssh", s.ssh)
 	s.router.HandleFunc("/connecttcp", s.connectTCP)
 	s.router.HandleFunc("/connecthttp", s.connectHTTP)
-	s.router.HandleFunc("/reboot", s.reboot)
-	s.router.HandleFunc("/applications/{application}/services/{service}/imagepullprogress", s.imagePullProgress).Methods("GET")
-	s.router.HandleFunc("/applications/{application}/services/{service}/metrics", s.metrics).Methods("GET")
-	s.router.Handle("/metrics/host", metrics.FilteredHostMetricsHandler())
-	s.router.Handle("/metrics/agent", promhttp.Handler())
+	s.router.HandleFunc("/reboot", s.withDeadline(s.reboot))
+	s.router.HandleFunc("/poll", s.withDeadline(s.poll)).Methods("POST")
+	s.router.HandleFunc("/applications/{application}/services/{service}/imagepullprogress", s.withDeadline(s.imagePullProgress)).Methods("GET")
+	s.router.HandleFunc("/applications/{application}/services/{service}/metrics", s.withDeadline(s.metrics)).Methods("GET")
+	s.router.HandleFunc("/applications/{application}/services/{service}/logs", s.withDeadlineUnlessFollowing(s.logs)).Methods("GET")
+	s.router.HandleFunc("/applications/{application}/services/{service}/exec", s.exec).Methods("POST")
+	s.router.HandleFunc("/exec", s.execHost).Methods("POST")
+	s.router.HandleFunc("/events", s.withDeadlineUnlessFollowing(s.events)).Methods("GET")
+	s.router.HandleFunc("/stats", s.withDeadline(s.stats)).Methods("GET")
+	s.router.HandleFunc("/ping", s.withDeadline(s.ping)).Methods("GET")
+	s.router.Handle("/metrics/host", s.withDeadline(metrics.FilteredHostMetricsHandler().ServeHTTP))
+	s.router.Handle("/metrics/agent", s.withDeadline(promhttp.Handler().ServeHTTP))
 
 	s.router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
 	s.router.HandleFunc("/debug/pprof/profile", pprof.Profile)
@@ -68,6 +91,42 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
 
+// SetRequestTimeout sets how long a non-streaming request can take before
+// its context is cancelled, along with any engine call it's blocked on. Zero
+// means the dpcontext default timeout.
+func (s *Service) SetRequestTimeout(timeout time.Duration) {
+	s.requestTimeout = timeout
+}
+
+// withDeadline cancels a request's context once the request timeout passes,
+// so a hung engine call can't tie up its handler
+func (s *Service) withDeadline(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := s.requestTimeout
+		if timeout <= 0 {
+			timeout = dpcontext.DefaultTimeout()
+		}
+
+		ctx, cancel := dpcontext.New(r.Context(), timeout)
+		defer cancel()
+
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// withDeadlineUnlessFollowing is like withDeadline, except for requests that
+// follow a stream with follow=true
+func (s *Service) withDeadlineUnlessFollowing(handler http.HandlerFunc) http.HandlerFunc {
+	withDeadline := s.withDeadline(handler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("follow") == "true" {
+			handler(w, r)
+			return
+		}
+		withDeadline(w, r)
+	}
+}
+
 func (s *Service) getSigner() (ssh.Signer, error) {
 	s.signerLock.Lock()
 	defer s.signerLock.Unlock()
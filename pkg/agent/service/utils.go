@@ -31,3 +31,15 @@ func withPath(w http.ResponseWriter, r *http.Request, f func(path string)) {
 
 	f(string(path))
 }
+
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -82,7 +82,7 @@ func (gc *GarbageCollector) applicationStatusGarbageCollector() {
 
 		for _, applicationStatus := range bundle.ApplicationStatuses {
 			if _, ok := applications[applicationStatus.ApplicationID]; !ok {
-				ctx, cancel := dpcontext.New(gc.ctx, time.Minute)
+				ctx, cancel := dpcontext.NewDefault(gc.ctx)
 
 				if err := gc.deleteApplicationStatus(ctx, applicationStatus.ApplicationID); err != nil {
 					log.WithField("application", applicationStatus.ApplicationID).
@@ -130,7 +130,7 @@ func (gc *GarbageCollector) serviceStatusGarbageCollector() {
 		}
 
 		deleteServiceStatus := func(applicationID, service string) {
-			ctx, cancel := dpcontext.New(gc.ctx, time.Minute)
+			ctx, cancel := dpcontext.NewDefault(gc.ctx)
 
 			if err := gc.deleteServiceStatus(ctx, applicationID, service); err != nil {
 				log.WithField("application", applicationID).
@@ -192,7 +192,7 @@ func (gc *GarbageCollector) serviceStateGarbageCollector() {
 		}
 
 		deleteServiceState := func(applicationID, service string) {
-			ctx, cancel := dpcontext.New(gc.ctx, time.Minute)
+			ctx, cancel := dpcontext.NewDefault(gc.ctx)
 
 			if err := gc.deleteServiceState(ctx, applicationID, service); err != nil {
 				log.WithField("application", applicationID).
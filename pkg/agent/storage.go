@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// Number of consecutive failed writes to the state directory before the
+// agent reports a storage error. A single failure may be transient, but a
+// read-only or full disk fails every write.
+const storageFailedWrites = 3
+
+// recordWriteResult tracks writes to the state directory. Once they fail
+// persistently the agent keeps running from memory, but reports a storage
+// error in its device info and local health until a write succeeds again.
+func (a *Agent) recordWriteResult(err error) {
+	a.healthLock.Lock()
+	defer a.healthLock.Unlock()
+
+	if err == nil {
+		a.consecutiveWriteFailures = 0
+		if a.storageError != "" {
+			log.Info("writes to state directory recovered")
+			a.storageError = ""
+			a.infoReporter.SetState("", "")
+		}
+		return
+	}
+
+	a.consecutiveWriteFailures++
+	if a.consecutiveWriteFailures < storageFailedWrites {
+		return
+	}
+
+	storageError := fmt.Sprintf("writes to %s are failing, state is not being persisted: %v", a.stateDir, err)
+	if a.storageError == "" {
+		log.WithError(err).Error("writes to state directory are failing persistently")
+	}
+	a.storageError = storageError
+	a.infoReporter.SetState(models.DeviceStateStorageError, storageError)
+}
@@ -17,7 +17,12 @@ type ApplicationSupervisor struct {
 	variables     variables.Interface
 	reporter      *Reporter
 	validators    []validator.Validator
+	starts        *startLimiter
+	probeHTTP     httpProber
 
+	restartStablePeriod time.Duration
+
+	dependencyErrors        map[string]error
 	serviceNames            map[string]struct{}
 	serviceSupervisors      map[string]*ServiceSupervisor
 	serviceSupervisorGCDone chan struct{}
@@ -36,6 +41,9 @@ func NewApplicationSupervisor(
 	variables variables.Interface,
 	reporter *Reporter,
 	validators []validator.Validator,
+	starts *startLimiter,
+	restartStablePeriod time.Duration,
+	probeHTTP httpProber,
 ) *ApplicationSupervisor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &ApplicationSupervisor{
@@ -44,6 +52,10 @@ func NewApplicationSupervisor(
 		variables:     variables,
 		reporter:      reporter,
 		validators:    validators,
+		starts:        starts,
+		probeHTTP:     probeHTTP,
+
+		restartStablePeriod: restartStablePeriod,
 
 		serviceNames:            make(map[string]struct{}),
 		serviceSupervisors:      make(map[string]*ServiceSupervisor),
@@ -68,6 +80,10 @@ func (s *ApplicationSupervisor) Set(bundle models.Bundle, application models.Ful
 
 	s.reporter.SetDesiredApplication(application.LatestRelease.ID, application.LatestRelease.Config)
 
+	s.lock.Lock()
+	s.dependencyErrors = dependencyErrors(application.LatestRelease.Config)
+	s.lock.Unlock()
+
 	serviceNames := make(map[string]struct{})
 	for serviceName, service := range application.LatestRelease.Config {
 		s.lock.Lock()
@@ -80,6 +96,10 @@ func (s *ApplicationSupervisor) Set(bundle models.Bundle, application models.Ful
 				s.variables,
 				s.reporter,
 				s.validators,
+				s.starts,
+				s.restartStablePeriod,
+				s.dependencies,
+				s.probeHTTP,
 			)
 			s.serviceSupervisors[serviceName] = serviceSupervisor
 		}
@@ -99,6 +119,25 @@ func (s *ApplicationSupervisor) Set(bundle models.Bundle, application models.Ful
 	})
 }
 
+// dependencies returns why a service can never start, or else which of the
+// services it depends on aren't running yet
+func (s *ApplicationSupervisor) dependencies(serviceName string, dependsOn []string) ([]string, error) {
+	s.lock.RLock()
+	err := s.dependencyErrors[serviceName]
+	s.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, dependency := range dependsOn {
+		if s.reporter.ServiceState(dependency) != models.ServiceStateRunning {
+			pending = append(pending, dependency)
+		}
+	}
+	return pending, nil
+}
+
 func (s *ApplicationSupervisor) Stop() {
 	s.stopLock.Lock()
 	defer s.stopLock.Unlock()
@@ -180,14 +219,16 @@ func (s *ApplicationSupervisor) containerGC() {
 			serviceName := instance.Labels[models.ServiceLabel]
 			if _, ok := s.serviceSupervisors[serviceName]; !ok {
 				// TODO: this could start many goroutines
-				go func(instanceID string) {
+				go func(instanceID, serviceName string) {
 					if err = containerStop(s.ctx, s.engine, instanceID); err != nil {
 						return
 					}
 					if err = containerRemove(s.ctx, s.engine, instanceID); err != nil {
 						return
 					}
-				}(instance.ID)
+					s.reporter.RecordEvent(serviceName, models.AgentEventServiceRemoved,
+						"removed container "+shortID(instanceID)+" of a service no longer in the release")
+				}(instance.ID, serviceName)
 			}
 		}
 		s.lock.RUnlock()
@@ -0,0 +1,75 @@
+package supervisor
+
+import (
+	"time"
+
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// restartBackoff tracks the restart delay of a single service's container,
+// in the style of Kubernetes' CrashLoopBackOff, and how often it has been
+// restarted
+type restartBackoff struct {
+	// stablePeriod is how long the container must stay up for the delay and
+	// restart count to be reset. Zero means DefaultRestartStablePeriod.
+	stablePeriod time.Duration
+
+	delay        time.Duration
+	nextRestart  time.Time
+	runningSince time.Time
+	restarts     int
+	lastRestart  time.Time
+}
+
+// running records that the container is up. The delay and restart count are
+// reset once it has been up for the stable period.
+func (b *restartBackoff) running(now time.Time) {
+	if b.runningSince.IsZero() {
+		b.runningSince = now
+	}
+	stablePeriod := b.stablePeriod
+	if stablePeriod <= 0 {
+		stablePeriod = DefaultRestartStablePeriod
+	}
+	if b.restarts != 0 && now.Sub(b.runningSince) >= stablePeriod {
+		b.reset()
+	}
+}
+
+// exited records that the container is down and returns whether it should
+// be restarted now. If not, the current delay is returned.
+func (b *restartBackoff) exited(now time.Time) (bool, time.Duration) {
+	b.runningSince = time.Time{}
+
+	if now.Before(b.nextRestart) {
+		return false, b.delay
+	}
+
+	if b.delay == 0 {
+		b.delay = restartBackoffInitial
+	} else if b.delay *= 2; b.delay > restartBackoffMax {
+		b.delay = restartBackoffMax
+	}
+	b.nextRestart = now.Add(b.delay)
+	b.restarts++
+	b.lastRestart = now
+
+	return true, 0
+}
+
+// annotate adds the restart count and time of the last restart to state
+func (b *restartBackoff) annotate(state models.SetDeviceServiceStateRequest) models.SetDeviceServiceStateRequest {
+	state.RestartCount = b.restarts
+	if b.restarts != 0 {
+		lastRestart := b.lastRestart
+		state.LastRestartAt = &lastRestart
+	}
+	return state
+}
+
+func (b *restartBackoff) reset() {
+	b.delay = 0
+	b.nextRestart = time.Time{}
+	b.restarts = 0
+	b.lastRestart = time.Time{}
+}
@@ -0,0 +1,63 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	var b restartBackoff
+	now := time.Now()
+
+	restart, _ := b.exited(now)
+	require.True(t, restart)
+	require.Equal(t, 1, b.restarts)
+
+	restart, delay := b.exited(now.Add(time.Second))
+	require.False(t, restart)
+	require.Equal(t, restartBackoffInitial, delay)
+
+	now = now.Add(restartBackoffInitial)
+	restart, _ = b.exited(now)
+	require.True(t, restart)
+	_, delay = b.exited(now)
+	require.Equal(t, 2*restartBackoffInitial, delay)
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(restartBackoffMax)
+		b.exited(now)
+	}
+	_, delay = b.exited(now)
+	require.Equal(t, restartBackoffMax, delay)
+
+	require.Equal(t, 12, b.restarts)
+	state := b.annotate(models.SetDeviceServiceStateRequest{State: models.ServiceStateExited})
+	require.Equal(t, 12, state.RestartCount)
+	require.NotNil(t, state.LastRestartAt)
+	require.Equal(t, now, *state.LastRestartAt)
+
+	b.running(now)
+	b.running(now.Add(DefaultRestartStablePeriod))
+	require.Equal(t, 0, b.restarts)
+	require.Nil(t, b.annotate(models.SetDeviceServiceStateRequest{}).LastRestartAt)
+	restart, _ = b.exited(now.Add(DefaultRestartStablePeriod))
+	require.True(t, restart)
+	_, delay = b.exited(now.Add(DefaultRestartStablePeriod))
+	require.Equal(t, restartBackoffInitial, delay)
+}
+
+func TestRestartBackoffStablePeriod(t *testing.T) {
+	b := restartBackoff{stablePeriod: time.Minute}
+	now := time.Now()
+
+	b.exited(now)
+	b.running(now)
+	b.running(now.Add(30 * time.Second))
+	require.Equal(t, 1, b.restarts)
+
+	b.running(now.Add(time.Minute))
+	require.Equal(t, 0, b.restarts)
+}
@@ -6,4 +6,21 @@ import (
 
 const (
 	defaultTickerFrequency = 3 * time.Second
+
+	// Restarts of an exited container are delayed exponentially between
+	// these bounds, and the delay and restart count are reset once the
+	// container has stayed up for the restart stable period
+	restartBackoffInitial = 10 * time.Second
+	restartBackoffMax     = 5 * time.Minute
+
+	DefaultRestartStablePeriod = 10 * time.Minute
+
+	// Engine operations that fail with a transient error are attempted
+	// this many times before the error is reported
+	engineRetryAttempts = 4
+
+	// Defaults for the fields a service's healthcheck leaves out
+	defaultHealthcheckInterval = 10 * time.Second
+	defaultHealthcheckTimeout  = 5 * time.Second
+	defaultHealthcheckRetries  = 3
 )
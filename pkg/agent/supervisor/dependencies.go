@@ -0,0 +1,109 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+const dependenciesValidatorName = "dependencies"
+
+// dependencyErrors returns, for each service whose depends_on can never be
+// satisfied, why not. A service in a dependency cycle would otherwise wait
+// forever on itself.
+func dependencyErrors(services map[string]models.Service) map[string]error {
+	errs := make(map[string]error)
+
+	serviceNames := make([]string, 0, len(services))
+	for serviceName := range services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(serviceName string)
+	visit = func(serviceName string) {
+		state[serviceName] = visiting
+		path = append(path, serviceName)
+
+		for _, dependency := range services[serviceName].DependsOn {
+			if _, ok := services[dependency]; !ok {
+				errs[serviceName] = fmt.Errorf("depends on unknown service '%s'", dependency)
+				continue
+			}
+			switch state[dependency] {
+			case unvisited:
+				visit(dependency)
+			case visiting:
+				var cycle []string
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == dependency {
+						cycle = append(append(cycle, path[i:]...), dependency)
+						break
+					}
+				}
+				err := fmt.Errorf("dependency cycle %s", strings.Join(cycle, " -> "))
+				for _, cycleServiceName := range cycle {
+					if _, ok := errs[cycleServiceName]; !ok {
+						errs[cycleServiceName] = err
+					}
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[serviceName] = visited
+	}
+
+	for _, serviceName := range serviceNames {
+		if state[serviceName] == unvisited {
+			visit(serviceName)
+		}
+	}
+
+	return errs
+}
+
+// startLimiter bounds how many containers are being started at once. A nil
+// startLimiter doesn't limit anything.
+type startLimiter struct {
+	slots chan struct{}
+}
+
+func newStartLimiter(maxConcurrentStarts int) *startLimiter {
+	if maxConcurrentStarts <= 0 {
+		return nil
+	}
+	return &startLimiter{
+		slots: make(chan struct{}, maxConcurrentStarts),
+	}
+}
+
+func (l *startLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *startLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
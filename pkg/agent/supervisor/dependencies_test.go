@@ -0,0 +1,28 @@
+package supervisor
+
+import (
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyErrors(t *testing.T) {
+	errs := dependencyErrors(map[string]models.Service{
+		"db":     {},
+		"api":    {DependsOn: []string{"db"}},
+		"web":    {DependsOn: []string{"api", "db"}},
+		"a":      {DependsOn: []string{"b"}},
+		"b":      {DependsOn: []string{"c"}},
+		"c":      {DependsOn: []string{"a"}},
+		"self":   {DependsOn: []string{"self"}},
+		"orphan": {DependsOn: []string{"missing"}},
+	})
+
+	require.Len(t, errs, 5)
+	for _, serviceName := range []string{"a", "b", "c"} {
+		require.EqualError(t, errs[serviceName], "dependency cycle a -> b -> c -> a")
+	}
+	require.EqualError(t, errs["self"], "dependency cycle self -> self")
+	require.EqualError(t, errs["orphan"], "depends on unknown service 'missing'")
+}
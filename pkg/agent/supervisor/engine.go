@@ -11,13 +11,45 @@ import (
 	"github.com/deviceplane/cli/pkg/models"
 )
 
+// engineRetryInitial is the delay before the first retry of an engine
+// operation that failed with a transient error. It doubles for each retry.
+var engineRetryInitial = time.Second
+
+// retryTransient runs f, retrying it while it fails with a transient engine
+// error such as the daemon restarting, so that a brief outage isn't reported
+// as a failing service. Other errors are returned right away.
+func retryTransient(ctx context.Context, operation string, f func() error) error {
+	delay := engineRetryInitial
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !engine.IsTransient(err) || attempt == engineRetryAttempts {
+			return err
+		}
+
+		log.WithError(err).
+			WithField("attempt", attempt).
+			Warnf("%s failed, retrying in %s", operation, delay)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
 const containerCreateTimeout = time.Minute
 
 func containerCreate(ctx context.Context, eng engine.Engine, name string, service models.Service) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, containerCreateTimeout)
 	defer cancel()
 
-	id, err := eng.CreateContainer(ctx, name, service)
+	var id string
+	err := retryTransient(ctx, "create container", func() (err error) {
+		id, err = eng.CreateContainer(ctx, name, service)
+		return err
+	})
 	if err != nil {
 		log.WithError(err).Error("create container")
 		return "", err
@@ -32,7 +64,10 @@ func containerStart(ctx context.Context, eng engine.Engine, id string) error {
 	ctx, cancel := context.WithTimeout(ctx, containerStartTimeout)
 	defer cancel()
 
-	if err := eng.StartContainer(ctx, id); err != nil && err != engine.ErrInstanceNotFound {
+	err := retryTransient(ctx, "start container", func() error {
+		return eng.StartContainer(ctx, id)
+	})
+	if err != nil && err != engine.ErrInstanceNotFound {
 		log.WithError(err).Error("start container")
 		return err
 	}
@@ -46,7 +81,11 @@ func containerList(ctx context.Context, eng engine.Engine, keyFilters map[string
 	ctx, cancel := context.WithTimeout(ctx, containerListTimeout)
 	defer cancel()
 
-	instances, err := eng.ListContainers(ctx, keyFilters, keyAndValueFilters, all)
+	var instances []engine.Instance
+	err := retryTransient(ctx, "list containers", func() (err error) {
+		instances, err = eng.ListContainers(ctx, keyFilters, keyAndValueFilters, all)
+		return err
+	})
 	if err != nil {
 		log.WithError(err).Error("list containers")
 		return nil, err
@@ -61,7 +100,10 @@ func containerStop(ctx context.Context, eng engine.Engine, id string) error {
 	ctx, cancel := context.WithTimeout(ctx, containerStopTimeout)
 	defer cancel()
 
-	if err := eng.StopContainer(ctx, id); err != nil && err != engine.ErrInstanceNotFound {
+	err := retryTransient(ctx, "stop container", func() error {
+		return eng.StopContainer(ctx, id)
+	})
+	if err != nil && err != engine.ErrInstanceNotFound {
 		log.WithError(err).Error("stop container")
 		return err
 	}
@@ -75,7 +117,10 @@ func containerRemove(ctx context.Context, eng engine.Engine, id string) error {
 	ctx, cancel := context.WithTimeout(ctx, containerRemoveTimeout)
 	defer cancel()
 
-	if err := eng.RemoveContainer(ctx, id); err != nil && err != engine.ErrInstanceNotFound {
+	err := retryTransient(ctx, "remove container", func() error {
+		return eng.RemoveContainer(ctx, id)
+	})
+	if err != nil && err != engine.ErrInstanceNotFound {
 		log.WithError(err).Error("remove container")
 		return err
 	}
@@ -89,10 +134,41 @@ func imagePull(ctx context.Context, eng engine.Engine, image string, getRegistry
 	ctx, cancel := context.WithTimeout(ctx, imagePullTimeout)
 	defer cancel()
 
-	if err := eng.PullImage(ctx, canonical_image.ToCanonical(image), getRegistryAuth(), w); err != nil {
+	err := retryTransient(ctx, "pull image", func() error {
+		return eng.PullImage(ctx, canonical_image.ToCanonical(image), getRegistryAuth(), w)
+	})
+	if err != nil {
 		log.WithError(err).Error("pull image")
 		return err
 	}
 
 	return nil
 }
+
+const imageInspectTimeout = time.Minute
+
+func imagePresent(ctx context.Context, eng engine.Engine, image string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, imageInspectTimeout)
+	defer cancel()
+
+	err := retryTransient(ctx, "inspect image", func() error {
+		return eng.InspectImage(ctx, canonical_image.ToCanonical(image))
+	})
+	switch err {
+	case nil:
+		return true, nil
+	case engine.ErrImageNotFound:
+		return false, nil
+	default:
+		log.WithError(err).Error("inspect image")
+		return false, err
+	}
+}
+
+// shortID abbreviates a container ID the way docker ps does
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,49 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransient(t *testing.T) {
+	defer func(initial time.Duration) { engineRetryInitial = initial }(engineRetryInitial)
+	engineRetryInitial = time.Millisecond
+
+	t.Run("transient", func(t *testing.T) {
+		attempts := 0
+		err := retryTransient(context.Background(), "list containers", func() error {
+			attempts++
+			if attempts < 3 {
+				return engine.Transient(errors.New("connection refused"))
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("still failing", func(t *testing.T) {
+		attempts := 0
+		err := retryTransient(context.Background(), "list containers", func() error {
+			attempts++
+			return engine.Transient(errors.New("connection refused"))
+		})
+		require.True(t, engine.IsTransient(err))
+		require.Equal(t, engineRetryAttempts, attempts)
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		attempts := 0
+		err := retryTransient(context.Background(), "pull image", func() error {
+			attempts++
+			return errors.New("invalid reference format")
+		})
+		require.EqualError(t, err, "invalid reference format")
+		require.Equal(t, 1, attempts)
+	})
+}
@@ -0,0 +1,140 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/pkg/errors"
+)
+
+// httpProber makes a GET request to a port inside a container's network
+// namespace
+type httpProber func(ctx context.Context, containerID string, port int, path string) (*http.Response, error)
+
+// maxHealthcheckOutput bounds how much of a failing test's output is
+// reported
+const maxHealthcheckOutput = 256
+
+func runHealthcheck(ctx context.Context, eng engine.Engine, probe httpProber, containerID string, healthcheck models.Healthcheck) error {
+	ctx, cancel := context.WithTimeout(ctx, healthcheckDuration(healthcheck.Timeout, defaultHealthcheckTimeout))
+	defer cancel()
+
+	if healthcheck.HTTP != nil {
+		path := healthcheck.HTTP.Path
+		if path == "" {
+			path = "/"
+		}
+		resp, err := probe(ctx, containerID, healthcheck.HTTP.Port, path)
+		if err != nil {
+			return errors.Wrap(err, "request failed")
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("%s returned %s", path, resp.Status)
+		}
+		return nil
+	}
+
+	var output bytes.Buffer
+	exitCode, err := eng.ContainerExec(ctx, containerID, healthcheckCommand(healthcheck.Test), &output, &output)
+	if err != nil {
+		return errors.Wrap(err, "exec failed")
+	}
+	if exitCode != 0 {
+		message := fmt.Sprintf("test exited with exit code %d", exitCode)
+		if out := strings.TrimSpace(output.String()); out != "" {
+			if len(out) > maxHealthcheckOutput {
+				out = out[:maxHealthcheckOutput] + "..."
+			}
+			message += ": " + out
+		}
+		return errors.New(message)
+	}
+	return nil
+}
+
+// healthcheckCommand strips the CMD and CMD-SHELL prefixes used by compose
+// files
+func healthcheckCommand(test []string) []string {
+	if len(test) == 0 {
+		return test
+	}
+	switch test[0] {
+	case "CMD":
+		return test[1:]
+	case "CMD-SHELL":
+		return []string{"/bin/sh", "-c", strings.Join(test[1:], " ")}
+	default:
+		return test
+	}
+}
+
+// healthcheckDuration parses a duration that is validated when the release
+// is created
+func healthcheckDuration(value string, defaultDuration time.Duration) time.Duration {
+	if value == "" {
+		return defaultDuration
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return defaultDuration
+	}
+	return d
+}
+
+// healthTracker follows the healthcheck results of a single container
+type healthTracker struct {
+	lastCheck time.Time
+	passed    bool
+	failures  int
+	lastError string
+}
+
+func (h *healthTracker) reset() {
+	*h = healthTracker{}
+}
+
+// due returns whether the healthcheck should be run again
+func (h *healthTracker) due(now time.Time, healthcheck models.Healthcheck) bool {
+	return now.Sub(h.lastCheck) >= healthcheckDuration(healthcheck.Interval, defaultHealthcheckInterval)
+}
+
+// observe records the result of a healthcheck. Failures during the
+// service's startup grace period aren't counted.
+func (h *healthTracker) observe(now time.Time, err error, inGracePeriod bool) {
+	h.lastCheck = now
+	if err == nil {
+		h.passed = true
+		h.failures = 0
+		h.lastError = ""
+		return
+	}
+	h.lastError = err.Error()
+	if !inGracePeriod {
+		h.failures++
+	}
+}
+
+// state returns the state a running container should be reported in. A
+// container is starting until its healthcheck first passes, and a healthy
+// container only becomes unhealthy after enough failures in a row.
+func (h *healthTracker) state(healthcheck models.Healthcheck) (models.ServiceState, string) {
+	retries := healthcheck.Retries
+	if retries <= 0 {
+		retries = defaultHealthcheckRetries
+	}
+	switch {
+	case h.failures >= retries:
+		return models.ServiceStateUnhealthy, "healthcheck failed: " + h.lastError
+	case h.passed:
+		return models.ServiceStateRunning, ""
+	default:
+		return models.ServiceStateStarting, ""
+	}
+}
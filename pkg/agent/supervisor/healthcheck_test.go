@@ -0,0 +1,95 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthTracker(t *testing.T) {
+	healthcheck := models.Healthcheck{Retries: 2}
+	var h healthTracker
+	now := time.Now()
+
+	require.True(t, h.due(now, healthcheck))
+	h.observe(now, errors.New("connection refused"), true)
+	h.observe(now, errors.New("connection refused"), true)
+	state, _ := h.state(healthcheck)
+	require.Equal(t, models.ServiceStateStarting, state)
+	require.False(t, h.due(now.Add(time.Second), healthcheck))
+
+	h.observe(now, nil, false)
+	state, _ = h.state(healthcheck)
+	require.Equal(t, models.ServiceStateRunning, state)
+
+	h.observe(now, errors.New("500 Internal Server Error"), false)
+	state, _ = h.state(healthcheck)
+	require.Equal(t, models.ServiceStateRunning, state)
+
+	h.observe(now, errors.New("500 Internal Server Error"), false)
+	state, message := h.state(healthcheck)
+	require.Equal(t, models.ServiceStateUnhealthy, state)
+	require.Equal(t, "healthcheck failed: 500 Internal Server Error", message)
+
+	h.reset()
+	state, _ = h.state(healthcheck)
+	require.Equal(t, models.ServiceStateStarting, state)
+}
+
+type execEngine struct {
+	engine.Engine
+	exitCode int
+	command  []string
+}
+
+func (e *execEngine) ContainerExec(ctx context.Context, id string, command []string, stdout, stderr io.Writer) (int, error) {
+	e.command = command
+	io.WriteString(stderr, "not ready\n")
+	return e.exitCode, nil
+}
+
+func TestRunHealthcheck(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		eng := &execEngine{exitCode: 1}
+		err := runHealthcheck(context.Background(), eng, nil, "container", models.Healthcheck{
+			Test: []string{"CMD-SHELL", "curl", "-f", "localhost"},
+		})
+		require.EqualError(t, err, "test exited with exit code 1: not ready")
+		require.Equal(t, []string{"/bin/sh", "-c", "curl -f localhost"}, eng.command)
+
+		eng.exitCode = 0
+		require.NoError(t, runHealthcheck(context.Background(), eng, nil, "container", models.Healthcheck{
+			Test: []string{"CMD", "true"},
+		}))
+		require.Equal(t, []string{"true"}, eng.command)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		status := http.StatusServiceUnavailable
+		probe := func(ctx context.Context, containerID string, port int, path string) (*http.Response, error) {
+			require.Equal(t, 8080, port)
+			require.Equal(t, "/", path)
+			return &http.Response{
+				StatusCode: status,
+				Status:     http.StatusText(status),
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			}, nil
+		}
+		healthcheck := models.Healthcheck{
+			HTTP: &models.HTTPHealthcheck{Port: 8080},
+		}
+
+		require.Error(t, runHealthcheck(context.Background(), nil, probe, "container", healthcheck))
+		status = http.StatusOK
+		require.NoError(t, runHealthcheck(context.Background(), nil, probe, "container", healthcheck))
+	})
+}
@@ -0,0 +1,63 @@
+package supervisor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deviceplane/cli/pkg/interpolation"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/yamltypes"
+)
+
+const serviceVariablesValidatorName = "ServiceVariablesValidator"
+
+// interpolateService expands "${NAME}" references in the command and
+// entrypoint using the device's service variables. It's applied before the
+// hash label is computed, so that changing a variable the service uses
+// recreates its container.
+func interpolateService(service models.Service, values map[string]string) models.Service {
+	if values == nil {
+		return service
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+	service.Command = interpolateCommand(service.Command, lookup)
+	service.Entrypoint = interpolateCommand(service.Entrypoint, lookup)
+	return service
+}
+
+func interpolateCommand(command yamltypes.Command, lookup func(string) (string, bool)) yamltypes.Command {
+	if command == nil {
+		return nil
+	}
+	interpolated := make(yamltypes.Command, len(command))
+	for i, arg := range command {
+		interpolated[i], _ = interpolation.ExpandBraced(arg, lookup)
+	}
+	return interpolated
+}
+
+// undefinedServiceVariables returns an error naming the variables the
+// command and entrypoint reference but values doesn't define. It must be
+// given the values the service was interpolated with.
+func undefinedServiceVariables(service models.Service, values map[string]string) error {
+	if values == nil {
+		return nil
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+
+	var undefined []string
+	for _, arg := range append(append([]string{}, service.Entrypoint...), service.Command...) {
+		_, names := interpolation.ExpandBraced(arg, lookup)
+		undefined = append(undefined, names...)
+	}
+	if len(undefined) > 0 {
+		return fmt.Errorf("undefined service variables: %s", strings.Join(undefined, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package supervisor
+
+import (
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/spec"
+	"github.com/deviceplane/cli/pkg/yamltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolateService(t *testing.T) {
+	service := models.Service{
+		Image:      "app",
+		Entrypoint: yamltypes.Command{"/bin/${SHELL}"},
+		Command:    yamltypes.Command{"--region", "${REGION}", "--missing", "${MISSING}", "$${REGION}", "$HOME"},
+	}
+
+	// Without service variables nothing is interpolated, so the hash is
+	// that of the release's service
+	require.Equal(t, service, interpolateService(service, nil))
+
+	interpolated := interpolateService(service, map[string]string{
+		"SHELL":  "sh",
+		"REGION": "eu",
+	})
+	require.Equal(t, yamltypes.Command{"/bin/sh"}, interpolated.Entrypoint)
+	require.Equal(t, yamltypes.Command{"--region", "eu", "--missing", "${MISSING}", "${REGION}", "$HOME"}, interpolated.Command)
+	require.Equal(t, yamltypes.Command{"--region", "${REGION}", "--missing", "${MISSING}", "$${REGION}", "$HOME"}, service.Command,
+		"Should not modify the release's service")
+
+	// A changed variable changes the hash, so the container is recreated
+	changed := interpolateService(service, map[string]string{
+		"SHELL":  "sh",
+		"REGION": "us",
+	})
+	require.NotEqual(t, spec.Hash(interpolated, "app"), spec.Hash(changed, "app"))
+	require.Equal(t, spec.Hash(interpolated, "app"), spec.Hash(interpolateService(service, map[string]string{
+		"SHELL":  "sh",
+		"REGION": "eu",
+	}), "app"))
+}
+
+func TestUndefinedServiceVariables(t *testing.T) {
+	service := models.Service{
+		Entrypoint: yamltypes.Command{"/bin/${SHELL}"},
+		Command:    yamltypes.Command{"--region", "${REGION}", "--zone", "${ZONE}", "$$HOME"},
+	}
+
+	require.NoError(t, undefinedServiceVariables(service, nil), "Should pass with service variables disabled")
+
+	values := map[string]string{"SHELL": "sh"}
+	err := undefinedServiceVariables(service, values)
+	require.Error(t, err, "Should fail on undefined variables")
+	require.Contains(t, err.Error(), "REGION, ZONE")
+
+	values["REGION"] = "eu"
+	values["ZONE"] = "a"
+	require.NoError(t, undefinedServiceVariables(service, values), "Should pass with every variable defined")
+
+	require.NoError(t, undefinedServiceVariables(models.Service{
+		Command: yamltypes.Command{"sh", "-c", "echo $HOME $${NOT_A_VARIABLE}"},
+	}, values), "Should ignore references other than ${NAME}")
+}
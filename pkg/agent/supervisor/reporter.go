@@ -8,13 +8,20 @@ import (
 	"github.com/apex/log"
 	dpcontext "github.com/deviceplane/cli/pkg/context"
 	"github.com/deviceplane/cli/pkg/models"
+	"github.com/pkg/errors"
 )
 
+// ErrServiceStatusesUnsupported is returned by reportServiceStatuses when the
+// server doesn't support batched service status reports
+var ErrServiceStatusesUnsupported = errors.New("batched service statuses not supported by the server")
+
 type Reporter struct {
 	applicationID           string
 	reportApplicationStatus func(ctx *dpcontext.Context, applicationID, currentRelease string) error
 	reportServiceStatus     func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStatusRequest) error
+	reportServiceStatuses   func(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error
 	reportServiceState      func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStateRequest) error
+	recordEvent             func(event models.AgentEvent)
 
 	desiredApplicationRelease      string
 	desiredApplicationServiceNames map[string]struct{}
@@ -24,11 +31,15 @@ type Reporter struct {
 	serviceStatuses           map[string]models.SetDeviceServiceStatusRequest
 	reportedServiceStatuses   map[string]models.SetDeviceServiceStatusRequest
 	serviceStatusReporterDone chan struct{}
+	// Set once the server turns out not to support batched reports
+	serviceStatusBatchUnsupported bool
 
 	serviceStates            map[string]models.SetDeviceServiceStateRequest
 	reportedServiceStates    map[string]models.SetDeviceServiceStateRequest
 	serviceStateReporterDone chan struct{}
 
+	rollbackReason string
+
 	once   sync.Once
 	lock   sync.RWMutex
 	ctx    context.Context
@@ -39,14 +50,18 @@ func NewReporter(
 	applicationID string,
 	reportApplicationStatus func(ctx *dpcontext.Context, applicationID, currentRelease string) error,
 	reportServiceStatus func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStatusRequest) error,
+	reportServiceStatuses func(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error,
 	reportServiceState func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStateRequest) error,
+	recordEvent func(event models.AgentEvent),
 ) *Reporter {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Reporter{
 		applicationID:           applicationID,
 		reportApplicationStatus: reportApplicationStatus,
 		reportServiceStatus:     reportServiceStatus,
+		reportServiceStatuses:   reportServiceStatuses,
 		reportServiceState:      reportServiceState,
+		recordEvent:             recordEvent,
 
 		desiredApplicationServiceNames: make(map[string]struct{}),
 		applicationStatusReporterDone:  make(chan struct{}),
@@ -90,8 +105,104 @@ func (r *Reporter) SetServiceStatus(serviceName string, status models.SetDeviceS
 
 func (r *Reporter) SetServiceState(serviceName string, state models.SetDeviceServiceStateRequest) {
 	r.lock.Lock()
+	previousState, ok := r.serviceStates[serviceName]
 	r.serviceStates[serviceName] = state
 	r.lock.Unlock()
+
+	// Restart bookkeeping is reported, but on its own doesn't make an event
+	if ok && previousState.State == state.State &&
+		previousState.ErrorMessage == state.ErrorMessage {
+		return
+	}
+	if r.recordEvent == nil {
+		return
+	}
+
+	event := models.AgentEvent{
+		Type:          models.AgentEventServiceStateChanged,
+		ApplicationID: r.applicationID,
+		Service:       serviceName,
+		Message:       string(state.State),
+	}
+	if state.ErrorMessage != "" || state.State == models.ServiceStateExited {
+		event.Type = models.AgentEventServiceFailed
+	}
+	if state.State == models.ServiceStateValidationFailed {
+		event.Type = models.AgentEventValidationFailed
+	}
+	if state.ErrorMessage != "" {
+		event.Message = string(state.State) + ": " + state.ErrorMessage
+	}
+	r.recordEvent(event)
+}
+
+// ServiceState returns the state last set for a service
+func (r *Reporter) ServiceState(serviceName string) models.ServiceState {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.serviceStates[serviceName].State
+}
+
+// RecordEvent records an event for one of the application's services
+func (r *Reporter) RecordEvent(serviceName string, eventType models.AgentEventType, message string) {
+	if r.recordEvent == nil {
+		return
+	}
+	r.recordEvent(models.AgentEvent{
+		Type:          eventType,
+		ApplicationID: r.applicationID,
+		Service:       serviceName,
+		Message:       message,
+	})
+}
+
+// SetRollbackReason annotates every reported service state with the reason
+// the agent rolled back to its last known good bundle. An empty reason
+// clears the annotation.
+func (r *Reporter) SetRollbackReason(reason string) {
+	r.lock.Lock()
+	r.rollbackReason = reason
+	r.lock.Unlock()
+}
+
+// Health returns whether every desired service is running the desired
+// release, and whether any of them is currently failing
+func (r *Reporter) Health() (healthy bool, failing bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	healthy = true
+	for serviceName := range r.desiredApplicationServiceNames {
+		state, ok := r.serviceStates[serviceName]
+		if !ok {
+			healthy = false
+			continue
+		}
+		if state.ErrorMessage != "" || state.State == models.ServiceStateExited {
+			failing = true
+		}
+
+		status, ok := r.serviceStatuses[serviceName]
+		if state.State != models.ServiceStateRunning || !ok || status.CurrentReleaseID != r.desiredApplicationRelease {
+			healthy = false
+		}
+	}
+
+	return healthy && !failing, failing
+}
+
+// ServiceCounts returns how many of the desired services are running, and
+// how many services are desired
+func (r *Reporter) ServiceCounts() (running, desired int) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for serviceName := range r.desiredApplicationServiceNames {
+		if r.serviceStates[serviceName].State == models.ServiceStateRunning {
+			running++
+		}
+	}
+	return running, len(r.desiredApplicationServiceNames)
 }
 
 func (r *Reporter) Stop() {
@@ -125,7 +236,7 @@ func (r *Reporter) applicationStatusReporter() {
 		}
 		r.lock.RUnlock()
 
-		ctx, cancel = dpcontext.New(r.ctx, time.Minute)
+		ctx, cancel = dpcontext.NewDefault(r.ctx)
 
 		if err := r.reportApplicationStatus(ctx, r.applicationID, releaseToReport); err != nil {
 			log.WithError(err).Error("report application status")
@@ -152,9 +263,6 @@ func (r *Reporter) serviceStatusReporter() {
 	defer ticker.Stop()
 
 	for {
-		var ctx *dpcontext.Context
-		var cancel func()
-
 		r.lock.RLock()
 		diff := make(map[string]models.SetDeviceServiceStatusRequest)
 		copy := make(map[string]models.SetDeviceServiceStatusRequest)
@@ -167,20 +275,11 @@ func (r *Reporter) serviceStatusReporter() {
 		}
 		r.lock.RUnlock()
 
-		for serviceName, status := range diff {
-			ctx, cancel = dpcontext.New(r.ctx, time.Minute)
-
-			if err := r.reportServiceStatus(
-				ctx,
-				r.applicationID,
-				serviceName,
-				status,
-			); err != nil {
-				log.WithError(err).Error("report service status")
-				goto cont
-			}
-
-			cancel()
+		// The reported statuses are only updated once every changed status
+		// has been reported, so after a failure they're all sent again
+		if err := r.sendServiceStatuses(diff); err != nil {
+			log.WithError(err).Error("report service status")
+			goto cont
 		}
 
 		r.reportedServiceStatuses = copy
@@ -196,6 +295,37 @@ func (r *Reporter) serviceStatusReporter() {
 	}
 }
 
+// sendServiceStatuses reports changed service statuses, in a single request
+// if that's supported by the server and one at a time otherwise
+func (r *Reporter) sendServiceStatuses(diff map[string]models.SetDeviceServiceStatusRequest) error {
+	if len(diff) == 0 {
+		return nil
+	}
+
+	if r.reportServiceStatuses != nil && !r.serviceStatusBatchUnsupported {
+		ctx, cancel := dpcontext.NewDefault(r.ctx)
+		err := r.reportServiceStatuses(ctx, r.applicationID, models.SetDeviceServiceStatusesRequest{
+			Statuses: diff,
+		})
+		cancel()
+		if err != ErrServiceStatusesUnsupported {
+			return err
+		}
+		log.Info("server doesn't support batched service status reports, reporting them one at a time")
+		r.serviceStatusBatchUnsupported = true
+	}
+
+	for serviceName, status := range diff {
+		ctx, cancel := dpcontext.NewDefault(r.ctx)
+		err := r.reportServiceStatus(ctx, r.applicationID, serviceName, status)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Reporter) serviceStateReporter() {
 	ticker := time.NewTicker(defaultTickerFrequency)
 	defer ticker.Stop()
@@ -208,10 +338,14 @@ func (r *Reporter) serviceStateReporter() {
 		diff := make(map[string]models.SetDeviceServiceStateRequest)
 		copy := make(map[string]models.SetDeviceServiceStateRequest)
 		for service, state := range r.serviceStates {
+			if r.rollbackReason != "" && state.ErrorMessage == "" {
+				state.ErrorMessage = r.rollbackReason
+			}
 			reportedState, ok := r.reportedServiceStates[service]
 			if !ok ||
 				(reportedState.State != state.State ||
-					reportedState.ErrorMessage != state.ErrorMessage) {
+					reportedState.ErrorMessage != state.ErrorMessage ||
+					reportedState.RestartCount != state.RestartCount) {
 				diff[service] = state
 			}
 			copy[service] = state
@@ -219,7 +353,7 @@ func (r *Reporter) serviceStateReporter() {
 		r.lock.RUnlock()
 
 		for serviceName, state := range diff {
-			ctx, cancel = dpcontext.New(r.ctx, time.Minute)
+			ctx, cancel = dpcontext.NewDefault(r.ctx)
 
 			if err := r.reportServiceState(
 				ctx,
@@ -0,0 +1,71 @@
+package supervisor
+
+import (
+	"errors"
+	"testing"
+
+	dpcontext "github.com/deviceplane/cli/pkg/context"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporterValidationFailedEvents(t *testing.T) {
+	var events []models.AgentEvent
+	reporter := NewReporter("app_1", nil, nil, nil, nil, func(event models.AgentEvent) {
+		events = append(events, event)
+	})
+
+	rejected := models.SetDeviceServiceStateRequest{
+		State:        models.ServiceStateValidationFailed,
+		ErrorMessage: "rejected by ImageValidator: image is not whitelisted",
+	}
+	reporter.SetServiceState("web", rejected)
+	reporter.SetServiceState("web", rejected)
+
+	require.Len(t, events, 1)
+	require.Equal(t, models.AgentEventValidationFailed, events[0].Type)
+	require.Equal(t, "web", events[0].Service)
+	require.Equal(t, "validation failed: "+rejected.ErrorMessage, events[0].Message)
+
+	reporter.SetServiceState("web", models.SetDeviceServiceStateRequest{
+		State: models.ServiceStateCreatingContainer,
+	})
+	require.Len(t, events, 2)
+	require.Equal(t, models.AgentEventServiceStateChanged, events[1].Type)
+	require.Empty(t, reporter.serviceStates["web"].ErrorMessage)
+}
+
+func TestReporterBatchedServiceStatuses(t *testing.T) {
+	var batches, singles int
+	batchErr := ErrServiceStatusesUnsupported
+	reporter := NewReporter("app_1", nil,
+		func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStatusRequest) error {
+			singles++
+			return nil
+		},
+		func(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error {
+			batches++
+			return batchErr
+		},
+		nil, nil)
+
+	diff := map[string]models.SetDeviceServiceStatusRequest{
+		"web": {CurrentReleaseID: "rel_1"},
+		"db":  {CurrentReleaseID: "rel_1"},
+	}
+
+	require.NoError(t, reporter.sendServiceStatuses(diff))
+	require.Equal(t, 1, batches)
+	require.Equal(t, 2, singles)
+
+	// Once the server doesn't support batches they're not tried again
+	require.NoError(t, reporter.sendServiceStatuses(diff))
+	require.Equal(t, 1, batches)
+	require.Equal(t, 4, singles)
+
+	reporter.serviceStatusBatchUnsupported = false
+	batchErr = errors.New("internal server error")
+	require.Error(t, reporter.sendServiceStatuses(diff))
+	require.Equal(t, 2, batches)
+	require.Equal(t, 4, singles)
+}
@@ -0,0 +1,66 @@
+package supervisor
+
+import (
+	"context"
+	"sort"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/spec"
+)
+
+// containerGenerations returns the generation, which is the spec hash, of the
+// container of each service, keyed by application ID and then service name.
+// Stopped containers count too, since reconcile keeps any container with a
+// matching generation and leaves starting it to keep-alive.
+func containerGenerations(instances []engine.Instance) map[string]map[string]string {
+	generations := make(map[string]map[string]string)
+	for _, instance := range instances {
+		applicationID := instance.Labels[models.ApplicationLabel]
+		serviceName := instance.Labels[models.ServiceLabel]
+		generation, ok := instance.Labels[models.HashLabel]
+		if applicationID == "" || serviceName == "" || !ok {
+			continue
+		}
+		if generations[applicationID] == nil {
+			generations[applicationID] = make(map[string]string)
+		}
+		generations[applicationID][serviceName] = generation
+	}
+	return generations
+}
+
+// diffGenerations returns the services of the bundle, as
+// "application/service", whose container isn't of the bundle's generation
+// with serviceVariables interpolated
+func diffGenerations(bundle models.Bundle, generations map[string]map[string]string, serviceVariables map[string]string) []string {
+	var differing []string
+	for _, application := range bundle.Applications {
+		applicationID := application.Application.ID
+		for serviceName, service := range application.LatestRelease.Config {
+			service = interpolateService(service, serviceVariables)
+			if generations[applicationID][serviceName] != spec.Hash(service, serviceName) {
+				differing = append(differing, applicationID+"/"+serviceName)
+			}
+		}
+	}
+	sort.Strings(differing)
+	return differing
+}
+
+// Resume compares a bundle that was applied before the agent restarted with
+// the containers the engine has, and returns the services, as
+// "application/service", whose container would be recreated once the bundle
+// is Set. If there are none, the bundle had been fully applied before the
+// restart.
+func (s *Supervisor) Resume(ctx context.Context, bundle models.Bundle) ([]string, error) {
+	instances, err := containerList(ctx, s.engine, map[string]struct{}{
+		models.ApplicationLabel: struct{}{},
+		models.ServiceLabel:     struct{}{},
+	}, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffGenerations(bundle, containerGenerations(instances), s.variables.GetServiceVariables()), nil
+}
@@ -0,0 +1,49 @@
+package supervisor
+
+import (
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/spec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffGenerations(t *testing.T) {
+	web := models.Service{Image: "nginx"}
+	db := models.Service{Image: "postgres"}
+	cache := models.Service{Image: "redis"}
+
+	bundle := models.Bundle{
+		Applications: []models.FullBundledApplication{{
+			Application: models.BundledApplication{ID: "app_1"},
+			LatestRelease: models.Release{Config: map[string]models.Service{
+				"web":   web,
+				"db":    db,
+				"cache": cache,
+			}},
+		}},
+	}
+
+	instance := func(serviceName string, service models.Service, state models.ServiceState) engine.Instance {
+		return engine.Instance{
+			Labels: map[string]string{
+				models.ApplicationLabel: "app_1",
+				models.ServiceLabel:     serviceName,
+				models.HashLabel:        spec.Hash(service, serviceName),
+			},
+			State: state,
+		}
+	}
+
+	generations := containerGenerations([]engine.Instance{
+		instance("web", web, models.ServiceStateRunning),
+		// The previous agent stopped before replacing this one
+		instance("db", models.Service{Image: "postgres:11"}, models.ServiceStateRunning),
+		// Kept by reconcile, and started again by keep-alive
+		instance("cache", cache, models.ServiceStateExited),
+		{Labels: map[string]string{"other": "label"}, State: models.ServiceStateRunning},
+	})
+
+	require.Equal(t, []string{"app_1/db"}, diffGenerations(bundle, generations, nil))
+}
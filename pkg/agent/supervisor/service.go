@@ -28,8 +28,14 @@ type ServiceSupervisor struct {
 	applicationID string
 	serviceName   string
 	engine        engine.Engine
+	variables     variables.Interface
 	reporter      *Reporter
 	validators    []validator.Validator
+	starts        *startLimiter
+	dependencies  func(serviceName string, dependsOn []string) ([]string, error)
+	probeHTTP     httpProber
+
+	restartStablePeriod time.Duration
 
 	imagePuller *imagePuller
 
@@ -57,14 +63,24 @@ func NewServiceSupervisor(
 	variables variables.Interface,
 	reporter *Reporter,
 	validators []validator.Validator,
+	starts *startLimiter,
+	restartStablePeriod time.Duration,
+	dependencies func(serviceName string, dependsOn []string) ([]string, error),
+	probeHTTP httpProber,
 ) *ServiceSupervisor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &ServiceSupervisor{
 		applicationID: applicationID,
 		serviceName:   serviceName,
 		engine:        engine,
+		variables:     variables,
 		reporter:      reporter,
 		validators:    validators,
+		starts:        starts,
+		dependencies:  dependencies,
+		probeHTTP:     probeHTTP,
+
+		restartStablePeriod: restartStablePeriod,
 
 		imagePuller: newImagePuller(applicationID, serviceName, engine, variables),
 
@@ -153,27 +169,24 @@ func (s *ServiceSupervisor) reconcile() {
 		return
 	}
 
+	// The container runs, and is hashed by, the interpolated service. It's
+	// validated against the same variables it was interpolated with.
+	serviceVariables := s.variables.GetServiceVariables()
+	interpolatedService := interpolateService(service, serviceVariables)
+
 	if len(instances) > 0 {
 		// TODO: filter down to just one instance if we find more
 		instance := instances[0]
 
-		if hashLabel, ok := instance.Labels[models.HashLabel]; ok && hashLabel == spec.Hash(service, s.serviceName) {
-			s.sendKeepAliveService(service)
+		if hashLabel, ok := instance.Labels[models.HashLabel]; ok && hashLabel == spec.Hash(interpolatedService, s.serviceName) {
+			s.sendKeepAliveService(interpolatedService)
 			s.sendKeepAliveRelease(release)
 			return
 		}
 
 		startCanceler()
 
-		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
-			State:        models.ServiceStatePullingImage,
-			ErrorMessage: "",
-		})
-		if err = s.imagePuller.Pull(ctx, service.Image); err != nil {
-			s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
-				State:        models.ServiceStatePullingImage,
-				ErrorMessage: err.Error(),
-			})
+		if !s.ensureImage(ctx, service) {
 			return
 		}
 
@@ -202,24 +215,19 @@ func (s *ServiceSupervisor) reconcile() {
 			})
 			return
 		}
+		s.reporter.RecordEvent(s.serviceName, models.AgentEventServiceRemoved, "removed previous container "+shortID(instance.ID))
 	} else {
 		startCanceler()
 
-		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
-			State:        models.ServiceStatePullingImage,
-			ErrorMessage: "",
-		})
-		if err = s.imagePuller.Pull(ctx, service.Image); err != nil {
-			s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
-				State:        models.ServiceStatePullingImage,
-				ErrorMessage: err.Error(),
-			})
+		if !s.ensureImage(ctx, service) {
 			return
 		}
 	}
 
 	s.sendKeepAliveDeactivate()
 
+	containerService := spec.WithStandardLabels(interpolatedService, s.applicationID, s.serviceName)
+
 	for _, v := range s.validators {
 		err := v.Validate(s.service)
 		if err != nil {
@@ -227,28 +235,87 @@ func (s *ServiceSupervisor) reconcile() {
 				WithField("validator", v.Name()).
 				WithError(err).
 				Error("validation failed")
+			// The next state reported for this service replaces this one,
+			// so it clears once a valid config is applied
+			s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+				State:        models.ServiceStateValidationFailed,
+				ErrorMessage: fmt.Sprintf("rejected by %s: %s", v.Name(), err.Error()),
+			})
 			return
 		}
 	}
 
+	if err = undefinedServiceVariables(service, serviceVariables); err != nil {
+		log.WithField("service", s.serviceName).
+			WithField("validator", serviceVariablesValidatorName).
+			WithError(err).
+			Error("validation failed")
+		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+			State:        models.ServiceStateValidationFailed,
+			ErrorMessage: fmt.Sprintf("rejected by %s: %s", serviceVariablesValidatorName, err.Error()),
+		})
+		return
+	}
+
+	pendingDependencies, err := s.dependencies(s.serviceName, service.DependsOn)
+	if err != nil {
+		log.WithField("service", s.serviceName).
+			WithError(err).
+			Error("invalid dependencies")
+		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+			State:        models.ServiceStateValidationFailed,
+			ErrorMessage: fmt.Sprintf("rejected by %s: %s", dependenciesValidatorName, err.Error()),
+		})
+		return
+	}
+	if len(pendingDependencies) > 0 {
+		log.WithField("service", s.serviceName).
+			WithField("dependencies", pendingDependencies).
+			Debug("waiting for dependencies")
+		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+			State:        models.ServiceStateWaitingForDependencies,
+			ErrorMessage: "",
+		})
+		return
+	}
+
+	if err = s.starts.acquire(ctx); err != nil {
+		return
+	}
+	defer s.starts.release()
+
 	s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
 		State:        models.ServiceStateCreatingContainer,
 		ErrorMessage: "",
 	})
-	if _, err = containerCreate(
+	id, err := containerCreate(
 		ctx,
 		s.engine,
-		strings.Join([]string{s.serviceName, hash.ShortHash(s.applicationID), spec.ShortHash(service, s.serviceName)}, "-"),
-		s.transformService(spec.WithStandardLabels(service, s.applicationID, s.serviceName)),
-	); err != nil {
+		strings.Join([]string{s.serviceName, hash.ShortHash(s.applicationID), spec.ShortHash(interpolatedService, s.serviceName)}, "-"),
+		s.transformService(containerService),
+	)
+	if err != nil {
 		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
 			State:        models.ServiceStateCreatingContainer,
 			ErrorMessage: err.Error(),
 		})
 		return
 	}
+	s.reporter.RecordEvent(s.serviceName, models.AgentEventServiceCreated,
+		fmt.Sprintf("created container %s for release %s", shortID(id), release))
 
-	s.sendKeepAliveService(service)
+	s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+		State:        models.ServiceStateStartingContainer,
+		ErrorMessage: "",
+	})
+	if err = containerStart(ctx, s.engine, id); err != nil {
+		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+			State:        models.ServiceStateStartingContainer,
+			ErrorMessage: err.Error(),
+		})
+	}
+
+	s.sendKeepAliveService(interpolatedService)
 	s.sendKeepAliveRelease(release)
 }
 
@@ -267,6 +334,58 @@ func (s *ServiceSupervisor) transformService(service models.Service) models.Serv
 	return service
 }
 
+// ensureImage makes the service's image available according to its pull
+// policy, reporting state along the way. It returns false if the service
+// can't be started.
+func (s *ServiceSupervisor) ensureImage(ctx context.Context, service models.Service) bool {
+	if service.PullPolicy == models.PullPolicyIfNotPresent || service.PullPolicy == models.PullPolicyNever {
+		present, err := imagePresent(ctx, s.engine, service.Image)
+		if err != nil {
+			s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+				State:        models.ServiceStatePullingImage,
+				ErrorMessage: err.Error(),
+			})
+			return false
+		}
+		if present {
+			return true
+		}
+		if service.PullPolicy == models.PullPolicyNever {
+			s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+				State:        models.ServiceStateImageNotPresent,
+				ErrorMessage: fmt.Sprintf("image %s isn't present and the pull policy is %s", service.Image, models.PullPolicyNever),
+			})
+			return false
+		}
+	}
+
+	s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+		State:        models.ServiceStatePullingImage,
+		ErrorMessage: "",
+	})
+	if err := s.imagePuller.Pull(ctx, service.Image); err != nil {
+		s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
+			State:        models.ServiceStatePullingImage,
+			ErrorMessage: err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// startupGracePeriod returns the service's startup_grace_period, which is
+// validated when the release is created
+func startupGracePeriod(service models.Service) time.Duration {
+	if service.StartupGracePeriod == "" {
+		return 0
+	}
+	gracePeriod, err := time.ParseDuration(service.StartupGracePeriod)
+	if err != nil {
+		return 0
+	}
+	return gracePeriod
+}
+
 func (s *ServiceSupervisor) sendKeepAliveRelease(release string) {
 	select {
 	case <-s.ctx.Done():
@@ -298,6 +417,9 @@ func (s *ServiceSupervisor) keepAlive() {
 	active := false
 	var release string
 	var service models.Service
+	backoff := restartBackoff{stablePeriod: s.restartStablePeriod}
+	var health healthTracker
+	var createdAt time.Time
 
 	ticker := time.NewTicker(defaultTickerFrequency)
 	defer ticker.Stop()
@@ -309,7 +431,14 @@ func (s *ServiceSupervisor) keepAlive() {
 			return
 		case release = <-s.keepAliveRelease:
 			break
-		case service = <-s.keepAliveService:
+		case newService := <-s.keepAliveService:
+			// A new container starts with a clean backoff
+			if spec.Hash(newService, s.serviceName) != spec.Hash(service, s.serviceName) {
+				backoff.reset()
+				health.reset()
+				createdAt = time.Now()
+			}
+			service = newService
 			active = true
 		case <-s.keepAliveDeactivate:
 			active = false
@@ -337,41 +466,100 @@ func (s *ServiceSupervisor) keepAlive() {
 			instance := instances[0]
 
 			if instance.State == models.ServiceStateRunning {
-				s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
-					State:        models.ServiceStateRunning,
-					ErrorMessage: "",
-				})
-				s.reporter.SetServiceStatus(s.serviceName, models.SetDeviceServiceStatusRequest{
-					CurrentReleaseID: release,
-				})
+				backoff.running(time.Now())
 				s.containerID.Store(instance.ID)
+
+				// The release isn't reported as current until the
+				// service's healthcheck passes
+				state, errorMessage := models.ServiceStateRunning, ""
+				if service.Healthcheck != nil {
+					if health.due(time.Now(), *service.Healthcheck) {
+						err := runHealthcheck(s.ctx, s.engine, s.probeHTTP, instance.ID, *service.Healthcheck)
+						health.observe(time.Now(), err, time.Since(createdAt) < startupGracePeriod(service))
+					}
+					state, errorMessage = health.state(*service.Healthcheck)
+				}
+
+				s.reporter.SetServiceState(s.serviceName, backoff.annotate(models.SetDeviceServiceStateRequest{
+					State:        state,
+					ErrorMessage: errorMessage,
+				}))
+				if state == models.ServiceStateRunning {
+					s.reporter.SetServiceStatus(s.serviceName, models.SetDeviceServiceStatusRequest{
+						CurrentReleaseID: release,
+					})
+				}
 			} else {
+				health.reset()
+
 				inspectResponse, err := s.engine.InspectContainer(s.ctx, instance.ID)
-				s.reporter.SetServiceState(s.serviceName, models.SetDeviceServiceStateRequest{
-					State: instance.State,
-					ErrorMessage: func() string {
-						if err != nil {
-							return "unknown error, cannot inspect container"
-						}
-						if inspectResponse.ExitCode != nil {
-							message := fmt.Sprintf(
-								"container exited with exit code %d",
-								*inspectResponse.ExitCode,
-							)
-							if inspectResponse.Error == "" {
-								return message
-							}
-							return fmt.Sprintf("%s (error: %s)",
-								message,
-								inspectResponse.Error,
-							)
+				errorMessage := func() string {
+					if err != nil {
+						return "unknown error, cannot inspect container"
+					}
+					if inspectResponse.ExitCode != nil {
+						message := fmt.Sprintf(
+							"container exited with exit code %d",
+							*inspectResponse.ExitCode,
+						)
+						if inspectResponse.Error == "" {
+							return message
 						}
-						return ""
-					}(),
-				})
+						return fmt.Sprintf("%s (error: %s)",
+							message,
+							inspectResponse.Error,
+						)
+					}
+					return ""
+				}()
+
+				restart, delay := backoff.exited(time.Now())
+
+				// Failures of a slow starting service aren't reported until
+				// its startup grace period is over
+				if time.Since(createdAt) < startupGracePeriod(service) {
+					log.WithField("service", s.serviceName).
+						WithField("error", errorMessage).
+						Debug("service failed during startup grace period")
+					s.reporter.SetServiceState(s.serviceName, backoff.annotate(models.SetDeviceServiceStateRequest{
+						State:        models.ServiceStateStartingContainer,
+						ErrorMessage: "",
+					}))
+					if restart {
+						s.restart(instance.ID, errorMessage)
+					}
+					continue
+				}
+
+				if !restart {
+					message := fmt.Sprintf("back-off %s restarting failed container", delay)
+					if errorMessage != "" {
+						message = fmt.Sprintf("%s: %s", message, errorMessage)
+					}
+					s.reporter.SetServiceState(s.serviceName, backoff.annotate(models.SetDeviceServiceStateRequest{
+						State:        models.ServiceStateBackingOff,
+						ErrorMessage: message,
+					}))
+					continue
+				}
+
+				s.reporter.SetServiceState(s.serviceName, backoff.annotate(models.SetDeviceServiceStateRequest{
+					State:        instance.State,
+					ErrorMessage: errorMessage,
+				}))
 
-				containerStart(s.ctx, s.engine, instance.ID)
+				s.restart(instance.ID, errorMessage)
 			}
 		}
 	}
 }
+
+// restart starts an exited container again, recording why it was restarted
+func (s *ServiceSupervisor) restart(containerID, reason string) {
+	message := "restarted container " + shortID(containerID)
+	if reason != "" {
+		message += " after " + reason
+	}
+	s.reporter.RecordEvent(s.serviceName, models.AgentEventServiceRestarted, message)
+	containerStart(s.ctx, s.engine, containerID)
+}
@@ -2,6 +2,7 @@ package supervisor
 
 import (
 	"context"
+	"net/http"
 	"sync"
 	"time"
 
@@ -17,11 +18,17 @@ type Supervisor struct {
 	variables               variables.Interface
 	reportApplicationStatus func(ctx *dpcontext.Context, applicationID, currentReleaseID string) error
 	reportServiceStatus     func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStatusRequest) error
+	reportServiceStatuses   func(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error
 	reportServiceState      func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStateRequest) error
+	recordEvent             func(event models.AgentEvent)
 	validators              []validator.Validator
+	starts                  *startLimiter
+	restartStablePeriod     time.Duration
+	probeHTTP               httpProber
 
 	applicationIDs         map[string]struct{}
 	applicationSupervisors map[string]*ApplicationSupervisor
+	rollbackReason         string
 	once                   sync.Once
 
 	lock   sync.RWMutex
@@ -34,8 +41,11 @@ func NewSupervisor(
 	variables variables.Interface,
 	reportApplicationStatus func(ctx *dpcontext.Context, applicationID, currentReleaseID string) error,
 	reportServiceStatus func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStatusRequest) error,
+	reportServiceStatuses func(ctx *dpcontext.Context, applicationID string, req models.SetDeviceServiceStatusesRequest) error,
 	reportServiceState func(ctx *dpcontext.Context, applicationID, service string, req models.SetDeviceServiceStateRequest) error,
+	recordEvent func(event models.AgentEvent),
 	validators []validator.Validator,
+	probeHTTP func(ctx context.Context, containerID string, port int, path string) (*http.Response, error),
 ) *Supervisor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Supervisor{
@@ -43,8 +53,11 @@ func NewSupervisor(
 		variables:               variables,
 		reportApplicationStatus: reportApplicationStatus,
 		reportServiceStatus:     reportServiceStatus,
+		reportServiceStatuses:   reportServiceStatuses,
 		reportServiceState:      reportServiceState,
+		recordEvent:             recordEvent,
 		validators:              validators,
+		probeHTTP:               probeHTTP,
 
 		applicationIDs:         make(map[string]struct{}),
 		applicationSupervisors: make(map[string]*ApplicationSupervisor),
@@ -64,9 +77,13 @@ func (s *Supervisor) Set(bundle models.Bundle, applications []models.FullBundled
 				application.Application.ID,
 				s.engine,
 				s.variables,
-				NewReporter(application.Application.ID, s.reportApplicationStatus, s.reportServiceStatus, s.reportServiceState),
+				NewReporter(application.Application.ID, s.reportApplicationStatus, s.reportServiceStatus, s.reportServiceStatuses, s.reportServiceState, s.recordEvent),
 				s.validators,
+				s.starts,
+				s.restartStablePeriod,
+				s.probeHTTP,
 			)
+			applicationSupervisor.reporter.SetRollbackReason(s.rollbackReason)
 			s.applicationSupervisors[application.Application.ID] = applicationSupervisor
 		}
 		applicationSupervisor.Set(bundle, application)
@@ -85,6 +102,75 @@ func (s *Supervisor) Set(bundle models.Bundle, applications []models.FullBundled
 	})
 }
 
+// SetMaxConcurrentStarts bounds how many containers are started at once
+// across all applications. Zero or less means no limit. Must be called
+// before the first Set.
+func (s *Supervisor) SetMaxConcurrentStarts(maxConcurrentStarts int) {
+	s.lock.Lock()
+	s.starts = newStartLimiter(maxConcurrentStarts)
+	s.lock.Unlock()
+}
+
+// SetRestartStablePeriod sets how long a restarted container has to stay up
+// for its restart count and backoff to be reset. Zero or less means
+// DefaultRestartStablePeriod. Must be called before the first Set.
+func (s *Supervisor) SetRestartStablePeriod(period time.Duration) {
+	s.lock.Lock()
+	s.restartStablePeriod = period
+	s.lock.Unlock()
+}
+
+// SetRollbackReason annotates the reported state of every service with the
+// reason for a rollback, or clears it if reason is empty
+func (s *Supervisor) SetRollbackReason(reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rollbackReason = reason
+	for _, applicationSupervisor := range s.applicationSupervisors {
+		applicationSupervisor.reporter.SetRollbackReason(reason)
+	}
+}
+
+// Health returns whether every service of the applications last passed to
+// Set is running its desired release, and whether any service is failing
+func (s *Supervisor) Health() (healthy bool, failing bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	healthy = true
+	for applicationID := range s.applicationIDs {
+		applicationSupervisor, ok := s.applicationSupervisors[applicationID]
+		if !ok {
+			healthy = false
+			continue
+		}
+		applicationHealthy, applicationFailing := applicationSupervisor.reporter.Health()
+		healthy = healthy && applicationHealthy
+		failing = failing || applicationFailing
+	}
+
+	return healthy, failing
+}
+
+// ServiceCounts returns how many services of the applications last passed to
+// Set are running, and how many there are in total
+func (s *Supervisor) ServiceCounts() (running, desired int) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for applicationID := range s.applicationIDs {
+		applicationSupervisor, ok := s.applicationSupervisors[applicationID]
+		if !ok {
+			continue
+		}
+		applicationRunning, applicationDesired := applicationSupervisor.reporter.ServiceCounts()
+		running += applicationRunning
+		desired += applicationDesired
+	}
+	return running, desired
+}
+
 func (s *Supervisor) applicationSupervisorGC() {
 	ticker := time.NewTicker(defaultTickerFrequency)
 	defer ticker.Stop()
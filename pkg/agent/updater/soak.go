@@ -0,0 +1,162 @@
+package updater
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/file"
+)
+
+const (
+	previousSuffix         = ".previous"
+	soakFilename           = "update-soak"
+	rolledBackFilename     = "update-rolled-back"
+	soakFilePermissions    = 0644
+	soakDirPermissions     = 0700
+	maxRolledBackFileBytes = 256
+)
+
+// soak is an update that's kept only if the new version confirms itself
+// before the deadline
+type soak struct {
+	Version         string    `json:"version"`
+	PreviousVersion string    `json:"previousVersion"`
+	Deadline        time.Time `json:"deadline"`
+}
+
+// Start resumes the soak of an update to the running version, if there is
+// one. If the running version doesn't call Confirm before the soak's
+// deadline, the previous binary is restored and the agent exits so that it's
+// restarted on the previous version.
+func (u *Updater) Start() {
+	s, err := u.readSoak()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).Error("load update soak")
+		}
+		return
+	}
+
+	if s.Version != u.version {
+		// Left behind by an update that didn't take
+		u.removeSoak()
+		return
+	}
+
+	u.lock.Lock()
+	u.soaking = true
+	u.lock.Unlock()
+
+	log.WithField("version", u.version).
+		WithField("deadline", s.Deadline).
+		Info("soaking agent update")
+
+	go func() {
+		timer := time.NewTimer(time.Until(s.Deadline))
+		defer timer.Stop()
+
+		select {
+		case <-u.confirmed:
+		case <-timer.C:
+			u.rollback(s)
+		}
+	}()
+}
+
+// Confirm marks the running version as good, ending its soak
+func (u *Updater) Confirm() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if !u.soaking {
+		return
+	}
+	u.soaking = false
+	close(u.confirmed)
+
+	u.removeSoak()
+	log.WithField("version", u.version).Info("agent update confirmed")
+}
+
+func (u *Updater) rollback(s soak) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if !u.soaking {
+		return
+	}
+	u.soaking = false
+
+	log.WithField("version", s.Version).
+		WithField("previous_version", s.PreviousVersion).
+		Error("agent update didn't report in before soak deadline, rolling back")
+
+	if u.updateFailed != nil {
+		u.updateFailed()
+	}
+
+	if err := os.Rename(u.binaryPath+previousSuffix, u.binaryPath); err != nil {
+		log.WithError(err).Error("restore previous agent binary")
+		u.removeSoak()
+		return
+	}
+	if err := u.writeStateFile(rolledBackFilename, []byte(s.Version)); err != nil {
+		log.WithError(err).Error("save rolled back version")
+	}
+	u.removeSoak()
+
+	u.exit(0)
+}
+
+// rolledBackVersion returns the last version that was rolled back, which
+// isn't updated to again
+func (u *Updater) rolledBackVersion() string {
+	f, err := os.Open(path.Join(u.stateDir, rolledBackFilename))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	version, err := ioutil.ReadAll(io.LimitReader(f, maxRolledBackFileBytes))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(version))
+}
+
+func (u *Updater) readSoak() (soak, error) {
+	var s soak
+	soakBytes, err := ioutil.ReadFile(path.Join(u.stateDir, soakFilename))
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(soakBytes, &s)
+	return s, err
+}
+
+func (u *Updater) writeSoak(s soak) error {
+	soakBytes, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return u.writeStateFile(soakFilename, soakBytes)
+}
+
+func (u *Updater) removeSoak() {
+	if err := os.Remove(path.Join(u.stateDir, soakFilename)); err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Error("remove update soak")
+	}
+}
+
+func (u *Updater) writeStateFile(filename string, contents []byte) error {
+	if err := os.MkdirAll(u.stateDir, soakDirPermissions); err != nil {
+		return err
+	}
+	return file.WriteFileAtomic(path.Join(u.stateDir, filename), contents, soakFilePermissions)
+}
@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUpdater(t *testing.T, deadline time.Time) (*Updater, string, func()) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	binaryPath := path.Join(dir, "deviceplane-agent")
+	require.NoError(t, ioutil.WriteFile(binaryPath, []byte("new"), 0755))
+	require.NoError(t, ioutil.WriteFile(binaryPath+previousSuffix, []byte("old"), 0755))
+
+	u := NewUpdater("prj_test", "2.0.0", binaryPath, path.Join(dir, "state"), nil)
+	require.NoError(t, u.writeSoak(soak{
+		Version:         "2.0.0",
+		PreviousVersion: "1.0.0",
+		Deadline:        deadline,
+	}))
+
+	return u, binaryPath, func() { os.RemoveAll(dir) }
+}
+
+func TestSoakRollsBackAfterDeadline(t *testing.T) {
+	u, binaryPath, cleanup := newTestUpdater(t, time.Now().Add(-time.Second))
+	defer cleanup()
+
+	exited := make(chan int, 1)
+	u.exit = func(code int) { exited <- code }
+	failures := 0
+	u.updateFailed = func() { failures++ }
+
+	u.Start()
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent didn't exit after rolling back")
+	}
+
+	binary, err := ioutil.ReadFile(binaryPath)
+	require.NoError(t, err)
+	require.Equal(t, "old", string(binary))
+	require.Equal(t, "2.0.0", u.rolledBackVersion())
+	require.Equal(t, 1, failures)
+
+	_, err = u.readSoak()
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSoakConfirmKeepsUpdate(t *testing.T) {
+	u, binaryPath, cleanup := newTestUpdater(t, time.Now().Add(time.Hour))
+	defer cleanup()
+
+	u.exit = func(code int) { t.Fatal("agent exited after update was confirmed") }
+
+	u.Start()
+	u.Confirm()
+	u.Confirm()
+
+	binary, err := ioutil.ReadFile(binaryPath)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(binary))
+	require.Empty(t, u.rolledBackVersion())
+
+	_, err = u.readSoak()
+	require.True(t, os.IsNotExist(err))
+}
@@ -2,43 +2,71 @@ package updater
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/apex/log"
 	dpcontext "github.com/deviceplane/cli/pkg/context"
 	dphttp "github.com/deviceplane/cli/pkg/http"
+	"github.com/pkg/errors"
 )
 
 const (
-	location        = "https://downloads.deviceplane.com/agent/%s/linux/%s/deviceplane-agent"
-	downloadTimeout = time.Hour
+	location         = "https://downloads.deviceplane.com/agent/%s/linux/%s/deviceplane-agent"
+	checksumLocation = location + ".sha256"
+	downloadTimeout  = time.Hour
+
+	// DefaultSoakPeriod is how long a new agent version has to report in
+	// before it's rolled back
+	DefaultSoakPeriod = 10 * time.Minute
 )
 
 type Updater struct {
-	projectID  string
-	version    string
-	binaryPath string
-
+	projectID    string
+	version      string
+	binaryPath   string
+	stateDir     string
+	updateFailed func()
+	exit         func(code int)
+
+	soakPeriod     time.Duration
+	soaking        bool
+	confirmed      chan struct{}
 	desiredVersion string
 	once           sync.Once
 	lock           sync.RWMutex
 }
 
-func NewUpdater(projectID, version, binaryPath string) *Updater {
+// NewUpdater returns an updater that replaces the agent binary at binaryPath
+// with the desired version. The state of an update that's soaking is kept in
+// stateDir.
+func NewUpdater(projectID, version, binaryPath, stateDir string, updateFailed func()) *Updater {
 	return &Updater{
-		projectID:  projectID,
-		version:    version,
-		binaryPath: binaryPath,
+		projectID:    projectID,
+		version:      version,
+		binaryPath:   binaryPath,
+		stateDir:     stateDir,
+		updateFailed: updateFailed,
+		exit:         os.Exit,
+		soakPeriod:   DefaultSoakPeriod,
+		confirmed:    make(chan struct{}),
 	}
 }
 
+// SetSoakPeriod sets how long a new version has to confirm itself before
+// it's rolled back. Zero disables rollbacks. Must be called before Start.
+func (u *Updater) SetSoakPeriod(soakPeriod time.Duration) {
+	u.soakPeriod = soakPeriod
+}
+
 func (u *Updater) SetDesiredVersion(desiredVersion string) {
 	u.lock.Lock()
 	u.desiredVersion = desiredVersion
@@ -49,6 +77,13 @@ func (u *Updater) SetDesiredVersion(desiredVersion string) {
 	})
 }
 
+// Pending returns whether the agent isn't running its desired version
+func (u *Updater) Pending() bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	return u.desiredVersion != "" && u.desiredVersion != u.version
+}
+
 func (u *Updater) updater() {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
@@ -59,11 +94,19 @@ func (u *Updater) updater() {
 		u.lock.RUnlock()
 
 		if desiredVersion != "" && desiredVersion != u.version {
+			if desiredVersion == u.rolledBackVersion() {
+				log.WithField("version", desiredVersion).Warn("not updating to version that was rolled back")
+				goto cont
+			}
+
 			ctx, cancel := dpcontext.New(context.Background(), downloadTimeout)
 			defer cancel()
 
 			if err := u.update(ctx, desiredVersion); err != nil {
 				log.WithError(err).Error("update agent")
+				if u.updateFailed != nil {
+					u.updateFailed()
+				}
 				goto cont
 			}
 		}
@@ -74,6 +117,11 @@ func (u *Updater) updater() {
 }
 
 func (u *Updater) update(ctx *dpcontext.Context, desiredVersion string) error {
+	checksum, err := u.checksum(ctx, desiredVersion)
+	if err != nil {
+		return errors.Wrap(err, "get checksum")
+	}
+
 	resp, err := dphttp.Get(ctx, fmt.Sprintf(location, desiredVersion, runtime.GOARCH))
 	if err != nil {
 		return err
@@ -86,22 +134,35 @@ func (u *Updater) update(ctx *dpcontext.Context, desiredVersion string) error {
 	}
 	defer os.Remove(f.Name())
 
+	hash := sha256.New()
+	previousBinaryPath := u.binaryPath + previousSuffix
+
 	for _, action := range []func() error{
 		func() error {
-			_, err := io.Copy(f, resp.Body)
+			_, err := io.Copy(io.MultiWriter(f, hash), resp.Body)
 			return err
 		},
 		func() error {
 			return f.Close()
 		},
+		func() error {
+			if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+				return fmt.Errorf("checksum mismatch: expected %s, got %s", checksum, sum)
+			}
+			return nil
+		},
 		func() error {
 			return os.Chmod(f.Name(), 0755)
 		},
 		func() error {
-			return syscall.Unlink(u.binaryPath)
+			return os.Rename(u.binaryPath, previousBinaryPath)
 		},
 		func() error {
-			return os.Rename(f.Name(), u.binaryPath)
+			if err := os.Rename(f.Name(), u.binaryPath); err != nil {
+				os.Rename(previousBinaryPath, u.binaryPath)
+				return err
+			}
+			return nil
 		},
 	} {
 		if err = action(); err != nil {
@@ -109,6 +170,37 @@ func (u *Updater) update(ctx *dpcontext.Context, desiredVersion string) error {
 		}
 	}
 
-	os.Exit(0)
+	if u.soakPeriod > 0 {
+		if err := u.writeSoak(soak{
+			Version:         desiredVersion,
+			PreviousVersion: u.version,
+			Deadline:        time.Now().Add(u.soakPeriod),
+		}); err != nil {
+			log.WithError(err).Error("save update soak")
+		}
+	}
+
+	u.exit(0)
 	return nil
 }
+
+// checksum returns the published SHA-256 checksum of the agent binary
+func (u *Updater) checksum(ctx *dpcontext.Context, desiredVersion string) (string, error) {
+	resp, err := dphttp.Get(ctx, fmt.Sprintf(checksumLocation, desiredVersion, runtime.GOARCH))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	// Checksum files are in the "<checksum>  <filename>" format of sha256sum
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", errors.New("empty checksum")
+	}
+	return strings.ToLower(fields[0]), nil
+}
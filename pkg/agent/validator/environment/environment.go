@@ -0,0 +1,55 @@
+package environment
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+type Validator struct {
+	variables variables.Interface
+}
+
+func NewValidator(variables variables.Interface) *Validator {
+	return &Validator{
+		variables: variables,
+	}
+}
+
+func (i *Validator) Validate(s models.Service) error {
+	return validate(
+		s.Environment,
+		i.variables.GetWhitelistedEnvironmentVariables(),
+		i.variables.GetBlacklistedEnvironmentVariables(),
+	)
+}
+
+func (i *Validator) Name() string { return "EnvironmentValidator" }
+
+func validate(environment []string, whitelist, blacklist []string) error {
+	for _, variable := range environment {
+		name := strings.SplitN(variable, "=", 2)[0]
+
+		if matchesAny(name, blacklist) {
+			return fmt.Errorf("environment variable %s is found in the device's blacklist", name)
+		}
+		// If there are no whitelisted variables, we allow everything
+		if len(whitelist) != 0 && !matchesAny(name, whitelist) {
+			return fmt.Errorf("environment variable %s is not found in the device's non-empty whitelist", name)
+		}
+	}
+
+	return nil
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
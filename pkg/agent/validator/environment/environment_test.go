@@ -0,0 +1,34 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	require.NoError(t,
+		validate([]string{"DOCKER_HOST=tcp://x"}, []string{}, []string{}),
+		"Should pass on empty files",
+	)
+
+	require.EqualError(t,
+		validate([]string{"A=1", "DOCKER_HOST=tcp://x"}, []string{}, []string{"DOCKER_HOST"}),
+		"environment variable DOCKER_HOST is found in the device's blacklist",
+	)
+
+	require.Error(t,
+		validate([]string{"DB_PASSWORD"}, []string{}, []string{"*_PASSWORD"}),
+		"Should fail on blacklisted patterns without a value",
+	)
+
+	require.NoError(t,
+		validate([]string{"AWS_REGION=x", "LOG_LEVEL=debug"}, []string{"AWS_*", "LOG_LEVEL"}, []string{}),
+		"Should pass on whitelisted names and patterns",
+	)
+
+	require.EqualError(t,
+		validate([]string{"LOG_LEVEL=debug", "DEBUG=1"}, []string{"LOG_LEVEL"}, []string{}),
+		"environment variable DEBUG is not found in the device's non-empty whitelist",
+	)
+}
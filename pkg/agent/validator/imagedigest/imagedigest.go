@@ -0,0 +1,47 @@
+package imagedigest
+
+import (
+	"fmt"
+
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/docker/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+)
+
+type Validator struct {
+	variables variables.Interface
+}
+
+func NewValidator(variables variables.Interface) *Validator {
+	return &Validator{
+		variables: variables,
+	}
+}
+
+func (i *Validator) Validate(s models.Service) error {
+	if !i.variables.GetRequireImageDigests() {
+		return nil
+	}
+	return validate(s.Image)
+}
+
+func (i *Validator) Name() string { return "ImageDigestValidator" }
+
+// validate requires image to be pinned by a sha256 digest, since a tag can
+// be moved to a different image after a release is made
+func validate(image string) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Errorf("image %q is not a valid reference: %v", image, err)
+	}
+
+	if canonical, ok := named.(reference.Canonical); ok && canonical.Digest().Algorithm() == digest.SHA256 {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"image %q is not pinned by digest, which this device requires. Use %s@sha256:<digest> instead",
+		image, reference.FamiliarName(named),
+	)
+}
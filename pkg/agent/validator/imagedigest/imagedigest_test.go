@@ -0,0 +1,36 @@
+package imagedigest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDigest = "sha256:9f1d3f5aa8d6e4e5f8a0b6ed7b1e3d6f1c4a7b2e5d8c0f3a6b9e2d5c8f1a4b7e"
+
+func TestValidation(t *testing.T) {
+	require.NoError(t,
+		validate("redis@"+testDigest),
+		"Should pass on digest",
+	)
+
+	require.NoError(t,
+		validate("registry.example.com/deviceplane/agent:1.0@"+testDigest),
+		"Should pass on tag and digest",
+	)
+
+	require.Error(t,
+		validate("redis"),
+		"Should fail on implicit latest tag",
+	)
+
+	require.Error(t,
+		validate("deviceplane/agent:latest"),
+		"Should fail on tag",
+	)
+
+	require.Error(t,
+		validate("Redis@"+testDigest),
+		"Should fail on invalid reference",
+	)
+}
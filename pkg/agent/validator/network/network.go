@@ -0,0 +1,53 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/deviceplane/cli/pkg/models"
+)
+
+// hostGateway is resolved by the engine to the host's IP address
+const hostGateway = "host-gateway"
+
+var hostnameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?)*$`)
+
+type Validator struct{}
+
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+func (i *Validator) Validate(s models.Service) error {
+	return validate(s.DNS, s.ExtraHosts)
+}
+
+func (i *Validator) Name() string { return "NetworkValidator" }
+
+// validate requires dns to be IP addresses and extraHosts to be
+// "hostname:ip" entries, as in docker's --dns and --add-host
+func validate(dns, extraHosts []string) error {
+	for _, server := range dns {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("DNS server %q is not an IP address", server)
+		}
+	}
+
+	for _, extraHost := range extraHosts {
+		parts := strings.SplitN(extraHost, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("extra host %q is not of the form hostname:ip", extraHost)
+		}
+		hostname, ip := parts[0], parts[1]
+		if len(hostname) > 253 || !hostnameRegexp.MatchString(hostname) {
+			return fmt.Errorf("extra host %q has an invalid hostname", extraHost)
+		}
+		if ip != hostGateway && net.ParseIP(ip) == nil {
+			return fmt.Errorf("extra host %q has an invalid IP address", extraHost)
+		}
+	}
+
+	return nil
+}
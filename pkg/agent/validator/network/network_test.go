@@ -0,0 +1,42 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	require.NoError(t,
+		validate(nil, nil),
+		"Should pass without entries",
+	)
+
+	require.NoError(t,
+		validate(
+			[]string{"10.0.0.53", "fd00::53"},
+			[]string{"db.internal:10.0.0.2", "cache:fd00::2", "host:host-gateway"},
+		),
+		"Should pass on IPv4 and IPv6 addresses",
+	)
+
+	require.EqualError(t,
+		validate([]string{"dns.internal"}, nil),
+		`DNS server "dns.internal" is not an IP address`,
+	)
+
+	require.EqualError(t,
+		validate(nil, []string{"db.internal"}),
+		`extra host "db.internal" is not of the form hostname:ip`,
+	)
+
+	require.EqualError(t,
+		validate(nil, []string{"db internal:10.0.0.2"}),
+		`extra host "db internal:10.0.0.2" has an invalid hostname`,
+	)
+
+	require.EqualError(t,
+		validate(nil, []string{"db.internal:10.0.0"}),
+		`extra host "db.internal:10.0.0" has an invalid IP address`,
+	)
+}
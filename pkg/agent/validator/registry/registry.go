@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/docker/distribution/reference"
+)
+
+type Validator struct {
+	variables variables.Interface
+}
+
+func NewValidator(variables variables.Interface) *Validator {
+	return &Validator{
+		variables: variables,
+	}
+}
+
+func (i *Validator) Validate(s models.Service) error {
+	return validate(s.Image, i.variables.GetAllowedRegistries())
+}
+
+func (i *Validator) Name() string { return "RegistryValidator" }
+
+// validate requires image to come from one of allowedRegistries. Images are
+// compared in their normalized form, so "redis" comes from
+// docker.io/library, and an entry only matches whole path components.
+func validate(image string, allowedRegistries []string) error {
+	// If the file doesn't exist, or there are no allowed registries, we
+	// allow everything
+	if len(allowedRegistries) == 0 {
+		return nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Errorf("image %q is not a valid reference: %v", image, err)
+	}
+
+	name := named.Name()
+	for _, registry := range allowedRegistries {
+		registry = strings.TrimSuffix(registry, "/")
+		if name == registry || strings.HasPrefix(name, registry+"/") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"image %q (%s) is not from an allowed registry. Allowed registries are: %s",
+		image, name, strings.Join(allowedRegistries, ", "),
+	)
+}
@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	allowed := []string{
+		"registry.example.com/team/",
+		"docker.io/library",
+	}
+
+	require.NoError(t,
+		validate("postgres", nil),
+		"Should pass on empty files",
+	)
+
+	require.NoError(t,
+		validate("registry.example.com/team/agent:1.0", allowed),
+		"Should pass on allowed registry and path",
+	)
+
+	require.NoError(t,
+		validate("redis:5", allowed),
+		"Should pass on normalized official image",
+	)
+
+	require.Error(t,
+		validate("registry.example.com/teamexploit/agent:1.0", allowed),
+		"Should fail on partial path component",
+	)
+
+	require.Error(t,
+		validate("registry.example.com.evil.io/team/agent", allowed),
+		"Should fail on lookalike registry",
+	)
+
+	require.Error(t,
+		validate("deviceplane/agent", allowed),
+		"Should fail on non-official Docker Hub image",
+	)
+
+	err := validate("quay.io/team/agent", allowed)
+	require.EqualError(t, err,
+		`image "quay.io/team/agent" (quay.io/team/agent) is not from an allowed registry. Allowed registries are: registry.example.com/team/, docker.io/library`,
+	)
+}
@@ -1,9 +1,14 @@
 package fsnotify
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,25 +16,50 @@ import (
 	"github.com/apex/log"
 	"github.com/deviceplane/cli/pkg/agent/variables"
 	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
 )
 
+const (
+	// reloadDelay is how long the conf dir must be quiet after a change
+	// before it's reloaded, so that a burst of writes causes a single reload
+	reloadDelay = 500 * time.Millisecond
+)
+
 type Variables struct {
 	dir  string
 	lock sync.RWMutex
 
-	disableSSH               bool
-	disableSSHSet            bool
-	authorizedSSHKeys        []ssh.PublicKey
-	authorizedSSHKeysSet     bool
-	hostSignerKey            string
-	hostSignerKeySet         bool
-	registryAuth             string
-	registryAuthSet          bool
-	whitelistedImages        []string
-	whitelistedImagesSet     bool
-	disableCustomCommands    bool
-	disableCustomCommandsSet bool
+	// values is nil until the conf dir has been loaded for the first time
+	values *values
+}
+
+// values are the variables loaded from the conf dir at one point in time.
+// They're only ever replaced as a whole, so a reload that fails part way
+// can't leave a mix of old and new policies in place.
+type values struct {
+	disableSSH            bool
+	authorizedSSHKeys     []ssh.PublicKey
+	hostSignerKey         string
+	registryAuth          string
+	whitelistedImages     []string
+	disableCustomCommands bool
+	localMetricsEndpoint  string
+	disableCloudMetrics   bool
+	requireImageDigests   bool
+	allowedRegistries     []string
+
+	whitelistedEnvironmentVariables []string
+	blacklistedEnvironmentVariables []string
+
+	// remoteLimits are keyed by variable name, shared or per endpoint
+	remoteLimits map[string]int
+
+	// serviceVariables is nil if the service variables dir doesn't exist
+	serviceVariables map[string]string
+
+	// set holds the variables whose files exist
+	set map[string]bool
 }
 
 func NewVariables(dir string) *Variables {
@@ -38,22 +68,32 @@ func NewVariables(dir string) *Variables {
 	}
 }
 
+// Start loads the conf dir and watches it for changes. If the conf dir can't
+// be loaded, Start fails rather than running with default policies in place
+// of invalid ones.
 func (v *Variables) Start() error {
+	if err := v.refresh(); err != nil {
+		return errors.Wrap(err, "load conf dir")
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 
-	v.refresh()
-
 	go func() {
+		var reload <-chan time.Time
 		for {
 			select {
 			case _, ok := <-watcher.Events:
 				if !ok {
 					return
 				}
+				reload = time.After(reloadDelay)
+			case <-reload:
+				reload = nil
 				v.refresh()
+				v.watchServiceVariables(watcher)
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
@@ -63,203 +103,354 @@ func (v *Variables) Start() error {
 		}
 	}()
 
-	return watcher.Add(v.dir)
+	if err := watcher.Add(v.dir); err != nil {
+		return err
+	}
+	v.watchServiceVariables(watcher)
+	return nil
 }
 
-func (v *Variables) refresh() {
-	for _, refresher := range []func() error{
-		v.refreshDisableSSH,
-		v.refreshAuthorizedSSHKeys,
-		v.refreshHostSignerKey,
-		v.refreshRegistryAuth,
-		v.refreshWhitelistedImages,
-		v.refreshDisableCustomCommands,
-	} {
-		if err := refresher(); err != nil {
-			log.WithError(err).Error("variables refresh")
-		}
+// watchServiceVariables watches the service variables dir too, if it exists,
+// since changes to the files in it aren't events of the conf dir. A dir
+// that's removed stops being watched by itself.
+func (v *Variables) watchServiceVariables(watcher *fsnotify.Watcher) {
+	if err := watcher.Add(path.Join(v.dir, variables.ServiceVariablesDir)); err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Error("watch service variables")
 	}
 }
 
-func (v *Variables) refreshDisableSSH() error {
-	_, err := os.Stat(path.Join(v.dir, variables.DisableSSH))
+// refresh reloads the conf dir, keeping the previous values if any of it
+// can't be read or is invalid. Nothing is loaded until the conf dir is valid.
+func (v *Variables) refresh() error {
+	values, err := load(v.dir)
 
 	v.lock.Lock()
 	defer v.lock.Unlock()
 
-	if err == nil {
-		v.disableSSH = true
-		v.disableSSHSet = true
-	} else if os.IsNotExist(err) {
-		v.disableSSH = false
-		v.disableSSHSet = true
-	} else {
+	if err != nil {
+		if v.values != nil {
+			log.WithError(err).Error("variables refresh, keeping previous values")
+		}
 		return err
 	}
-
+	v.values = values
 	return nil
 }
 
-func (v *Variables) refreshAuthorizedSSHKeys() error {
-	bytes, err := ioutil.ReadFile(path.Join(v.dir, variables.AuthorizedSSHKeys))
+// defaultValues are the values of an empty conf dir
+func defaultValues() values {
+	return values{
+		authorizedSSHKeys:               []ssh.PublicKey{},
+		whitelistedImages:               []string{},
+		allowedRegistries:               []string{},
+		whitelistedEnvironmentVariables: []string{},
+		blacklistedEnvironmentVariables: []string{},
+		remoteLimits:                    make(map[string]int),
+		set:                             make(map[string]bool),
+	}
+}
 
-	v.lock.Lock()
-	defer v.lock.Unlock()
+// load reads the conf dir. Variables that can't be read or are invalid are
+// left at their defaults, and an error naming each of them is returned along
+// with the values.
+func load(dir string) (*values, error) {
+	values := defaultValues()
+
+	var errs []string
+	failed := make(map[string]bool)
+	fail := func(name string, err error) {
+		errs = append(errs, errors.Wrap(err, name).Error())
+		failed[name] = true
+	}
 
-	if err == nil {
-		authorizedSSHKeys, err := parseAuthorizedKeysFile(bytes)
-		if err != nil {
-			return err
-		}
-		v.authorizedSSHKeys = authorizedSSHKeys
-		v.authorizedSSHKeysSet = true
-	} else if os.IsNotExist(err) {
-		v.authorizedSSHKeys = make([]ssh.PublicKey, 0)
-		v.authorizedSSHKeysSet = true
+	if disableSSH, err := exists(path.Join(dir, variables.DisableSSH)); err != nil {
+		fail(variables.DisableSSH, err)
 	} else {
-		return err
+		values.disableSSH = disableSSH
 	}
 
-	return nil
-}
-
-func (v *Variables) refreshHostSignerKey() error {
-	bytes, err := ioutil.ReadFile(path.Join(v.dir, variables.HostSignerKey))
+	if authorizedSSHKeys, err := readFile(path.Join(dir, variables.AuthorizedSSHKeys)); err != nil {
+		fail(variables.AuthorizedSSHKeys, err)
+	} else if strings.TrimSpace(string(authorizedSSHKeys)) != "" {
+		if keys, err := parseAuthorizedKeysFile(authorizedSSHKeys); err != nil {
+			fail(variables.AuthorizedSSHKeys, err)
+		} else {
+			values.authorizedSSHKeys = keys
+		}
+	}
 
-	v.lock.Lock()
-	defer v.lock.Unlock()
+	if hostSignerKey, err := readFile(path.Join(dir, variables.HostSignerKey)); err != nil {
+		fail(variables.HostSignerKey, err)
+	} else if len(hostSignerKey) != 0 {
+		if err := validateHostSignerKey(hostSignerKey); err != nil {
+			fail(variables.HostSignerKey, err)
+		} else {
+			values.hostSignerKey = string(hostSignerKey)
+		}
+	}
 
-	if err == nil {
-		v.hostSignerKey = string(bytes)
-		v.hostSignerKeySet = true
-	} else if os.IsNotExist(err) {
-		v.hostSignerKey = ""
-		v.hostSignerKeySet = true
+	if registryAuth, err := readFile(path.Join(dir, variables.RegistryAuth)); err != nil {
+		fail(variables.RegistryAuth, err)
 	} else {
-		return err
+		values.registryAuth = strings.TrimSpace(string(registryAuth))
 	}
 
-	return nil
-}
+	if disableCustomCommands, err := exists(path.Join(dir, variables.DisableCustomCommands)); err != nil {
+		fail(variables.DisableCustomCommands, err)
+	} else {
+		values.disableCustomCommands = disableCustomCommands
+	}
 
-func (v *Variables) refreshRegistryAuth() error {
-	bytes, err := ioutil.ReadFile(path.Join(v.dir, variables.RegistryAuth))
+	if localMetricsEndpoint, err := readFile(path.Join(dir, variables.LocalMetricsEndpoint)); err != nil {
+		fail(variables.LocalMetricsEndpoint, err)
+	} else if endpoint := strings.TrimSpace(string(localMetricsEndpoint)); endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil {
+			fail(variables.LocalMetricsEndpoint, err)
+		} else if u.Host == "" {
+			fail(variables.LocalMetricsEndpoint, errors.Errorf("%q has no host", endpoint))
+		} else {
+			values.localMetricsEndpoint = endpoint
+		}
+	}
 
-	v.lock.Lock()
-	defer v.lock.Unlock()
+	if disableCloudMetrics, err := exists(path.Join(dir, variables.DisableCloudMetrics)); err != nil {
+		fail(variables.DisableCloudMetrics, err)
+	} else {
+		values.disableCloudMetrics = disableCloudMetrics
+	}
 
-	if err == nil {
-		v.registryAuth = strings.TrimSpace(string(bytes))
-		v.registryAuthSet = true
-	} else if os.IsNotExist(err) {
-		v.registryAuth = ""
-		v.registryAuthSet = true
+	if requireImageDigests, err := exists(path.Join(dir, variables.RequireImageDigests)); err != nil {
+		fail(variables.RequireImageDigests, err)
 	} else {
-		return err
+		values.requireImageDigests = requireImageDigests
 	}
 
-	return nil
-}
+	for name, list := range map[string]*[]string{
+		variables.WhitelistedImages:               &values.whitelistedImages,
+		variables.AllowedRegistries:               &values.allowedRegistries,
+		variables.WhitelistedEnvironmentVariables: &values.whitelistedEnvironmentVariables,
+		variables.BlacklistedEnvironmentVariables: &values.blacklistedEnvironmentVariables,
+	} {
+		if lines, err := readList(path.Join(dir, name)); err != nil {
+			fail(name, err)
+		} else {
+			*list = lines
+		}
+	}
 
-func (v *Variables) refreshWhitelistedImages() error {
-	bytes, err := ioutil.ReadFile(path.Join(v.dir, variables.WhitelistedImages))
+	for _, name := range variables.RemoteLimits() {
+		if n, err := readLimit(path.Join(dir, name)); err != nil {
+			fail(name, err)
+		} else if n != 0 {
+			values.remoteLimits[name] = n
+		}
+	}
 
-	v.lock.Lock()
-	defer v.lock.Unlock()
+	serviceVariablesDir := path.Join(dir, variables.ServiceVariablesDir)
+	if serviceVariables, err := readServiceVariables(serviceVariablesDir); err != nil {
+		fail(variables.ServiceVariablesDir, err)
+	} else {
+		values.serviceVariables = serviceVariables
+	}
 
-	if err == nil {
-		v.whitelistedImages = []string{}
-		nonCleanedImages := strings.Split(string(bytes), "\n")
-		for _, image := range nonCleanedImages {
-			cleanedImage := strings.TrimSpace(image)
-			if len(cleanedImage) != 0 {
-				v.whitelistedImages = append(v.whitelistedImages, cleanedImage)
-			}
+	for _, name := range append([]string{
+		variables.DisableSSH,
+		variables.AuthorizedSSHKeys,
+		variables.HostSignerKey,
+		variables.RegistryAuth,
+		variables.WhitelistedImages,
+		variables.DisableCustomCommands,
+		variables.LocalMetricsEndpoint,
+		variables.DisableCloudMetrics,
+		variables.RequireImageDigests,
+		variables.AllowedRegistries,
+		variables.WhitelistedEnvironmentVariables,
+		variables.BlacklistedEnvironmentVariables,
+		variables.ServiceVariablesDir,
+	}, variables.RemoteLimits()...) {
+		// A variable that failed to load is at its default, so it isn't
+		// considered set even though its file exists
+		if set, err := exists(path.Join(dir, name)); err == nil {
+			values.set[name] = set && !failed[name]
 		}
+	}
+
+	if len(errs) != 0 {
+		sort.Strings(errs)
+		return &values, errors.New(strings.Join(errs, "; "))
+	}
+	return &values, nil
+}
+
+// validateHostSignerKey checks that key is a PEM encoded RSA private key, as
+// the SSH server expects
+func validateHostSignerKey(key []byte) error {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return errors.New("no PEM data found")
+	}
+	_, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	return err
+}
 
-		v.whitelistedImagesSet = true
+// exists returns whether a flag file exists
+func exists(filename string) (bool, error) {
+	_, err := os.Stat(filename)
+	if err == nil {
+		return true, nil
 	} else if os.IsNotExist(err) {
-		v.whitelistedImages = []string{}
-		v.whitelistedImagesSet = true
-	} else {
-		return err
+		return false, nil
 	}
+	return false, err
+}
 
-	return nil
+// readFile returns the contents of a file, or nothing if it doesn't exist
+func readFile(filename string) ([]byte, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return bytes, err
 }
 
-func (v *Variables) refreshDisableCustomCommands() error {
-	_, err := os.Stat(path.Join(v.dir, variables.DisableCustomCommands))
+// readList returns the non-empty lines of a file, or an empty list if the
+// file doesn't exist
+func readList(filename string) ([]string, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
 
-	v.lock.Lock()
-	defer v.lock.Unlock()
+	list := []string{}
+	for _, line := range strings.Split(string(bytes), "\n") {
+		if cleaned := strings.TrimSpace(line); len(cleaned) != 0 {
+			list = append(list, cleaned)
+		}
+	}
+	return list, nil
+}
 
-	if err == nil {
-		v.disableCustomCommands = true
-		v.disableCustomCommandsSet = true
-	} else if os.IsNotExist(err) {
-		v.disableCustomCommands = false
-		v.disableCustomCommandsSet = true
-	} else {
-		return err
+// readServiceVariables returns the contents of each file in dir, keyed by
+// file name, or nil if dir doesn't exist
+func readServiceVariables(dir string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	return nil
+	serviceVariables := make(map[string]string, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		bytes, err := ioutil.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		serviceVariables[file.Name()] = strings.TrimRight(string(bytes), "\r\n")
+	}
+	return serviceVariables, nil
+}
+
+// readLimit returns the positive number in a file, or 0 if the file doesn't
+// exist
+func readLimit(filename string) (int, error) {
+	bytes, err := readFile(filename)
+	if err != nil || bytes == nil {
+		return 0, err
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(string(bytes)))
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		return 0, errors.Errorf("%d is not positive", limit)
+	}
+	return limit, nil
 }
 
 func (v *Variables) GetDisableSSH() bool {
-	v.waitFor(func() bool {
-		return v.disableSSHSet
-	})
-	return v.disableSSH
+	return v.current().disableSSH
 }
 
 func (v *Variables) GetAuthorizedSSHKeys() []ssh.PublicKey {
-	v.waitFor(func() bool {
-		return v.authorizedSSHKeysSet
-	})
-	return v.authorizedSSHKeys
+	return v.current().authorizedSSHKeys
 }
 
 func (v *Variables) GetHostSignerKey() string {
-	v.waitFor(func() bool {
-		return v.hostSignerKeySet
-	})
-	return v.hostSignerKey
+	return v.current().hostSignerKey
 }
 
 func (v *Variables) GetRegistryAuth() string {
-	v.waitFor(func() bool {
-		return v.registryAuthSet
-	})
-	return v.registryAuth
+	return v.current().registryAuth
 }
 
 func (v *Variables) GetWhitelistedImages() []string {
-	v.waitFor(func() bool {
-		return v.whitelistedImagesSet
-	})
-	return v.whitelistedImages
+	return v.current().whitelistedImages
 }
 
 func (v *Variables) GetDisableCustomCommands() bool {
-	v.waitFor(func() bool {
-		return v.disableCustomCommandsSet
-	})
-	return v.disableCustomCommands
+	return v.current().disableCustomCommands
 }
 
-func (v *Variables) waitFor(getField func() bool) {
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
+func (v *Variables) GetLocalMetricsEndpoint() string {
+	return v.current().localMetricsEndpoint
+}
 
-	for {
-		v.lock.RLock()
-		field := getField()
-		v.lock.RUnlock()
-		if field {
-			return
-		}
-		<-ticker.C
+func (v *Variables) GetDisableCloudMetrics() bool {
+	return v.current().disableCloudMetrics
+}
+
+func (v *Variables) GetRequireImageDigests() bool {
+	return v.current().requireImageDigests
+}
+
+func (v *Variables) GetAllowedRegistries() []string {
+	return v.current().allowedRegistries
+}
+
+func (v *Variables) GetWhitelistedEnvironmentVariables() []string {
+	return v.current().whitelistedEnvironmentVariables
+}
+
+func (v *Variables) GetBlacklistedEnvironmentVariables() []string {
+	return v.current().blacklistedEnvironmentVariables
+}
+
+func (v *Variables) GetRemoteMaxSessions(endpoint string) int {
+	return variables.EndpointLimit(v.current().remoteLimits, variables.RemoteMaxSessions, endpoint)
+}
+
+func (v *Variables) GetRemoteRateLimit(endpoint string) int {
+	return variables.EndpointLimit(v.current().remoteLimits, variables.RemoteRateLimit, endpoint)
+}
+
+// IsSet returns whether the file of the named variable exists. Flags are set
+// by the existence of their file, so a flag is never set to false.
+func (v *Variables) IsSet(name string) bool {
+	return v.current().set[name]
+}
+
+// GetServiceVariables returns the service variables loaded along with the
+// other variables, so they're consistent with them
+func (v *Variables) GetServiceVariables() map[string]string {
+	return v.current().serviceVariables
+}
+
+// current returns the loaded values, or the defaults if the conf dir hasn't
+// been loaded, which is only the case until Start succeeds
+func (v *Variables) current() *values {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.values == nil {
+		values := defaultValues()
+		return &values
 	}
+	return v.values
 }
@@ -0,0 +1,119 @@
+package fsnotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshKeepsPreviousValuesOnInvalidContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, contents string) {
+		require.NoError(t, ioutil.WriteFile(path.Join(dir, name), []byte(contents), 0644))
+	}
+
+	write(variables.DisableCustomCommands, "")
+	write(variables.AuthorizedSSHKeys, rawKeys[0])
+	write(variables.WhitelistedImages, "nginx\n\nredis\n")
+
+	v := NewVariables(dir)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+	require.Len(t, v.GetAuthorizedSSHKeys(), 1)
+	require.Equal(t, []string{"nginx", "redis"}, v.GetWhitelistedImages())
+
+	// A half written keys file must not take effect along with the removed
+	// policy file
+	require.NoError(t, os.Remove(path.Join(dir, variables.DisableCustomCommands)))
+	write(variables.AuthorizedSSHKeys, rawKeys[0][:20])
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+	require.Len(t, v.GetAuthorizedSSHKeys(), 1)
+
+	write(variables.AuthorizedSSHKeys, rawKeys[0])
+	write(variables.LocalMetricsEndpoint, "not a url")
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	write(variables.LocalMetricsEndpoint, "statsd://localhost:8125\n")
+	write(variables.HostSignerKey, "not a key")
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	require.NoError(t, os.Remove(path.Join(dir, variables.HostSignerKey)))
+	v.refresh()
+	require.False(t, v.GetDisableCustomCommands())
+	require.Equal(t, "statsd://localhost:8125", v.GetLocalMetricsEndpoint())
+	require.Empty(t, v.GetHostSignerKey())
+	require.Zero(t, v.GetRemoteMaxSessions(""))
+
+	write(variables.RemoteMaxSessions, "2\n")
+	write(variables.RemoteLimit(variables.RemoteMaxSessions, "ssh"), "4")
+	write(variables.RemoteRateLimit, "10")
+	v.refresh()
+	require.Equal(t, 2, v.GetRemoteMaxSessions(""))
+	require.Equal(t, 4, v.GetRemoteMaxSessions("ssh"))
+	require.Equal(t, 2, v.GetRemoteMaxSessions("exec"))
+	require.Equal(t, 10, v.GetRemoteRateLimit("ssh"))
+	require.True(t, v.IsSet(variables.RemoteRateLimit))
+	require.True(t, v.IsSet(variables.RemoteLimit(variables.RemoteMaxSessions, "ssh")))
+	require.False(t, v.IsSet(variables.RemoteLimit(variables.RemoteMaxSessions, "exec")))
+	require.False(t, v.IsSet(variables.DisableCustomCommands))
+	require.False(t, v.IsSet(variables.ServiceVariablesDir))
+
+	write(variables.RemoteMaxSessions, "0")
+	v.refresh()
+	require.Equal(t, 2, v.GetRemoteMaxSessions(""))
+}
+
+func TestStartFailsOnInvalidContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, contents string) {
+		require.NoError(t, ioutil.WriteFile(path.Join(dir, name), []byte(contents), 0644))
+	}
+
+	write(variables.DisableSSH, "")
+	write(variables.AuthorizedSSHKeys, rawKeys[0][:20])
+
+	// Nothing is loaded rather than the valid variables alone
+	v := NewVariables(dir)
+	require.Error(t, v.Start())
+	require.Error(t, v.refresh())
+
+	write(variables.AuthorizedSSHKeys, rawKeys[0])
+	require.NoError(t, v.refresh())
+	require.True(t, v.GetDisableSSH())
+	require.Len(t, v.GetAuthorizedSSHKeys(), 1)
+}
+
+func TestServiceVariablesAreLoadedWithTheOtherVariables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	v := NewVariables(dir)
+	require.NoError(t, v.refresh())
+	require.Nil(t, v.GetServiceVariables())
+	require.False(t, v.IsSet(variables.ServiceVariablesDir))
+
+	serviceVariablesDir := path.Join(dir, variables.ServiceVariablesDir)
+	require.NoError(t, os.Mkdir(serviceVariablesDir, 0700))
+	require.NoError(t, ioutil.WriteFile(path.Join(serviceVariablesDir, "REGION"), []byte("eu\n"), 0600))
+
+	// Files on disk don't take effect until the conf dir is reloaded
+	require.Nil(t, v.GetServiceVariables())
+
+	require.NoError(t, v.refresh())
+	require.Equal(t, map[string]string{"REGION": "eu"}, v.GetServiceVariables())
+	require.True(t, v.IsSet(variables.ServiceVariablesDir))
+}
@@ -0,0 +1,384 @@
+// Package httppoll implements variables.Interface with variables fetched
+// from an HTTP endpoint, for devices whose policies are managed centrally
+package httppoll
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/deviceplane/cli/pkg/file"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// DefaultPollInterval is how often variables are fetched unless
+	// another interval is given to NewVariables
+	DefaultPollInterval = time.Minute
+
+	fetchTimeout = 30 * time.Second
+)
+
+// Document is what the endpoint serves: a JSON object keyed by the variable
+// names in the variables package. Missing keys leave a variable at its
+// default, as a missing file does for fsnotify.Variables. The remote limits
+// aren't fields, since they can be overridden per endpoint, but are read
+// from the same object.
+type Document struct {
+	DisableSSH            bool     `json:"disable-ssh"`
+	AuthorizedSSHKeys     []string `json:"authorized-ssh-keys"`
+	HostSignerKey         string   `json:"host-signer-key"`
+	RegistryAuth          string   `json:"registry-auth"`
+	WhitelistedImages     []string `json:"whitelisted-images"`
+	DisableCustomCommands bool     `json:"disable-custom-commands"`
+	LocalMetricsEndpoint  string   `json:"local-metrics-endpoint"`
+	DisableCloudMetrics   bool     `json:"disable-cloud-metrics"`
+	RequireImageDigests   bool     `json:"require-image-digests"`
+	AllowedRegistries     []string `json:"allowed-registries"`
+
+	WhitelistedEnvironmentVariables []string `json:"whitelisted-environment-variables"`
+	BlacklistedEnvironmentVariables []string `json:"blacklisted-environment-variables"`
+
+	// ServiceVariables enables service variables if it's set, even if
+	// it's empty
+	ServiceVariables map[string]string `json:"service-variables"`
+}
+
+var errNotHTTPS = errors.New("variables URL must be https")
+
+// HTTPClient sends requests to the endpoint, such as the agent's client so
+// that they go through its proxy and TLS settings
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Variables struct {
+	url          string
+	cacheFile    string
+	pollInterval time.Duration
+	httpClient   HTTPClient
+	// token authenticates the device to the endpoint, if it's set
+	token string
+
+	lock sync.RWMutex
+	// values is nil until variables have been fetched successfully, or
+	// loaded from the cache file
+	values *values
+}
+
+// values are the variables of one fetched document. Like fsnotify's, they're
+// only ever replaced as a whole.
+type values struct {
+	Document
+	authorizedSSHKeys []ssh.PublicKey
+
+	// remoteLimits are keyed by variable name, shared or per endpoint
+	remoteLimits map[string]int
+
+	// set holds the variables present in the document, other than as null
+	set map[string]bool
+}
+
+// NewVariables returns variables fetched from url every pollInterval. The
+// last document fetched successfully is saved to cacheFile, if it isn't
+// empty, so that variables are available before the endpoint can be
+// reached after a restart. Until then, every variable is at its default.
+//
+// The document can grant SSH access to the device, so url must be https.
+// Requests can be authenticated with a token given to SetToken, or with a
+// client certificate configured on the client given to SetHTTPClient. The
+// device's access key is never sent, since the endpoint isn't the control
+// plane.
+func NewVariables(url, cacheFile string, pollInterval time.Duration) *Variables {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	return &Variables{
+		url:          url,
+		cacheFile:    cacheFile,
+		pollInterval: pollInterval,
+		httpClient:   http.DefaultClient,
+	}
+}
+
+func (v *Variables) Start() error {
+	u, err := url.Parse(v.url)
+	if err != nil {
+		return errors.Wrap(err, "parse variables URL")
+	}
+	if u.Scheme != "https" {
+		return errNotHTTPS
+	}
+
+	if v.cacheFile != "" {
+		if err := v.loadCache(); err != nil {
+			log.WithError(err).Error("load cached variables")
+		}
+	}
+
+	v.refresh()
+
+	go func() {
+		ticker := time.NewTicker(v.pollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			v.refresh()
+		}
+	}()
+
+	return nil
+}
+
+// SetToken sets a bearer token requests are authenticated with. Must be
+// called before Start.
+func (v *Variables) SetToken(token string) {
+	v.token = token
+}
+
+// SetHTTPClient sets the client requests are sent with, instead of
+// http.DefaultClient. Must be called before Start.
+func (v *Variables) SetHTTPClient(httpClient HTTPClient) {
+	v.httpClient = httpClient
+}
+
+// refresh fetches the variables, keeping the previous values if the
+// endpoint can't be reached or serves an invalid document
+func (v *Variables) refresh() {
+	bytes, err := v.fetch()
+	if err == nil {
+		err = v.set(bytes)
+	}
+	if err != nil {
+		log.WithError(err).Error("variables refresh, keeping previous values")
+		return
+	}
+
+	if v.cacheFile != "" {
+		if err := file.WriteFileAtomic(v.cacheFile, bytes, 0600); err != nil {
+			log.WithError(err).Error("save cached variables")
+		}
+	}
+}
+
+func (v *Variables) fetch() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if v.token != "" {
+		req.Header.Set("Authorization", "Bearer "+v.token)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch variables: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (v *Variables) loadCache() error {
+	bytes, err := ioutil.ReadFile(v.cacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return v.set(bytes)
+}
+
+// set replaces the current values with those of a document, if it's valid
+func (v *Variables) set(bytes []byte) error {
+	values, err := parse(bytes)
+	if err != nil {
+		return err
+	}
+
+	v.lock.Lock()
+	v.values = values
+	v.lock.Unlock()
+	return nil
+}
+
+// defaultValues are the values of an empty document. Nothing is set, so
+// layered variables fall through to their other sources.
+var defaultValues = values{
+	Document: Document{
+		WhitelistedImages:               []string{},
+		AllowedRegistries:               []string{},
+		WhitelistedEnvironmentVariables: []string{},
+		BlacklistedEnvironmentVariables: []string{},
+	},
+	authorizedSSHKeys: []ssh.PublicKey{},
+	remoteLimits:      map[string]int{},
+	set:               map[string]bool{},
+}
+
+func parse(bytes []byte) (*values, error) {
+	var values values
+	if err := json.Unmarshal(bytes, &values.Document); err != nil {
+		return nil, errors.Wrap(err, "parse variables")
+	}
+
+	var rawValues map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &rawValues); err != nil {
+		return nil, errors.Wrap(err, "parse variables")
+	}
+	values.set = make(map[string]bool, len(rawValues))
+	for name, rawValue := range rawValues {
+		values.set[name] = string(rawValue) != "null"
+	}
+
+	values.authorizedSSHKeys = make([]ssh.PublicKey, 0, len(values.AuthorizedSSHKeys))
+	for _, rawKey := range values.AuthorizedSSHKeys {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(rawKey))
+		if err != nil {
+			return nil, errors.Wrap(err, variables.AuthorizedSSHKeys)
+		}
+		values.authorizedSSHKeys = append(values.authorizedSSHKeys, key)
+	}
+
+	if values.HostSignerKey != "" {
+		block, _ := pem.Decode([]byte(values.HostSignerKey))
+		if block == nil {
+			return nil, errors.Errorf("%s: no PEM data found", variables.HostSignerKey)
+		}
+		if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, errors.Wrap(err, variables.HostSignerKey)
+		}
+	}
+
+	if values.LocalMetricsEndpoint != "" {
+		if u, err := url.Parse(values.LocalMetricsEndpoint); err != nil {
+			return nil, errors.Wrap(err, variables.LocalMetricsEndpoint)
+		} else if u.Host == "" {
+			return nil, errors.Errorf("%s: %q has no host", variables.LocalMetricsEndpoint, values.LocalMetricsEndpoint)
+		}
+	}
+
+	values.remoteLimits = make(map[string]int)
+	for _, name := range variables.RemoteLimits() {
+		if !values.set[name] {
+			continue
+		}
+		var limit int
+		if err := json.Unmarshal(rawValues[name], &limit); err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		if limit <= 0 {
+			return nil, errors.Errorf("%s: %d is not positive", name, limit)
+		}
+		values.remoteLimits[name] = limit
+	}
+
+	// Lists are never nil, matching fsnotify.Variables
+	for _, list := range []*[]string{
+		&values.WhitelistedImages,
+		&values.AllowedRegistries,
+		&values.WhitelistedEnvironmentVariables,
+		&values.BlacklistedEnvironmentVariables,
+	} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+
+	return &values, nil
+}
+
+func (v *Variables) GetDisableSSH() bool {
+	return v.current().DisableSSH
+}
+
+func (v *Variables) GetAuthorizedSSHKeys() []ssh.PublicKey {
+	return v.current().authorizedSSHKeys
+}
+
+func (v *Variables) GetHostSignerKey() string {
+	return v.current().HostSignerKey
+}
+
+func (v *Variables) GetRegistryAuth() string {
+	return v.current().RegistryAuth
+}
+
+func (v *Variables) GetWhitelistedImages() []string {
+	return v.current().WhitelistedImages
+}
+
+func (v *Variables) GetDisableCustomCommands() bool {
+	return v.current().DisableCustomCommands
+}
+
+func (v *Variables) GetLocalMetricsEndpoint() string {
+	return v.current().LocalMetricsEndpoint
+}
+
+func (v *Variables) GetDisableCloudMetrics() bool {
+	return v.current().DisableCloudMetrics
+}
+
+func (v *Variables) GetRequireImageDigests() bool {
+	return v.current().RequireImageDigests
+}
+
+func (v *Variables) GetAllowedRegistries() []string {
+	return v.current().AllowedRegistries
+}
+
+func (v *Variables) GetWhitelistedEnvironmentVariables() []string {
+	return v.current().WhitelistedEnvironmentVariables
+}
+
+func (v *Variables) GetBlacklistedEnvironmentVariables() []string {
+	return v.current().BlacklistedEnvironmentVariables
+}
+
+func (v *Variables) GetServiceVariables() map[string]string {
+	return v.current().ServiceVariables
+}
+
+func (v *Variables) GetRemoteMaxSessions(endpoint string) int {
+	return variables.EndpointLimit(v.current().remoteLimits, variables.RemoteMaxSessions, endpoint)
+}
+
+func (v *Variables) GetRemoteRateLimit(endpoint string) int {
+	return variables.EndpointLimit(v.current().remoteLimits, variables.RemoteRateLimit, endpoint)
+}
+
+// IsSet returns whether the named variable is in the document, so that a
+// flag set to false can be told apart from one that isn't set
+func (v *Variables) IsSet(name string) bool {
+	return v.current().set[name]
+}
+
+// current returns the loaded values, or the defaults if variables haven't
+// been fetched or loaded from the cache file yet
+func (v *Variables) current() *values {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.values == nil {
+		return &defaultValues
+	}
+	return v.values
+}
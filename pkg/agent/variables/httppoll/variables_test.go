@@ -0,0 +1,166 @@
+package httppoll
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/stretchr/testify/require"
+)
+
+const rawKey = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC5TLZVo6MkNVzXEWQGOB4hhaKVSz18LmAWllDXfadorxobV47chg4YE/8K+rUtbG/gaXVaZ0ENnu1wbojofETzdnFfKIiWBLkQFDWVBG+xJSEQWr/udi0kiSZ6AS0vCu7iVwgCjbKilOeRrKniQneGVMeti0YXuJpBuNzOMNxQOIDI6a45l6+EaVmsFcesPRg2cvvuizc9ejsm5JJ3XfNhmP7ovvk9vzwyHUbbywvki8m1I5/lGL3n3NoTvfKrI7uX24a5MQR3/cNRMfev9Nlf7Ss/uyLfW0afFBF+XoDfqGbVmyyDV812+kpSIcj833/5C84G3vrxeAZMgMZLZNW1 josh@x"
+
+const testToken = "token"
+
+type testServer struct {
+	lock   sync.Mutex
+	status int
+	body   string
+}
+
+func (s *testServer) set(status int, body string) {
+	s.lock.Lock()
+	s.status, s.body = status, body
+	s.lock.Unlock()
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(s.status)
+	w.Write([]byte(s.body))
+}
+
+// newTestVariables returns variables fetched from server with the test
+// token, without starting them
+func newTestVariables(server *httptest.Server, cacheFile string) *Variables {
+	v := NewVariables(server.URL, cacheFile, 0)
+	v.SetHTTPClient(server.Client())
+	v.SetToken(testToken)
+	return v
+}
+
+func TestRefreshKeepsPreviousValuesOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := &testServer{}
+	server := httptest.NewTLSServer(ts)
+	defer server.Close()
+
+	cacheFile := path.Join(dir, "variables.json")
+	v := newTestVariables(server, cacheFile)
+
+	ts.set(http.StatusOK, `{
+		"disable-custom-commands": true,
+		"authorized-ssh-keys": ["`+rawKey+`"],
+		"whitelisted-images": ["nginx", "redis"],
+		"service-variables": {"a": "b"},
+		"remote-max-sessions": 2,
+		"remote-max-sessions-ssh": 4
+	}`)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+	require.Len(t, v.GetAuthorizedSSHKeys(), 1)
+	require.Equal(t, []string{"nginx", "redis"}, v.GetWhitelistedImages())
+	require.Equal(t, map[string]string{"a": "b"}, v.GetServiceVariables())
+	require.Equal(t, 2, v.GetRemoteMaxSessions("exec"))
+	require.Equal(t, 4, v.GetRemoteMaxSessions("ssh"))
+	require.Zero(t, v.GetRemoteRateLimit("ssh"))
+	require.Equal(t, []string{}, v.GetAllowedRegistries())
+	require.False(t, v.GetDisableSSH())
+	require.True(t, v.IsSet(variables.DisableCustomCommands))
+	require.False(t, v.IsSet(variables.DisableSSH))
+
+	ts.set(http.StatusInternalServerError, "")
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	ts.set(http.StatusOK, `{"disable-custom-commands": fals`)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	ts.set(http.StatusOK, `{"authorized-ssh-keys": ["`+rawKey[:20]+`"]}`)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	ts.set(http.StatusOK, `{"local-metrics-endpoint": "not a url"}`)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	ts.set(http.StatusOK, `{"host-signer-key": "not a key"}`)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	ts.set(http.StatusOK, `{"remote-rate-limit": -1}`)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	ts.set(http.StatusOK, `{"remote-max-sessions-ssh": 0}`)
+	v.refresh()
+	require.True(t, v.GetDisableCustomCommands())
+
+	ts.set(http.StatusOK, `{"disable-custom-commands": false, "registry-auth": null}`)
+	v.refresh()
+	require.True(t, v.IsSet(variables.DisableCustomCommands))
+	require.False(t, v.IsSet(variables.RegistryAuth))
+
+	ts.set(http.StatusOK, `{}`)
+	v.refresh()
+	require.False(t, v.GetDisableCustomCommands())
+	require.Empty(t, v.GetAuthorizedSSHKeys())
+	require.Nil(t, v.GetServiceVariables())
+}
+
+func TestCacheIsUsedUntilFetched(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := &testServer{}
+	ts.set(http.StatusOK, `{"disable-ssh": true}`)
+	server := httptest.NewTLSServer(ts)
+
+	cacheFile := path.Join(dir, "variables.json")
+	newTestVariables(server, cacheFile).refresh()
+	server.Close()
+
+	// The endpoint is gone, but the last variables fetched are still
+	// available without waiting
+	v := newTestVariables(server, cacheFile)
+	require.NoError(t, v.loadCache())
+	v.refresh()
+	require.True(t, v.GetDisableSSH())
+}
+
+func TestDefaultsUntilFetched(t *testing.T) {
+	ts := &testServer{}
+	ts.set(http.StatusOK, `{"disable-ssh": true, "whitelisted-images": ["nginx"]}`)
+	server := httptest.NewTLSServer(ts)
+	defer server.Close()
+
+	v := newTestVariables(server, "")
+	v.SetToken("wrong")
+	v.refresh()
+	require.False(t, v.GetDisableSSH())
+	require.Equal(t, []string{}, v.GetWhitelistedImages())
+	require.False(t, v.IsSet(variables.DisableSSH))
+
+	v.SetToken(testToken)
+	v.refresh()
+	require.True(t, v.GetDisableSSH())
+}
+
+func TestStartRejectsPlainHTTP(t *testing.T) {
+	require.Equal(t, errNotHTTPS, NewVariables("http://example.com/variables.json", "", 0).Start())
+}
@@ -0,0 +1,226 @@
+// Package layered implements variables.Interface over an ordered list of
+// sources, so that local overrides can be put on top of a remote default
+package layered
+
+import (
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"golang.org/x/crypto/ssh"
+)
+
+// Variables returns, for each variable, the value of the first source that
+// has it set.
+//
+// Sources that implement variables.Lookup say for themselves whether a
+// variable is set, so a flag set to false in one source hides a true in the
+// sources after it. For other sources a variable is only considered set if
+// it isn't its zero value: false, empty, or 0.
+type Variables struct {
+	sources []variables.Interface
+}
+
+// NewVariables returns variables layered over sources, which take precedence
+// in the order given
+func NewVariables(sources ...variables.Interface) *Variables {
+	return &Variables{
+		sources: sources,
+	}
+}
+
+// unset returns whether a source that doesn't implement variables.Lookup has
+// each variable unset
+var unset = map[string]func(source variables.Interface) bool{
+	variables.DisableSSH: func(source variables.Interface) bool {
+		return !source.GetDisableSSH()
+	},
+	variables.AuthorizedSSHKeys: func(source variables.Interface) bool {
+		return len(source.GetAuthorizedSSHKeys()) == 0
+	},
+	variables.HostSignerKey: func(source variables.Interface) bool {
+		return source.GetHostSignerKey() == ""
+	},
+	variables.RegistryAuth: func(source variables.Interface) bool {
+		return source.GetRegistryAuth() == ""
+	},
+	variables.WhitelistedImages: func(source variables.Interface) bool {
+		return len(source.GetWhitelistedImages()) == 0
+	},
+	variables.DisableCustomCommands: func(source variables.Interface) bool {
+		return !source.GetDisableCustomCommands()
+	},
+	variables.LocalMetricsEndpoint: func(source variables.Interface) bool {
+		return source.GetLocalMetricsEndpoint() == ""
+	},
+	variables.DisableCloudMetrics: func(source variables.Interface) bool {
+		return !source.GetDisableCloudMetrics()
+	},
+	variables.RequireImageDigests: func(source variables.Interface) bool {
+		return !source.GetRequireImageDigests()
+	},
+	variables.AllowedRegistries: func(source variables.Interface) bool {
+		return len(source.GetAllowedRegistries()) == 0
+	},
+	variables.WhitelistedEnvironmentVariables: func(source variables.Interface) bool {
+		return len(source.GetWhitelistedEnvironmentVariables()) == 0
+	},
+	variables.BlacklistedEnvironmentVariables: func(source variables.Interface) bool {
+		return len(source.GetBlacklistedEnvironmentVariables()) == 0
+	},
+	variables.ServiceVariablesDir: func(source variables.Interface) bool {
+		return source.GetServiceVariables() == nil
+	},
+}
+
+func init() {
+	// A source without variables.Lookup has an endpoint's limit set if it
+	// has either the endpoint's own or the shared one
+	for _, endpoint := range append([]string{""}, variables.RemoteEndpoints...) {
+		endpoint := endpoint
+		unset[variables.RemoteLimit(variables.RemoteMaxSessions, endpoint)] = func(source variables.Interface) bool {
+			return source.GetRemoteMaxSessions(endpoint) == 0
+		}
+		unset[variables.RemoteLimit(variables.RemoteRateLimit, endpoint)] = func(source variables.Interface) bool {
+			return source.GetRemoteRateLimit(endpoint) == 0
+		}
+	}
+}
+
+// has returns whether source has the named variable set
+func has(source variables.Interface, name string) bool {
+	if lookup, ok := source.(variables.Lookup); ok {
+		return lookup.IsSet(name)
+	}
+	return !unset[name](source)
+}
+
+// source returns the first source that has the named variable set, or nil
+func (v *Variables) source(name string) variables.Interface {
+	for _, source := range v.sources {
+		if has(source, name) {
+			return source
+		}
+	}
+	return nil
+}
+
+// IsSet returns whether any source has the named variable set, so that
+// layered variables can themselves be layered
+func (v *Variables) IsSet(name string) bool {
+	if _, ok := unset[name]; !ok {
+		return false
+	}
+	return v.source(name) != nil
+}
+
+func (v *Variables) GetDisableSSH() bool {
+	if source := v.source(variables.DisableSSH); source != nil {
+		return source.GetDisableSSH()
+	}
+	return false
+}
+
+func (v *Variables) GetAuthorizedSSHKeys() []ssh.PublicKey {
+	if source := v.source(variables.AuthorizedSSHKeys); source != nil {
+		return source.GetAuthorizedSSHKeys()
+	}
+	return []ssh.PublicKey{}
+}
+
+func (v *Variables) GetHostSignerKey() string {
+	if source := v.source(variables.HostSignerKey); source != nil {
+		return source.GetHostSignerKey()
+	}
+	return ""
+}
+
+func (v *Variables) GetRegistryAuth() string {
+	if source := v.source(variables.RegistryAuth); source != nil {
+		return source.GetRegistryAuth()
+	}
+	return ""
+}
+
+func (v *Variables) GetWhitelistedImages() []string {
+	if source := v.source(variables.WhitelistedImages); source != nil {
+		return source.GetWhitelistedImages()
+	}
+	return []string{}
+}
+
+func (v *Variables) GetDisableCustomCommands() bool {
+	if source := v.source(variables.DisableCustomCommands); source != nil {
+		return source.GetDisableCustomCommands()
+	}
+	return false
+}
+
+func (v *Variables) GetLocalMetricsEndpoint() string {
+	if source := v.source(variables.LocalMetricsEndpoint); source != nil {
+		return source.GetLocalMetricsEndpoint()
+	}
+	return ""
+}
+
+func (v *Variables) GetDisableCloudMetrics() bool {
+	if source := v.source(variables.DisableCloudMetrics); source != nil {
+		return source.GetDisableCloudMetrics()
+	}
+	return false
+}
+
+func (v *Variables) GetRequireImageDigests() bool {
+	if source := v.source(variables.RequireImageDigests); source != nil {
+		return source.GetRequireImageDigests()
+	}
+	return false
+}
+
+func (v *Variables) GetAllowedRegistries() []string {
+	if source := v.source(variables.AllowedRegistries); source != nil {
+		return source.GetAllowedRegistries()
+	}
+	return []string{}
+}
+
+func (v *Variables) GetWhitelistedEnvironmentVariables() []string {
+	if source := v.source(variables.WhitelistedEnvironmentVariables); source != nil {
+		return source.GetWhitelistedEnvironmentVariables()
+	}
+	return []string{}
+}
+
+func (v *Variables) GetBlacklistedEnvironmentVariables() []string {
+	if source := v.source(variables.BlacklistedEnvironmentVariables); source != nil {
+		return source.GetBlacklistedEnvironmentVariables()
+	}
+	return []string{}
+}
+
+func (v *Variables) GetServiceVariables() map[string]string {
+	if source := v.source(variables.ServiceVariablesDir); source != nil {
+		return source.GetServiceVariables()
+	}
+	return nil
+}
+
+func (v *Variables) GetRemoteMaxSessions(endpoint string) int {
+	return v.remoteLimit(variables.RemoteMaxSessions, endpoint, variables.Interface.GetRemoteMaxSessions)
+}
+
+func (v *Variables) GetRemoteRateLimit(endpoint string) int {
+	return v.remoteLimit(variables.RemoteRateLimit, endpoint, variables.Interface.GetRemoteRateLimit)
+}
+
+// remoteLimit returns endpoint's own override of limit from the first source
+// that has one, even if a source before it has the shared limit set, and
+// otherwise the shared limit of the first source that has it set. Sources
+// without variables.Lookup can't tell the two apart, so their shared limit
+// counts as the endpoint's own.
+func (v *Variables) remoteLimit(limit, endpoint string, get func(variables.Interface, string) int) int {
+	if source := v.source(variables.RemoteLimit(limit, endpoint)); source != nil {
+		return get(source, endpoint)
+	}
+	if source := v.source(limit); source != nil {
+		return get(source, "")
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package layered
+
+import (
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/agent/variables"
+	"github.com/stretchr/testify/require"
+)
+
+// source sets its fields as variables. If set isn't nil it implements
+// variables.Lookup with it.
+type source struct {
+	variables.Interface
+	disableSSH bool
+	images     []string
+	// limits are remote limits keyed by variable name
+	limits map[string]int
+	set    map[string]bool
+}
+
+func (s *source) GetDisableSSH() bool            { return s.disableSSH }
+func (s *source) GetWhitelistedImages() []string { return s.images }
+
+func (s *source) GetRemoteMaxSessions(endpoint string) int {
+	return variables.EndpointLimit(s.limits, variables.RemoteMaxSessions, endpoint)
+}
+
+type lookupSource struct {
+	*source
+}
+
+func (s lookupSource) IsSet(name string) bool { return s.set[name] }
+
+func TestLayeredZeroValues(t *testing.T) {
+	override := &source{limits: map[string]int{variables.RemoteMaxSessions: 2}}
+	defaults := &source{disableSSH: true, images: []string{"nginx"}, limits: map[string]int{
+		variables.RemoteMaxSessions:                               8,
+		variables.RemoteLimit(variables.RemoteMaxSessions, "ssh"): 4,
+	}}
+
+	v := NewVariables(override, defaults)
+	require.True(t, v.GetDisableSSH())
+	require.Equal(t, []string{"nginx"}, v.GetWhitelistedImages())
+	require.Equal(t, 2, v.GetRemoteMaxSessions(""))
+	require.Equal(t, 2, v.GetRemoteMaxSessions("exec"))
+	require.True(t, v.IsSet(variables.DisableSSH))
+	require.False(t, NewVariables(&source{}).IsSet(variables.RemoteMaxSessions))
+	require.False(t, v.IsSet("unknown"))
+
+	v = NewVariables(&source{})
+	require.False(t, v.GetDisableSSH())
+	require.Equal(t, []string{}, v.GetWhitelistedImages())
+}
+
+func TestLayeredLookup(t *testing.T) {
+	// A flag explicitly set to false hides a true from the sources after it
+	override := lookupSource{&source{set: map[string]bool{variables.DisableSSH: true}}}
+	defaults := lookupSource{&source{
+		disableSSH: true,
+		images:     []string{"nginx"},
+		set:        map[string]bool{variables.DisableSSH: true, variables.WhitelistedImages: true},
+	}}
+
+	v := NewVariables(override, defaults)
+	require.False(t, v.GetDisableSSH())
+	require.Equal(t, []string{"nginx"}, v.GetWhitelistedImages())
+	require.True(t, v.IsSet(variables.DisableSSH))
+
+	// Layered variables can be layered in turn
+	v = NewVariables(NewVariables(&source{}), v)
+	require.False(t, v.GetDisableSSH())
+	require.Equal(t, []string{"nginx"}, v.GetWhitelistedImages())
+}
+
+func TestLayeredRemoteLimits(t *testing.T) {
+	sshMaxSessions := variables.RemoteLimit(variables.RemoteMaxSessions, "ssh")
+
+	// An endpoint's own limit takes precedence over a shared one set before it
+	override := lookupSource{&source{
+		limits: map[string]int{variables.RemoteMaxSessions: 2},
+		set:    map[string]bool{variables.RemoteMaxSessions: true},
+	}}
+	defaults := lookupSource{&source{
+		limits: map[string]int{variables.RemoteMaxSessions: 8, sshMaxSessions: 4},
+		set:    map[string]bool{variables.RemoteMaxSessions: true, sshMaxSessions: true},
+	}}
+
+	v := NewVariables(override, defaults)
+	require.Equal(t, 4, v.GetRemoteMaxSessions("ssh"))
+	require.Equal(t, 2, v.GetRemoteMaxSessions("exec"))
+	require.True(t, v.IsSet(sshMaxSessions))
+	require.False(t, v.IsSet(variables.RemoteLimit(variables.RemoteMaxSessions, "exec")))
+}
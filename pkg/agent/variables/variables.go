@@ -11,8 +11,73 @@ const (
 	RegistryAuth          = "registry-auth"
 	WhitelistedImages     = "whitelisted-images"
 	DisableCustomCommands = "disable-custom-commands"
+	LocalMetricsEndpoint  = "local-metrics-endpoint"
+	DisableCloudMetrics   = "disable-cloud-metrics"
+
+	// RequireImageDigests rejects services whose images are referenced by a
+	// tag rather than pinned by digest
+	RequireImageDigests = "require-image-digests"
+
+	// AllowedRegistries lists one registry per line, optionally followed by
+	// a path such as "registry.example.com/team". Images from other
+	// registries are rejected if the list is non-empty.
+	AllowedRegistries = "allowed-registries"
+
+	// Environment variable policy files list one name per line. A name may
+	// be a pattern such as "AWS_*" or "*_PASSWORD".
+	WhitelistedEnvironmentVariables = "whitelisted-environment-variables"
+	BlacklistedEnvironmentVariables = "blacklisted-environment-variables"
+
+	// ServiceVariablesDir holds one file per variable that can be referenced
+	// as ${NAME} in service commands and entrypoints. Interpolation is only
+	// enabled if the directory exists.
+	ServiceVariablesDir = "service-variables"
+
+	// RemoteMaxSessions and RemoteRateLimit hold a number that overrides the
+	// remote server's limits on expensive endpoints such as SSH, exec and
+	// logs: how many sessions of each can be open at once, and how many can
+	// be started per minute. A limit can be overridden for one endpoint
+	// alone by appending its name, as in "remote-max-sessions-ssh", and
+	// endpoints without their own override use the shared one.
+	RemoteMaxSessions = "remote-max-sessions"
+	RemoteRateLimit   = "remote-rate-limit"
 )
 
+// RemoteEndpoints are the remote server's limited endpoints
+var RemoteEndpoints = []string{"ssh", "connect", "exec", "logs"}
+
+// RemoteLimit returns the name of the variable overriding limit, either
+// RemoteMaxSessions or RemoteRateLimit, for endpoint alone. An empty endpoint
+// returns the shared limit.
+func RemoteLimit(limit, endpoint string) string {
+	if endpoint == "" {
+		return limit
+	}
+	return limit + "-" + endpoint
+}
+
+// RemoteLimits returns the names of the shared remote limits and of their
+// overrides for each endpoint
+func RemoteLimits() []string {
+	var names []string
+	for _, limit := range []string{RemoteMaxSessions, RemoteRateLimit} {
+		names = append(names, limit)
+		for _, endpoint := range RemoteEndpoints {
+			names = append(names, RemoteLimit(limit, endpoint))
+		}
+	}
+	return names
+}
+
+// EndpointLimit returns endpoint's own override of limit from limits, which
+// are keyed by variable name, or the shared one if it has none
+func EndpointLimit(limits map[string]int, limit, endpoint string) int {
+	if n := limits[RemoteLimit(limit, endpoint)]; n > 0 {
+		return n
+	}
+	return limits[limit]
+}
+
 type Interface interface {
 	GetDisableSSH() bool
 	GetAuthorizedSSHKeys() []ssh.PublicKey
@@ -20,4 +85,24 @@ type Interface interface {
 	GetRegistryAuth() string
 	GetWhitelistedImages() []string
 	GetDisableCustomCommands() bool
+	GetLocalMetricsEndpoint() string
+	GetDisableCloudMetrics() bool
+	GetRequireImageDigests() bool
+	GetAllowedRegistries() []string
+	GetWhitelistedEnvironmentVariables() []string
+	GetBlacklistedEnvironmentVariables() []string
+	// GetServiceVariables returns nil if service variables are not enabled
+	GetServiceVariables() map[string]string
+	// GetRemoteMaxSessions and GetRemoteRateLimit return endpoint's limit,
+	// falling back to the shared one, or 0 if neither is overridden. An
+	// empty endpoint returns the shared limit.
+	GetRemoteMaxSessions(endpoint string) int
+	GetRemoteRateLimit(endpoint string) int
+}
+
+// Lookup is implemented by sources that can tell a variable that isn't set
+// apart from one that's set to its zero value, such as a flag set to false
+type Lookup interface {
+	// IsSet returns whether the named variable is set
+	IsSet(name string) bool
 }
@@ -7,12 +7,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/apex/log"
+	"github.com/deviceplane/cli/pkg/agent/validator/customcommands"
+	"github.com/deviceplane/cli/pkg/codes"
+	"github.com/deviceplane/cli/pkg/execstream"
 	"github.com/deviceplane/cli/pkg/models"
 	"github.com/function61/holepunch-server/pkg/wsconnadapter"
 	"github.com/gorilla/websocket"
@@ -31,8 +38,40 @@ const (
 	metricsURL      = "metrics"
 	servicesURL     = "services"
 	membershipsURL  = "memberships"
+	logsURL         = "logs"
+	execURL         = "exec"
+	eventsURL       = "events"
+	labelsURL       = "labels"
+	capabilitiesURL = "capabilities"
+	deviceBundleURL = "inspectbundle"
+	statsURL        = "stats"
+	registerURL     = "register"
+	connectivityURL = "connectivity"
+	rolesURL        = "roles"
+	roleBindingsURL = "membershiprolebindings"
+	pollURL         = "poll"
+	meURL           = "me"
 )
 
+// MaxPageSize is the most items the API returns in one page of a list
+const MaxPageSize = 100
+
+const (
+	pageSizeParam = "page_size"
+	afterParam    = "after"
+)
+
+// ErrRequestTimedOut is returned for requests that don't complete before
+// their context's deadline
+var ErrRequestTimedOut = errors.New("request timed out")
+
+// ErrDeviceNameAlreadyInUse is returned by RenameDevice if another device
+// in the project already has the new name
+var ErrDeviceNameAlreadyInUse = errors.New("device name already in use")
+
+// ErrUserNotFound is returned by CreateMembership if no user has the email
+var ErrUserNotFound = errors.New("user not found")
+
 type Client struct {
 	url        *url.URL
 	accessKey  string
@@ -50,6 +89,68 @@ func NewClient(url *url.URL, accessKey string, httpClient *http.Client) *Client
 	}
 }
 
+// websocketDialer returns a dialer that shares the TLS and proxy settings of
+// the client's HTTP transport, so that SSH sessions and streams take the
+// same route as other requests
+func (c *Client) websocketDialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+		if transport.Proxy != nil {
+			dialer.Proxy = transport.Proxy
+		}
+	}
+	return &dialer
+}
+
+// GetCapabilities returns the version and optional features of the API.
+// Servers that predate the capabilities endpoint report no capabilities.
+func (c *Client) GetCapabilities(ctx context.Context) (*models.APICapabilities, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", getURL(c.url, capabilitiesURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &models.APICapabilities{}, nil
+	}
+
+	var capabilities models.APICapabilities
+	if err := c.handleResponse(resp, &capabilities); err != nil {
+		return nil, err
+	}
+	return &capabilities, nil
+}
+
+// GetMe returns the user or service account the access key belongs to.
+// Exactly one of them is returned.
+func (c *Client) GetMe(ctx context.Context) (*models.User, *models.ServiceAccount, error) {
+	var me struct {
+		models.ServiceAccount
+		SuperAdmin bool `json:"superAdmin"`
+	}
+	if err := c.get(ctx, &me, meURL); err != nil {
+		return nil, nil, err
+	}
+
+	// Only service accounts belong to a project
+	if me.ProjectID != "" {
+		return nil, &me.ServiceAccount, nil
+	}
+	return &models.User{
+		ID:         me.ID,
+		CreatedAt:  me.CreatedAt,
+		Name:       me.Name,
+		SuperAdmin: me.SuperAdmin,
+	}, nil, nil
+}
+
 func (c *Client) CreateProject(ctx context.Context, name string) (*models.Project, error) {
 	var project models.Project
 	if err := c.post(ctx, models.Project{Name: name}, &project, projectsURL); err != nil {
@@ -58,6 +159,60 @@ func (c *Client) CreateProject(ctx context.Context, name string) (*models.Projec
 	return &project, nil
 }
 
+func (c *Client) GetProject(ctx context.Context, project string) (*models.Project, error) {
+	var p models.Project
+	if err := c.get(ctx, &p, projectsURL, project); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (c *Client) DeleteProject(ctx context.Context, project string) error {
+	return c.delete(ctx, nil, projectsURL, project)
+}
+
+// ListMemberships returns the members of a project, with their roles
+func (c *Client) ListMemberships(ctx context.Context, project string) ([]models.MembershipFull2, error) {
+	var memberships []models.MembershipFull2
+	if err := c.get(ctx, &memberships, projectsURL, project, membershipsURL+"?full"); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// CreateMembership adds the user with an email to a project
+func (c *Client) CreateMembership(ctx context.Context, project, email string) (*models.Membership, error) {
+	var membership models.Membership
+	if err := c.post(ctx, struct {
+		Email string `json:"email"`
+	}{
+		Email: email,
+	}, &membership, projectsURL, project, membershipsURL); err != nil {
+		if strings.TrimSpace(err.Error()) == ErrUserNotFound.Error() {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// CreateMembershipRoleBinding grants a project member a role
+func (c *Client) CreateMembershipRoleBinding(ctx context.Context, project, userID, role string) (*models.MembershipRoleBinding, error) {
+	var membershipRoleBinding models.MembershipRoleBinding
+	if err := c.post(ctx, []byte{}, &membershipRoleBinding, projectsURL, project, membershipsURL, userID, rolesURL, role, roleBindingsURL); err != nil {
+		return nil, err
+	}
+	return &membershipRoleBinding, nil
+}
+
+func (c *Client) ListRoles(ctx context.Context, project string) ([]models.Role, error) {
+	var roles []models.Role
+	if err := c.get(ctx, &roles, projectsURL, project, rolesURL); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
 func (c *Client) CreateApplication(ctx context.Context, project string, name string) (*models.Application, error) {
 	var application models.Application
 	if err := c.post(ctx, models.Application{Name: name}, &application, projectsURL, project, applicationsURL); err != nil {
@@ -87,8 +242,29 @@ func (c *Client) ListApplications(ctx context.Context, project string) ([]models
 	return applications, nil
 }
 
+// ListDevices returns every device matching filters, fetching them a page
+// at a time
 func (c *Client) ListDevices(ctx context.Context, filters []models.Filter, project string) ([]models.Device, error) {
 	var devices []models.Device
+	var after string
+	for {
+		page, err := c.ListDevicesPage(ctx, filters, project, MaxPageSize, after)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, page...)
+		if len(page) < MaxPageSize {
+			return devices, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// ListDevicesPage returns up to pageSize devices matching filters, starting
+// after the device with the ID after, or from the first device if after is
+// empty. pageSize can be at most MaxPageSize.
+func (c *Client) ListDevicesPage(ctx context.Context, filters []models.Filter, project string, pageSize int, after string) ([]models.Device, error) {
+	var devices []models.Device
 
 	urlValues := url.Values{}
 	for _, filter := range filters {
@@ -100,13 +276,12 @@ func (c *Client) ListDevices(ctx context.Context, filters []models.Filter, proje
 		b64Filter := base64.StdEncoding.EncodeToString(bytes)
 		urlValues.Add("filter", b64Filter)
 	}
-
-	var queryString string
-	if encoded := urlValues.Encode(); encoded != "" {
-		queryString = "?" + encoded
+	urlValues.Set(pageSizeParam, strconv.Itoa(pageSize))
+	if after != "" {
+		urlValues.Set(afterParam, after)
 	}
 
-	if err := c.get(ctx, &devices, projectsURL, project, devicesURL+queryString); err != nil {
+	if err := c.get(ctx, &devices, projectsURL, project, devicesURL+"?"+urlValues.Encode()); err != nil {
 		return nil, err
 	}
 	return devices, nil
@@ -128,6 +303,16 @@ func (c *Client) GetDevice(ctx context.Context, project, device string) (*models
 	return &d, nil
 }
 
+// GetDeviceFull is GetDevice with the statuses of every application in the
+// project on the device
+func (c *Client) GetDeviceFull(ctx context.Context, project, device string) (*models.DeviceFull, error) {
+	var d models.DeviceFull
+	if err := c.get(ctx, &d, projectsURL, project, devicesURL, device+"?full"); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
 func (c *Client) GetDeviceMetrics(ctx context.Context, project, device string) (*string, error) {
 	var rawOpenMetrics string
 	if err := c.get(ctx, &rawOpenMetrics, projectsURL, project, devicesURL, device, metricsURL, "host"); err != nil {
@@ -136,6 +321,26 @@ func (c *Client) GetDeviceMetrics(ctx context.Context, project, device string) (
 	return &rawOpenMetrics, nil
 }
 
+// GetDeviceServiceStats returns the resource usage of every service running
+// on a device
+func (c *Client) GetDeviceServiceStats(ctx context.Context, project, device string) ([]models.ServiceStats, error) {
+	var stats []models.ServiceStats
+	if err := c.get(ctx, &stats, projectsURL, project, devicesURL, device, statsURL); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetDeviceConnectivity returns whether a device can be reached through the
+// control plane
+func (c *Client) GetDeviceConnectivity(ctx context.Context, project, device string) (*models.DeviceConnectivity, error) {
+	var connectivity models.DeviceConnectivity
+	if err := c.get(ctx, &connectivity, projectsURL, project, devicesURL, device, connectivityURL); err != nil {
+		return nil, err
+	}
+	return &connectivity, nil
+}
+
 func (c *Client) GetServiceMetrics(ctx context.Context, project, device, application, service string) (*string, error) {
 	var rawOpenMetrics string
 	if err := c.get(ctx, &rawOpenMetrics, projectsURL, project, devicesURL, device, applicationsURL, application, servicesURL, service, metricsURL); err != nil {
@@ -144,6 +349,128 @@ func (c *Client) GetServiceMetrics(ctx context.Context, project, device, applica
 	return &rawOpenMetrics, nil
 }
 
+func (c *Client) GetServiceLogs(ctx context.Context, project, device, application, service string, follow bool, tail int, since time.Duration) (io.ReadCloser, error) {
+	urlValues := url.Values{}
+	if follow {
+		urlValues.Set("follow", "true")
+	}
+	if tail >= 0 {
+		urlValues.Set("tail", strconv.Itoa(tail))
+	}
+	if since > 0 {
+		urlValues.Set("since", since.String())
+	}
+
+	return c.getStream(ctx, urlValues, projectsURL, project, devicesURL, device, applicationsURL, application, servicesURL, service, logsURL)
+}
+
+// ExecService runs command inside a service's container, copying its output
+// to stdout and stderr as it arrives, and returns the command's exit code
+func (c *Client) ExecService(ctx context.Context, project, device, application, service string, command []string, stdout, stderr io.Writer) (int, error) {
+	return c.exec(ctx, command, stdout, stderr, projectsURL, project, devicesURL, device, applicationsURL, application, servicesURL, service, execURL)
+}
+
+// ExecHost is like ExecService but runs command on the device's host
+func (c *Client) ExecHost(ctx context.Context, project, device string, command []string, stdout, stderr io.Writer) (int, error) {
+	return c.exec(ctx, command, stdout, stderr, projectsURL, project, devicesURL, device, execURL)
+}
+
+func (c *Client) exec(ctx context.Context, command []string, stdout, stderr io.Writer, s ...string) (int, error) {
+	reqBytes, err := json.Marshal(models.ExecRequest{
+		Command: command,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", getURL(c.url, s...), bytes.NewReader(reqBytes))
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(c.accessKey, "")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, requestError(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return execstream.Copy(stdout, stderr, resp.Body)
+	case codes.StatusCustomCommandsDisabled:
+		return 0, customcommands.ErrCustomCommandsAreDisabled
+	case codes.StatusExecNotAvailable:
+		bytes, _ := ioutil.ReadAll(resp.Body)
+		return 0, errors.New(strings.TrimSpace(string(bytes)))
+	default:
+		return 0, c.handleResponse(resp, nil)
+	}
+}
+
+func (c *Client) GetDeviceEvents(ctx context.Context, project, device string, follow bool, types []string) (io.ReadCloser, error) {
+	urlValues := url.Values{}
+	if follow {
+		urlValues.Set("follow", "true")
+	}
+	for _, t := range types {
+		urlValues.Add("type", t)
+	}
+
+	return c.getStream(ctx, urlValues, projectsURL, project, devicesURL, device, eventsURL)
+}
+
+// getStream returns the body of a successful GET request, which the caller
+// must close
+func (c *Client) getStream(ctx context.Context, urlValues url.Values, s ...string) (io.ReadCloser, error) {
+	var queryString string
+	if encoded := urlValues.Encode(); encoded != "" {
+		queryString = "?" + encoded
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", getURL(c.url, s...)+queryString, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.accessKey, "")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, requestError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, c.handleResponse(resp, nil)
+	}
+
+	return resp.Body, nil
+}
+
+func (c *Client) SetDeviceLabel(ctx context.Context, project, device, key, value string) error {
+	req := struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}{
+		Key:   key,
+		Value: value,
+	}
+	return c.put(ctx, req, nil, projectsURL, project, devicesURL, device, labelsURL)
+}
+
+func (c *Client) DeleteDeviceLabel(ctx context.Context, project, device, key string) error {
+	return c.delete(ctx, nil, projectsURL, project, devicesURL, device, labelsURL, key)
+}
+
+// GetDeviceBundle returns the raw JSON of the bundle the device is sent
+func (c *Client) GetDeviceBundle(ctx context.Context, project, device string) ([]byte, error) {
+	var bundle string
+	if err := c.get(ctx, &bundle, projectsURL, project, devicesURL, device, deviceBundleURL); err != nil {
+		return nil, err
+	}
+	return []byte(bundle), nil
+}
+
 func (c *Client) GetLatestRelease(ctx context.Context, project, application string) (*models.Release, error) {
 	var release models.Release
 	if err := c.get(ctx, &release, projectsURL, project, applicationsURL, application, releasesURL, "latest"); err != nil {
@@ -170,7 +497,7 @@ func (c *Client) SSH(ctx context.Context, project, deviceID string) (net.Conn, e
 
 	req.SetBasicAuth(c.accessKey, "")
 
-	wsConn, _, err := websocket.DefaultDialer.Dial(getWebsocketURL(c.url, projectsURL, project, devicesURL, deviceID, sshURL), req.Header)
+	wsConn, err := c.dialWebsocket(getWebsocketURL(c.url, projectsURL, project, devicesURL, deviceID, sshURL), req.Header)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +513,7 @@ func (c *Client) Connect(ctx context.Context, project, deviceID, connection stri
 
 	req.SetBasicAuth(c.accessKey, "")
 
-	wsConn, _, err := websocket.DefaultDialer.Dial(getWebsocketURL(c.url, projectsURL, project, devicesURL, deviceID, connectURL, connection), req.Header)
+	wsConn, err := c.dialWebsocket(getWebsocketURL(c.url, projectsURL, project, devicesURL, deviceID, connectURL, connection), req.Header)
 	if err != nil {
 		return nil, err
 	}
@@ -194,6 +521,30 @@ func (c *Client) Connect(ctx context.Context, project, deviceID, connection stri
 	return wsconnadapter.New(wsConn), nil
 }
 
+// RegisterDevice registers a new device with a registration token, like the
+// agent does when it first starts. projectID must be an ID, not a name.
+// No hardware ID is sent, since only the device can derive its own.
+func (c *Client) RegisterDevice(ctx context.Context, projectID, registrationToken string) (*models.RegisterDeviceResponse, error) {
+	var registerDeviceResponse models.RegisterDeviceResponse
+	if err := c.post(ctx, models.RegisterDeviceRequest{
+		DeviceRegistrationTokenID: registrationToken,
+	}, &registerDeviceResponse, projectsURL, projectID, devicesURL, registerURL); err != nil {
+		return nil, err
+	}
+	return &registerDeviceResponse, nil
+}
+
+func (c *Client) RenameDevice(ctx context.Context, project, device, name string) (*models.Device, error) {
+	var d models.Device
+	if err := c.patch(ctx, models.UpdateDeviceRequest{Name: name}, &d, projectsURL, project, devicesURL, device); err != nil {
+		if strings.TrimSpace(err.Error()) == ErrDeviceNameAlreadyInUse.Error() {
+			return nil, ErrDeviceNameAlreadyInUse
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
 func (c *Client) Reboot(ctx context.Context, project, device string) error {
 	if err := c.post(ctx, []byte{}, nil, projectsURL, project, devicesURL, device, rebootURL); err != nil {
 		return err
@@ -201,6 +552,12 @@ func (c *Client) Reboot(ctx context.Context, project, device string) error {
 	return nil
 }
 
+// SyncDevice makes a device download its bundle now, rather than at its
+// next poll
+func (c *Client) SyncDevice(ctx context.Context, project, device string) error {
+	return c.post(ctx, []byte{}, nil, projectsURL, project, devicesURL, device, pollURL)
+}
+
 func (c *Client) get(ctx context.Context, out interface{}, s ...string) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", getURL(c.url, s...), nil)
 	if err != nil {
@@ -234,21 +591,105 @@ func (c *Client) post(ctx context.Context, in, out interface{}, s ...string) err
 	return c.performRequest(req, out)
 }
 
+func (c *Client) put(ctx context.Context, in, out interface{}, s ...string) error {
+	reqBytes, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", getURL(c.url, s...), bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+
+	return c.performRequest(req, out)
+}
+
+func (c *Client) patch(ctx context.Context, in, out interface{}, s ...string) error {
+	reqBytes, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PATCH", getURL(c.url, s...), bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+
+	return c.performRequest(req, out)
+}
+
+func (c *Client) delete(ctx context.Context, out interface{}, s ...string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", getURL(c.url, s...), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.performRequest(req, out)
+}
+
 func (c *Client) performRequest(req *http.Request, out interface{}) error {
 	req.SetBasicAuth(c.accessKey, "")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return err
+		return requestError(err)
 	}
 	defer resp.Body.Close()
 
-	return c.handleResponse(resp, out)
+	return requestError(c.handleResponse(resp, out))
+}
+
+// do sends req, logging its outcome at debug level for --verbose
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+
+	entry := log.WithField("method", req.Method).
+		WithField("url", req.URL.String()).
+		WithField("duration", time.Since(start))
+	if err != nil {
+		entry.WithError(err).Debug("API request failed")
+		return nil, err
+	}
+	entry.WithField("status", resp.StatusCode).Debug("API request")
+	return resp, nil
+}
+
+// dialWebsocket opens a websocket, logging its outcome at debug level like do
+func (c *Client) dialWebsocket(url string, header http.Header) (*websocket.Conn, error) {
+	start := time.Now()
+	wsConn, resp, err := c.websocketDialer().Dial(url, header)
+
+	entry := log.WithField("method", "GET").
+		WithField("url", url).
+		WithField("duration", time.Since(start))
+	if resp != nil {
+		entry = entry.WithField("status", resp.StatusCode)
+	}
+	if err != nil {
+		entry.WithError(err).Debug("API websocket failed")
+		return nil, err
+	}
+	entry.Debug("API websocket")
+	return wsConn, nil
+}
+
+// requestError replaces the error of a request that ran out of time with
+// ErrRequestTimedOut
+func requestError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrRequestTimedOut
+	}
+	return err
 }
 
 func (c *Client) handleResponse(resp *http.Response, out interface{}) error {
 	switch resp.StatusCode {
 	case http.StatusOK:
+		if out == nil {
+			return nil
+		}
 		switch o := out.(type) {
 		case *string:
 			bytes, err := ioutil.ReadAll(resp.Body)
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListDevicesFetchesEveryPage(t *testing.T) {
+	var devices []models.Device
+	for i := 0; i < 2*MaxPageSize+5; i++ {
+		devices = append(devices, models.Device{ID: fmt.Sprintf("dev_%03d", i)})
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		pageSize, err := strconv.Atoi(r.URL.Query().Get(pageSizeParam))
+		require.NoError(t, err)
+
+		start := 0
+		if after := r.URL.Query().Get(afterParam); after != "" {
+			for i, d := range devices {
+				if d.ID == after {
+					start = i + 1
+				}
+			}
+		}
+		end := start + pageSize
+		if end > len(devices) {
+			end = len(devices)
+		}
+		json.NewEncoder(w).Encode(devices[start:end])
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	c := NewClient(u, "", nil)
+
+	all, err := c.ListDevices(context.Background(), nil, "prj_test")
+	require.NoError(t, err)
+	require.Equal(t, devices, all)
+	require.Equal(t, 3, requests)
+
+	page, err := c.ListDevicesPage(context.Background(), nil, "prj_test", 10, "dev_004")
+	require.NoError(t, err)
+	require.Len(t, page, 10)
+	require.Equal(t, "dev_005", page[0].ID)
+}
+
+func TestCreateMembershipUserNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/projects/prj_test/memberships", r.URL.Path)
+		http.Error(w, ErrUserNotFound.Error(), http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	c := NewClient(u, "", nil)
+
+	_, err = c.CreateMembership(context.Background(), "prj_test", "nobody@example.com")
+	require.Equal(t, ErrUserNotFound, err)
+}
+
+func TestWebsocketDialerSharesProxy(t *testing.T) {
+	proxyURL, err := url.Parse("socks5://proxy.example.com:1080")
+	require.NoError(t, err)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+
+	apiURL, err := url.Parse("https://api.example.com/api")
+	require.NoError(t, err)
+	client := NewClient(apiURL, "", &http.Client{Transport: transport})
+
+	req, err := http.NewRequest("GET", "wss://api.example.com/api/ssh", nil)
+	require.NoError(t, err)
+	got, err := client.websocketDialer().Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, proxyURL, got)
+}
+
+func TestGetMe(t *testing.T) {
+	var me interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/me", r.URL.Path)
+		json.NewEncoder(w).Encode(me)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	c := NewClient(u, "", nil)
+
+	me = models.User{ID: "usr_1", Name: "Ada", SuperAdmin: true}
+	user, serviceAccount, err := c.GetMe(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, serviceAccount)
+	require.Equal(t, "usr_1", user.ID)
+	require.Equal(t, "Ada", user.Name)
+	require.True(t, user.SuperAdmin)
+
+	me = models.ServiceAccount{ID: "sac_1", ProjectID: "prj_1", Name: "ci"}
+	user, serviceAccount, err = c.GetMe(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, user)
+	require.Equal(t, "sac_1", serviceAccount.ID)
+	require.Equal(t, "prj_1", serviceAccount.ProjectID)
+}
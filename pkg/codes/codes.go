@@ -5,4 +5,7 @@ const (
 	StatusDeviceConnectionFailure       = 601
 	StatusMetricsNotAvailable           = 602
 	StatusImagePullProgressNotAvailable = 603
+	StatusLogsNotAvailable              = 604
+	StatusExecNotAvailable              = 605
+	StatusCustomCommandsDisabled        = 606
 )
@@ -2,11 +2,14 @@ package context
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 )
 
 var _ context.Context = &Context{}
 
+var defaultTimeout = int64(time.Minute)
+
 type Context struct {
 	context.Context
 }
@@ -17,3 +20,22 @@ func New(ctx context.Context, timeout time.Duration) (*Context, func()) {
 		Context: ctx,
 	}, cancel
 }
+
+// NewDefault is like New but uses the default timeout, which is one minute
+// unless changed with SetDefaultTimeout.
+func NewDefault(ctx context.Context) (*Context, func()) {
+	return New(ctx, DefaultTimeout())
+}
+
+func DefaultTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&defaultTimeout))
+}
+
+// SetDefaultTimeout changes the timeout used by NewDefault. Non-positive
+// values are ignored.
+func SetDefaultTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	atomic.StoreInt64(&defaultTimeout, int64(timeout))
+}
@@ -29,6 +29,9 @@ const (
 	ActionGetImagePullProgress         = Action("GetImagePullProgress")
 	ActionGetMetrics                   = Action("GetMetrics")
 	ActionGetServiceMetrics            = Action("GetServiceMetrics")
+	ActionGetServiceLogs               = Action("GetServiceLogs")
+	ActionGetDeviceEvents              = Action("GetDeviceEvents")
+	ActionGetDeviceBundle              = Action("GetDeviceBundle")
 	ActionGetDeviceRegistrationToken   = Action("GetDeviceRegistrationToken")
 	ActionListDeviceRegistrationTokens = Action("ListDeviceRegistrationTokens")
 	ActionGetProjectConfig             = Action("GetProjectConfig")
@@ -43,6 +46,8 @@ const (
 	ActionUpdateDevice                                     = Action("UpdateDevice")
 	ActionDeleteDevice                                     = Action("DeleteDevice")
 	ActionSSH                                              = Action("SSH")
+	ActionExec                                             = Action("Exec")
+	ActionHostExec                                         = Action("HostExec")
 	ActionConnect                                          = Action("Connect")
 	ActionReboot                                           = Action("Reboot")
 	ActionListAllDeviceLabels                              = Action("ListAllDeviceLabels")
@@ -105,6 +110,9 @@ var (
 		ActionGetImagePullProgress,
 		ActionGetMetrics,
 		ActionGetServiceMetrics,
+		ActionGetServiceLogs,
+		ActionGetDeviceEvents,
+		ActionGetDeviceBundle,
 		ActionGetDeviceRegistrationToken,
 		ActionListDeviceRegistrationTokens,
 		ActionGetProjectConfig,
@@ -120,6 +128,8 @@ var (
 		ActionUpdateDevice,
 		ActionDeleteDevice,
 		ActionSSH,
+		ActionExec,
+		ActionHostExec,
 		ActionConnect,
 		ActionReboot,
 		ActionSetDeviceLabel,
@@ -2,11 +2,14 @@ package service
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/deviceplane/cli/pkg/agent/service/client"
 	"github.com/deviceplane/cli/pkg/codes"
@@ -17,6 +20,10 @@ import (
 	"github.com/pkg/errors"
 )
 
+// pingTimeout is how long deviceConnectivity waits for a device to answer a
+// ping
+const pingTimeout = 10 * time.Second
+
 var (
 	errProtocolMismatch = errors.New("protocol mismatch")
 )
@@ -137,6 +144,37 @@ func (s *Service) reboot(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// pollBundle makes a device download its bundle now rather than at its next
+// poll
+func (s *Service) pollBundle(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionUpdateDevice,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					s.withDeviceConnection(w, r, project, device, func(deviceConn net.Conn) {
+						resp, err := client.Poll(r.Context(), deviceConn)
+						if err != nil {
+							http.Error(w, err.Error(), codes.StatusDeviceConnectionFailure)
+							return
+						}
+
+						if resp.StatusCode == http.StatusNotFound {
+							resp.Body.Close()
+							http.Error(w, "the device's agent is too old to sync on request", http.StatusNotFound)
+							return
+						}
+
+						utils.ProxyResponseFromDevice(w, resp)
+					})
+				})
+			},
+		)
+	})
+}
+
 func (s *Service) deviceDebug(w http.ResponseWriter, r *http.Request) {
 	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
 		s.validateAuthorization(
@@ -257,6 +295,95 @@ func (s *Service) agentMetrics(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *Service) serviceStats(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionGetServiceMetrics,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					s.withDeviceConnection(w, r, project, device, func(deviceConn net.Conn) {
+						resp, err := client.GetServiceStats(r.Context(), deviceConn)
+						if err != nil {
+							http.Error(w, err.Error(), codes.StatusDeviceConnectionFailure)
+							return
+						}
+
+						utils.ProxyResponseFromDevice(w, resp)
+					})
+				})
+			},
+		)
+	})
+}
+
+// deviceConnectivity reports whether a device has a connection open to the
+// controller, and how long a ping over it takes. Unlike other device routes,
+// a device that can't be reached isn't an error here.
+func (s *Service) deviceConnectivity(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionGetDevice,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					connectivity := models.DeviceConnectivity{
+						DeviceID:   device.ID,
+						LastSeenAt: device.LastSeenAt,
+						Status:     device.Status,
+					}
+
+					deviceConn, err := s.connman.Dial(r.Context(), project.ID+device.ID)
+					if err != nil {
+						connectivity.ConnectionError = err.Error()
+						utils.Respond(w, connectivity)
+						return
+					}
+					defer deviceConn.Close()
+					connectivity.Connected = true
+
+					pingDevice(r.Context(), deviceConn, pingTimeout, &connectivity)
+
+					utils.Respond(w, connectivity)
+				})
+			},
+		)
+	})
+}
+
+// pingDevice pings the agent over deviceConn, recording the round trip or why
+// the ping failed in connectivity. The ping is written and read directly on
+// the connection, so it's bounded by a deadline rather than by ctx.
+func pingDevice(ctx context.Context, deviceConn net.Conn, timeout time.Duration, connectivity *models.DeviceConnectivity) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := deviceConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		connectivity.PingError = err.Error()
+		return
+	}
+
+	start := time.Now()
+	resp, err := client.Ping(ctx, deviceConn)
+	roundTrip := time.Since(start)
+	switch {
+	case err != nil:
+		connectivity.PingError = err.Error()
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		connectivity.PingError = "the device's agent is too old to answer pings"
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		connectivity.PingError = fmt.Sprintf("ping failed with status %d", resp.StatusCode)
+	default:
+		resp.Body.Close()
+		roundTripMillis := float64(roundTrip) / float64(time.Millisecond)
+		connectivity.RoundTripMillis = &roundTripMillis
+	}
+}
+
 func (s *Service) serviceMetrics(w http.ResponseWriter, r *http.Request) {
 	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
 		s.validateAuthorization(
@@ -293,3 +420,129 @@ func (s *Service) serviceMetrics(w http.ResponseWriter, r *http.Request) {
 		)
 	})
 }
+
+func (s *Service) serviceLogs(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionGetServiceLogs,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					s.withApplication(w, r, project, func(application *models.Application) {
+						s.withDeviceConnection(w, r, project, device, func(deviceConn net.Conn) {
+							vars := mux.Vars(r)
+							service := vars["service"]
+
+							resp, err := client.GetServiceLogs(
+								r.Context(), deviceConn, application.ID, service, r.URL.Query(),
+							)
+							if err != nil {
+								http.Error(w, err.Error(), codes.StatusDeviceConnectionFailure)
+								return
+							}
+
+							utils.ProxyStreamingResponseFromDevice(w, resp)
+						})
+					})
+				})
+			},
+		)
+	})
+}
+
+func (s *Service) serviceExec(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionExec,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					s.withApplication(w, r, project, func(application *models.Application) {
+						var execRequest models.ExecRequest
+						if err := read(r, &execRequest); err != nil {
+							http.Error(w, err.Error(), http.StatusBadRequest)
+							return
+						}
+						if len(execRequest.Command) == 0 {
+							http.Error(w, "command is required", http.StatusBadRequest)
+							return
+						}
+
+						s.withDeviceConnection(w, r, project, device, func(deviceConn net.Conn) {
+							vars := mux.Vars(r)
+							service := vars["service"]
+
+							resp, err := client.ExecService(
+								r.Context(), deviceConn, application.ID, service, execRequest,
+							)
+							if err != nil {
+								http.Error(w, err.Error(), codes.StatusDeviceConnectionFailure)
+								return
+							}
+
+							utils.ProxyStreamingResponseFromDevice(w, resp)
+						})
+					})
+				})
+			},
+		)
+	})
+}
+
+func (s *Service) hostExec(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionHostExec,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					var execRequest models.ExecRequest
+					if err := read(r, &execRequest); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+					if len(execRequest.Command) == 0 {
+						http.Error(w, "command is required", http.StatusBadRequest)
+						return
+					}
+
+					s.withDeviceConnection(w, r, project, device, func(deviceConn net.Conn) {
+						resp, err := client.ExecHost(r.Context(), deviceConn, execRequest)
+						if err != nil {
+							http.Error(w, err.Error(), codes.StatusDeviceConnectionFailure)
+							return
+						}
+
+						utils.ProxyStreamingResponseFromDevice(w, resp)
+					})
+				})
+			},
+		)
+	})
+}
+
+func (s *Service) deviceEvents(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionGetDeviceEvents,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					s.withDeviceConnection(w, r, project, device, func(deviceConn net.Conn) {
+						resp, err := client.GetDeviceEvents(r.Context(), deviceConn, r.URL.Query())
+						if err != nil {
+							http.Error(w, err.Error(), codes.StatusDeviceConnectionFailure)
+							return
+						}
+
+						utils.ProxyStreamingResponseFromDevice(w, resp)
+					})
+				})
+			},
+		)
+	})
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deviceplane/cli/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+// answerPing reads one request from conn and answers it with status
+func answerPing(t *testing.T, conn net.Conn, status int) {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	require.NoError(t, err)
+	require.Equal(t, "/ping", req.URL.Path)
+
+	resp := http.Response{
+		StatusCode:    status,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		ContentLength: 0,
+	}
+	require.NoError(t, resp.Write(conn))
+}
+
+func TestPingDevice(t *testing.T) {
+	for _, test := range []struct {
+		status    int
+		pingError string
+	}{
+		{http.StatusOK, ""},
+		{http.StatusNotFound, "the device's agent is too old to answer pings"},
+		{http.StatusInternalServerError, "ping failed with status 500"},
+	} {
+		controllerConn, agentConn := net.Pipe()
+		go answerPing(t, agentConn, test.status)
+
+		var connectivity models.DeviceConnectivity
+		pingDevice(context.Background(), controllerConn, time.Second, &connectivity)
+		require.Equal(t, test.pingError, connectivity.PingError)
+		if test.pingError == "" {
+			require.NotNil(t, connectivity.RoundTripMillis)
+		} else {
+			require.Nil(t, connectivity.RoundTripMillis)
+		}
+
+		controllerConn.Close()
+		agentConn.Close()
+	}
+}
+
+func TestPingDeviceTimeout(t *testing.T) {
+	controllerConn, agentConn := net.Pipe()
+	defer controllerConn.Close()
+	defer agentConn.Close()
+
+	// The agent reads the ping but never answers
+	go http.ReadRequest(bufio.NewReader(agentConn))
+
+	start := time.Now()
+	var connectivity models.DeviceConnectivity
+	pingDevice(context.Background(), controllerConn, 100*time.Millisecond, &connectivity)
+	require.NotEmpty(t, connectivity.PingError)
+	require.Nil(t, connectivity.RoundTripMillis)
+	require.True(t, time.Since(start) < 5*time.Second)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -45,6 +46,13 @@ func (s *Service) health(w http.ResponseWriter, r *http.Request) {
 	s.st.Incr("health", nil, 1)
 }
 
+func (s *Service) capabilities(w http.ResponseWriter, r *http.Request) {
+	utils.Respond(w, models.APICapabilities{
+		APIVersion:   models.APIVersion,
+		Capabilities: models.SupportedCapabilities,
+	})
+}
+
 func (s *Service) intentional500(w http.ResponseWriter, r *http.Request) {
 	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
 		s.withSuperUserAuth(w, r, user, func() {
@@ -789,6 +797,7 @@ func (s *Service) createProject(w http.ResponseWriter, r *http.Request) {
 			"default",
 			"",
 			nil,
+			false,
 		)
 		if err != nil {
 			log.WithError(err).Error("create default registration token")
@@ -2473,9 +2482,7 @@ func (s *Service) updateDevice(w http.ResponseWriter, r *http.Request) {
 			user, serviceAccount,
 			func(project *models.Project) {
 				s.withDevice(w, r, project, func(device *models.Device) {
-					var updateDeviceRequest struct {
-						Name string `json:"name" validate:"name"`
-					}
+					var updateDeviceRequest models.UpdateDeviceRequest
 					if err := read(r, &updateDeviceRequest); err != nil {
 						http.Error(w, err.Error(), http.StatusBadRequest)
 						return
@@ -2676,6 +2683,7 @@ func (s *Service) createDeviceRegistrationToken(w http.ResponseWriter, r *http.R
 					Name             string `json:"name" validate:"name"`
 					Description      string `json:"description" validate:"description"`
 					MaxRegistrations *int   `json:"maxRegistrations"`
+					AllowReclaim     bool   `json:"allowReclaim"`
 				}
 				if err := read(r, &createDeviceRegistrationTokenRequest); err != nil {
 					http.Error(w, err.Error(), http.StatusBadRequest)
@@ -2700,7 +2708,8 @@ func (s *Service) createDeviceRegistrationToken(w http.ResponseWriter, r *http.R
 					project.ID,
 					createDeviceRegistrationTokenRequest.Name,
 					createDeviceRegistrationTokenRequest.Description,
-					createDeviceRegistrationTokenRequest.MaxRegistrations)
+					createDeviceRegistrationTokenRequest.MaxRegistrations,
+					createDeviceRegistrationTokenRequest.AllowReclaim)
 				if err != nil {
 					log.WithError(err).Error("create device registration token")
 					w.WriteHeader(http.StatusInternalServerError)
@@ -2755,6 +2764,7 @@ func (s *Service) updateDeviceRegistrationToken(w http.ResponseWriter, r *http.R
 						Name             string `json:"name" validate:"name"`
 						Description      string `json:"description" validate:"description"`
 						MaxRegistrations *int   `json:"maxRegistrations"`
+						AllowReclaim     bool   `json:"allowReclaim"`
 					}
 					if err := read(r, &updateDeviceRegistrationTokenRequest); err != nil {
 						http.Error(w, err.Error(), http.StatusBadRequest)
@@ -2768,6 +2778,7 @@ func (s *Service) updateDeviceRegistrationToken(w http.ResponseWriter, r *http.R
 						updateDeviceRegistrationTokenRequest.Name,
 						updateDeviceRegistrationTokenRequest.Description,
 						updateDeviceRegistrationTokenRequest.MaxRegistrations,
+						updateDeviceRegistrationTokenRequest.AllowReclaim,
 					)
 					if err != nil {
 						log.WithError(err).Error("update device registration token")
@@ -3069,29 +3080,70 @@ func (s *Service) registerDevice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if deviceRegistrationToken.MaxRegistrations != nil {
-		devicesRegisteredCount, err := s.devicesRegisteredWithToken.GetDevicesRegisteredWithTokenCount(r.Context(), registerDeviceRequest.DeviceRegistrationTokenID, projectID)
-		if err != nil {
-			log.WithError(err).Error("get devices registered with token count")
+	// A device that registers again with a hardware ID it registered with
+	// before, e.g. after being reimaged, gets its existing device back if its
+	// token allows it. Hardware IDs are stored hashed, like access keys,
+	// since they're all it takes to reclaim a device.
+	var device *models.Device
+	var hardwareID *string
+	if registerDeviceRequest.HardwareID != "" {
+		hardwareIDHash := hash.Hash(registerDeviceRequest.HardwareID)
+		hardwareID = &hardwareIDHash
+
+		device, err = s.devices.LookupDeviceByHardwareID(r.Context(), hardwareIDHash, projectID)
+		if err == store.ErrDeviceNotFound {
+			device = nil
+		} else if err != nil {
+			log.WithError(err).Error("lookup device by hardware ID")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
+		} else if !deviceRegistrationToken.AllowReclaim {
+			http.Error(w, "hardware ID is already registered and the registration token doesn't allow reclaiming devices", http.StatusConflict)
+			return
+		} else if device.RegistrationTokenID == nil || *device.RegistrationTokenID != deviceRegistrationToken.ID {
+			// Only reclaim devices registered with the same token, so a token
+			// can't be used to take over unrelated devices
+			http.Error(w, "hardware ID is registered to a device with a different registration token", http.StatusConflict)
+			return
 		}
+	}
 
-		if devicesRegisteredCount.AllCount >= *deviceRegistrationToken.MaxRegistrations {
-			log.WithError(err).Error("device allocation limit reached")
-			w.WriteHeader(http.StatusBadRequest)
+	if device == nil {
+		if deviceRegistrationToken.MaxRegistrations != nil {
+			devicesRegisteredCount, err := s.devicesRegisteredWithToken.GetDevicesRegisteredWithTokenCount(r.Context(), registerDeviceRequest.DeviceRegistrationTokenID, projectID)
+			if err != nil {
+				log.WithError(err).Error("get devices registered with token count")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if devicesRegisteredCount.AllCount >= *deviceRegistrationToken.MaxRegistrations {
+				log.WithError(err).Error("device allocation limit reached")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		device, err = s.devices.CreateDevice(r.Context(),
+			projectID, namesgenerator.GetRandomName(), deviceRegistrationToken.ID,
+			deviceRegistrationToken.Labels, deviceRegistrationToken.EnvironmentVariables,
+			hardwareID,
+		)
+		if err != nil {
+			log.WithError(err).Error("create device")
+			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-	}
+	} else {
+		log.WithField("device", device.ID).Info("reclaiming device by hardware ID")
 
-	device, err := s.devices.CreateDevice(r.Context(),
-		projectID, namesgenerator.GetRandomName(), deviceRegistrationToken.ID,
-		deviceRegistrationToken.Labels, deviceRegistrationToken.EnvironmentVariables,
-	)
-	if err != nil {
-		log.WithError(err).Error("create device")
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		// Whatever held the device's previous access keys, such as its
+		// image before it was reimaged, loses access to it
+		if err := s.deviceAccessKeys.DeleteDeviceAccessKeys(r.Context(), projectID, device.ID); err != nil {
+			log.WithError(err).Error("delete device access keys")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 	}
 
 	deviceAccessKeyValue := ksuid.New().String()
@@ -3109,6 +3161,43 @@ func (s *Service) registerDevice(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// checkDeviceRegistration checks a registration token like registerDevice,
+// without registering a device, so devices can be checked before enrollment
+func (s *Service) checkDeviceRegistration(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["project"]
+
+	var registerDeviceRequest models.RegisterDeviceRequest
+	if err := read(r, &registerDeviceRequest); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deviceRegistrationToken, err := s.deviceRegistrationTokens.GetDeviceRegistrationToken(r.Context(), registerDeviceRequest.DeviceRegistrationTokenID, projectID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if deviceRegistrationToken.MaxRegistrations != nil {
+		devicesRegisteredCount, err := s.devicesRegisteredWithToken.GetDevicesRegisteredWithTokenCount(r.Context(), registerDeviceRequest.DeviceRegistrationTokenID, projectID)
+		if err != nil {
+			log.WithError(err).Error("get devices registered with token count")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if devicesRegisteredCount.AllCount >= *deviceRegistrationToken.MaxRegistrations {
+			http.Error(w, "device allocation limit reached", http.StatusBadRequest)
+			return
+		}
+	}
+
+	utils.Respond(w, models.CheckDeviceRegistrationResponse{
+		ServerTime: time.Now(),
+	})
+}
+
 func (s *Service) getBundle(w http.ResponseWriter, r *http.Request) {
 	s.withDeviceAuth(w, r, func(project *models.Project, device *models.Device) {
 		s.st.Incr("get_bundle",
@@ -3125,83 +3214,131 @@ func (s *Service) getBundle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		applications, err := s.applications.ListApplications(r.Context(), project.ID)
+		bundle, err := s.buildBundle(r.Context(), project, device)
 		if err != nil {
-			log.WithError(err).Error("list applications")
+			log.WithError(err).Error("build bundle")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		bundle := models.Bundle{
-			DeviceID:             device.ID,
-			DeviceName:           device.Name,
-			EnvironmentVariables: device.EnvironmentVariables,
-			DesiredAgentVersion:  device.DesiredAgentVersion,
-		}
-
-		for _, application := range applications {
-			scheduled, scheduledDevice, err := scheduling.IsApplicationScheduled(*device, application.SchedulingRule)
-			if err != nil {
-				log.WithError(err).Error("evaluate application scheduling rule")
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			if !scheduled {
-				continue
-			}
-
-			release, err := utils.GetReleaseByIdentifier(s.releases, r.Context(), project.ID, application.ID, scheduledDevice.ReleaseID)
-			if err == store.ErrReleaseNotFound {
-				continue
-			}
-			if err != nil {
-				log.WithError(err).Errorf("get release by ID %s", scheduledDevice.ReleaseID)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-
-			bundle.Applications = append(bundle.Applications, models.FullBundledApplication{
-				Application: models.BundledApplication{
-					ID:                    application.ID,
-					ProjectID:             application.ProjectID,
-					Name:                  application.Name,
-					MetricEndpointConfigs: application.MetricEndpointConfigs,
-				},
-				LatestRelease: *release,
-			})
-		}
-
-		deviceApplicationStatuses, err := s.deviceApplicationStatuses.ListDeviceApplicationStatuses(
-			r.Context(), project.ID, device.ID)
-		if err == nil {
-			bundle.ApplicationStatuses = deviceApplicationStatuses
-		} else {
-			log.WithError(err).Error("list device application statuses")
+		bundleBytes, err := json.Marshal(bundle)
+		if err != nil {
+			log.WithError(err).Error("marshal bundle")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		deviceServiceStatuses, err := s.deviceServiceStatuses.ListDeviceServiceStatuses(
-			r.Context(), project.ID, device.ID)
-		if err == nil {
-			bundle.ServiceStatuses = deviceServiceStatuses
-		} else {
-			log.WithError(err).Error("list device service statuses")
-			w.WriteHeader(http.StatusInternalServerError)
+		// Devices poll constantly, so let them skip unchanged bundles
+		etag := `"` + hash.Hash(string(bundleBytes)) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 
-		deviceServiceStates, err := s.deviceServiceStates.ListDeviceServiceStates(
-			r.Context(), project.ID, device.ID)
-		if err == nil {
-			bundle.ServiceStates = deviceServiceStates
-		} else {
-			log.WithError(err).Error("list device service states")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bundleBytes)
+	})
+}
+
+// inspectBundle returns the bundle a device would receive, for users
+// debugging what a device is running. Unlike getBundle it doesn't count as
+// the device checking in.
+func (s *Service) inspectBundle(w http.ResponseWriter, r *http.Request) {
+	s.withUserOrServiceAccountAuth(w, r, func(user *models.User, serviceAccount *models.ServiceAccount) {
+		s.validateAuthorization(
+			authz.ResourceDevices, authz.ActionGetDeviceBundle,
+			w, r,
+			user, serviceAccount,
+			func(project *models.Project) {
+				s.withDevice(w, r, project, func(device *models.Device) {
+					bundle, err := s.buildBundle(r.Context(), project, device)
+					if err != nil {
+						log.WithError(err).Error("build bundle")
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+
+					utils.Respond(w, bundle)
+				})
+			},
+		)
+	})
+}
+
+func (s *Service) buildBundle(ctx context.Context, project *models.Project, device *models.Device) (*models.Bundle, error) {
+	applications, err := s.applications.ListApplications(ctx, project.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "list applications")
+	}
+
+	bundle := models.Bundle{
+		SchemaVersion:        models.BundleSchemaVersion,
+		DeviceID:             device.ID,
+		DeviceName:           device.Name,
+		EnvironmentVariables: device.EnvironmentVariables,
+		DesiredAgentVersion:  device.DesiredAgentVersion,
+	}
+
+	for _, application := range applications {
+		scheduled, scheduledDevice, err := scheduling.IsApplicationScheduled(*device, application.SchedulingRule)
+		if err != nil {
+			return nil, errors.Wrap(err, "evaluate application scheduling rule")
+		}
+		if !scheduled {
+			continue
 		}
 
-		utils.Respond(w, bundle)
+		release, err := utils.GetReleaseByIdentifier(s.releases, ctx, project.ID, application.ID, scheduledDevice.ReleaseID)
+		if err == store.ErrReleaseNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "get release by ID %s", scheduledDevice.ReleaseID)
+		}
+
+		bundle.Applications = append(bundle.Applications, models.FullBundledApplication{
+			Application: models.BundledApplication{
+				ID:                    application.ID,
+				ProjectID:             application.ProjectID,
+				Name:                  application.Name,
+				MetricEndpointConfigs: application.MetricEndpointConfigs,
+			},
+			LatestRelease: *release,
+		})
+	}
+
+	deviceApplicationStatuses, err := s.deviceApplicationStatuses.ListDeviceApplicationStatuses(
+		ctx, project.ID, device.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "list device application statuses")
+	}
+	bundle.ApplicationStatuses = deviceApplicationStatuses
+
+	deviceServiceStatuses, err := s.deviceServiceStatuses.ListDeviceServiceStatuses(
+		ctx, project.ID, device.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "list device service statuses")
+	}
+	bundle.ServiceStatuses = deviceServiceStatuses
+
+	deviceServiceStates, err := s.deviceServiceStates.ListDeviceServiceStates(
+		ctx, project.ID, device.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "list device service states")
+	}
+	bundle.ServiceStates = deviceServiceStates
+
+	return &bundle, nil
+}
+
+// checkDeviceAuth lets an agent check its access key, and which device it
+// belongs to, before using it
+func (s *Service) checkDeviceAuth(w http.ResponseWriter, r *http.Request) {
+	s.withDeviceAuth(w, r, func(project *models.Project, device *models.Device) {
+		utils.Respond(w, models.CheckDeviceAuthResponse{
+			DeviceID: device.ID,
+		})
 	})
 }
 
@@ -3213,6 +3350,9 @@ func (s *Service) setDeviceInfo(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		reportedAt := time.Now()
+		setDeviceInfoRequest.DeviceInfo.ReportedAt = &reportedAt
+
 		if _, err := s.devices.SetDeviceInfo(r.Context(), device.ID, project.ID, setDeviceInfoRequest.DeviceInfo); err != nil {
 			log.WithError(err).Error("set device info")
 			w.WriteHeader(http.StatusInternalServerError)
@@ -3279,6 +3419,29 @@ func (s *Service) setDeviceServiceStatus(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+func (s *Service) setDeviceServiceStatuses(w http.ResponseWriter, r *http.Request) {
+	s.withDeviceAuth(w, r, func(project *models.Project, device *models.Device) {
+		vars := mux.Vars(r)
+		applicationID := vars["application"]
+
+		var setDeviceServiceStatusesRequest models.SetDeviceServiceStatusesRequest
+		if err := read(r, &setDeviceServiceStatusesRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for service, status := range setDeviceServiceStatusesRequest.Statuses {
+			if err := s.deviceServiceStatuses.SetDeviceServiceStatus(r.Context(), project.ID, device.ID,
+				applicationID, service, status.CurrentReleaseID,
+			); err != nil {
+				log.WithError(err).Error("set device service status")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+	})
+}
+
 func (s *Service) deleteDeviceServiceStatus(w http.ResponseWriter, r *http.Request) {
 	s.withDeviceAuth(w, r, func(project *models.Project, device *models.Device) {
 		vars := mux.Vars(r)
@@ -3312,6 +3475,8 @@ func (s *Service) setDeviceServiceState(w http.ResponseWriter, r *http.Request)
 			service,
 			setDeviceServiceStateRequest.State,
 			setDeviceServiceStateRequest.ErrorMessage,
+			setDeviceServiceStateRequest.RestartCount,
+			setDeviceServiceStateRequest.LastRestartAt,
 		); err != nil {
 			log.WithError(err).Error("set device service state")
 			w.WriteHeader(http.StatusInternalServerError)
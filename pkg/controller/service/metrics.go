@@ -100,13 +100,19 @@ func (s *Service) forwardDeviceMetrics(w http.ResponseWriter, r *http.Request) {
 				return false
 			}
 
+			deviceMetrics, agentMetrics := processing.SplitAgentMetrics(metricsRequest.Series)
+
 			var forwardedMetricsRequest models.DatadogPostMetricsRequest
 			forwardedMetricsRequest.Series = processing.ProcessDeviceMetrics(
-				metricsRequest.Series,
+				deviceMetrics,
 				deviceMetricsConfig.ExposedMetrics,
 				project,
 				device,
 			)
+			forwardedMetricsRequest.Series = append(
+				forwardedMetricsRequest.Series,
+				processing.ProcessAgentMetrics(agentMetrics, project, device)...,
+			)
 
 			client := datadog.NewClient(*project.DatadogAPIKey)
 			if err := client.PostMetrics(r.Context(), forwardedMetricsRequest); err != nil {
@@ -235,10 +235,18 @@ func NewService(
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/ssh", s.ssh)
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/connect/{connection}", s.connectTCP)
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/reboot", s.reboot)
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/poll", s.pollBundle).Methods("POST")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/services/{service}/imagepullprogress", s.imagePullProgress).Methods("GET")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/metrics/host", s.hostMetrics).Methods("GET")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/metrics/agent", s.agentMetrics).Methods("GET")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/stats", s.serviceStats).Methods("GET")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/connectivity", s.deviceConnectivity).Methods("GET")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/services/{service}/metrics", s.serviceMetrics).Methods("GET")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/services/{service}/logs", s.serviceLogs).Methods("GET")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/services/{service}/exec", s.serviceExec).Methods("POST")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/exec", s.hostExec).Methods("POST")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/events", s.deviceEvents).Methods("GET")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/inspectbundle", s.inspectBundle).Methods("GET")
 	apiRouter.PathPrefix("/projects/{project}/devices/{device}/debug/").HandlerFunc(s.deviceDebug)
 
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/environmentvariables", s.setDeviceEnvironmentVariable).Methods("PUT")
@@ -265,10 +273,13 @@ func NewService(
 	apiRouter.HandleFunc("/projects/{project}/configs/{key}", s.setProjectConfig).Methods("PUT")
 
 	apiRouter.HandleFunc("/projects/{project}/devices/register", s.registerDevice).Methods("POST")
+	apiRouter.HandleFunc("/projects/{project}/devices/register/check", s.checkDeviceRegistration).Methods("POST")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/auth", s.checkDeviceAuth).Methods("GET")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/bundle", s.getBundle).Methods("GET")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/info", s.setDeviceInfo).Methods("POST")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/deviceapplicationstatuses", s.setDeviceApplicationStatus).Methods("POST")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/deviceapplicationstatuses", s.deleteDeviceApplicationStatus).Methods("DELETE")
+	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/deviceservicestatuses", s.setDeviceServiceStatuses).Methods("POST")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/services/{service}/deviceservicestatuses", s.setDeviceServiceStatus).Methods("POST")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/services/{service}/deviceservicestatuses", s.deleteDeviceServiceStatus).Methods("DELETE")
 	apiRouter.HandleFunc("/projects/{project}/devices/{device}/applications/{application}/services/{service}/deviceservicestates", s.setDeviceServiceState).Methods("POST")
@@ -289,6 +300,7 @@ func NewService(
 	debugRouter.PathPrefix("/pprof/").Handler(http.StripPrefix("/api", http.HandlerFunc(pprof.Index)))
 
 	apiRouter.HandleFunc("/health", s.health).Methods("GET")
+	apiRouter.HandleFunc("/capabilities", s.capabilities).Methods("GET")
 	apiRouter.HandleFunc("/500", s.intentional500).Methods("GET")
 
 	s.router.PathPrefix("/api").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -327,6 +327,7 @@ create table if not exists device_registration_tokens (
   max_registrations int,
   labels longtext not null,
   environment_variables longtext not null,
+  allow_reclaim boolean not null default false,
 
   primary key (id),
   unique name_project_id_unique (name, project_id),
@@ -350,9 +351,11 @@ create table if not exists devices (
   last_seen_at timestamp not null default current_timestamp,
   labels longtext not null,
   environment_variables longtext not null,
+  hardware_id varchar(128),
 
   primary key (id),
   unique name_project_id_unique (name, project_id),
+  unique project_id_hardware_id_unique (project_id, hardware_id),
   foreign key devices_project_id(project_id)
   references projects(id)
   on delete cascade,
@@ -526,6 +529,8 @@ create table if not exists device_service_states (
 
   state varchar(100) not null,
   error_message longtext not null,
+  restart_count int not null default 0,
+  last_restart_at timestamp null default null,
 
   primary key (project_id, device_id, application_id, service),
   foreign key device_service_states_project_id(project_id)
@@ -557,6 +562,69 @@ create table if not exists project_configs (
   on delete cascade
 );
 
+--
+-- Migrations
+--
+-- Columns and indexes added to existing tables. "create table if not exists"
+-- leaves tables that already exist unchanged, so each one is only added if
+-- information_schema shows it's missing.
+--
+
+set @migration = (
+  select if(count(*) = 0,
+    'alter table devices add column hardware_id varchar(128)',
+    'do 0')
+  from information_schema.columns
+  where table_schema = database() and table_name = 'devices' and column_name = 'hardware_id'
+);
+prepare migration from @migration;
+execute migration;
+deallocate prepare migration;
+
+set @migration = (
+  select if(count(*) = 0,
+    'alter table devices add unique project_id_hardware_id_unique (project_id, hardware_id)',
+    'do 0')
+  from information_schema.statistics
+  where table_schema = database() and table_name = 'devices' and index_name = 'project_id_hardware_id_unique'
+);
+prepare migration from @migration;
+execute migration;
+deallocate prepare migration;
+
+set @migration = (
+  select if(count(*) = 0,
+    'alter table device_service_states add column restart_count int not null default 0',
+    'do 0')
+  from information_schema.columns
+  where table_schema = database() and table_name = 'device_service_states' and column_name = 'restart_count'
+);
+prepare migration from @migration;
+execute migration;
+deallocate prepare migration;
+
+set @migration = (
+  select if(count(*) = 0,
+    'alter table device_service_states add column last_restart_at timestamp null default null',
+    'do 0')
+  from information_schema.columns
+  where table_schema = database() and table_name = 'device_service_states' and column_name = 'last_restart_at'
+);
+prepare migration from @migration;
+execute migration;
+deallocate prepare migration;
+
+set @migration = (
+  select if(count(*) = 0,
+    'alter table device_registration_tokens add column allow_reclaim boolean not null default false',
+    'do 0')
+  from information_schema.columns
+  where table_schema = database() and table_name = 'device_registration_tokens' and column_name = 'allow_reclaim'
+);
+prepare migration from @migration;
+execute migration;
+deallocate prepare migration;
+
 --
 -- Commit
 --
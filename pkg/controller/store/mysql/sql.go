@@ -470,9 +470,10 @@ const createDevice = `
     name,
     registration_token_id,
     labels,
-    environment_variables
+    environment_variables,
+    hardware_id
   )
-  values (?, ?, ?, ?, ?, ?)
+  values (?, ?, ?, ?, ?, ?, ?)
 `
 
 // Index: project_id_id
@@ -487,6 +488,12 @@ const lookupDevice = `
   where name = ? and project_id = ?
 `
 
+// Index: project_id_hardware_id_unique
+const lookupDeviceByHardwareID = `
+  select id, created_at, project_id, name, registration_token_id, desired_agent_version, info, labels, environment_variables, last_seen_at from devices
+  where hardware_id = ? and project_id = ?
+`
+
 // Index: project_id_id
 const listDevices = `
   select id, created_at, project_id, name, registration_token_id, desired_agent_version, info, labels, environment_variables, last_seen_at from devices
@@ -554,33 +561,34 @@ const createDeviceRegistrationToken = `
     description,
     max_registrations,
     labels,
-    environment_variables
+    environment_variables,
+    allow_reclaim
   )
-  values (?, ?, ?, ?, ?, '{}', '{}')
+  values (?, ?, ?, ?, ?, '{}', '{}', ?)
 `
 
 // Index: project_id_id
 const getDeviceRegistrationToken = `
-  select id, created_at, project_id, max_registrations, name, description, labels, environment_variables from device_registration_tokens
+  select id, created_at, project_id, max_registrations, name, description, labels, environment_variables, allow_reclaim from device_registration_tokens
   where id = ? and project_id = ?
 `
 
 // Index: project_id_name
 const lookupDeviceRegistrationToken = `
-  select id, created_at, project_id, max_registrations, name, description, labels, environment_variables from device_registration_tokens
+  select id, created_at, project_id, max_registrations, name, description, labels, environment_variables, allow_reclaim from device_registration_tokens
   where name = ? and project_id = ?
 `
 
 // Index: project_id_id
 const listDeviceRegistrationTokens = `
-  select id, created_at, project_id, max_registrations, name, description, labels, environment_variables from device_registration_tokens
+  select id, created_at, project_id, max_registrations, name, description, labels, environment_variables, allow_reclaim from device_registration_tokens
   where project_id = ?
 `
 
 // Index: project_id_id
 const updateDeviceRegistrationToken = `
   update device_registration_tokens
-  set name = ?, description = ?, max_registrations = ?
+  set name = ?, description = ?, max_registrations = ?, allow_reclaim = ?
   where id = ? and project_id = ?
 `
 
@@ -633,6 +641,12 @@ const validateDeviceAccessKey = `
   where project_id = ? and hash = ?
 `
 
+// Index: device_access_keys_device_id
+const deleteDeviceAccessKeys = `
+  delete from device_access_keys
+  where device_id = ? and project_id = ?
+`
+
 const createConnection = `
   insert into connections (
     id,
@@ -877,23 +891,27 @@ const setDeviceServiceState = `
     application_id,
     service,
     state,
-    error_message
+    error_message,
+    restart_count,
+    last_restart_at
   )
-  values (?, ?, ?, ?, ?, ?)
+  values (?, ?, ?, ?, ?, ?, ?, ?)
   on duplicate key update
     state = ?,
-    error_message = ?
+    error_message = ?,
+    restart_count = ?,
+    last_restart_at = ?
 `
 
 // Index: primary key
 const getDeviceServiceState = `
-  select project_id, device_id, application_id, service, state, error_message from device_service_states
+  select project_id, device_id, application_id, service, state, error_message, restart_count, last_restart_at from device_service_states
   where project_id = ? and device_id = ? and application_id = ? and service = ?
 `
 
 // Index: project_id_device_id_application_id
 const getDeviceServiceStates = `
-  select project_id, device_id, application_id, service, state, error_message from device_service_states
+  select project_id, device_id, application_id, service, state, error_message, restart_count, last_restart_at from device_service_states
   where project_id = ? and device_id = ? and application_id = ?
 `
 
@@ -908,13 +926,13 @@ const getDeviceServiceStateCountsByApplication = `
 
 // Index: project_id_device_id_application_id
 const listDeviceServiceStates = `
-  select project_id, device_id, application_id, service, state, error_message from device_service_states
+  select project_id, device_id, application_id, service, state, error_message, restart_count, last_restart_at from device_service_states
   where project_id = ? and device_id = ?
 `
 
 // Index: project_id_device_id_application_id
 const listAllDeviceServiceStates = `
-  select project_id, device_id, application_id, service, state, error_message from device_service_states
+  select project_id, device_id, application_id, service, state, error_message, restart_count, last_restart_at from device_service_states
   where project_id = ?
 `
 
@@ -1324,7 +1324,7 @@ func (s *Store) scanServiceAccountRoleBinding(scanner scanner) (*models.ServiceA
 	return &serviceAccountRoleBinding, nil
 }
 
-func (s *Store) CreateDevice(ctx context.Context, projectID, name, deviceRegistrationTokenID string, deviceLabels, environmentVariables map[string]string) (*models.Device, error) {
+func (s *Store) CreateDevice(ctx context.Context, projectID, name, deviceRegistrationTokenID string, deviceLabels, environmentVariables map[string]string, hardwareID *string) (*models.Device, error) {
 	deviceID := newDeviceID()
 
 	serializedDeviceLabels, err := json.Marshal(deviceLabels)
@@ -1346,6 +1346,7 @@ func (s *Store) CreateDevice(ctx context.Context, projectID, name, deviceRegistr
 		deviceRegistrationTokenID,
 		string(serializedDeviceLabels),
 		string(serializedDeviceEnvironmentVariables),
+		hardwareID,
 	); err != nil {
 		return nil, err
 	}
@@ -1379,6 +1380,19 @@ func (s *Store) LookupDevice(ctx context.Context, name, projectID string) (*mode
 	return device, nil
 }
 
+func (s *Store) LookupDeviceByHardwareID(ctx context.Context, hardwareID, projectID string) (*models.Device, error) {
+	deviceRow := s.db.QueryRowContext(ctx, lookupDeviceByHardwareID, hardwareID, projectID)
+
+	device, err := s.scanDevice(deviceRow)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrDeviceNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
 func (s *Store) ListDevices(ctx context.Context, projectID, searchQuery string) ([]models.Device, error) {
 	var deviceRows *sql.Rows
 	var err error
@@ -1684,7 +1698,7 @@ func (s *Store) DeleteDeviceEnvironmentVariable(ctx context.Context, deviceID, p
 	return nil
 }
 
-func (s *Store) CreateDeviceRegistrationToken(ctx context.Context, projectID, name, description string, maxRegistrations *int) (*models.DeviceRegistrationToken, error) {
+func (s *Store) CreateDeviceRegistrationToken(ctx context.Context, projectID, name, description string, maxRegistrations *int, allowReclaim bool) (*models.DeviceRegistrationToken, error) {
 	id := newDeviceRegistrationTokenID()
 
 	if _, err := s.db.ExecContext(
@@ -1695,6 +1709,7 @@ func (s *Store) CreateDeviceRegistrationToken(ctx context.Context, projectID, na
 		name,
 		description,
 		maxRegistrations,
+		allowReclaim,
 	); err != nil {
 		return nil, err
 	}
@@ -1751,13 +1766,14 @@ func (s *Store) GetDeviceRegistrationToken(ctx context.Context, id, projectID st
 	return deviceRegistrationToken, nil
 }
 
-func (s *Store) UpdateDeviceRegistrationToken(ctx context.Context, id, projectID, name, description string, maxRegistrations *int) (*models.DeviceRegistrationToken, error) {
+func (s *Store) UpdateDeviceRegistrationToken(ctx context.Context, id, projectID, name, description string, maxRegistrations *int, allowReclaim bool) (*models.DeviceRegistrationToken, error) {
 	if _, err := s.db.ExecContext(
 		ctx,
 		updateDeviceRegistrationToken,
 		name,
 		description,
 		maxRegistrations,
+		allowReclaim,
 		id,
 		projectID,
 	); err != nil {
@@ -1902,6 +1918,7 @@ func (s *Store) scanDeviceRegistrationToken(scanner scanner) (*models.DeviceRegi
 		&deviceRegistrationToken.Description,
 		&labelsString,
 		&environmentVariablesString,
+		&deviceRegistrationToken.AllowReclaim,
 	); err != nil {
 		return nil, err
 	}
@@ -1993,6 +2010,16 @@ func (s *Store) ValidateDeviceAccessKey(ctx context.Context, projectID, hash str
 	return deviceAccessKey, nil
 }
 
+func (s *Store) DeleteDeviceAccessKeys(ctx context.Context, projectID, deviceID string) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		deleteDeviceAccessKeys,
+		deviceID,
+		projectID,
+	)
+	return err
+}
+
 func (s *Store) scanDeviceAccessKey(scanner scanner) (*models.DeviceAccessKey, error) {
 	var deviceAccessKey models.DeviceAccessKey
 	if err := scanner.Scan(
@@ -2689,7 +2716,7 @@ func (s *Store) scanDeviceServiceStatus(scanner scanner) (*models.DeviceServiceS
 	return &deviceServiceStatus, nil
 }
 
-func (s *Store) SetDeviceServiceState(ctx context.Context, projectID, deviceID, applicationID, service string, state models.ServiceState, errorMessage string) error {
+func (s *Store) SetDeviceServiceState(ctx context.Context, projectID, deviceID, applicationID, service string, state models.ServiceState, errorMessage string, restartCount int, lastRestartAt *time.Time) error {
 	_, err := s.db.ExecContext(
 		ctx,
 		setDeviceServiceState,
@@ -2699,8 +2726,12 @@ func (s *Store) SetDeviceServiceState(ctx context.Context, projectID, deviceID,
 		service,
 		state,
 		errorMessage,
+		restartCount,
+		lastRestartAt,
 		state,
 		errorMessage,
+		restartCount,
+		lastRestartAt,
 	)
 	return err
 }
@@ -2863,6 +2894,8 @@ func (s *Store) scanDeviceServiceState(scanner scanner) (*models.DeviceServiceSt
 		&deviceServiceState.Service,
 		&deviceServiceState.State,
 		&deviceServiceState.ErrorMessage,
+		&deviceServiceState.RestartCount,
+		&deviceServiceState.LastRestartAt,
 	); err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/deviceplane/cli/pkg/models"
 )
@@ -150,9 +151,10 @@ type ServiceAccountRoleBindings interface {
 var ErrServiceAccountRoleBindingNotFound = errors.New("service account role binding not found")
 
 type Devices interface {
-	CreateDevice(ctx context.Context, projectID, name, registrationTokenID string, deviceLabels, deviceEnvironmentVariables map[string]string) (*models.Device, error)
+	CreateDevice(ctx context.Context, projectID, name, registrationTokenID string, deviceLabels, deviceEnvironmentVariables map[string]string, hardwareID *string) (*models.Device, error)
 	GetDevice(ctx context.Context, deviceID, projectID string) (*models.Device, error)
 	LookupDevice(ctx context.Context, name, projectID string) (*models.Device, error)
+	LookupDeviceByHardwareID(ctx context.Context, hardwareID, projectID string) (*models.Device, error)
 	ListDevices(ctx context.Context, projectID, searchQuery string) ([]models.Device, error)
 	UpdateDeviceName(ctx context.Context, deviceID, projectID, name string) (*models.Device, error)
 	DeleteDevice(ctx context.Context, deviceID, projectID string) error
@@ -169,11 +171,11 @@ var ErrDeviceNotFound = errors.New("device not found")
 var ErrDeviceNameAlreadyInUse = errors.New("device name already in use")
 
 type DeviceRegistrationTokens interface {
-	CreateDeviceRegistrationToken(ctx context.Context, projectID, name, description string, maxRegistrations *int) (*models.DeviceRegistrationToken, error)
+	CreateDeviceRegistrationToken(ctx context.Context, projectID, name, description string, maxRegistrations *int, allowReclaim bool) (*models.DeviceRegistrationToken, error)
 	GetDeviceRegistrationToken(ctx context.Context, tokenID, projectID string) (*models.DeviceRegistrationToken, error)
 	LookupDeviceRegistrationToken(ctx context.Context, name, projectID string) (*models.DeviceRegistrationToken, error)
 	ListDeviceRegistrationTokens(ctx context.Context, projectID string) ([]models.DeviceRegistrationToken, error)
-	UpdateDeviceRegistrationToken(ctx context.Context, tokenID, projectID, name, description string, maxRegistrations *int) (*models.DeviceRegistrationToken, error)
+	UpdateDeviceRegistrationToken(ctx context.Context, tokenID, projectID, name, description string, maxRegistrations *int, allowReclaim bool) (*models.DeviceRegistrationToken, error)
 	DeleteDeviceRegistrationToken(ctx context.Context, tokenID, projectID string) error
 	SetDeviceRegistrationTokenLabel(ctx context.Context, tokenID, projectID, key, value string) (*string, error)
 	DeleteDeviceRegistrationTokenLabel(ctx context.Context, tokenID, projectID, key string) error
@@ -192,6 +194,7 @@ type DeviceAccessKeys interface {
 	CreateDeviceAccessKey(ctx context.Context, projectID, deviceID, hash string) (*models.DeviceAccessKey, error)
 	GetDeviceAccessKey(ctx context.Context, id, projectID string) (*models.DeviceAccessKey, error)
 	ValidateDeviceAccessKey(ctx context.Context, projectID, hash string) (*models.DeviceAccessKey, error)
+	DeleteDeviceAccessKeys(ctx context.Context, projectID, deviceID string) error
 }
 
 var ErrDeviceAccessKeyNotFound = errors.New("device access key not found")
@@ -262,7 +265,7 @@ type DeviceServiceStatuses interface {
 var ErrDeviceServiceStatusNotFound = errors.New("device service status not found")
 
 type DeviceServiceStates interface {
-	SetDeviceServiceState(ctx context.Context, projectID, deviceID, applicationID, service string, state models.ServiceState, errorMessage string) error
+	SetDeviceServiceState(ctx context.Context, projectID, deviceID, applicationID, service string, state models.ServiceState, errorMessage string, restartCount int, lastRestartAt *time.Time) error
 	GetDeviceServiceState(ctx context.Context, projectID, deviceID, applicationID, service string) (*models.DeviceServiceState, error)
 	GetDeviceServiceStates(ctx context.Context, projectID, deviceID, applicationID string) ([]models.DeviceServiceState, error)
 	ListApplicationServiceStateCounts(ctx context.Context, projectID, applicationID string) ([]models.ServiceStateCount, error)
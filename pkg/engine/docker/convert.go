@@ -159,3 +159,21 @@ func convertToInstance(c types.Container) engine.Instance {
 		State:  state,
 	}
 }
+
+func convertStats(s types.StatsJSON) *engine.ContainerStats {
+	// Page cache is reclaimable so it's excluded, like "docker stats" does
+	memoryUsage := s.MemoryStats.Usage
+	cache, ok := s.MemoryStats.Stats["cache"]
+	if !ok {
+		cache = s.MemoryStats.Stats["inactive_file"]
+	}
+	if cache < memoryUsage {
+		memoryUsage -= cache
+	}
+
+	return &engine.ContainerStats{
+		CPUSeconds:       float64(s.CPUStats.CPUUsage.TotalUsage) / 1e9,
+		MemoryUsageBytes: memoryUsage,
+		MemoryLimitBytes: s.MemoryStats.Limit,
+	}
+}
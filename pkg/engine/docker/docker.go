@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/deviceplane/cli/pkg/engine"
@@ -40,7 +41,7 @@ func (e *Engine) CreateContainer(ctx context.Context, name string, s models.Serv
 
 	resp, err := e.client.ContainerCreate(ctx, config, hostConfig, nil, name)
 	if err != nil {
-		return "", err
+		return "", classifyError(err)
 	}
 
 	return resp.ID, nil
@@ -49,7 +50,7 @@ func (e *Engine) CreateContainer(ctx context.Context, name string, s models.Serv
 func (e *Engine) InspectContainer(ctx context.Context, id string) (*engine.InspectResponse, error) {
 	container, err := e.client.ContainerInspect(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	var exitCode *int
@@ -71,7 +72,7 @@ func (e *Engine) StartContainer(ctx context.Context, id string) error {
 		if strings.Contains(err.Error(), "No such container") {
 			return engine.ErrInstanceNotFound
 		}
-		return err
+		return classifyError(err)
 	}
 	return nil
 }
@@ -90,7 +91,7 @@ func (e *Engine) ListContainers(ctx context.Context, keyFilters map[string]struc
 		All:     all,
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	var instances []engine.Instance
@@ -123,6 +124,53 @@ func (e *Engine) RemoveContainer(ctx context.Context, id string) error {
 	return nil
 }
 
+func (e *Engine) ContainerLogs(ctx context.Context, id string, options engine.LogsOptions) (io.ReadCloser, error) {
+	tail := "all"
+	if options.Tail >= 0 {
+		tail = strconv.Itoa(options.Tail)
+	}
+	var since string
+	if !options.Since.IsZero() {
+		since = strconv.FormatInt(options.Since.Unix(), 10)
+	}
+
+	out, err := e.client.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     options.Follow,
+		Tail:       tail,
+		Since:      since,
+	})
+	if err != nil {
+		// TODO
+		if strings.Contains(err.Error(), "No such container") {
+			return nil, engine.ErrInstanceNotFound
+		}
+		return nil, err
+	}
+
+	return newDemuxReader(out), nil
+}
+
+func (e *Engine) ContainerStats(ctx context.Context, id string) (*engine.ContainerStats, error) {
+	resp, err := e.client.ContainerStats(ctx, id, false)
+	if err != nil {
+		// TODO
+		if strings.Contains(err.Error(), "No such container") {
+			return nil, engine.ErrInstanceNotFound
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, errors.Wrap(err, "decode container stats")
+	}
+
+	return convertStats(stats), nil
+}
+
 func (e *Engine) PullImage(ctx context.Context, image, registryAuth string, w io.Writer) error {
 	processedRegistryAuth := ""
 	if registryAuth != "" {
@@ -137,11 +185,21 @@ func (e *Engine) PullImage(ctx context.Context, image, registryAuth string, w io
 		RegistryAuth: processedRegistryAuth,
 	})
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 	defer out.Close()
 	_, err = io.Copy(w, out)
-	return err
+	return classifyError(err)
+}
+
+func (e *Engine) InspectImage(ctx context.Context, image string) error {
+	if _, _, err := e.client.ImageInspectWithRaw(ctx, image); err != nil {
+		if client.IsErrNotFound(err) {
+			return engine.ErrImageNotFound
+		}
+		return classifyError(err)
+	}
+	return nil
 }
 
 func getProcessedRegistryAuth(registryAuth string) (string, error) {
@@ -1,11 +1,18 @@
 package docker
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"io/ioutil"
 	"testing"
 
+	"github.com/deviceplane/cli/pkg/engine"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,3 +39,32 @@ func TestGetRegistryAuthConfig(t *testing.T) {
 	require.Equal(t, "username", authConfig.Username)
 	require.Equal(t, "password", authConfig.Password)
 }
+
+func TestClassifyError(t *testing.T) {
+	require.True(t, engine.IsTransient(classifyError(client.ErrorConnectionFailed("unix:///var/run/docker.sock"))))
+	require.True(t, engine.IsTransient(classifyError(context.DeadlineExceeded)))
+	require.False(t, engine.IsTransient(classifyError(errors.New("invalid reference format"))))
+	require.Nil(t, classifyError(nil))
+}
+
+func TestDemuxReader(t *testing.T) {
+	var buf bytes.Buffer
+	for _, frame := range []struct {
+		stream  byte
+		payload string
+	}{
+		{1, "hello "},
+		{2, "from "},
+		{1, "stdout\n"},
+	} {
+		header := make([]byte, logHeaderLength)
+		header[0] = frame.stream
+		binary.BigEndian.PutUint32(header[4:], uint32(len(frame.payload)))
+		buf.Write(header)
+		buf.WriteString(frame.payload)
+	}
+
+	out, err := ioutil.ReadAll(newDemuxReader(ioutil.NopCloser(&buf)))
+	require.Nil(t, err)
+	require.Equal(t, "hello from stdout\n", string(out))
+}
@@ -0,0 +1,26 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/docker/docker/client"
+)
+
+// classifyError marks errors that a retry may get past, such as the daemon
+// restarting, as transient
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if client.IsErrConnectionFailed(err) || errors.Is(err, context.DeadlineExceeded) {
+		return engine.Transient(err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return engine.Transient(err)
+	}
+	return err
+}
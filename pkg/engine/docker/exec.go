@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/deviceplane/cli/pkg/engine"
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	stdoutStream = byte(1)
+	stderrStream = byte(2)
+)
+
+func (e *Engine) ContainerExec(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	config := types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	}
+
+	created, err := e.client.ContainerExecCreate(ctx, id, config)
+	if err != nil {
+		// TODO
+		if strings.Contains(err.Error(), "No such container") {
+			return 0, engine.ErrInstanceNotFound
+		}
+		return 0, err
+	}
+
+	attached, err := e.client.ContainerExecAttach(ctx, created.ID, config)
+	if err != nil {
+		return 0, errors.Wrap(err, "attach to exec")
+	}
+	defer attached.Close()
+
+	if err := demuxStreams(stdout, stderr, attached.Reader); err != nil {
+		return 0, errors.Wrap(err, "read exec output")
+	}
+
+	inspected, err := e.client.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return 0, errors.Wrap(err, "inspect exec")
+	}
+
+	return inspected.ExitCode, nil
+}
+
+// demuxStreams splits the frames of an attached non-TTY stream between
+// stdout and stderr until the stream ends
+func demuxStreams(stdout, stderr io.Writer, r io.Reader) error {
+	var header [logHeaderLength]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var dst io.Writer
+		switch header[0] {
+		case stdoutStream:
+			dst = stdout
+		case stderrStream:
+			dst = stderr
+		default:
+			dst = io.Discard
+		}
+
+		if _, err := io.CopyN(dst, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"io"
+)
+
+const logHeaderLength = 8
+
+// demuxReader strips the stream headers Docker prepends to each frame of
+// a non-TTY container's log output, interleaving stdout and stderr. The
+// frames are split by demuxStreams, like those of an exec.
+type demuxReader struct {
+	*io.PipeReader
+	r io.ReadCloser
+}
+
+func newDemuxReader(r io.ReadCloser) *demuxReader {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(demuxStreams(pw, pw, r))
+	}()
+	return &demuxReader{
+		PipeReader: pr,
+		r:          r,
+	}
+}
+
+func (d *demuxReader) Close() error {
+	d.PipeReader.Close()
+	return d.r.Close()
+}
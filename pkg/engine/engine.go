@@ -4,14 +4,42 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
 	"github.com/deviceplane/cli/pkg/models"
 )
 
 var (
 	ErrInstanceNotFound = errors.New("instance not found")
+	ErrImageNotFound    = errors.New("image not found")
 )
 
+// transientError marks an error that is likely to go away if the operation
+// is retried, such as when the engine's daemon is restarting
+type transientError struct {
+	error
+}
+
+func (e transientError) Unwrap() error {
+	return e.error
+}
+
+// Transient marks err as transient. Engines use it for errors such as a
+// refused connection or a timeout.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return transientError{err}
+}
+
+// IsTransient returns whether err, or any error it wraps, was marked as
+// transient
+func IsTransient(err error) bool {
+	var transient transientError
+	return errors.As(err, &transient)
+}
+
 type Engine interface {
 	CreateContainer(context.Context, string, models.Service) (string, error)
 	InspectContainer(context.Context, string) (*InspectResponse, error)
@@ -19,8 +47,14 @@ type Engine interface {
 	ListContainers(context.Context, map[string]struct{}, map[string]string, bool) ([]Instance, error)
 	StopContainer(context.Context, string) error
 	RemoveContainer(context.Context, string) error
+	ContainerLogs(context.Context, string, LogsOptions) (io.ReadCloser, error)
+	ContainerStats(context.Context, string) (*ContainerStats, error)
+	ContainerExec(context.Context, string, []string, io.Writer, io.Writer) (int, error)
 
 	PullImage(context.Context, string, string, io.Writer) error
+	// InspectImage returns ErrImageNotFound if the image isn't present
+	// locally
+	InspectImage(context.Context, string) error
 }
 
 type Instance struct {
@@ -35,3 +69,19 @@ type InspectResponse struct {
 	ExitCode *int
 	Error    string
 }
+
+type LogsOptions struct {
+	Follow bool
+	// Tail is the number of lines to return from the end of the logs. A
+	// negative value returns all lines.
+	Tail  int
+	Since time.Time
+}
+
+type ContainerStats struct {
+	// CPUSeconds is the total CPU time consumed by the container
+	CPUSeconds       float64
+	MemoryUsageBytes uint64
+	// MemoryLimitBytes is zero if the container has no memory limit
+	MemoryLimitBytes uint64
+}
@@ -0,0 +1,121 @@
+// Package execstream implements the framing used to stream the output of a
+// command run inside a service container back to the caller. Each frame has
+// an eight byte header holding the stream type and the payload length, the
+// same layout Docker uses for attached streams. The last frame of a complete
+// stream carries the command's exit code.
+package execstream
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	StreamStdout = byte(1)
+	StreamStderr = byte(2)
+	StreamExit   = byte(3)
+
+	headerLength = 8
+)
+
+var (
+	ErrMissingExitCode = errors.New("stream ended before the command exited")
+)
+
+type Writer struct {
+	w    io.Writer
+	lock sync.Mutex
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: w,
+	}
+}
+
+func (w *Writer) Stdout() io.Writer {
+	return streamWriter{w, StreamStdout}
+}
+
+func (w *Writer) Stderr() io.Writer {
+	return streamWriter{w, StreamStderr}
+}
+
+func (w *Writer) WriteExitCode(code int) error {
+	var payload [4]byte
+	binary.BigEndian.PutUint32(payload[:], uint32(int32(code)))
+	return w.writeFrame(StreamExit, payload[:])
+}
+
+func (w *Writer) writeFrame(stream byte, p []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var header [headerLength]byte
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+
+	if _, err := w.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.w.Write(p)
+	return err
+}
+
+type streamWriter struct {
+	w      *Writer
+	stream byte
+}
+
+func (s streamWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := s.w.writeFrame(s.stream, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Copy demultiplexes r into stdout and stderr and returns the exit code of
+// the command once the stream is complete
+func Copy(stdout, stderr io.Writer, r io.Reader) (int, error) {
+	var header [headerLength]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return 0, ErrMissingExitCode
+			}
+			return 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+
+		switch header[0] {
+		case StreamStdout, StreamStderr:
+			dst := stdout
+			if header[0] == StreamStderr {
+				dst = stderr
+			}
+			if _, err := io.CopyN(dst, r, size); err != nil {
+				if err == io.EOF {
+					return 0, ErrMissingExitCode
+				}
+				return 0, err
+			}
+		case StreamExit:
+			if size != 4 {
+				return 0, errors.Errorf("invalid exit code frame of length %d", size)
+			}
+			var payload [4]byte
+			if _, err := io.ReadFull(r, payload[:]); err != nil {
+				return 0, ErrMissingExitCode
+			}
+			return int(int32(binary.BigEndian.Uint32(payload[:]))), nil
+		default:
+			return 0, errors.Errorf("unknown stream type %d", header[0])
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package execstream
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	var stream bytes.Buffer
+	w := NewWriter(&stream)
+
+	w.Stdout().Write([]byte("hello "))
+	w.Stderr().Write([]byte("warning\n"))
+	w.Stdout().Write([]byte("world\n"))
+	require.NoError(t, w.WriteExitCode(3))
+
+	var stdout, stderr bytes.Buffer
+	exitCode, err := Copy(&stdout, &stderr, &stream)
+	require.NoError(t, err)
+	require.Equal(t, 3, exitCode)
+	require.Equal(t, "hello world\n", stdout.String())
+	require.Equal(t, "warning\n", stderr.String())
+}
+
+func TestCopyMissingExitCode(t *testing.T) {
+	var stream bytes.Buffer
+	w := NewWriter(&stream)
+	w.Stderr().Write([]byte("exec failed\n"))
+
+	var stdout, stderr bytes.Buffer
+	_, err := Copy(&stdout, &stderr, &stream)
+	require.Equal(t, ErrMissingExitCode, err)
+	require.Equal(t, "exec failed\n", stderr.String())
+}
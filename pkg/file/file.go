@@ -5,10 +5,34 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"runtime"
 )
 
+// syncDir is replaced in tests to observe directory syncs
+var syncDir = func(dir string) error {
+	// Directories can't be opened for syncing on Windows, where renames
+	// are durable once they return
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// WriteFileAtomic replaces filename with data such that, even after a power
+// loss, the file holds either its old or its new contents in full
 func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
 	dir, file := path.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
 	tempFile, err := ioutil.TempFile(dir, fmt.Sprintf(".%s", file))
 	if err != nil {
 		return err
@@ -16,6 +40,11 @@ func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
 	defer os.Remove(tempFile.Name())
 
 	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
 		return err
 	}
 	if err := tempFile.Close(); err != nil {
@@ -25,5 +54,10 @@ func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
 		return err
 	}
 
-	return os.Rename(tempFile.Name(), filename)
+	if err := os.Rename(tempFile.Name(), filename); err != nil {
+		return err
+	}
+
+	// The rename is only durable once the directory entry is synced
+	return syncDir(dir)
 }
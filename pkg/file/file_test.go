@@ -0,0 +1,42 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	realSyncDir := syncDir
+	defer func() { syncDir = realSyncDir }()
+
+	var synced []string
+	syncDir = func(d string) error {
+		synced = append(synced, d)
+		return realSyncDir(d)
+	}
+
+	filename := filepath.Join(dir, "access-key")
+	require.NoError(t, WriteFileAtomic(filename, []byte("key"), 0600))
+
+	contents, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "key", string(contents))
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.Equal(t, []string{dir + "/"}, synced)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1, "temporary file should be removed")
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -17,6 +18,21 @@ var (
 	ErrNonSuccessResponse = errors.New("non-2xx status code")
 )
 
+// NonSuccessResponseError is returned by Client.Do for non-2xx responses.
+// Its cause is ErrNonSuccessResponse.
+type NonSuccessResponseError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *NonSuccessResponseError) Error() string {
+	return fmt.Sprintf("code: %d, body: %s: %s", e.StatusCode, e.Body, ErrNonSuccessResponse.Error())
+}
+
+func (e *NonSuccessResponseError) Cause() error {
+	return ErrNonSuccessResponse
+}
+
 type Request struct {
 	*http.Request
 }
@@ -48,7 +64,10 @@ func (c *Client) Do(req *Request) (*Response, error) {
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		body, _ := ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, errors.WithMessagef(ErrNonSuccessResponse, "code: %d, body: %s", resp.StatusCode, string(body))
+		return nil, &NonSuccessResponseError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+		}
 	}
 
 	return &Response{
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -139,3 +140,51 @@ func validVariableNameChar(c uint8) bool {
 func isNum(c uint8) bool {
 	return c >= '0' && c <= '9'
 }
+
+// ExpandBraced replaces only "${NAME}" references, leaving any other use of
+// "$" untouched so that strings meant for a shell keep working. "$${" is an
+// escaped "${". References to undefined variables are left as they are and
+// their names are returned.
+func ExpandBraced(s string, lookup func(string) (string, bool)) (string, []string) {
+	var buffer bytes.Buffer
+	var undefined []string
+
+	for pos := 0; pos < len(s); pos++ {
+		if strings.HasPrefix(s[pos:], "$${") {
+			buffer.WriteString("${")
+			pos += 2
+			continue
+		}
+
+		if strings.HasPrefix(s[pos:], "${") {
+			end := strings.IndexByte(s[pos+2:], '}')
+			if end > 0 && validVariableName(s[pos+2:pos+2+end]) {
+				name := s[pos+2 : pos+2+end]
+				if value, ok := lookup(name); ok {
+					buffer.WriteString(value)
+				} else {
+					buffer.WriteString(s[pos : pos+3+end])
+					undefined = append(undefined, name)
+				}
+				pos += 2 + end
+				continue
+			}
+		}
+
+		buffer.WriteByte(s[pos])
+	}
+
+	return buffer.String(), undefined
+}
+
+func validVariableName(name string) bool {
+	if name == "" || isNum(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !validVariableNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -76,3 +76,25 @@ func TestParseLine(t *testing.T) {
 	testInvalidInterpolate(t, "${A!}")
 	testInvalidInterpolate(t, "$!")
 }
+
+func TestExpandBraced(t *testing.T) {
+	lookup := func(variable string) (string, bool) {
+		value, ok := map[string]string{
+			"SITE":  "hq2",
+			"EMPTY": "",
+		}[variable]
+		return value, ok
+	}
+
+	out, undefined := ExpandBraced("--site=${SITE} --empty=${EMPTY}", lookup)
+	require.Equal(t, "--site=hq2 --empty=", out)
+	require.Empty(t, undefined)
+
+	out, undefined = ExpandBraced("echo $HOME $${SITE} ${ 1} ${", lookup)
+	require.Equal(t, "echo $HOME ${SITE} ${ 1} ${", out)
+	require.Empty(t, undefined)
+
+	out, undefined = ExpandBraced("${SITE}-${TYPO}", lookup)
+	require.Equal(t, "hq2-${TYPO}", out)
+	require.Equal(t, []string{"TYPO"}, undefined)
+}
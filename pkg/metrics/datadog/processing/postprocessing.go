@@ -21,6 +21,7 @@ func addNoTags(func(tag, value string)) {}
 
 var ProcessProjectMetrics = metricProcessorFunc("deviceplane.", addNoTags)
 var ProcessDeviceMetrics = metricProcessorFunc("deviceplane.device.", addNoTags)
+
 var ProcessServiceMetrics = func(applicationName, serviceName string) metricProcessor {
 	return metricProcessorFunc("deviceplane.service.",
 		func(addTag func(tag, value string)) {
@@ -34,6 +35,35 @@ var ProcessServiceMetrics = func(applicationName, serviceName string) metricProc
 	)
 }
 
+// AgentMetricPrefix starts the names of the agent's metrics about itself,
+// which are sent along with device metrics
+const AgentMetricPrefix = "deviceplane.agent."
+
+var processAgentMetrics = metricProcessorFunc(AgentMetricPrefix, addNoTags)
+
+// ProcessAgentMetrics processes the agent's metrics about itself. Unlike
+// device metrics they don't have to be exposed by the project, and are always
+// tagged with the device they came from.
+func ProcessAgentMetrics(metrics []models.DatadogMetric, project *models.Project, device *models.Device) []models.DatadogMetric {
+	return processAgentMetrics(metrics, []models.ExposedMetric{{
+		Name:       WildcardMetric,
+		Properties: []string{"device"},
+	}}, project, device)
+}
+
+// SplitAgentMetrics separates the agent's metrics about itself from device
+// metrics
+func SplitAgentMetrics(metrics []models.DatadogMetric) (deviceMetrics, agentMetrics []models.DatadogMetric) {
+	for _, m := range metrics {
+		if strings.HasPrefix(m.Metric, AgentMetricPrefix) {
+			agentMetrics = append(agentMetrics, m)
+		} else {
+			deviceMetrics = append(deviceMetrics, m)
+		}
+	}
+	return deviceMetrics, agentMetrics
+}
+
 type metricProcessor func(
 	metrics []models.DatadogMetric,
 	exposedMetrics []models.ExposedMetric,
@@ -2,9 +2,13 @@ package translation
 
 import (
 	"strings"
+	"sync"
 )
 
+// StatsCache remembers the last value of each counter, to convert counters to
+// the change since they were last seen. It's safe for concurrent use.
 type StatsCache struct {
+	lock         sync.Mutex
 	counterCache map[string]float64
 }
 
@@ -23,11 +27,15 @@ func squish(prefix, metric string, tags []string) string {
 
 func (s *StatsCache) UpdateCount(prefix, metric string, tags []string, newCount float64) (delta float64, ok bool) {
 	key := squish(prefix, metric, tags)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
 	currentCount, ok := s.counterCache[key]
 	if ok {
 		delta = newCount - currentCount
 	}
-	s.counterCache[key] = currentCount
+	s.counterCache[key] = newCount
 
 	return delta, ok
 }
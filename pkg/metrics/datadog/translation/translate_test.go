@@ -193,3 +193,17 @@ promhttp_metric_handler_requests_total{code="200"} 15
 promhttp_metric_handler_requests_total{code="500"} 0
 promhttp_metric_handler_requests_total{code="503"} 0
 `
+
+func TestStatsCacheDeltas(t *testing.T) {
+	statsCache := NewStatsCache()
+
+	if _, ok := statsCache.UpdateCount("prefix", "requests", nil, 5); ok {
+		t.Error("First count should have no delta")
+	}
+	if delta, ok := statsCache.UpdateCount("prefix", "requests", nil, 8); !ok || delta != 3 {
+		t.Errorf("Expected delta of 3, got %v", delta)
+	}
+	if delta, ok := statsCache.UpdateCount("prefix", "requests", nil, 10); !ok || delta != 2 {
+		t.Errorf("Expected delta of 2, got %v", delta)
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+// APIVersion is bumped whenever the API changes in a way that older
+// clients cannot handle.
+const APIVersion = "1"
+
+type Capability string
+
+const (
+	CapabilityServiceLogs  = Capability("service-logs")
+	CapabilityDeviceEvents = Capability("device-events")
+	CapabilityServiceExec  = Capability("service-exec")
+	CapabilityDeviceBundle = Capability("device-bundle")
+	CapabilityHostExec     = Capability("host-exec")
+	CapabilityServiceStats = Capability("service-stats")
+
+	CapabilityDeviceConnectivity = Capability("device-connectivity")
+	CapabilityDeviceSync         = Capability("device-sync")
+)
+
+// SupportedCapabilities lists the optional features served by this build
+// of the API.
+var SupportedCapabilities = []Capability{
+	CapabilityServiceLogs,
+	CapabilityDeviceEvents,
+	CapabilityServiceExec,
+	CapabilityDeviceBundle,
+	CapabilityHostExec,
+	CapabilityServiceStats,
+	CapabilityDeviceConnectivity,
+	CapabilityDeviceSync,
+}
+
+type APICapabilities struct {
+	APIVersion   string       `json:"apiVersion" yaml:"apiVersion"`
+	Capabilities []Capability `json:"capabilities" yaml:"capabilities"`
+}
+
+func (c APICapabilities) Has(capability Capability) bool {
+	for _, supported := range c.Capabilities {
+		if supported == capability {
+			return true
+		}
+	}
+	return false
+}
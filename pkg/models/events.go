@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type AgentEventType string
+
+const (
+	AgentEventBundleApplied       = AgentEventType("bundle-applied")
+	AgentEventRollback            = AgentEventType("rollback")
+	AgentEventHookFailed          = AgentEventType("hook-failed")
+	AgentEventServiceStateChanged = AgentEventType("service-state-changed")
+	AgentEventServiceFailed       = AgentEventType("service-failed")
+	AgentEventServiceCreated      = AgentEventType("service-created")
+	AgentEventServiceRemoved      = AgentEventType("service-removed")
+	AgentEventServiceRestarted    = AgentEventType("service-restarted")
+	AgentEventValidationFailed    = AgentEventType("validation-failed")
+)
+
+// AgentEvent is a notable change on a device, as recorded by its agent
+type AgentEvent struct {
+	Type          AgentEventType `json:"type" yaml:"type"`
+	Timestamp     time.Time      `json:"timestamp" yaml:"timestamp"`
+	ApplicationID string         `json:"applicationId,omitempty" yaml:"applicationId,omitempty"`
+	Service       string         `json:"service,omitempty" yaml:"service,omitempty"`
+	Message       string         `json:"message" yaml:"message"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Types in this file make up the payloads of the agent's local device API,
+// which is served on the loopback interface under a versioned path prefix.
+
+import "time"
+
+type LocalAPIVersion struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+}
+
+// LocalHealth is served by /v1/healthz and /v1/readyz. Ready is false until
+// the device is registered and has a bundle, and again whenever bundle
+// downloads have been failing for too long.
+type LocalHealth struct {
+	Registered         bool       `json:"registered" yaml:"registered"`
+	BundleLoaded       bool       `json:"bundleLoaded" yaml:"bundleLoaded"`
+	LastBundleDownload *time.Time `json:"lastBundleDownload,omitempty" yaml:"lastBundleDownload,omitempty"`
+	Ready              bool       `json:"ready" yaml:"ready"`
+	StorageError       string     `json:"storageError,omitempty" yaml:"storageError,omitempty"`
+}
@@ -159,6 +159,10 @@ type DeviceRegistrationToken struct {
 	Description          string            `json:"description" yaml:"description"`
 	Labels               map[string]string `json:"labels" yaml:"labels"`
 	EnvironmentVariables map[string]string `json:"environmentVariables" yaml:"environmentVariables"`
+	// AllowReclaim lets a device that registers with the token again, with
+	// the hardware ID it registered with before, reclaim its existing
+	// device. The device's previous access keys are revoked.
+	AllowReclaim bool `json:"allowReclaim" yaml:"allowReclaim"`
 }
 
 type DevicesRegisteredWithTokenCount struct {
@@ -240,6 +244,8 @@ type DeviceServiceState struct {
 	Service       string       `json:"service" yaml:"service"`
 	State         ServiceState `json:"state" yaml:"state"`
 	ErrorMessage  string       `json:"errorMessage" yaml:"errorMessage"`
+	RestartCount  int          `json:"restartCount" yaml:"restartCount"`
+	LastRestartAt *time.Time   `json:"lastRestartAt" yaml:"lastRestartAt"`
 }
 
 type ServiceState string
@@ -253,6 +259,14 @@ const (
 	ServiceStateStartingContainer         ServiceState = "starting container"
 	ServiceStateRunning                   ServiceState = "running"
 	ServiceStateExited                    ServiceState = "exited"
+	ServiceStateBackingOff                ServiceState = "backing off"
+	ServiceStateValidationFailed          ServiceState = "validation failed"
+	ServiceStateWaitingForDependencies    ServiceState = "waiting for dependencies"
+	ServiceStateImageNotPresent           ServiceState = "image not present"
+	// A service with a healthcheck is starting until the check first
+	// passes, and unhealthy once it has failed too many times in a row
+	ServiceStateStarting  ServiceState = "starting"
+	ServiceStateUnhealthy ServiceState = "unhealthy"
 )
 
 var AllServiceStates = map[ServiceState]bool{
@@ -264,6 +278,12 @@ var AllServiceStates = map[ServiceState]bool{
 	ServiceStateStartingContainer:         true,
 	ServiceStateRunning:                   true,
 	ServiceStateExited:                    true,
+	ServiceStateBackingOff:                true,
+	ServiceStateValidationFailed:          true,
+	ServiceStateWaitingForDependencies:    true,
+	ServiceStateImageNotPresent:           true,
+	ServiceStateStarting:                  true,
+	ServiceStateUnhealthy:                 true,
 }
 
 type ServiceStateCount struct {
@@ -274,6 +294,38 @@ type ServiceStateCount struct {
 	ApplicationID string       `json:"applicationId" yaml:"applicationId"`
 }
 
+// ServiceStats is the resource usage of a running service container, as
+// reported by its device
+type ServiceStats struct {
+	ApplicationID string `json:"applicationId" yaml:"applicationId"`
+	Service       string `json:"service" yaml:"service"`
+	// CPUSeconds is the total CPU time consumed by the container
+	CPUSeconds       float64 `json:"cpuSeconds" yaml:"cpuSeconds"`
+	MemoryUsageBytes uint64  `json:"memoryUsageBytes" yaml:"memoryUsageBytes"`
+	// MemoryLimitBytes is zero if the container has no memory limit
+	MemoryLimitBytes uint64 `json:"memoryLimitBytes" yaml:"memoryLimitBytes"`
+}
+
+// DevicePing is an agent's reply to a ping through its device connection
+type DevicePing struct {
+	Time time.Time `json:"time" yaml:"time"`
+}
+
+// DeviceConnectivity describes whether a device can be reached through the
+// control plane. ConnectionError is set if the device has no open connection,
+// and PingError if it has one but didn't answer a ping over it.
+type DeviceConnectivity struct {
+	DeviceID        string       `json:"deviceId" yaml:"deviceId"`
+	LastSeenAt      time.Time    `json:"lastSeenAt" yaml:"lastSeenAt"`
+	Status          DeviceStatus `json:"status" yaml:"status"`
+	Connected       bool         `json:"connected" yaml:"connected"`
+	ConnectionError string       `json:"connectionError,omitempty" yaml:"connectionError,omitempty"`
+	// RoundTripMillis is the latency of a ping from the control plane to
+	// the agent and back, if it answered one
+	RoundTripMillis *float64 `json:"roundTripMillis,omitempty" yaml:"roundTripMillis,omitempty"`
+	PingError       string   `json:"pingError,omitempty" yaml:"pingError,omitempty"`
+}
+
 type MembershipFull1 struct {
 	Membership
 	User    User        `json:"user" yaml:"user"`
@@ -338,7 +390,15 @@ type ReleaseFull struct {
 	DeviceCounts            ReleaseDeviceCounts `json:"deviceCounts" yaml:"deviceCounts"`
 }
 
+// BundleSchemaVersion is the newest bundle schema this code understands. It's
+// bumped whenever bundles change in a way older agents would misread.
+const BundleSchemaVersion = 1
+
 type Bundle struct {
+	// SchemaVersion is omitted by controllers that predate it, which send
+	// version 1 bundles
+	SchemaVersion int `json:"schemaVersion,omitempty" yaml:"schemaVersion,omitempty"`
+
 	Applications        []FullBundledApplication  `json:"applications" yaml:"applications"`
 	ApplicationStatuses []DeviceApplicationStatus `json:"applicationStatuses" yaml:"applicationStatuses"`
 	ServiceStatuses     []DeviceServiceStatus     `json:"serviceStatuses" yaml:"serviceStatuses"`
@@ -366,9 +426,51 @@ type FullBundledApplication struct {
 }
 
 type DeviceInfo struct {
-	AgentVersion string    `json:"agentVersion" yaml:"agentVersion"`
-	IPAddress    string    `json:"ipAddress" yaml:"ipAddress"`
-	OSRelease    OSRelease `json:"osRelease" yaml:"osRelease"`
+	AgentVersion      string             `json:"agentVersion" yaml:"agentVersion"`
+	IPAddress         string             `json:"ipAddress" yaml:"ipAddress"`
+	IPAddresses       []string           `json:"ipAddresses,omitempty" yaml:"ipAddresses,omitempty"`
+	OSRelease         OSRelease          `json:"osRelease" yaml:"osRelease"`
+	Kernel            KernelInfo         `json:"kernel" yaml:"kernel"`
+	MemoryTotalBytes  uint64             `json:"memoryTotalBytes,omitempty" yaml:"memoryTotalBytes,omitempty"`
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty" yaml:"networkInterfaces,omitempty"`
+	BundleHookResults []BundleHookResult `json:"bundleHookResults,omitempty" yaml:"bundleHookResults,omitempty"`
+	State             DeviceState        `json:"state,omitempty" yaml:"state,omitempty"`
+	StateMessage      string             `json:"stateMessage,omitempty" yaml:"stateMessage,omitempty"`
+	// SupportedBundleSchemaVersion is the newest bundle schema the agent
+	// can apply
+	SupportedBundleSchemaVersion int `json:"supportedBundleSchemaVersion,omitempty" yaml:"supportedBundleSchemaVersion,omitempty"`
+	// ReportedAt is set by the controller when it receives the info. The
+	// agent reports when its info changes, and at least every ten minutes
+	// otherwise.
+	ReportedAt *time.Time `json:"reportedAt,omitempty" yaml:"reportedAt,omitempty"`
+}
+
+// NetworkInterface is one of a device's network interfaces. Addresses are
+// in CIDR notation.
+type NetworkInterface struct {
+	Name          string   `json:"name" yaml:"name"`
+	MACAddress    string   `json:"macAddress,omitempty" yaml:"macAddress,omitempty"`
+	IPv4Addresses []string `json:"ipv4Addresses,omitempty" yaml:"ipv4Addresses,omitempty"`
+	IPv6Addresses []string `json:"ipv6Addresses,omitempty" yaml:"ipv6Addresses,omitempty"`
+	Up            bool     `json:"up" yaml:"up"`
+}
+
+// DeviceState is reported by the agent when it is running in a degraded
+// mode. It is empty while the agent is healthy.
+type DeviceState string
+
+const (
+	// DeviceStateStorageError means writes to the agent's state directory
+	// keep failing, so bundles are only applied in memory
+	DeviceStateStorageError = DeviceState("storage-error")
+)
+
+type BundleHookResult struct {
+	Name      string    `json:"name" yaml:"name"`
+	Stage     string    `json:"stage" yaml:"stage"`
+	Succeeded bool      `json:"succeeded" yaml:"succeeded"`
+	Output    string    `json:"output" yaml:"output"`
+	RanAt     time.Time `json:"ranAt" yaml:"ranAt"`
 }
 
 type OSRelease struct {
@@ -380,6 +482,12 @@ type OSRelease struct {
 	IDLike     string `json:"idLike" yaml:"idLike"`
 }
 
+type KernelInfo struct {
+	Release      string `json:"release" yaml:"release"`
+	Version      string `json:"version" yaml:"version"`
+	Architecture string `json:"architecture" yaml:"architecture"`
+}
+
 const (
 	DefaultMetricPort uint   = 2112
 	DefaultMetricPath string = "/metrics"
@@ -1,11 +1,19 @@
 package models
 
+import "time"
+
 type CreateReleaseRequest struct {
 	RawConfig string `json:"rawConfig" validate:"config"`
 }
 
 type RegisterDeviceRequest struct {
 	DeviceRegistrationTokenID string `json:"deviceRegistrationTokenId" validate:"id"`
+
+	// HardwareID optionally identifies the device across reinstalls, so
+	// that registering again reclaims the existing device if the
+	// registration token allows it. It's derived from a secret kept on the
+	// device, see the identity package.
+	HardwareID string `json:"hardwareId,omitempty" validate:"max=128"`
 }
 
 type RegisterDeviceResponse struct {
@@ -13,6 +21,17 @@ type RegisterDeviceResponse struct {
 	DeviceAccessKeyValue string `json:"deviceAccessKeyValue"`
 }
 
+// CheckDeviceRegistrationResponse is returned when a registration token
+// would be accepted. ServerTime lets devices check their clock.
+type CheckDeviceRegistrationResponse struct {
+	ServerTime time.Time `json:"serverTime"`
+}
+
+// CheckDeviceAuthResponse identifies the device an access key belongs to
+type CheckDeviceAuthResponse struct {
+	DeviceID string `json:"deviceId"`
+}
+
 type SetDeviceInfoRequest struct {
 	DeviceInfo DeviceInfo `json:"deviceInfo"` // TODO: validate
 }
@@ -25,9 +44,17 @@ type SetDeviceServiceStatusRequest struct {
 	CurrentReleaseID string `json:"currentReleaseId" validate:"id"`
 }
 
+// SetDeviceServiceStatusesRequest sets the statuses of several services of an
+// application at once, keyed by service name
+type SetDeviceServiceStatusesRequest struct {
+	Statuses map[string]SetDeviceServiceStatusRequest `json:"statuses" validate:"dive"`
+}
+
 type SetDeviceServiceStateRequest struct {
-	State        ServiceState `json:"state"`
-	ErrorMessage string       `json:"errorMessage"`
+	State         ServiceState `json:"state"`
+	ErrorMessage  string       `json:"errorMessage"`
+	RestartCount  int          `json:"restartCount,omitempty"`
+	LastRestartAt *time.Time   `json:"lastRestartAt,omitempty"`
 }
 
 type Auth0SsoRequest struct {
@@ -38,3 +65,11 @@ type Auth0SsoRequest struct {
 	State       string `json:"state"`
 	TokenType   string `json:"token_type"`
 }
+
+type ExecRequest struct {
+	Command []string `json:"command"`
+}
+
+type UpdateDeviceRequest struct {
+	Name string `json:"name" validate:"name"`
+}
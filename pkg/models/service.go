@@ -3,42 +3,77 @@ package models
 import "github.com/deviceplane/cli/pkg/yamltypes"
 
 type Service struct {
-	CapAdd         []string                  `yaml:"cap_add,omitempty"`
-	CapDrop        []string                  `yaml:"cap_drop,omitempty"`
-	Command        yamltypes.Command         `yaml:"command,flow,omitempty"`
-	CPUSet         string                    `yaml:"cpuset,omitempty"`
-	CPUShares      yamltypes.StringorInt     `yaml:"cpu_shares,omitempty"`
-	CPUQuota       yamltypes.StringorInt     `yaml:"cpu_quota,omitempty"`
-	Devices        []string                  `yaml:"devices,omitempty"`
-	DNS            yamltypes.Stringorslice   `yaml:"dns,omitempty"`
-	DNSOpts        []string                  `yaml:"dns_opt,omitempty"`
-	DNSSearch      yamltypes.Stringorslice   `yaml:"dns_search,omitempty"`
-	DomainName     string                    `yaml:"domainname,omitempty"`
-	Entrypoint     yamltypes.Command         `yaml:"entrypoint,flow,omitempty"`
-	Environment    yamltypes.MaporEqualSlice `yaml:"environment,omitempty"`
-	ExtraHosts     []string                  `yaml:"extra_hosts,omitempty"`
-	GroupAdd       []string                  `yaml:"group_add,omitempty"`
-	Image          string                    `yaml:"image,omitempty"`
-	Hostname       string                    `yaml:"hostname,omitempty"`
-	Ipc            string                    `yaml:"ipc,omitempty"`
-	Labels         yamltypes.SliceorMap      `yaml:"labels,omitempty"`
-	MemLimit       yamltypes.MemStringorInt  `yaml:"mem_limit,omitempty"`
-	MemReservation yamltypes.MemStringorInt  `yaml:"mem_reservation,omitempty"`
-	MemSwapLimit   yamltypes.MemStringorInt  `yaml:"memswap_limit,omitempty"`
-	NetworkMode    string                    `yaml:"network_mode,omitempty"`
-	OomKillDisable bool                      `yaml:"oom_kill_disable,omitempty"`
-	OomScoreAdj    yamltypes.StringorInt     `yaml:"oom_score_adj,omitempty"`
-	Pid            string                    `yaml:"pid,omitempty"`
-	Ports          []string                  `yaml:"ports,omitempty"`
-	Privileged     bool                      `yaml:"privileged,omitempty"`
-	ReadOnly       bool                      `yaml:"read_only,omitempty"`
-	Restart        string                    `yaml:"restart,omitempty"`
-	Runtime        string                    `yaml:"runtime,omitempty"`
-	SecurityOpt    []string                  `yaml:"security_opt,omitempty"`
-	ShmSize        yamltypes.MemStringorInt  `yaml:"shm_size,omitempty"`
-	StopSignal     string                    `yaml:"stop_signal,omitempty"`
-	User           string                    `yaml:"user,omitempty"`
-	Uts            string                    `yaml:"uts,omitempty"`
-	Volumes        *yamltypes.Volumes        `yaml:"volumes,omitempty"`
-	WorkingDir     string                    `yaml:"working_dir,omitempty"`
+	CapAdd             []string                  `yaml:"cap_add,omitempty"`
+	CapDrop            []string                  `yaml:"cap_drop,omitempty"`
+	Command            yamltypes.Command         `yaml:"command,flow,omitempty"`
+	CPUSet             string                    `yaml:"cpuset,omitempty"`
+	CPUShares          yamltypes.StringorInt     `yaml:"cpu_shares,omitempty"`
+	CPUQuota           yamltypes.StringorInt     `yaml:"cpu_quota,omitempty"`
+	DependsOn          []string                  `yaml:"depends_on,omitempty"`
+	Devices            []string                  `yaml:"devices,omitempty"`
+	DNS                yamltypes.Stringorslice   `yaml:"dns,omitempty"`
+	DNSOpts            []string                  `yaml:"dns_opt,omitempty"`
+	DNSSearch          yamltypes.Stringorslice   `yaml:"dns_search,omitempty"`
+	DomainName         string                    `yaml:"domainname,omitempty"`
+	Entrypoint         yamltypes.Command         `yaml:"entrypoint,flow,omitempty"`
+	Environment        yamltypes.MaporEqualSlice `yaml:"environment,omitempty"`
+	ExtraHosts         yamltypes.MaporColonSlice `yaml:"extra_hosts,omitempty"`
+	GroupAdd           []string                  `yaml:"group_add,omitempty"`
+	Healthcheck        *Healthcheck              `yaml:"healthcheck,omitempty"`
+	Image              string                    `yaml:"image,omitempty"`
+	Hostname           string                    `yaml:"hostname,omitempty"`
+	Ipc                string                    `yaml:"ipc,omitempty"`
+	Labels             yamltypes.SliceorMap      `yaml:"labels,omitempty"`
+	MemLimit           yamltypes.MemStringorInt  `yaml:"mem_limit,omitempty"`
+	MemReservation     yamltypes.MemStringorInt  `yaml:"mem_reservation,omitempty"`
+	MemSwapLimit       yamltypes.MemStringorInt  `yaml:"memswap_limit,omitempty"`
+	NetworkMode        string                    `yaml:"network_mode,omitempty"`
+	OomKillDisable     bool                      `yaml:"oom_kill_disable,omitempty"`
+	OomScoreAdj        yamltypes.StringorInt     `yaml:"oom_score_adj,omitempty"`
+	Pid                string                    `yaml:"pid,omitempty"`
+	Ports              []string                  `yaml:"ports,omitempty"`
+	PullPolicy         PullPolicy                `yaml:"pull_policy,omitempty"`
+	Privileged         bool                      `yaml:"privileged,omitempty"`
+	ReadOnly           bool                      `yaml:"read_only,omitempty"`
+	Restart            string                    `yaml:"restart,omitempty"`
+	Runtime            string                    `yaml:"runtime,omitempty"`
+	SecurityOpt        []string                  `yaml:"security_opt,omitempty"`
+	ShmSize            yamltypes.MemStringorInt  `yaml:"shm_size,omitempty"`
+	StartupGracePeriod string                    `yaml:"startup_grace_period,omitempty"`
+	StopSignal         string                    `yaml:"stop_signal,omitempty"`
+	User               string                    `yaml:"user,omitempty"`
+	Uts                string                    `yaml:"uts,omitempty"`
+	Volumes            *yamltypes.Volumes        `yaml:"volumes,omitempty"`
+	WorkingDir         string                    `yaml:"working_dir,omitempty"`
+}
+
+// PullPolicy controls when the agent pulls a service's image. An empty
+// policy behaves like PullPolicyAlways.
+type PullPolicy string
+
+const (
+	PullPolicyAlways       = PullPolicy("Always")
+	PullPolicyIfNotPresent = PullPolicy("IfNotPresent")
+	PullPolicyNever        = PullPolicy("Never")
+)
+
+// Healthcheck decides whether a running container is ready. Exactly one of
+// Test and HTTP is set.
+type Healthcheck struct {
+	// Test is run inside the container and passes if it exits with 0. As in
+	// compose files it may be prefixed with CMD or CMD-SHELL.
+	Test yamltypes.Command `yaml:"test,flow,omitempty"`
+	// HTTP is requested inside the container's network namespace and passes
+	// on a 2xx or 3xx response
+	HTTP     *HTTPHealthcheck `yaml:"http,omitempty"`
+	Interval string           `yaml:"interval,omitempty"`
+	Timeout  string           `yaml:"timeout,omitempty"`
+	// Retries is the number of consecutive failures after which the
+	// service is reported as unhealthy
+	Retries int `yaml:"retries,omitempty"`
+}
+
+type HTTPHealthcheck struct {
+	Port int    `yaml:"port"`
+	Path string `yaml:"path,omitempty"`
 }
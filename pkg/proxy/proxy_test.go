@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURL(t *testing.T) {
+	proxyURL, err := ParseURL("user:pass@proxy.example.com:3128")
+	require.NoError(t, err)
+	require.Equal(t, "http", proxyURL.Scheme)
+	require.Equal(t, "proxy.example.com:3128", proxyURL.Host)
+	require.Equal(t, "user", proxyURL.User.Username())
+
+	proxyURL, err = ParseURL("socks5://proxy.example.com:1080")
+	require.NoError(t, err)
+	require.Equal(t, "socks5", proxyURL.Scheme)
+
+	_, err = ParseURL("https://proxy.example.com:3128")
+	require.Error(t, err)
+	_, err = ParseURL("http://")
+	require.Error(t, err)
+}
+
+func TestFunc(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://api.example.com/api", nil)
+	require.NoError(t, err)
+
+	proxyURL, err := ParseURL("socks5://proxy.example.com:1080")
+	require.NoError(t, err)
+	got, err := Func(proxyURL)(req)
+	require.NoError(t, err)
+	require.Equal(t, proxyURL, got)
+}
@@ -25,31 +25,42 @@ func fullService() models.Service {
 		Environment: yamltypes.MaporEqualSlice([]string{"x", "y", "z"}),
 		ExtraHosts:  []string{"x", "y", "z"},
 		GroupAdd:    []string{"x", "y", "z"},
-		Image:       "x",
-		Hostname:    "x",
-		Ipc:         "x",
+		Healthcheck: &models.Healthcheck{
+			HTTP: &models.HTTPHealthcheck{
+				Port: 8080,
+				Path: "/healthz",
+			},
+			Interval: "5s",
+			Timeout:  "1s",
+			Retries:  2,
+		},
+		Image:    "x",
+		Hostname: "x",
+		Ipc:      "x",
 		Labels: yamltypes.SliceorMap(map[string]string{
 			"k1": "v1",
 			"k2": "v2",
 			"k3": "v3",
 		}),
-		MemLimit:       yamltypes.MemStringorInt(1),
-		MemReservation: yamltypes.MemStringorInt(1),
-		MemSwapLimit:   yamltypes.MemStringorInt(1),
-		NetworkMode:    "x",
-		OomKillDisable: true,
-		OomScoreAdj:    yamltypes.StringorInt(1),
-		Pid:            "x",
-		Ports:          []string{"x", "y", "z"},
-		Privileged:     true,
-		ReadOnly:       true,
-		Restart:        "always",
-		Runtime:        "nvidia",
-		SecurityOpt:    []string{"x", "y", "z"},
-		ShmSize:        yamltypes.MemStringorInt(1),
-		StopSignal:     "x",
-		User:           "x",
-		Uts:            "x",
+		MemLimit:           yamltypes.MemStringorInt(1),
+		MemReservation:     yamltypes.MemStringorInt(1),
+		MemSwapLimit:       yamltypes.MemStringorInt(1),
+		NetworkMode:        "x",
+		OomKillDisable:     true,
+		OomScoreAdj:        yamltypes.StringorInt(1),
+		Pid:                "x",
+		Ports:              []string{"x", "y", "z"},
+		PullPolicy:         models.PullPolicyIfNotPresent,
+		Privileged:         true,
+		ReadOnly:           true,
+		Restart:            "always",
+		Runtime:            "nvidia",
+		SecurityOpt:        []string{"x", "y", "z"},
+		ShmSize:            yamltypes.MemStringorInt(1),
+		StartupGracePeriod: "1m",
+		StopSignal:         "x",
+		User:               "x",
+		Uts:                "x",
 		Volumes: &yamltypes.Volumes{
 			Volumes: []*yamltypes.Volume{
 				{
@@ -3,50 +3,55 @@ package spec
 import (
 	"fmt"
 
+	"github.com/deviceplane/cli/pkg/models"
 	"github.com/deviceplane/cli/pkg/validation"
 	"gopkg.in/yaml.v2"
 )
 
 var (
 	validators = map[string][]func(interface{}) error{
-		"cap_add":          []func(interface{}) error{validation.ValidateStringArray},
-		"cap_drop":         []func(interface{}) error{validation.ValidateStringArray},
-		"command":          []func(interface{}) error{validation.ValidateStringOrStringArray},
-		"cpuset":           []func(interface{}) error{validation.ValidateString},
-		"cpu_shares":       []func(interface{}) error{validation.ValidateStringOrInteger},
-		"cpu_quota":        []func(interface{}) error{validation.ValidateStringOrInteger},
-		"devices":          []func(interface{}) error{validation.ValidateStringArray},
-		"dns":              []func(interface{}) error{validation.ValidateStringOrStringArray},
-		"dns_opt":          []func(interface{}) error{validation.ValidateStringOrStringArray},
-		"dns_search":       []func(interface{}) error{validation.ValidateStringOrStringArray},
-		"domainname":       []func(interface{}) error{validation.ValidateString},
-		"entrypoint":       []func(interface{}) error{validation.ValidateStringOrStringArray},
-		"environment":      []func(interface{}) error{validation.ValidateArrayOrObject},
-		"extra_hosts":      []func(interface{}) error{validation.ValidateArrayOrObject},
-		"group_add":        []func(interface{}) error{validation.ValidateStringIntegerArray},
-		"image":            []func(interface{}) error{validation.ValidateString},
-		"hostname":         []func(interface{}) error{validation.ValidateString},
-		"ipc":              []func(interface{}) error{validation.ValidateString},
-		"labels":           []func(interface{}) error{validation.ValidateArrayOrObject},
-		"mem_limit":        []func(interface{}) error{validation.ValidateStringOrInteger},
-		"mem_reservation":  []func(interface{}) error{validation.ValidateStringOrInteger},
-		"memswap_limit":    []func(interface{}) error{validation.ValidateStringOrInteger},
-		"network_mode":     []func(interface{}) error{validation.ValidateString},
-		"oom_kill_disable": []func(interface{}) error{validation.ValidateBoolean},
-		"oom_score_adj":    []func(interface{}) error{validation.ValidateInteger},
-		"pid":              []func(interface{}) error{validation.ValidateString},
-		"ports":            []func(interface{}) error{validation.ValidateStringIntegerArray},
-		"privileged":       []func(interface{}) error{validation.ValidateBoolean},
-		"read_only":        []func(interface{}) error{validation.ValidateBoolean},
-		"restart":          []func(interface{}) error{validation.ValidateString},
-		"runtime":          []func(interface{}) error{validation.ValidateString},
-		"security_opt":     []func(interface{}) error{validation.ValidateStringArray},
-		"shm_size":         []func(interface{}) error{validation.ValidateStringOrInteger},
-		"stop_signal":      []func(interface{}) error{validation.ValidateString},
-		"user":             []func(interface{}) error{validation.ValidateString},
-		"uts":              []func(interface{}) error{validation.ValidateString},
-		"volumes":          []func(interface{}) error{validation.ValidateStringArray},
-		"working_dir":      []func(interface{}) error{validation.ValidateString},
+		"cap_add":              []func(interface{}) error{validation.ValidateStringArray},
+		"cap_drop":             []func(interface{}) error{validation.ValidateStringArray},
+		"command":              []func(interface{}) error{validation.ValidateStringOrStringArray},
+		"cpuset":               []func(interface{}) error{validation.ValidateString},
+		"cpu_shares":           []func(interface{}) error{validation.ValidateStringOrInteger},
+		"cpu_quota":            []func(interface{}) error{validation.ValidateStringOrInteger},
+		"depends_on":           []func(interface{}) error{validation.ValidateStringArray},
+		"devices":              []func(interface{}) error{validation.ValidateStringArray},
+		"dns":                  []func(interface{}) error{validation.ValidateStringOrStringArray},
+		"dns_opt":              []func(interface{}) error{validation.ValidateStringOrStringArray},
+		"dns_search":           []func(interface{}) error{validation.ValidateStringOrStringArray},
+		"domainname":           []func(interface{}) error{validation.ValidateString},
+		"entrypoint":           []func(interface{}) error{validation.ValidateStringOrStringArray},
+		"environment":          []func(interface{}) error{validation.ValidateArrayOrObject},
+		"extra_hosts":          []func(interface{}) error{validation.ValidateArrayOrObject},
+		"group_add":            []func(interface{}) error{validation.ValidateStringIntegerArray},
+		"image":                []func(interface{}) error{validation.ValidateString},
+		"healthcheck":          []func(interface{}) error{validateHealthcheck},
+		"hostname":             []func(interface{}) error{validation.ValidateString},
+		"ipc":                  []func(interface{}) error{validation.ValidateString},
+		"labels":               []func(interface{}) error{validation.ValidateArrayOrObject},
+		"mem_limit":            []func(interface{}) error{validation.ValidateStringOrInteger},
+		"mem_reservation":      []func(interface{}) error{validation.ValidateStringOrInteger},
+		"memswap_limit":        []func(interface{}) error{validation.ValidateStringOrInteger},
+		"network_mode":         []func(interface{}) error{validation.ValidateString},
+		"oom_kill_disable":     []func(interface{}) error{validation.ValidateBoolean},
+		"oom_score_adj":        []func(interface{}) error{validation.ValidateInteger},
+		"pid":                  []func(interface{}) error{validation.ValidateString},
+		"ports":                []func(interface{}) error{validation.ValidateStringIntegerArray},
+		"pull_policy":          []func(interface{}) error{validation.ValidateString, validatePullPolicy},
+		"privileged":           []func(interface{}) error{validation.ValidateBoolean},
+		"read_only":            []func(interface{}) error{validation.ValidateBoolean},
+		"restart":              []func(interface{}) error{validation.ValidateString},
+		"runtime":              []func(interface{}) error{validation.ValidateString},
+		"security_opt":         []func(interface{}) error{validation.ValidateStringArray},
+		"shm_size":             []func(interface{}) error{validation.ValidateStringOrInteger},
+		"startup_grace_period": []func(interface{}) error{validation.ValidateString, validation.ValidateDuration},
+		"stop_signal":          []func(interface{}) error{validation.ValidateString},
+		"user":                 []func(interface{}) error{validation.ValidateString},
+		"uts":                  []func(interface{}) error{validation.ValidateString},
+		"volumes":              []func(interface{}) error{validation.ValidateStringArray},
+		"working_dir":          []func(interface{}) error{validation.ValidateString},
 	}
 )
 
@@ -93,3 +98,74 @@ func Validate(c []byte) error {
 
 	return nil
 }
+
+func validatePullPolicy(elem interface{}) error {
+	switch models.PullPolicy(elem.(string)) {
+	case models.PullPolicyAlways, models.PullPolicyIfNotPresent, models.PullPolicyNever:
+		return nil
+	default:
+		return fmt.Errorf("expected one of %s, %s or %s",
+			models.PullPolicyAlways, models.PullPolicyIfNotPresent, models.PullPolicyNever)
+	}
+}
+
+var healthcheckValidators = map[string][]func(interface{}) error{
+	"test":     []func(interface{}) error{validation.ValidateStringOrStringArray},
+	"http":     []func(interface{}) error{validateHTTPHealthcheck},
+	"interval": []func(interface{}) error{validation.ValidateString, validation.ValidateDuration},
+	"timeout":  []func(interface{}) error{validation.ValidateString, validation.ValidateDuration},
+	"retries":  []func(interface{}) error{validation.ValidateInteger},
+}
+
+func validateHealthcheck(elem interface{}) error {
+	healthcheck, ok := elem.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("expected an object")
+	}
+
+	for key, value := range healthcheck {
+		typedKey, _ := key.(string)
+		validators, ok := healthcheckValidators[typedKey]
+		if !ok {
+			return fmt.Errorf("invalid key '%v'", key)
+		}
+		for _, validator := range validators {
+			if err := validator(value); err != nil {
+				return fmt.Errorf("%s: %v", typedKey, err)
+			}
+		}
+	}
+
+	_, hasTest := healthcheck["test"]
+	_, hasHTTP := healthcheck["http"]
+	if hasTest == hasHTTP {
+		return fmt.Errorf("expected exactly one of test or http")
+	}
+
+	return nil
+}
+
+func validateHTTPHealthcheck(elem interface{}) error {
+	http, ok := elem.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("expected an object")
+	}
+
+	for key := range http {
+		if key != "port" && key != "path" {
+			return fmt.Errorf("invalid key '%v'", key)
+		}
+	}
+
+	port, ok := http["port"].(int)
+	if !ok || port <= 0 || port > 65535 {
+		return fmt.Errorf("expected a port between 1 and 65535")
+	}
+	if path, ok := http["path"]; ok {
+		if err := validation.ValidateString(path); err != nil {
+			return fmt.Errorf("path: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/deviceplane/cli/pkg/models"
+	"github.com/deviceplane/cli/pkg/yamltypes"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
@@ -15,4 +16,38 @@ func TestValidate(t *testing.T) {
 		})
 		require.NoError(t, Validate(full))
 	})
+
+	t.Run("invalid startup grace period", func(t *testing.T) {
+		s := fullService()
+		s.StartupGracePeriod = "soon"
+		invalid, _ := yaml.Marshal(map[string]models.Service{
+			"s": s,
+		})
+		require.Error(t, Validate(invalid))
+	})
+	t.Run("invalid pull policy", func(t *testing.T) {
+		s := fullService()
+		s.PullPolicy = "Sometimes"
+		invalid, _ := yaml.Marshal(map[string]models.Service{
+			"s": s,
+		})
+		require.Error(t, Validate(invalid))
+	})
+	t.Run("healthcheck with test and http", func(t *testing.T) {
+		s := fullService()
+		s.Healthcheck.Test = yamltypes.Command{"true"}
+		invalid, _ := yaml.Marshal(map[string]models.Service{
+			"s": s,
+		})
+		require.Error(t, Validate(invalid))
+	})
+
+	t.Run("healthcheck without a port", func(t *testing.T) {
+		s := fullService()
+		s.Healthcheck.HTTP.Port = 0
+		invalid, _ := yaml.Marshal(map[string]models.Service{
+			"s": s,
+		})
+		require.Error(t, Validate(invalid))
+	})
 }
@@ -0,0 +1,47 @@
+// Package tlsconfig builds the TLS settings used to connect to the API
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// New returns a TLS config that trusts the CAs in caCertFile in addition to
+// the system's, and presents the certificate in clientCertFile and
+// clientKeyFile for mutual TLS. Empty file names leave the corresponding
+// setting at its default. The files are read immediately so that problems
+// with them are reported before any connection is made.
+func New(caCertFile, clientCertFile, clientKeyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if caCertFile != "" {
+		pem, err := ioutil.ReadFile(caCertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA certificate")
+		}
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in %s", caCertFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if (clientCertFile == "") != (clientKeyFile == "") {
+		return nil, errors.New("a client certificate and key must be given together")
+	}
+	if clientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
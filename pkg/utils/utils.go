@@ -151,6 +151,37 @@ func ProxyResponseFromDevice(w http.ResponseWriter, resp *http.Response) {
 	resp.Body.Close()
 }
 
+// ProxyStreamingResponseFromDevice is like ProxyResponseFromDevice but
+// flushes after every write so long-lived streams reach the client promptly
+func ProxyStreamingResponseFromDevice(w http.ResponseWriter, resp *http.Response) {
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.Header().Set(ProxiedFromDeviceHeader, "")
+
+	w.WriteHeader(resp.StatusCode)
+	defer resp.Body.Close()
+
+	flusher, ok := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if ok {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 func ProxyResponse(w http.ResponseWriter, resp *http.Response) {
 	for key, values := range resp.Header {
 		for _, value := range values {
@@ -1,6 +1,9 @@
 package validation
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 func ValidateString(elem interface{}) error {
 	switch elem.(type) {
@@ -38,6 +41,18 @@ func ValidateStringOrInteger(elem interface{}) error {
 	}
 }
 
+func ValidateDuration(elem interface{}) error {
+	switch v := elem.(type) {
+	case string:
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("expected a duration such as \"30s\" or \"2m\"")
+		}
+		return nil
+	default:
+		return fmt.Errorf("expected type string")
+	}
+}
+
 func ValidateStringArray(elem interface{}) error {
 	switch typedElem := elem.(type) {
 	case []interface{}:
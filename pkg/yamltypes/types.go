@@ -3,6 +3,7 @@ package yamltypes
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -228,6 +229,8 @@ func toSepMapParts(value map[interface{}]interface{}, sep string) ([]string, err
 			return nil, fmt.Errorf("Cannot unmarshal '%v' of type %T into a string value", k, k)
 		}
 	}
+	// Map order is random, and services are hashed from these parts
+	sort.Strings(parts)
 	return parts, nil
 }
 
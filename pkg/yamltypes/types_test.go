@@ -136,6 +136,15 @@ func TestMaporsliceYaml(t *testing.T) {
 	assert.True(t, contains(s2.Foo, "qux"))
 }
 
+func TestMaporColonSliceYaml(t *testing.T) {
+	var s struct {
+		Hosts MaporColonSlice
+	}
+	assert.Nil(t, yaml.Unmarshal([]byte(`{hosts: {db: 10.0.0.2, cache: "fd00::2", api: 10.0.0.3}}`), &s))
+
+	assert.Equal(t, MaporColonSlice{"api:10.0.0.3", "cache:fd00::2", "db:10.0.0.2"}, s.Hosts)
+}
+
 func TestMapWithEmptyValue(t *testing.T) {
 	str := `foo:
   bar: baz